@@ -225,6 +225,7 @@ type GetCurrentUserResponse struct {
 	EmailVerified    bool     `json:"email_verified"`
 	CurrentLatitude  *float64 `json:"current_latitude"`
 	CurrentLongitude *float64 `json:"current_longitude"`
+	DateOfBirth      *string  `json:"date_of_birth"`
 }
 
 func (a *api) getCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -243,6 +244,7 @@ func (a *api) getCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
 		EmailVerified:    account.EmailVerified,
 		CurrentLatitude:  account.CurrentLatitude,
 		CurrentLongitude: account.CurrentLongitude,
+		DateOfBirth:      dateOfBirthResponse(account.DateOfBirth),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -250,6 +252,14 @@ func (a *api) getCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+func dateOfBirthResponse(dateOfBirth *time.Time) *string {
+	if dateOfBirth == nil {
+		return nil
+	}
+	formatted := dateOfBirth.Format(time.DateOnly)
+	return &formatted
+}
+
 type VerifyEmailRequest struct {
 	Email string `json:"email" validate:"required,email,monash_email"`
 	Token string `json:"token" validate:"required"`
@@ -468,6 +478,8 @@ type UpdateUserRequest struct {
 	LastName         string   `json:"last_name"`
 	CurrentLatitude  *float64 `json:"current_latitude"`
 	CurrentLongitude *float64 `json:"current_longitude"`
+	// Lets P1 drivers know whether the passenger is of peer age
+	DateOfBirth *string `json:"date_of_birth" validate:"omitempty,datetime=2006-01-02"`
 }
 
 type UpdateUserResponse struct {
@@ -479,6 +491,7 @@ type UpdateUserResponse struct {
 	EmailVerified    bool     `json:"email_verified"`
 	CurrentLatitude  *float64 `json:"current_latitude"`
 	CurrentLongitude *float64 `json:"current_longitude"`
+	DateOfBirth      *string  `json:"date_of_birth"`
 	Message          string   `json:"message"`
 }
 
@@ -513,6 +526,7 @@ func (a *api) updateUserHandler(w http.ResponseWriter, r *http.Request) {
 		EmailVerified:    account.EmailVerified,
 		CurrentLatitude:  account.CurrentLatitude,
 		CurrentLongitude: account.CurrentLongitude,
+		DateOfBirth:      account.DateOfBirth,
 	}
 
 	// Update only the fields that are provided
@@ -552,9 +566,21 @@ func (a *api) updateUserHandler(w http.ResponseWriter, r *http.Request) {
 	if req.CurrentLongitude != nil {
 		updatedAccount.CurrentLongitude = req.CurrentLongitude
 	}
+	if req.DateOfBirth != nil {
+		dateOfBirth, err := time.Parse(time.DateOnly, *req.DateOfBirth)
+		if err != nil {
+			a.errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if !dateOfBirth.Before(time.Now()) {
+			a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("date_of_birth must be in the past"))
+			return
+		}
+		updatedAccount.DateOfBirth = &dateOfBirth
+	}
 
 	if req.Type == "" && req.Email == "" && req.FirstName == "" && req.MiddleName == "" && req.LastName == "" &&
-		req.CurrentLatitude == nil && req.CurrentLongitude == nil {
+		req.CurrentLatitude == nil && req.CurrentLongitude == nil && req.DateOfBirth == nil {
 		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("no fields to update"))
 		return
 	}
@@ -574,6 +600,7 @@ func (a *api) updateUserHandler(w http.ResponseWriter, r *http.Request) {
 		EmailVerified:    acc.EmailVerified,
 		CurrentLatitude:  acc.CurrentLatitude,
 		CurrentLongitude: acc.CurrentLongitude,
+		DateOfBirth:      dateOfBirthResponse(acc.DateOfBirth),
 	}
 
 	if emailChanged {
@@ -679,8 +706,10 @@ func (a *api) getSpecificUserHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 type AddFavouriteAddressRequest struct {
-	AddressName string `json:"address_name" validate:"required"`
-	AddressLine string `json:"address_line" validate:"required"`
+	AddressName string   `json:"address_name" validate:"required"`
+	AddressLine string   `json:"address_line" validate:"required"`
+	Latitude    *float64 `json:"latitude,omitempty" validate:"required_with=Longitude,omitempty,latitude"`
+	Longitude   *float64 `json:"longitude,omitempty" validate:"required_with=Latitude,omitempty,longitude"`
 }
 
 func (a *api) addFavouriteAddressHandler(w http.ResponseWriter, r *http.Request) {
@@ -697,8 +726,12 @@ func (a *api) addFavouriteAddressHandler(w http.ResponseWriter, r *http.Request)
 		a.errorResponse(w, r, http.StatusBadRequest, err)
 		return
 	}
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
 
-	err = a.accountsRepo.AddFavouriteAddress(ctx, account.ID, req.AddressName, req.AddressLine)
+	err = a.accountsRepo.AddFavouriteAddress(ctx, account.ID, req.AddressName, req.AddressLine, req.Latitude, req.Longitude)
 	if err != nil {
 		a.errorResponse(w, r, http.StatusInternalServerError, err)
 		return
@@ -708,9 +741,11 @@ func (a *api) addFavouriteAddressHandler(w http.ResponseWriter, r *http.Request)
 }
 
 type Address struct {
-	ID          int64  `json:"id"`
-	AddressName string `json:"address_name"`
-	AddressLine string `json:"address_line"`
+	ID          int64    `json:"id"`
+	AddressName string   `json:"address_name"`
+	AddressLine string   `json:"address_line"`
+	Latitude    *float64 `json:"latitude,omitempty"`
+	Longitude   *float64 `json:"longitude,omitempty"`
 }
 
 type GetFavouriteAddressesResponse struct {
@@ -739,6 +774,8 @@ func (a *api) getFavouriteAddressesHandler(w http.ResponseWriter, r *http.Reques
 			ID:          addr.ID,
 			AddressName: addr.AddressName,
 			AddressLine: addr.AddressLine,
+			Latitude:    addr.Latitude,
+			Longitude:   addr.Longitude,
 		}
 	}
 
@@ -820,15 +857,26 @@ type CreateVehicleRequest struct {
 	ModelYear    int    `json:"model_year" validate:"required,min=1886,max=2100"`
 	Colour       string `json:"colour" validate:"required"`
 	LicensePlate string `json:"license_plate" validate:"required"`
+	// Passenger seats, not counting the driver's; four if left out
+	Seats int `json:"seats,omitempty" validate:"omitempty,min=1,max=8"`
+	// Used to suggest a fair contribution. Consumption is litres, or kWh for electric, per 100 km.
+	FuelType        *string  `json:"fuel_type" validate:"required_with=FuelConsumption,omitempty,oneof=petrol premium_petrol diesel lpg electric"`
+	FuelConsumption *float64 `json:"fuel_consumption" validate:"required_with=FuelType,omitempty,gt=0,max=100"`
+	// Ride features the vehicle has, matched against passengers' requirements and preferences
+	Features domain.RideFeatures `json:"features,omitempty" validate:"omitempty,dive,oneof=luggage wheelchair_accessible child_seat"`
 }
 
 type CreateVehicleResponse struct {
-	ID           int64  `json:"id"`
-	Make         string `json:"make"`
-	Model        string `json:"model"`
-	ModelYear    int    `json:"model_year"`
-	Colour       string `json:"colour"`
-	LicensePlate string `json:"license_plate"`
+	ID              int64               `json:"id"`
+	Make            string              `json:"make"`
+	Model           string              `json:"model"`
+	ModelYear       int                 `json:"model_year"`
+	Colour          string              `json:"colour"`
+	LicensePlate    string              `json:"license_plate"`
+	Seats           int                 `json:"seats"`
+	FuelType        *domain.FuelType    `json:"fuel_type"`
+	FuelConsumption *float64            `json:"fuel_consumption"`
+	Features        domain.RideFeatures `json:"features"`
 }
 
 func (a *api) createVehicleHandler(w http.ResponseWriter, r *http.Request) {
@@ -859,6 +907,16 @@ func (a *api) createVehicleHandler(w http.ResponseWriter, r *http.Request) {
 		Colour:       req.Colour,
 		LicensePlate: req.LicensePlate,
 		AccountID:    account.ID,
+		Features:     req.Features,
+		Seats:        domain.DefaultVehicleSeats,
+	}
+	if req.Seats > 0 {
+		vehicle.Seats = req.Seats
+	}
+	if req.FuelType != nil {
+		fuelType := domain.FuelType(*req.FuelType)
+		vehicle.FuelType = &fuelType
+		vehicle.FuelConsumption = req.FuelConsumption
 	}
 
 	createdVehicle, err := a.accountsRepo.CreateVehicle(ctx, vehicle)
@@ -868,12 +926,16 @@ func (a *api) createVehicleHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := CreateVehicleResponse{
-		ID:           createdVehicle.ID,
-		Make:         createdVehicle.Make,
-		Model:        createdVehicle.Model,
-		ModelYear:    createdVehicle.ModelYear,
-		Colour:       createdVehicle.Colour,
-		LicensePlate: createdVehicle.LicensePlate,
+		ID:              createdVehicle.ID,
+		Make:            createdVehicle.Make,
+		Model:           createdVehicle.Model,
+		ModelYear:       createdVehicle.ModelYear,
+		Colour:          createdVehicle.Colour,
+		LicensePlate:    createdVehicle.LicensePlate,
+		Seats:           createdVehicle.Seats,
+		FuelType:        createdVehicle.FuelType,
+		FuelConsumption: createdVehicle.FuelConsumption,
+		Features:        createdVehicle.Features,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -886,12 +948,16 @@ type GetVehicleRequest struct {
 }
 
 type GetVehicleResponse struct {
-	ID           int64  `json:"id"`
-	Make         string `json:"make"`
-	Model        string `json:"model"`
-	ModelYear    int    `json:"model_year"`
-	Colour       string `json:"colour"`
-	LicensePlate string `json:"license_plate"`
+	ID              int64               `json:"id"`
+	Make            string              `json:"make"`
+	Model           string              `json:"model"`
+	ModelYear       int                 `json:"model_year"`
+	Colour          string              `json:"colour"`
+	LicensePlate    string              `json:"license_plate"`
+	Seats           int                 `json:"seats"`
+	FuelType        *domain.FuelType    `json:"fuel_type"`
+	FuelConsumption *float64            `json:"fuel_consumption"`
+	Features        domain.RideFeatures `json:"features"`
 }
 
 func (a *api) getVehicleHandler(w http.ResponseWriter, r *http.Request) {
@@ -915,12 +981,172 @@ func (a *api) getVehicleHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := GetVehicleResponse{
-		ID:           vehicle.ID,
-		Make:         vehicle.Make,
-		Model:        vehicle.Model,
-		ModelYear:    vehicle.ModelYear,
-		Colour:       vehicle.Colour,
-		LicensePlate: vehicle.LicensePlate,
+		ID:              vehicle.ID,
+		Make:            vehicle.Make,
+		Model:           vehicle.Model,
+		ModelYear:       vehicle.ModelYear,
+		Colour:          vehicle.Colour,
+		LicensePlate:    vehicle.LicensePlate,
+		Seats:           vehicle.Seats,
+		FuelType:        vehicle.FuelType,
+		FuelConsumption: vehicle.FuelConsumption,
+		Features:        vehicle.Features,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+type UpdateVehicleFeaturesRequest struct {
+	Features domain.RideFeatures `json:"features" validate:"dive,oneof=luggage wheelchair_accessible child_seat"`
+}
+
+// updateVehicleFeaturesHandler replaces the ride features of the driver's vehicle
+func (a *api) updateVehicleFeaturesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+
+	var req UpdateVehicleFeaturesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	vehicle, err := a.accountsRepo.UpdateVehicleFeatures(ctx, account.ID, req.Features)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if vehicle == nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("vehicle not found for user"))
+		return
+	}
+
+	response := GetVehicleResponse{
+		ID:              vehicle.ID,
+		Make:            vehicle.Make,
+		Model:           vehicle.Model,
+		ModelYear:       vehicle.ModelYear,
+		Colour:          vehicle.Colour,
+		LicensePlate:    vehicle.LicensePlate,
+		Seats:           vehicle.Seats,
+		FuelType:        vehicle.FuelType,
+		FuelConsumption: vehicle.FuelConsumption,
+		Features:        vehicle.Features,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+type DriverPreferencesRequest struct {
+	Features domain.RideFeatures `json:"features" validate:"dive,oneof=quiet_ride no_smoking pets_allowed"`
+	// Left as it is when not given
+	AvoidTolls *bool `json:"avoid_tolls,omitempty"`
+}
+
+type DriverPreferencesResponse struct {
+	// What the driver offers themselves
+	Features domain.RideFeatures `json:"features"`
+	// Those plus the vehicle's features; requests requiring anything else are hidden
+	Offered domain.RideFeatures `json:"offered"`
+	// Whether the driver's routes keep off toll roads where there is a sensible way around
+	AvoidTolls bool `json:"avoid_tolls"`
+}
+
+func (a *api) getDriverPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+	if account.Type != "driver" {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("only drivers have ride preferences"))
+		return
+	}
+
+	features, err := a.accountsRepo.GetDriverPreferences(ctx, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	a.driverPreferencesResponse(ctx, w, r, account.ID, features)
+}
+
+func (a *api) updateDriverPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var req DriverPreferencesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+	if account.Type != "driver" {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("only drivers have ride preferences"))
+		return
+	}
+
+	features, err := a.accountsRepo.SetDriverPreferences(ctx, account.ID, req.Features)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if req.AvoidTolls != nil {
+		_, err = a.accountsRepo.SetDriverRouteOptions(ctx, account.ID, domain.RouteOptions{AvoidTolls: *req.AvoidTolls})
+		if err != nil {
+			a.errorResponse(w, r, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	a.driverPreferencesResponse(ctx, w, r, account.ID, features)
+}
+
+func (a *api) driverPreferencesResponse(ctx context.Context, w http.ResponseWriter, r *http.Request, driverID int64, features domain.RideFeatures) {
+	vehicle, err := a.accountsRepo.GetVehicleByAccountID(ctx, driverID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	routeOptions, err := a.accountsRepo.GetDriverRouteOptions(ctx, driverID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := DriverPreferencesResponse{
+		Features:   features,
+		Offered:    domain.OfferedFeatures(vehicle, features),
+		AvoidTolls: routeOptions.AvoidTolls,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
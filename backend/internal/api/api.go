@@ -2,12 +2,14 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/Arjun113/nOPark/internal/domain"
 	"github.com/Arjun113/nOPark/internal/repository"
+	"github.com/Arjun113/nOPark/internal/services"
 	"github.com/Arjun113/nOPark/internal/services/email"
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
@@ -21,12 +23,22 @@ type api struct {
 	emailService *email.Service
 	validator    *validator.Validate
 
-	accountsRepo      domain.AccountsRepository
-	mapsRepo          domain.MapsRepository
-	ridesRepo         domain.RidesRepository
-	ratelimitRepo     domain.RatelimitRepository
-	notificationsRepo domain.NotificationsRepository
-	reviewsRepo       domain.ReviewsRepository
+	accountsRepo           domain.AccountsRepository
+	mapsRepo               domain.MapsRepository
+	ridesRepo              domain.RidesRepository
+	ratelimitRepo          domain.RatelimitRepository
+	notificationsRepo      domain.NotificationsRepository
+	reviewsRepo            domain.ReviewsRepository
+	commutesRepo           domain.CommutesRepository
+	offersRepo             domain.OffersRepository
+	ledgerRepo             domain.LedgerRepository
+	receiptsRepo           domain.ReceiptsRepository
+	pricingRepo            domain.PricingRepository
+	zonesRepo              domain.ZonesRepository
+	driverApplicationsRepo domain.DriverApplicationsRepository
+
+	matchingService       *services.MatchingService
+	costSuggestionService *services.CostSuggestionService
 }
 
 func NewAPI(ctx context.Context, logger *zap.Logger, pool *pgxpool.Pool) *api {
@@ -37,11 +49,20 @@ func NewAPI(ctx context.Context, logger *zap.Logger, pool *pgxpool.Pool) *api {
 	ratelimitRepo := repository.NewPostgresRatelimit(pool)
 	notificationsRepo := repository.NewPostgresNotifications(pool)
 	reviewsRepo := repository.NewPostgresReviews(pool)
+	commutesRepo := repository.NewPostgresCommutes(pool)
+	offersRepo := repository.NewPostgresOffers(pool)
+	ledgerRepo := repository.NewPostgresLedger(pool)
+	receiptsRepo := repository.NewPostgresReceipts(pool)
+	pricingRepo := repository.NewPostgresPricing(pool)
+	zonesRepo := repository.NewPostgresZones(pool)
+	driverApplicationsRepo := repository.NewPostgresDriverApplications(pool)
 
 	client := &http.Client{}
 	emailService := email.NewService()
 	validate := validator.New()
 	validate.RegisterValidation("monash_email", MonashEmail)
+	matchingService := services.NewMatchingService(ridesRepo, mapsRepo, accountsRepo)
+	costSuggestionService := services.NewCostSuggestionService(accountsRepo, pricingRepo)
 
 	return &api{
 		logger:       logger,
@@ -49,12 +70,22 @@ func NewAPI(ctx context.Context, logger *zap.Logger, pool *pgxpool.Pool) *api {
 		emailService: emailService,
 		validator:    validate,
 
-		accountsRepo:      accountsRepo,
-		mapsRepo:          mapsRepo,
-		ridesRepo:         ridesRepo,
-		ratelimitRepo:     ratelimitRepo,
-		notificationsRepo: notificationsRepo,
-		reviewsRepo:       reviewsRepo,
+		accountsRepo:           accountsRepo,
+		mapsRepo:               mapsRepo,
+		ridesRepo:              ridesRepo,
+		ratelimitRepo:          ratelimitRepo,
+		notificationsRepo:      notificationsRepo,
+		reviewsRepo:            reviewsRepo,
+		commutesRepo:           commutesRepo,
+		offersRepo:             offersRepo,
+		ledgerRepo:             ledgerRepo,
+		receiptsRepo:           receiptsRepo,
+		pricingRepo:            pricingRepo,
+		zonesRepo:              zonesRepo,
+		driverApplicationsRepo: driverApplicationsRepo,
+
+		matchingService:       matchingService,
+		costSuggestionService: costSuggestionService,
 	}
 }
 
@@ -96,28 +127,82 @@ func (a *api) Routes() *mux.Router {
 	p.HandleFunc("/v1/accounts/location", a.updateLocationHandler).Methods("PUT")
 	p.HandleFunc("/v1/accounts/vehicle", a.createVehicleHandler).Methods("POST")
 	p.HandleFunc("/v1/accounts/vehicle", a.getVehicleHandler).Methods("GET")
+	p.HandleFunc("/v1/accounts/vehicle/features", a.updateVehicleFeaturesHandler).Methods("PUT")
+	p.HandleFunc("/v1/accounts/preferences", a.getDriverPreferencesHandler).Methods("GET")
+	p.HandleFunc("/v1/accounts/preferences", a.updateDriverPreferencesHandler).Methods("PUT")
+	p.HandleFunc("/v1/accounts/license", a.createLicenseHandler).Methods("POST")
+	p.HandleFunc("/v1/accounts/license", a.getLicenseHandler).Methods("GET")
+	p.HandleFunc("/v1/accounts/license", a.updateLicenseHandler).Methods("PUT")
+	p.HandleFunc("/v1/accounts/license", a.deleteLicenseHandler).Methods("DELETE")
+	p.HandleFunc("/v1/accounts/driver-application", a.submitDriverApplicationHandler).Methods("POST")
+	p.HandleFunc("/v1/accounts/driver-application", a.getDriverApplicationHandler).Methods("GET")
 	p.HandleFunc("/v1/accounts/{id}", a.getSpecificUserHandler).Methods("GET")
 	p.HandleFunc("/v1/accounts/{id}/review", a.createReviewHandler).Methods("POST")
 
 	// Protected ride routes
 	p.HandleFunc("/v1/rides/requests", a.getRideRequestsHandler).Methods("GET")
 	p.HandleFunc("/v1/rides/requests", a.createRideRequestHandler).Methods("POST")
+	p.HandleFunc("/v1/rides/matches", a.getMatchesHandler).Methods("GET")
 	p.HandleFunc("/v1/rides/", a.createRideDraftHandler).Methods("POST")
 	p.HandleFunc("/v1/rides/proposals", a.GetRideProposalsHandler).Methods("GET")
+	p.HandleFunc("/v1/rides/proposals/counter-offers", a.createCounterOfferHandler).Methods("POST")
+	p.HandleFunc("/v1/rides/proposals/counter-offers", a.getCounterOffersHandler).Methods("GET")
+	p.HandleFunc("/v1/rides/proposals/counter-offers/respond", a.respondToCounterOfferHandler).Methods("POST")
 	p.HandleFunc("/v1/rides/confirm", a.confirmRideProposalHandler).Methods("POST")
 	p.HandleFunc("/v1/rides/pickup", a.reachPickupHandler).Methods("POST")
+	p.HandleFunc("/v1/rides/boarding-code/reset", a.resetBoardingCodeHandler).Methods("POST")
+	p.HandleFunc("/v1/rides/dropoff", a.dropoffPassengerHandler).Methods("POST")
 	p.HandleFunc("/v1/rides/complete", a.completeRideHandler).Methods("POST")
 	p.HandleFunc("/v1/rides/summary", a.getRideSummaryHandler).Methods("GET")
+	p.HandleFunc("/v1/rides/receipt", a.getReceiptHandler).Methods("GET")
 	p.HandleFunc("/v1/rides/compensation", a.compensationEstimateHandler).Methods("GET")
+	p.HandleFunc("/v1/rides/compensation/suggestion", a.compensationSuggestionHandler).Methods("GET")
+	p.HandleFunc("/v1/tariffs/current", a.getCurrentTariffHandler).Methods("GET")
+	p.HandleFunc("/v1/fuel-prices", a.getFuelPricesHandler).Methods("GET")
 	p.HandleFunc("/v1/rides/history", a.getRideHistoryHandler).Methods("GET")
 	p.HandleFunc("/v1/rides/route", a.getRouteForRideHandler).Methods("GET")
+	p.HandleFunc("/v1/rides/requests/cancel", a.cancelRideRequestHandler).Methods("POST")
+	p.HandleFunc("/v1/rides/proposals/cancel", a.cancelRideProposalHandler).Methods("POST")
+	p.HandleFunc("/v1/rides/cancel", a.cancelRideHandler).Methods("POST")
+
+	// Protected commute routes
+	p.HandleFunc("/v1/commutes", a.createCommuteHandler).Methods("POST")
+	p.HandleFunc("/v1/commutes", a.getCommutesHandler).Methods("GET")
+	p.HandleFunc("/v1/commutes/pause", a.pauseCommuteHandler).Methods("POST")
+	p.HandleFunc("/v1/commutes/resume", a.resumeCommuteHandler).Methods("POST")
+	p.HandleFunc("/v1/commutes/skip", a.skipCommuteHandler).Methods("POST")
+	p.HandleFunc("/v1/commutes/end", a.endCommuteHandler).Methods("POST")
+
+	// Protected offer routes
+	p.HandleFunc("/v1/offers", a.createOfferHandler).Methods("POST")
+	p.HandleFunc("/v1/offers", a.getOffersHandler).Methods("GET")
+	p.HandleFunc("/v1/offers/cancel", a.cancelOfferHandler).Methods("POST")
+	p.HandleFunc("/v1/offers/join", a.joinOfferHandler).Methods("POST")
+	p.HandleFunc("/v1/offers/bookings", a.getOfferBookingsHandler).Methods("GET")
+	p.HandleFunc("/v1/offers/bookings/respond", a.respondToBookingHandler).Methods("POST")
+	p.HandleFunc("/v1/offers/bookings/cancel", a.cancelBookingHandler).Methods("POST")
+
+	// Protected ledger routes
+	p.HandleFunc("/v1/ledger/balance", a.getBalanceHandler).Methods("GET")
+	p.HandleFunc("/v1/ledger/statement", a.getStatementHandler).Methods("GET")
+	p.HandleFunc("/v1/ledger/settlements", a.getSettlementsHandler).Methods("GET")
+	p.HandleFunc("/v1/ledger/settlements/paid", a.markSettlementPaidHandler).Methods("POST")
+	p.HandleFunc("/v1/ledger/refunds", a.refundHandler).Methods("POST")
+	p.HandleFunc("/v1/ledger/earnings", a.getEarningsStatementHandler).Methods("GET")
 
 	// Admin IP management routes (protected by admin check within handlers)
 	p.HandleFunc("/v1/admin/ip/block", a.blockIPHandler).Methods("POST")
 	p.HandleFunc("/v1/admin/ip/unblock", a.unblockIPHandler).Methods("GET")
+	p.HandleFunc("/v1/admin/ledger/adjustments", a.createAdjustmentHandler).Methods("POST")
+	p.HandleFunc("/v1/admin/tariffs", a.getTariffsHandler).Methods("GET")
+	p.HandleFunc("/v1/admin/tariffs", a.createTariffHandler).Methods("POST")
+	p.HandleFunc("/v1/admin/fuel-prices", a.updateFuelPriceHandler).Methods("PUT")
+	p.HandleFunc("/v1/admin/driver-applications", a.getDriverApplicationsHandler).Methods("GET")
+	p.HandleFunc("/v1/admin/driver-applications/review", a.reviewDriverApplicationHandler).Methods("POST")
 
 	// Protected map routes
 	p.HandleFunc("/v1/maps/route", a.getRouteHandler).Methods("POST")
+	p.HandleFunc("/v1/maps/zones", a.getZonesHandler).Methods("GET")
 
 	return r
 }
@@ -127,6 +212,31 @@ func (a *api) errorResponse(w http.ResponseWriter, _ *http.Request, status int,
 	http.Error(w, err.Error(), status)
 }
 
+// domainErrorResponse maps errors returned by the domain and repositories to a status code
+func (a *api) domainErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var transitionErr *domain.InvalidTransitionError
+	if errors.As(err, &transitionErr) || errors.Is(err, domain.ErrNotEnoughSeats) || errors.Is(err, domain.ErrCounterOfferPending) ||
+		errors.Is(err, domain.ErrRefundExceedsCharge) || errors.Is(err, domain.ErrOverlappingRequest) {
+		a.errorResponse(w, r, http.StatusConflict, err)
+		return
+	}
+	var boardingErr *domain.BoardingCodeError
+	if errors.As(err, &boardingErr) {
+		status := http.StatusForbidden
+		if boardingErr.AttemptsLeft <= 0 {
+			status = http.StatusTooManyRequests
+		}
+		a.errorResponse(w, r, status, err)
+		return
+	}
+	if errors.Is(err, domain.ErrLicenseRequired) || errors.Is(err, domain.ErrLicenseExpired) || errors.Is(err, domain.ErrLearnerLicense) ||
+		errors.Is(err, domain.ErrPeerPassengerLimit) || errors.Is(err, domain.ErrDriverNotApproved) {
+		a.errorResponse(w, r, http.StatusForbidden, err)
+		return
+	}
+	a.errorResponse(w, r, http.StatusInternalServerError, err)
+}
+
 func (a *api) validateRequest(req any) error {
 	if err := a.validator.Struct(req); err != nil {
 		if validationErrors, ok := err.(validator.ValidationErrors); ok {
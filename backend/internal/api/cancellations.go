@@ -0,0 +1,233 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Arjun113/nOPark/internal/domain"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+type CancelRideRequestRequest struct {
+	RequestID int64  `json:"request_id" validate:"required"`
+	Reason    string `json:"reason" validate:"required,max=250"`
+}
+
+func (a *api) cancelRideRequestHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var req CancelRideRequestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+	if account.Type != "passenger" {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("only passengers can cancel ride requests"))
+		return
+	}
+
+	request, err := a.ridesRepo.GetRequestByID(ctx, req.RequestID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if request.PassengerID != account.ID {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("you are not authorized to cancel this request"))
+		return
+	}
+
+	proposals, err := a.ridesRepo.CancelRequest(ctx, request.ID, domain.Cancellation{
+		CancelledBy: account.ID,
+		Reason:      req.Reason,
+	})
+	if err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+
+	// Let every driver who had proposed to this passenger know
+	for _, proposal := range proposals {
+		a.queueCancellationNotification(ctx, proposal.DriverID, proposal.RideID,
+			fmt.Sprintf("%s cancelled their ride request.", account.FirstName))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type CancelRideProposalRequest struct {
+	ProposalID int64  `json:"proposal_id" validate:"required"`
+	Reason     string `json:"reason" validate:"required,max=250"`
+}
+
+func (a *api) cancelRideProposalHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var req CancelRideProposalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+
+	proposal, err := a.ridesRepo.GetProposalByID(ctx, req.ProposalID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	request, err := a.ridesRepo.GetRequestByID(ctx, proposal.RequestID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Either side of the proposal may withdraw; the other side gets told
+	var counterpartyID int64
+	var message string
+	switch account.ID {
+	case proposal.DriverID:
+		counterpartyID = request.PassengerID
+		message = fmt.Sprintf("Driver %s %s withdrew from your ride.", account.FirstName, account.LastName)
+	case request.PassengerID:
+		counterpartyID = proposal.DriverID
+		message = fmt.Sprintf("%s %s dropped out of your ride.", account.FirstName, account.LastName)
+	default:
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("you are not authorized to cancel this proposal"))
+		return
+	}
+
+	proposal, err = a.ridesRepo.CancelProposal(ctx, proposal.ID, domain.Cancellation{
+		CancelledBy: account.ID,
+		Reason:      req.Reason,
+	})
+	if err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+
+	a.queueCancellationNotification(ctx, counterpartyID, proposal.RideID, message)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type CancelRideRequest struct {
+	RideID int64  `json:"ride_id" validate:"required"`
+	Reason string `json:"reason" validate:"required,max=250"`
+}
+
+func (a *api) cancelRideHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var req CancelRideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+	if account.Type != "driver" {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("only drivers can cancel rides"))
+		return
+	}
+	// Suspended drivers can still cancel, so their passengers are released rather than left
+	// waiting on a ride that will not happen
+
+	ride, proposals, err := a.ridesRepo.GetRideAndProposals(ctx, req.RideID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if ride == nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("ride not found"))
+		return
+	}
+	if len(proposals) == 0 || proposals[0].DriverID != account.ID {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("you are not authorized to cancel this ride"))
+		return
+	}
+
+	cancelled, err := a.ridesRepo.CancelRide(ctx, req.RideID, domain.Cancellation{
+		CancelledBy: account.ID,
+		Reason:      req.Reason,
+	})
+	if err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+
+	a.notifyRideCancelled(ctx, req.RideID, cancelled)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// notifyRideCancelled tells every passenger on a ride the driver called off, noting whose
+// request went back into the pool
+func (a *api) notifyRideCancelled(ctx context.Context, rideID int64, cancelled []*domain.ProposalDBModel) {
+	for _, proposal := range cancelled {
+		request, err := a.ridesRepo.GetRequestByID(ctx, proposal.RequestID)
+		if err != nil {
+			a.logger.Error("Failed to get request for ride cancellation notification",
+				zap.Error(err),
+				zap.Int64("request_id", proposal.RequestID))
+			continue
+		}
+
+		message := "Your driver cancelled the ride."
+		if request.Status == domain.RequestStatusOpen {
+			message = "Your driver cancelled the ride. Your request is open again."
+		}
+		a.queueCancellationNotification(ctx, request.PassengerID, rideID, message)
+	}
+}
+
+// queueCancellationNotification queues a ride update for an affected party, logging rather than failing on error
+func (a *api) queueCancellationNotification(ctx context.Context, accountID, rideID int64, message string) {
+	notificationPayload := fmt.Sprintf(`{"ride_id": %d, "notification": "%s"}`, rideID, domain.NotificationRideCancelled)
+	notification := &domain.NotificationDBModel{
+		NotificationType:    domain.NotificationTypeRideUpdates,
+		NotificationMessage: message,
+		AccountID:           accountID,
+		Payload:             &notificationPayload,
+	}
+
+	_, err := a.notificationsRepo.CreateNotification(ctx, notification)
+	if err != nil {
+		a.logger.Error("Failed to create ride cancellation notification",
+			zap.Error(err),
+			zap.Int64("account_id", accountID),
+			zap.Int64("ride_id", rideID))
+	}
+}
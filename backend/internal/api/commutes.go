@@ -0,0 +1,347 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Arjun113/nOPark/internal/domain"
+	"go.uber.org/zap"
+)
+
+type CreateCommuteRequest struct {
+	PickupAddressID  int64             `json:"pickup_address_id" validate:"required"`
+	DropoffLocation  string            `json:"dropoff_location" validate:"required,max=100"`
+	DropoffLatitude  float64           `json:"dropoff_latitude" validate:"required,latitude"`
+	DropoffLongitude float64           `json:"dropoff_longitude" validate:"required,longitude"`
+	Compensation     domain.Money      `json:"compensation" validate:"required,gt=0"`
+	Weekdays         []int16           `json:"weekdays" validate:"required,min=1,max=7,unique,dive,min=0,max=6"`
+	StartTime        string            `json:"start_time" validate:"required,datetime=15:04"`
+	WindowKind       domain.WindowKind `json:"window_kind" validate:"required,oneof=departure arrival"`
+	WindowMinutes    int               `json:"window_minutes" validate:"required,min=5,max=120"`
+	StartsOn         string            `json:"starts_on,omitempty" validate:"omitempty,datetime=2006-01-02"`
+	EndsOn           *string           `json:"ends_on,omitempty" validate:"omitempty,datetime=2006-01-02"`
+}
+
+type CommuteResponse struct {
+	ID               int64                `json:"id"`
+	PickupAddressID  int64                `json:"pickup_address_id"`
+	PickupLocation   string               `json:"pickup_location"`
+	DropoffLocation  string               `json:"dropoff_location"`
+	DropoffLatitude  float64              `json:"dropoff_latitude"`
+	DropoffLongitude float64              `json:"dropoff_longitude"`
+	Compensation     domain.Money         `json:"compensation"`
+	Weekdays         []int16              `json:"weekdays"`
+	StartTime        string               `json:"start_time"`
+	WindowKind       domain.WindowKind    `json:"window_kind"`
+	WindowMinutes    int                  `json:"window_minutes"`
+	Timezone         string               `json:"timezone"`
+	Status           domain.CommuteStatus `json:"status"`
+	StartsOn         string               `json:"starts_on"`
+	EndsOn           *string              `json:"ends_on,omitempty"`
+	SkippedDates     []string             `json:"skipped_dates"`
+	CreatedAt        string               `json:"created_at"`
+}
+
+func commuteResponse(commute *domain.CommuteDBModel) CommuteResponse {
+	return CommuteResponse{
+		ID:               commute.ID,
+		PickupAddressID:  commute.PickupAddressID,
+		PickupLocation:   commute.PickupLocation,
+		DropoffLocation:  commute.DropoffLocation,
+		DropoffLatitude:  commute.DropoffLatitude,
+		DropoffLongitude: commute.DropoffLongitude,
+		Compensation:     commute.Compensation,
+		Weekdays:         commute.Weekdays,
+		StartTime:        commute.StartTime,
+		WindowKind:       commute.WindowKind,
+		WindowMinutes:    commute.WindowMinutes,
+		Timezone:         commute.Timezone,
+		Status:           commute.Status,
+		StartsOn:         commute.StartsOn,
+		EndsOn:           commute.EndsOn,
+		SkippedDates:     commute.SkippedDates,
+		CreatedAt:        commute.CreatedAt,
+	}
+}
+
+func (a *api) createCommuteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var req CreateCommuteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if req.EndsOn != nil && req.StartsOn != "" && *req.EndsOn < req.StartsOn {
+		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("ends_on must not be before starts_on"))
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+	if account.Type != "passenger" {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("only passengers can create commutes"))
+		return
+	}
+
+	// Commutes always leave from one of the passenger's saved addresses
+	addresses, err := a.accountsRepo.GetFavouriteAddresses(ctx, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	var pickup *domain.AddressDBModel
+	for i := range addresses {
+		if addresses[i].ID == req.PickupAddressID {
+			pickup = &addresses[i]
+			break
+		}
+	}
+	if pickup == nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("saved address %d not found", req.PickupAddressID))
+		return
+	}
+	if pickup.Latitude == nil || pickup.Longitude == nil {
+		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("saved address %d has no coordinates", req.PickupAddressID))
+		return
+	}
+
+	zones, err := a.zonesRepo.GetZones(ctx)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if !zones.InServiceArea(domain.Coordinates{Lat: *pickup.Latitude, Lon: *pickup.Longitude}) ||
+		!zones.InServiceArea(domain.Coordinates{Lat: req.DropoffLatitude, Lon: req.DropoffLongitude}) {
+		a.errorResponse(w, r, http.StatusBadRequest, domain.ErrOutsideServiceArea)
+		return
+	}
+
+	commute, err := a.commutesRepo.CreateCommute(ctx, &domain.CommuteDBModel{
+		AccountID:        account.ID,
+		PickupAddressID:  pickup.ID,
+		DropoffLocation:  req.DropoffLocation,
+		DropoffLatitude:  req.DropoffLatitude,
+		DropoffLongitude: req.DropoffLongitude,
+		Compensation:     req.Compensation,
+		Weekdays:         req.Weekdays,
+		StartTime:        req.StartTime,
+		WindowKind:       req.WindowKind,
+		WindowMinutes:    req.WindowMinutes,
+		StartsOn:         req.StartsOn,
+		EndsOn:           req.EndsOn,
+	})
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(commuteResponse(commute))
+}
+
+type GetCommutesResponse struct {
+	Commutes []CommuteResponse `json:"commutes"`
+}
+
+func (a *api) getCommutesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+
+	commutes, err := a.commutesRepo.GetCommutesByAccountID(ctx, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := GetCommutesResponse{
+		Commutes: make([]CommuteResponse, len(commutes)),
+	}
+	for i, commute := range commutes {
+		response.Commutes[i] = commuteResponse(commute)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+type CommuteActionRequest struct {
+	CommuteID int64 `json:"commute_id" validate:"required"`
+}
+
+func (a *api) pauseCommuteHandler(w http.ResponseWriter, r *http.Request) {
+	a.transitionCommute(w, r, domain.CommuteEventPause)
+}
+
+func (a *api) resumeCommuteHandler(w http.ResponseWriter, r *http.Request) {
+	a.transitionCommute(w, r, domain.CommuteEventResume)
+}
+
+func (a *api) endCommuteHandler(w http.ResponseWriter, r *http.Request) {
+	a.transitionCommute(w, r, domain.CommuteEventEnd)
+}
+
+// transitionCommute pauses, resumes or ends a commute. Pausing and ending also cancel the
+// requests the worker has already created for it.
+func (a *api) transitionCommute(w http.ResponseWriter, r *http.Request, event domain.CommuteEvent) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var req CommuteActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, commute, ok := a.getOwnCommute(w, r, req.CommuteID)
+	if !ok {
+		return
+	}
+
+	commute, err := a.commutesRepo.TransitionCommute(ctx, commute.ID, event)
+	if err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+
+	switch event {
+	case domain.CommuteEventPause:
+		a.cancelCommuteRequests(ctx, account, commute.ID, nil, "Commute paused")
+	case domain.CommuteEventEnd:
+		a.cancelCommuteRequests(ctx, account, commute.ID, nil, "Commute ended")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(commuteResponse(commute))
+}
+
+type SkipCommuteRequest struct {
+	CommuteID int64  `json:"commute_id" validate:"required"`
+	Date      string `json:"date" validate:"required,datetime=2006-01-02"`
+}
+
+func (a *api) skipCommuteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var req SkipCommuteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, commute, ok := a.getOwnCommute(w, r, req.CommuteID)
+	if !ok {
+		return
+	}
+	if commute.Status == domain.CommuteStatusEnded {
+		a.errorResponse(w, r, http.StatusConflict, fmt.Errorf("commute has ended"))
+		return
+	}
+	location, err := time.LoadLocation(commute.Timezone)
+	if err != nil {
+		location = time.Local
+	}
+	// Dates compare correctly as strings in this layout
+	if req.Date < time.Now().In(location).Format(time.DateOnly) {
+		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("cannot skip a date in the past"))
+		return
+	}
+
+	if err := a.commutesRepo.SkipCommuteDate(ctx, commute.ID, req.Date); err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	a.cancelCommuteRequests(ctx, account, commute.ID, &req.Date, "Commute skipped")
+
+	commute, err = a.commutesRepo.GetCommuteByID(ctx, commute.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(commuteResponse(commute))
+}
+
+// getOwnCommute loads a commute for the signed-in passenger, writing the error response itself
+func (a *api) getOwnCommute(w http.ResponseWriter, r *http.Request, commuteID int64) (*domain.AccountDBModel, *domain.CommuteDBModel, bool) {
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return nil, nil, false
+	}
+
+	commute, err := a.commutesRepo.GetCommuteByID(r.Context(), commuteID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("commute not found"))
+		return nil, nil, false
+	}
+	if commute.AccountID != account.ID {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("you are not authorized to change this commute"))
+		return nil, nil, false
+	}
+
+	return account, commute, true
+}
+
+// cancelCommuteRequests cancels requests already created for a commute and lets any drivers
+// who had proposed know. Failures are logged so the commute change itself still succeeds.
+func (a *api) cancelCommuteRequests(ctx context.Context, account *domain.AccountDBModel, commuteID int64, date *string, reason string) {
+	requestIDs, err := a.commutesRepo.GetUpcomingCommuteRequestIDs(ctx, commuteID, date)
+	if err != nil {
+		a.logger.Error("Failed to get upcoming commute requests",
+			zap.Error(err),
+			zap.Int64("commute_id", commuteID))
+		return
+	}
+
+	for _, requestID := range requestIDs {
+		proposals, err := a.ridesRepo.CancelRequest(ctx, requestID, domain.Cancellation{
+			CancelledBy: account.ID,
+			Reason:      reason,
+		})
+		if err != nil {
+			a.logger.Error("Failed to cancel commute request",
+				zap.Error(err),
+				zap.Int64("commute_id", commuteID),
+				zap.Int64("request_id", requestID))
+			continue
+		}
+
+		for _, proposal := range proposals {
+			a.queueCancellationNotification(ctx, proposal.DriverID, proposal.RideID,
+				fmt.Sprintf("%s cancelled their ride request.", account.FirstName))
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Arjun113/nOPark/internal/domain"
+	"go.uber.org/zap"
+)
+
+// CostShareResponse is a passenger's fair part of what the driver is paid for the whole ride.
+// Distances are in metres.
+type CostShareResponse struct {
+	Amount          domain.Money `json:"amount"`
+	DistanceOnBoard float64      `json:"distance_on_board"`
+	SharedDistance  float64      `json:"shared_distance"`
+	// False once the ride has completed and the split is final
+	Estimate bool `json:"estimate"`
+}
+
+func storedCostShare(amount *domain.Money, distance, sharedDistance *float64) *CostShareResponse {
+	if amount == nil || distance == nil || sharedDistance == nil {
+		return nil
+	}
+	return &CostShareResponse{
+		Amount:          *amount,
+		DistanceOnBoard: *distance,
+		SharedDistance:  *sharedDistance,
+	}
+}
+
+// rideCostShares estimates how what the driver is paid for the ride will be split between its
+// accepted passengers while it is in progress. The final split is recorded as the ride
+// completes.
+func (a *api) rideCostShares(ctx context.Context, ride *domain.RideDBModel, proposals []*domain.ProposalDBModel) ([]*domain.CostShare, error) {
+	requests := make([]*domain.RequestDBModel, 0)
+	total := domain.Money(0)
+	var driverID int64
+	for _, proposal := range proposals {
+		if proposal.Status != domain.ProposalStatusAccepted {
+			continue
+		}
+		driverID = proposal.DriverID
+		request, err := a.ridesRepo.GetRequestByID(ctx, proposal.RequestID)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+		total += proposal.SettledCompensation(request)
+	}
+
+	stops := domain.CostShareStops(requests, domain.Coordinates{Lat: ride.DestinationLatitude, Lon: ride.DestinationLongitude})
+	if len(stops) == 0 {
+		return []*domain.CostShare{}, nil
+	}
+
+	route, err := a.routeCostShareStops(ctx, driverID, stops)
+	if err != nil {
+		return nil, err
+	}
+
+	return domain.SplitTripCost(total, stops, route.Legs)
+}
+
+// completionCostShareRoute routes the ride through its stops as they will be once it completes,
+// with whoever is still in the car dropped off now and anyone never picked up left out. Pass the
+// request being dropped off when that is what completes the ride. Nil means no split is
+// recorded: the ride is not completing yet, or routing failed, which is logged.
+func (a *api) completionCostShareRoute(ctx context.Context, ride *domain.RideDBModel, proposals []*domain.ProposalDBModel, droppingOffID *int64) *domain.CostShareRoute {
+	now := time.Now()
+	requests := make([]*domain.RequestDBModel, 0)
+	var driverID int64
+	for _, proposal := range proposals {
+		if proposal.Status != domain.ProposalStatusAccepted {
+			continue
+		}
+		request, err := a.ridesRepo.GetRequestByID(ctx, proposal.RequestID)
+		if err != nil {
+			a.logger.Error("Failed to load request for cost split",
+				zap.Error(err),
+				zap.Int64("ride_id", ride.ID),
+				zap.Int64("request_id", proposal.RequestID))
+			return nil
+		}
+
+		switch {
+		case request.Status == domain.RequestStatusDroppedOff:
+		case request.Status == domain.RequestStatusPickedUp && (droppingOffID == nil || *droppingOffID == request.ID):
+			request.DroppedOffAt = &now
+		case droppingOffID != nil:
+			// Someone else is still to be dropped off, so this is not the end of the ride
+			return nil
+		default:
+			continue
+		}
+		driverID = proposal.DriverID
+		requests = append(requests, request)
+	}
+	// The ride's own transaction reads them in this order, which breaks ties between dropoffs
+	sort.Slice(requests, func(i, j int) bool { return requests[i].ID < requests[j].ID })
+
+	stops := domain.CostShareStops(requests, domain.Coordinates{Lat: ride.DestinationLatitude, Lon: ride.DestinationLongitude})
+	if len(stops) == 0 {
+		return nil
+	}
+
+	route, err := a.routeCostShareStops(ctx, driverID, stops)
+	if err != nil {
+		a.logger.Error("Failed to route ride for cost split",
+			zap.Error(err),
+			zap.Int64("ride_id", ride.ID))
+		return nil
+	}
+	return route
+}
+
+// routeCostShareStops routes the driver through stops in order
+func (a *api) routeCostShareStops(ctx context.Context, driverID int64, stops []domain.CostShareStop) (*domain.CostShareRoute, error) {
+	waypoints := make([]domain.Coordinates, 0, len(stops))
+	for _, stop := range stops[1 : len(stops)-1] {
+		waypoints = append(waypoints, stop.Location)
+	}
+	routeOptions, err := a.accountsRepo.GetDriverRouteOptions(ctx, driverID)
+	if err != nil {
+		return nil, err
+	}
+	route, err := a.mapsRepo.GetMultistopRouteWithOptions(ctx, stops[0].Location, waypoints, stops[len(stops)-1].Location, routeOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.CostShareRoute{Stops: stops, Legs: route.Legs}, nil
+}
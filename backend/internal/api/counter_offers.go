@@ -0,0 +1,292 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Arjun113/nOPark/internal/domain"
+	"github.com/Arjun113/nOPark/internal/utils"
+	"go.uber.org/zap"
+)
+
+type CounterOfferResponse struct {
+	ID         int64                     `json:"id"`
+	ProposalID int64                     `json:"proposal_id"`
+	OfferedBy  domain.CounterOfferParty  `json:"offered_by"`
+	Amount     domain.Money              `json:"amount"`
+	Status     domain.CounterOfferStatus `json:"status"`
+	AnsweredAt *time.Time                `json:"answered_at,omitempty"`
+	CreatedAt  string                    `json:"created_at"`
+}
+
+func counterOfferResponse(counter *domain.CounterOfferDBModel) CounterOfferResponse {
+	return CounterOfferResponse{
+		ID:         counter.ID,
+		ProposalID: counter.ProposalID,
+		OfferedBy:  counter.OfferedBy,
+		Amount:     counter.Amount,
+		Status:     counter.Status,
+		AnsweredAt: counter.AnsweredAt,
+		CreatedAt:  counter.CreatedAt,
+	}
+}
+
+// proposalParty returns the proposal with its request and which side of it the account is on,
+// or false if the account is on neither
+func (a *api) proposalParty(ctx context.Context, proposalID, accountID int64) (*domain.ProposalDBModel, *domain.RequestDBModel, domain.CounterOfferParty, bool, error) {
+	proposal, err := a.ridesRepo.GetProposalByID(ctx, proposalID)
+	if err != nil {
+		return nil, nil, "", false, err
+	}
+	request, err := a.ridesRepo.GetRequestByID(ctx, proposal.RequestID)
+	if err != nil {
+		return nil, nil, "", false, err
+	}
+
+	switch accountID {
+	case proposal.DriverID:
+		return proposal, request, domain.CounterOfferPartyDriver, true, nil
+	case request.PassengerID:
+		return proposal, request, domain.CounterOfferPartyPassenger, true, nil
+	}
+	return proposal, request, "", false, nil
+}
+
+type CreateCounterOfferRequest struct {
+	ProposalID int64 `json:"proposal_id" validate:"required"`
+	// At most $1000, the limit being in cents
+	Amount domain.Money `json:"amount" validate:"required,gt=0,max=100000"`
+}
+
+// createCounterOfferHandler lets the driver put a different price on a proposal the passenger
+// has not answered yet. The passenger then accepts, rejects or counters it.
+func (a *api) createCounterOfferHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var req CreateCounterOfferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+	if account.Type != "driver" {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("only drivers can make counter-offers on proposals"))
+		return
+	}
+	if err := a.checkApprovedDriver(ctx, account.ID); err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+
+	_, request, party, ok, err := a.proposalParty(ctx, req.ProposalID, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("proposal not found"))
+		return
+	}
+	if !ok || party != domain.CounterOfferPartyDriver {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("you are not authorized to negotiate this proposal"))
+		return
+	}
+
+	counter, err := a.ridesRepo.CreateCounterOffer(ctx, req.ProposalID, domain.CounterOfferPartyDriver, req.Amount)
+	if err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+
+	a.queueCounterOfferNotification(ctx, request.PassengerID, counter,
+		fmt.Sprintf("Driver %s %s offered $%s for your trip.", account.FirstName, account.LastName, counter.Amount))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(counterOfferResponse(counter))
+}
+
+type RespondToCounterOfferRequest struct {
+	CounterOfferID int64        `json:"counter_offer_id" validate:"required"`
+	Response       string       `json:"response" validate:"required,oneof=accept reject counter"`
+	Amount         domain.Money `json:"amount" validate:"required_if=Response counter,omitempty,gt=0,max=100000"`
+}
+
+type RespondToCounterOfferResponse struct {
+	Answered CounterOfferResponse  `json:"answered"`
+	Counter  *CounterOfferResponse `json:"counter,omitempty"`
+}
+
+// respondToCounterOfferHandler answers the other side's price. Accepting makes it what the
+// ride settles on, rejecting keeps the last agreed amount, and countering puts amount forward.
+func (a *api) respondToCounterOfferHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var req RespondToCounterOfferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+
+	counter, err := a.ridesRepo.GetCounterOfferByID(ctx, req.CounterOfferID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("counter-offer not found"))
+		return
+	}
+	proposal, request, party, ok, err := a.proposalParty(ctx, counter.ProposalID, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if !ok || party != counter.OfferedBy.Other() {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("you are not authorized to answer this counter-offer"))
+		return
+	}
+
+	var amount *domain.Money
+	if req.Response == string(domain.CounterOfferEventCounter) {
+		amount = &req.Amount
+	}
+	answered, countered, err := a.ridesRepo.RespondToCounterOffer(ctx, counter.ID, domain.CounterOfferEvent(req.Response), amount)
+	if err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+
+	recipientID := proposal.DriverID
+	if answered.OfferedBy == domain.CounterOfferPartyPassenger {
+		recipientID = request.PassengerID
+	}
+	name := fmt.Sprintf("%s %s", account.FirstName, account.LastName)
+	if party == domain.CounterOfferPartyDriver {
+		name = "Driver " + name
+	}
+
+	response := RespondToCounterOfferResponse{
+		Answered: counterOfferResponse(answered),
+	}
+	if countered != nil {
+		c := counterOfferResponse(countered)
+		response.Counter = &c
+		a.queueCounterOfferNotification(ctx, recipientID, countered,
+			fmt.Sprintf("%s countered with $%s.", name, countered.Amount))
+	} else {
+		a.queueCounterOfferNotification(ctx, recipientID, answered,
+			fmt.Sprintf("%s %s your offer of $%s.", name, answered.Status, answered.Amount))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+type GetCounterOffersRequest struct {
+	ProposalID int64 `json:"proposal_id" validate:"required"`
+}
+
+type GetCounterOffersResponse struct {
+	ProposalID int64 `json:"proposal_id"`
+	// What the passenger asked for, and what the ride settles on so far
+	RequestedCompensation float64                `json:"requested_compensation"`
+	AgreedCompensation    domain.Money           `json:"agreed_compensation"`
+	CounterOffers         []CounterOfferResponse `json:"counter_offers"`
+}
+
+// getCounterOffersHandler returns a proposal's negotiation history, oldest first
+func (a *api) getCounterOffersHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	proposalID, err := utils.IntFromQueryParam(r, "proposal_id", false)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("invalid proposal_id"))
+		return
+	}
+	reqParams := GetCounterOffersRequest{
+		ProposalID: *proposalID,
+	}
+	if err := a.validateRequest(reqParams); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+
+	proposal, request, _, ok, err := a.proposalParty(ctx, reqParams.ProposalID, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("proposal not found"))
+		return
+	}
+	if !ok {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("you are not authorized to view this proposal"))
+		return
+	}
+
+	counters, err := a.ridesRepo.GetCounterOffersByProposalID(ctx, proposal.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := GetCounterOffersResponse{
+		ProposalID:            proposal.ID,
+		RequestedCompensation: request.Compensation,
+		AgreedCompensation:    proposal.SettledCompensation(request),
+		CounterOffers:         make([]CounterOfferResponse, len(counters)),
+	}
+	for i, counter := range counters {
+		response.CounterOffers[i] = counterOfferResponse(counter)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// queueCounterOfferNotification tells the other side about a move in the negotiation,
+// logging rather than failing on error
+func (a *api) queueCounterOfferNotification(ctx context.Context, accountID int64, counter *domain.CounterOfferDBModel, message string) {
+	notificationType := domain.NotificationCounterAnswer
+	if counter.Status == domain.CounterOfferStatusPending {
+		notificationType = domain.NotificationCounterOffer
+	}
+	notificationPayload := fmt.Sprintf(`{"proposal_id": %d, "counter_offer_id": %d, "notification": "%s"}`, counter.ProposalID, counter.ID, notificationType)
+	notification := &domain.NotificationDBModel{
+		NotificationType:    domain.NotificationTypeRideUpdates,
+		NotificationMessage: message,
+		AccountID:           accountID,
+		Payload:             &notificationPayload,
+	}
+
+	_, err := a.notificationsRepo.CreateNotification(ctx, notification)
+	if err != nil {
+		a.logger.Error("Failed to create counter-offer notification",
+			zap.Error(err),
+			zap.Int64("account_id", accountID),
+			zap.Int64("counter_offer_id", counter.ID))
+	}
+}
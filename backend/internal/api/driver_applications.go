@@ -0,0 +1,338 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Arjun113/nOPark/internal/domain"
+	"github.com/Arjun113/nOPark/internal/utils"
+	"go.uber.org/zap"
+)
+
+type DriverApplicationResponse struct {
+	AccountID  int64                          `json:"account_id"`
+	Status     domain.DriverApplicationStatus `json:"status"`
+	Reason     *string                        `json:"reason"`
+	ReviewedAt *time.Time                     `json:"reviewed_at"`
+	CreatedAt  string                         `json:"created_at"`
+}
+
+func driverApplicationResponse(application *domain.DriverApplicationDBModel) DriverApplicationResponse {
+	return DriverApplicationResponse{
+		AccountID:  application.AccountID,
+		Status:     application.Status,
+		Reason:     application.Reason,
+		ReviewedAt: application.ReviewedAt,
+		CreatedAt:  application.CreatedAt,
+	}
+}
+
+// checkApprovedDriver returns domain.ErrDriverNotApproved unless an admin has approved the
+// driver and not suspended them since
+func (a *api) checkApprovedDriver(ctx context.Context, accountID int64) error {
+	application, err := a.driverApplicationsRepo.GetDriverApplication(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return application.CheckApproved()
+}
+
+// checkDriverCanApply returns why the driver cannot be approved yet: they need a vehicle and a
+// licence that lets them carry passengers
+func (a *api) checkDriverCanApply(ctx context.Context, accountID int64) error {
+	license, err := a.accountsRepo.GetLicenseByAccountID(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	if err := domain.CheckLicense(license, licenseNow()); err != nil {
+		return err
+	}
+
+	vehicle, err := a.accountsRepo.GetVehicleByAccountID(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	if vehicle == nil {
+		return errors.New("a vehicle is required to drive")
+	}
+	return nil
+}
+
+// submitDriverApplicationHandler asks an admin to approve the driver. They must have added
+// their licence and vehicle first.
+func (a *api) submitDriverApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+	if account.Type != "driver" {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("only drivers can apply to drive"))
+		return
+	}
+
+	existing, err := a.driverApplicationsRepo.GetDriverApplication(ctx, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if existing != nil {
+		a.errorResponse(w, r, http.StatusConflict, fmt.Errorf("you have already applied to drive and your application is %s", existing.Status))
+		return
+	}
+
+	if err := a.checkDriverCanApply(ctx, account.ID); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	application, err := a.driverApplicationsRepo.CreateDriverApplication(ctx, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(driverApplicationResponse(application))
+}
+
+func (a *api) getDriverApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+
+	application, err := a.driverApplicationsRepo.GetDriverApplication(ctx, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if application == nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("you have not applied to drive"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(driverApplicationResponse(application))
+}
+
+type GetDriverApplicationsRequest struct {
+	Status string `validate:"omitempty,oneof=pending approved suspended"`
+}
+
+type AdminDriverApplicationResponse struct {
+	DriverApplicationResponse
+	FirstName  string              `json:"first_name"`
+	LastName   string              `json:"last_name"`
+	Email      string              `json:"email"`
+	ReviewedBy *int64              `json:"reviewed_by"`
+	License    *LicenseResponse    `json:"license"`
+	Vehicle    *GetVehicleResponse `json:"vehicle"`
+}
+
+type GetDriverApplicationsResponse struct {
+	Applications []AdminDriverApplicationResponse `json:"applications"`
+}
+
+// getDriverApplicationsHandler lists applications oldest first, with each driver's licence and
+// vehicle for review. Admin only.
+func (a *api) getDriverApplicationsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	status, _ := utils.StringFromQueryParam(r, "status", true)
+	reqParams := GetDriverApplicationsRequest{}
+	if status != nil {
+		reqParams.Status = *status
+	}
+	if err := a.validateRequest(reqParams); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+	if account.Type != "admin" {
+		a.errorResponse(w, r, http.StatusForbidden, errors.New("admin access required"))
+		return
+	}
+
+	var filter *domain.DriverApplicationStatus
+	if reqParams.Status != "" {
+		s := domain.DriverApplicationStatus(reqParams.Status)
+		filter = &s
+	}
+
+	applications, err := a.driverApplicationsRepo.GetDriverApplications(ctx, filter)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := GetDriverApplicationsResponse{
+		Applications: make([]AdminDriverApplicationResponse, len(applications)),
+	}
+	for i, application := range applications {
+		response.Applications[i], err = a.adminDriverApplicationResponse(ctx, application)
+		if err != nil {
+			a.errorResponse(w, r, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (a *api) adminDriverApplicationResponse(ctx context.Context, application *domain.DriverApplicationDBModel) (AdminDriverApplicationResponse, error) {
+	response := AdminDriverApplicationResponse{
+		DriverApplicationResponse: driverApplicationResponse(application),
+		FirstName:                 application.FirstName,
+		LastName:                  application.LastName,
+		Email:                     application.Email,
+		ReviewedBy:                application.ReviewedBy,
+	}
+
+	license, err := a.accountsRepo.GetLicenseByAccountID(ctx, application.AccountID)
+	if err != nil {
+		return response, err
+	}
+	if license != nil {
+		details := licenseResponse(license)
+		response.License = &details
+	}
+
+	vehicle, err := a.accountsRepo.GetVehicleByAccountID(ctx, application.AccountID)
+	if err != nil {
+		return response, err
+	}
+	if vehicle != nil {
+		response.Vehicle = &GetVehicleResponse{
+			ID:              vehicle.ID,
+			Make:            vehicle.Make,
+			Model:           vehicle.Model,
+			ModelYear:       vehicle.ModelYear,
+			Colour:          vehicle.Colour,
+			LicensePlate:    vehicle.LicensePlate,
+			Seats:           vehicle.Seats,
+			FuelType:        vehicle.FuelType,
+			FuelConsumption: vehicle.FuelConsumption,
+			Features:        vehicle.Features,
+		}
+	}
+
+	return response, nil
+}
+
+type ReviewDriverApplicationRequest struct {
+	AccountID int64                         `json:"account_id" validate:"required"`
+	Decision  domain.DriverApplicationEvent `json:"decision" validate:"required,oneof=approve suspend"`
+	// Shown to the driver; required when suspending
+	Reason *string `json:"reason" validate:"required_if=Decision suspend,omitempty,min=1,max=500"`
+}
+
+// reviewDriverApplicationHandler approves or suspends a driver and lets them know. Approving
+// needs the driver to still have a vehicle and a licence that lets them carry passengers;
+// suspending an approved driver stops them taking new passengers. Admin only.
+func (a *api) reviewDriverApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var req ReviewDriverApplicationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+	if account.Type != "admin" {
+		a.errorResponse(w, r, http.StatusForbidden, errors.New("admin access required"))
+		return
+	}
+
+	application, err := a.driverApplicationsRepo.GetDriverApplication(ctx, req.AccountID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if application == nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("driver application not found"))
+		return
+	}
+
+	if req.Decision == domain.DriverApplicationEventApprove {
+		if err := a.checkDriverCanApply(ctx, req.AccountID); err != nil {
+			a.errorResponse(w, r, http.StatusConflict, fmt.Errorf("the driver cannot be approved: %w", err))
+			return
+		}
+	}
+
+	application, err = a.driverApplicationsRepo.ReviewDriverApplication(ctx, req.AccountID, req.Decision, account.ID, req.Reason)
+	if err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+
+	a.logger.Info("Driver application reviewed",
+		zap.Int64("account_id", application.AccountID),
+		zap.String("status", string(application.Status)),
+		zap.String("admin", account.Email))
+
+	message := "Your driver application has been approved. You can now take passengers."
+	if application.Status == domain.DriverApplicationStatusSuspended {
+		message = fmt.Sprintf("Your driver account has been suspended: %s", *application.Reason)
+	}
+	a.queueDriverApplicationNotification(ctx, application, message)
+
+	response, err := a.adminDriverApplicationResponse(ctx, application)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (a *api) queueDriverApplicationNotification(ctx context.Context, application *domain.DriverApplicationDBModel, message string) {
+	notificationPayload := fmt.Sprintf(`{"status": "%s", "notification": "%s"}`, application.Status, domain.NotificationDriverApplication)
+	notification := &domain.NotificationDBModel{
+		NotificationType:    domain.NotificationTypeRideUpdates,
+		NotificationMessage: message,
+		AccountID:           application.AccountID,
+		Payload:             &notificationPayload,
+	}
+
+	_, err := a.notificationsRepo.CreateNotification(ctx, notification)
+	if err != nil {
+		a.logger.Error("Failed to create driver application notification",
+			zap.Error(err),
+			zap.Int64("account_id", application.AccountID))
+	}
+}
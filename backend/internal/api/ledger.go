@@ -0,0 +1,488 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Arjun113/nOPark/internal/domain"
+	"github.com/Arjun113/nOPark/internal/utils"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+type SettlementResponse struct {
+	ID        int64                   `json:"id"`
+	PayerID   int64                   `json:"payer_id"`
+	PayeeID   int64                   `json:"payee_id"`
+	RideID    *int64                  `json:"ride_id,omitempty"`
+	RequestID *int64                  `json:"request_id,omitempty"`
+	Amount    domain.Money            `json:"amount"`
+	Status    domain.SettlementStatus `json:"status"`
+	PaidAt    *time.Time              `json:"paid_at,omitempty"`
+	CreatedAt time.Time               `json:"created_at"`
+	UpdatedAt time.Time               `json:"updated_at"`
+}
+
+func settlementResponse(settlement *domain.SettlementDBModel) SettlementResponse {
+	return SettlementResponse{
+		ID:        settlement.ID,
+		PayerID:   settlement.PayerID,
+		PayeeID:   settlement.PayeeID,
+		RideID:    settlement.RideID,
+		RequestID: settlement.RequestID,
+		Amount:    settlement.Amount,
+		Status:    settlement.Status,
+		PaidAt:    settlement.PaidAt,
+		CreatedAt: settlement.CreatedAt,
+		UpdatedAt: settlement.UpdatedAt,
+	}
+}
+
+type LedgerEntryResponse struct {
+	ID           int64                   `json:"id"`
+	Kind         domain.LedgerEntryKind  `json:"kind"`
+	RideID       *int64                  `json:"ride_id,omitempty"`
+	RequestID    *int64                  `json:"request_id,omitempty"`
+	SettlementID *int64                  `json:"settlement_id,omitempty"`
+	RefundOf     *int64                  `json:"refund_of,omitempty"`
+	Description  string                  `json:"description"`
+	Postings     []LedgerPostingResponse `json:"postings"`
+	CreatedAt    time.Time               `json:"created_at"`
+}
+
+type LedgerPostingResponse struct {
+	AccountID int64        `json:"account_id"`
+	Amount    domain.Money `json:"amount"`
+}
+
+func ledgerEntryResponse(entry *domain.LedgerEntryDBModel) LedgerEntryResponse {
+	response := LedgerEntryResponse{
+		ID:           entry.ID,
+		Kind:         entry.Kind,
+		RideID:       entry.RideID,
+		RequestID:    entry.RequestID,
+		SettlementID: entry.SettlementID,
+		RefundOf:     entry.RefundOf,
+		Description:  entry.Description,
+		Postings:     make([]LedgerPostingResponse, len(entry.Postings)),
+		CreatedAt:    entry.CreatedAt,
+	}
+	for i, posting := range entry.Postings {
+		response.Postings[i] = LedgerPostingResponse{
+			AccountID: posting.AccountID,
+			Amount:    posting.Amount,
+		}
+	}
+	return response
+}
+
+type GetBalanceResponse struct {
+	// What you are owed overall; negative when you owe
+	Balance domain.Money `json:"balance"`
+	// Unpaid settlements either way
+	OwedToYou domain.Money `json:"owed_to_you"`
+	YouOwe    domain.Money `json:"you_owe"`
+}
+
+func (a *api) getBalanceHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+
+	balance, err := a.ledgerRepo.GetBalance(ctx, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	pending := domain.SettlementStatusPending
+	settlements, err := a.ledgerRepo.GetSettlements(ctx, account.ID, &pending)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := GetBalanceResponse{
+		Balance: balance,
+	}
+	for _, settlement := range settlements {
+		if settlement.PayeeID == account.ID {
+			response.OwedToYou += settlement.Amount
+		} else {
+			response.YouOwe += settlement.Amount
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+type GetStatementRequest struct {
+	Limit int64 `validate:"min=1,max=100"`
+}
+
+type StatementLineResponse struct {
+	EntryID        int64                  `json:"entry_id"`
+	Kind           domain.LedgerEntryKind `json:"kind"`
+	RideID         *int64                 `json:"ride_id,omitempty"`
+	RequestID      *int64                 `json:"request_id,omitempty"`
+	SettlementID   *int64                 `json:"settlement_id,omitempty"`
+	Description    string                 `json:"description"`
+	Amount         domain.Money           `json:"amount"`
+	BalanceAfter   domain.Money           `json:"balance_after"`
+	CounterpartyID *int64                 `json:"counterparty_id,omitempty"`
+	CreatedAt      time.Time              `json:"created_at"`
+}
+
+type GetStatementResponse struct {
+	Balance domain.Money            `json:"balance"`
+	Lines   []StatementLineResponse `json:"lines"`
+	// Pass as cursor to get the next page. Absent on the last page.
+	NextCursor *int64 `json:"next_cursor,omitempty"`
+}
+
+// getStatementHandler pages through every posting to the account, newest first, with the
+// balance each one left. Filter with from and to (RFC3339).
+func (a *api) getStatementHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	from, err := utils.TimeFromQueryParam(r, "from", true)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	to, err := utils.TimeFromQueryParam(r, "to", true)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	cursor, err := utils.IntFromQueryParam(r, "cursor", true)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	limit, err := utils.IntFromQueryParam(r, "limit", true)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	reqParams := GetStatementRequest{
+		Limit: domain.DefaultStatementLimit,
+	}
+	if limit != nil {
+		reqParams.Limit = *limit
+	}
+	if err := a.validateRequest(reqParams); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+
+	balance, err := a.ledgerRepo.GetBalance(ctx, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	lines, err := a.ledgerRepo.GetStatement(ctx, domain.StatementFilter{
+		AccountID:       account.ID,
+		From:            from,
+		To:              to,
+		BeforePostingID: cursor,
+		// One extra tells us whether there is another page
+		Limit: int(reqParams.Limit) + 1,
+	})
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := GetStatementResponse{
+		Balance: balance,
+		Lines:   make([]StatementLineResponse, 0, len(lines)),
+	}
+	if len(lines) > int(reqParams.Limit) {
+		lines = lines[:reqParams.Limit]
+		response.NextCursor = &lines[len(lines)-1].PostingID
+	}
+	for _, line := range lines {
+		response.Lines = append(response.Lines, StatementLineResponse{
+			EntryID:        line.EntryID,
+			Kind:           line.Kind,
+			RideID:         line.RideID,
+			RequestID:      line.RequestID,
+			SettlementID:   line.SettlementID,
+			Description:    line.Description,
+			Amount:         line.Amount,
+			BalanceAfter:   line.BalanceAfter,
+			CounterpartyID: line.CounterpartyID,
+			CreatedAt:      line.CreatedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+type GetSettlementsRequest struct {
+	Status string `validate:"omitempty,oneof=pending paid cancelled"`
+}
+
+type GetSettlementsResponse struct {
+	Settlements []SettlementResponse `json:"settlements"`
+}
+
+// getSettlementsHandler lists what the account owes and is owed, newest first
+func (a *api) getSettlementsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	status, _ := utils.StringFromQueryParam(r, "status", true)
+	reqParams := GetSettlementsRequest{}
+	if status != nil {
+		reqParams.Status = *status
+	}
+	if err := a.validateRequest(reqParams); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+
+	var filter *domain.SettlementStatus
+	if reqParams.Status != "" {
+		s := domain.SettlementStatus(reqParams.Status)
+		filter = &s
+	}
+	settlements, err := a.ledgerRepo.GetSettlements(ctx, account.ID, filter)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := GetSettlementsResponse{
+		Settlements: make([]SettlementResponse, len(settlements)),
+	}
+	for i, settlement := range settlements {
+		response.Settlements[i] = settlementResponse(settlement)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+type MarkSettlementPaidRequest struct {
+	SettlementID int64 `json:"settlement_id" validate:"required"`
+}
+
+// markSettlementPaidHandler is how the payee confirms they have been paid
+func (a *api) markSettlementPaidHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var req MarkSettlementPaidRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+
+	settlement, err := a.ledgerRepo.GetSettlementByID(ctx, req.SettlementID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("settlement not found"))
+		return
+	}
+	if settlement.PayeeID != account.ID {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("only the payee can mark a settlement paid"))
+		return
+	}
+
+	settlement, err = a.ledgerRepo.MarkSettlementPaid(ctx, settlement.ID)
+	if err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+
+	a.queueLedgerNotification(ctx, settlement.PayerID, domain.NotificationSettlementPaid, settlement.ID,
+		fmt.Sprintf("%s %s has received your payment of $%s.", account.FirstName, account.LastName, settlement.Amount))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(settlementResponse(settlement))
+}
+
+type RefundRequest struct {
+	RequestID int64 `json:"request_id" validate:"required"`
+	// All that is left of the charge when not given
+	Amount *domain.Money `json:"amount" validate:"omitempty,gt=0"`
+	Reason string        `json:"reason" validate:"required,max=200"`
+}
+
+// refundHandler gives a passenger back some or all of what they were charged for a ride.
+// Only the ride's driver or an admin can refund.
+func (a *api) refundHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var req RefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+
+	charge, remaining, err := a.ledgerRepo.GetRideCharge(ctx, req.RequestID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("no charge for this request"))
+		return
+	}
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	var passengerID, driverID int64
+	for _, posting := range charge.Postings {
+		if posting.Amount < 0 {
+			passengerID = posting.AccountID
+		} else {
+			driverID = posting.AccountID
+		}
+	}
+	if account.ID != driverID && account.Type != "admin" {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("only the driver can refund this ride"))
+		return
+	}
+
+	amount := remaining
+	if req.Amount != nil {
+		amount = *req.Amount
+	}
+	refund, err := a.ledgerRepo.Refund(ctx, charge.ID, amount, req.Reason, account.ID)
+	if err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+
+	a.queueLedgerNotification(ctx, passengerID, domain.NotificationRefundIssued, refund.ID,
+		fmt.Sprintf("You have been refunded $%s for a ride.", amount))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ledgerEntryResponse(refund))
+}
+
+type CreateAdjustmentRequest struct {
+	FromAccountID int64        `json:"from_account_id" validate:"required"`
+	ToAccountID   int64        `json:"to_account_id" validate:"required,nefield=FromAccountID"`
+	Amount        domain.Money `json:"amount" validate:"required,gt=0"`
+	Reason        string       `json:"reason" validate:"required,max=200"`
+}
+
+// createAdjustmentHandler lets an admin correct balances by moving money between two users
+func (a *api) createAdjustmentHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+	if account.Type != "admin" {
+		a.errorResponse(w, r, http.StatusForbidden, errors.New("admin access required"))
+		return
+	}
+
+	var req CreateAdjustmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	for _, id := range []int64{req.FromAccountID, req.ToAccountID} {
+		if _, err := a.accountsRepo.GetAccountByID(ctx, id); err != nil {
+			a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("account %d not found", id))
+			return
+		}
+	}
+
+	adjustment, err := a.ledgerRepo.PostAdjustment(ctx, req.FromAccountID, req.ToAccountID, req.Amount, req.Reason, account.ID)
+	if err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+
+	a.logger.Info("Ledger adjustment posted",
+		zap.Int64("entry_id", adjustment.ID),
+		zap.Int64("from_account_id", req.FromAccountID),
+		zap.Int64("to_account_id", req.ToAccountID),
+		zap.String("amount", req.Amount.String()),
+		zap.String("admin", account.Email))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ledgerEntryResponse(adjustment))
+}
+
+// queueLedgerNotification tells someone money has moved, logging rather than failing on error
+func (a *api) queueLedgerNotification(ctx context.Context, accountID int64, notificationType string, id int64, message string) {
+	notificationPayload := fmt.Sprintf(`{"id": %d, "notification": "%s"}`, id, notificationType)
+	notification := &domain.NotificationDBModel{
+		NotificationType:    domain.NotificationTypeRideUpdates,
+		NotificationMessage: message,
+		AccountID:           accountID,
+		Payload:             &notificationPayload,
+	}
+
+	_, err := a.notificationsRepo.CreateNotification(ctx, notification)
+	if err != nil {
+		a.logger.Error("Failed to create ledger notification",
+			zap.Error(err),
+			zap.Int64("account_id", accountID),
+			zap.Int64("id", id))
+	}
+}
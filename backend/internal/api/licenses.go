@@ -0,0 +1,265 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Arjun113/nOPark/internal/domain"
+)
+
+type LicenseRequest struct {
+	LicenseNo   string                    `json:"license_no" validate:"required"`
+	CardNumber  string                    `json:"card_number" validate:"required"`
+	Type        domain.LicenseType        `json:"license_type" validate:"required,oneof=l p1 p2 full"`
+	Conditions  []domain.LicenseCondition `json:"conditions,omitempty" validate:"omitempty,unique,dive,oneof=A I S V X Z"`
+	DateOfIssue string                    `json:"date_of_issue" validate:"required,datetime=2006-01-02"`
+	Expiry      string                    `json:"expiry" validate:"required,datetime=2006-01-02"`
+}
+
+type LicenseResponse struct {
+	ID          int64                     `json:"id"`
+	LicenseNo   string                    `json:"license_no"`
+	CardNumber  string                    `json:"card_number"`
+	Type        domain.LicenseType        `json:"license_type"`
+	Conditions  []domain.LicenseCondition `json:"conditions"`
+	DateOfIssue string                    `json:"date_of_issue"`
+	Expiry      string                    `json:"expiry"`
+	// Whether the licence lets the driver take passengers at all, and why not
+	CanDrive bool    `json:"can_drive"`
+	Reason   *string `json:"reason,omitempty"`
+}
+
+// Licence dates are days in Melbourne
+var licenseLocation = func() *time.Location {
+	location, err := time.LoadLocation(domain.LicenseTimezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}()
+
+func licenseNow() time.Time {
+	return time.Now().In(licenseLocation)
+}
+
+func licenseResponse(license *domain.LicenseDBModel) LicenseResponse {
+	response := LicenseResponse{
+		ID:          license.ID,
+		LicenseNo:   license.LicenseNo,
+		CardNumber:  license.CardNumber,
+		Type:        license.Type,
+		Conditions:  license.Conditions,
+		DateOfIssue: license.DateOfIssue.Format(time.DateOnly),
+		Expiry:      license.Expiry.Format(time.DateOnly),
+		CanDrive:    true,
+	}
+	if err := domain.CheckLicense(license, licenseNow()); err != nil {
+		reason := err.Error()
+		response.CanDrive = false
+		response.Reason = &reason
+	}
+	return response
+}
+
+// licenseFromRequest validates the request's dates and builds the driver's licence from it
+func licenseFromRequest(req *LicenseRequest, accountID int64) (*domain.LicenseDBModel, error) {
+	dateOfIssue, err := time.Parse(time.DateOnly, req.DateOfIssue)
+	if err != nil {
+		return nil, err
+	}
+	expiry, err := time.Parse(time.DateOnly, req.Expiry)
+	if err != nil {
+		return nil, err
+	}
+	if !expiry.After(dateOfIssue) {
+		return nil, fmt.Errorf("expiry must be after date_of_issue")
+	}
+	if dateOfIssue.Format(time.DateOnly) > licenseNow().Format(time.DateOnly) {
+		return nil, fmt.Errorf("date_of_issue cannot be in the future")
+	}
+
+	return &domain.LicenseDBModel{
+		LicenseNo:   req.LicenseNo,
+		CardNumber:  req.CardNumber,
+		Type:        req.Type,
+		Conditions:  req.Conditions,
+		DateOfIssue: dateOfIssue,
+		Expiry:      expiry,
+		AccountID:   accountID,
+	}, nil
+}
+
+func (a *api) createLicenseHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var req LicenseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+	if account.Type != "driver" {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("only drivers can add a licence"))
+		return
+	}
+
+	license, err := licenseFromRequest(&req, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	existing, err := a.accountsRepo.GetLicenseByAccountID(ctx, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if existing != nil {
+		a.errorResponse(w, r, http.StatusConflict, fmt.Errorf("you have already added a licence, update it instead"))
+		return
+	}
+
+	created, err := a.accountsRepo.CreateLicense(ctx, license)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(licenseResponse(created))
+}
+
+func (a *api) getLicenseHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+	if account.Type != "driver" {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("only drivers have a licence"))
+		return
+	}
+
+	license, err := a.accountsRepo.GetLicenseByAccountID(ctx, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if license == nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("licence not found for user"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(licenseResponse(license))
+}
+
+// updateLicenseHandler replaces the driver's licence details, e.g. when they move from P1 to P2
+func (a *api) updateLicenseHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var req LicenseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+	if account.Type != "driver" {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("only drivers have a licence"))
+		return
+	}
+
+	license, err := licenseFromRequest(&req, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	updated, err := a.accountsRepo.UpdateLicense(ctx, license)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if updated == nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("licence not found for user"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(licenseResponse(updated))
+}
+
+func (a *api) deleteLicenseHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+
+	if err := a.accountsRepo.DeleteLicense(ctx, account.ID); err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkDriverLicense returns why the driver's licence does not let them carry the parties
+// together, or nil if it does. Each party is a passenger account and how many travel with them.
+func (a *api) checkDriverLicense(ctx context.Context, driverID int64, passengerIDs []int64, partySizes []int) error {
+	license, err := a.accountsRepo.GetLicenseByAccountID(ctx, driverID)
+	if err != nil {
+		return err
+	}
+	now := licenseNow()
+	if err := domain.CheckLicense(license, now); err != nil {
+		return err
+	}
+
+	parties := make([]domain.PassengerParty, len(passengerIDs))
+	for i, passengerID := range passengerIDs {
+		passenger, err := a.accountsRepo.GetAccountByID(ctx, passengerID)
+		if err != nil {
+			return err
+		}
+		parties[i] = domain.PassengerParty{PartySize: partySizes[i]}
+		if passenger != nil {
+			parties[i].DateOfBirth = passenger.DateOfBirth
+		}
+	}
+	return license.CheckPassengers(parties, now)
+}
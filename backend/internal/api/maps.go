@@ -3,11 +3,13 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/Arjun113/nOPark/internal/domain"
 	"github.com/Arjun113/nOPark/internal/utils"
+	"github.com/jackc/pgx/v5"
 )
 
 type GetRouteRequest struct {
@@ -15,16 +17,33 @@ type GetRouteRequest struct {
 	StartLng float64 `json:"start_lng" validate:"required,min=-180,max=180"`
 	EndLat   float64 `json:"end_lat" validate:"required,min=-90,max=90"`
 	EndLng   float64 `json:"end_lng" validate:"required,min=-180,max=180"`
+	// Keep off toll roads where there is a sensible way around
+	AvoidTolls bool `json:"avoid_tolls"`
 }
 
-type GetRouteResponse struct {
-	StartLat float64 `json:"start_lat"`
-	StartLng float64 `json:"start_lng"`
-	EndLat   float64 `json:"end_lat"`
-	EndLng   float64 `json:"end_lng"`
+// RouteTollResponse is how far, in metres, a route runs along a toll road
+type RouteTollResponse struct {
+	Road     string  `json:"road"`
 	Distance float64 `json:"distance"`
-	Duration int64   `json:"duration"`
-	Polyline string  `json:"polyline"`
+}
+
+type GetRouteResponse struct {
+	StartLat float64             `json:"start_lat"`
+	StartLng float64             `json:"start_lng"`
+	EndLat   float64             `json:"end_lat"`
+	EndLng   float64             `json:"end_lng"`
+	Distance float64             `json:"distance"`
+	Duration int64               `json:"duration"`
+	Polyline string              `json:"polyline"`
+	Tolls    []RouteTollResponse `json:"tolls"`
+}
+
+func routeTollsResponse(tolls []domain.RouteToll) []RouteTollResponse {
+	response := make([]RouteTollResponse, len(tolls))
+	for i, toll := range tolls {
+		response[i] = RouteTollResponse{Road: toll.Road, Distance: toll.Distance}
+	}
+	return response
 }
 
 func (a *api) getRouteHandler(w http.ResponseWriter, r *http.Request) {
@@ -37,9 +56,10 @@ func (a *api) getRouteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	route, err := a.mapsRepo.GetDirectRoute(ctx,
+	route, err := a.mapsRepo.GetDirectRouteWithOptions(ctx,
 		domain.Coordinates{Lat: req.StartLat, Lon: req.StartLng},
 		domain.Coordinates{Lat: req.EndLat, Lon: req.EndLng},
+		domain.RouteOptions{AvoidTolls: req.AvoidTolls},
 	)
 
 	if err != nil {
@@ -55,6 +75,7 @@ func (a *api) getRouteHandler(w http.ResponseWriter, r *http.Request) {
 		Distance: route.Distance,
 		Duration: route.Duration,
 		Polyline: route.Polyline,
+		Tolls:    routeTollsResponse(route.Tolls),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -85,11 +106,15 @@ func (a *api) getRouteForRideHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ride, proposals, err := a.ridesRepo.GetRideAndProposals(ctx, reqParams.RideID)
-	if err != nil {
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		a.errorResponse(w, r, http.StatusInternalServerError, err)
 		return
 	}
-	if ride.Status != "in_progress" {
+	if ride == nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("ride not found"))
+		return
+	}
+	if ride.Status != domain.RideStatusInProgress {
 		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("ride is not in progress"))
 		return
 	}
@@ -115,13 +140,14 @@ func (a *api) getRouteForRideHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Process all accepted proposals to collect waypoints
 	for _, prop := range proposals {
-		if prop.Status == "accepted" {
+		if prop.Status == domain.ProposalStatusAccepted {
 			request, err := a.ridesRepo.GetRequestByID(ctx, prop.RequestID)
 			if err != nil {
 				a.errorResponse(w, r, http.StatusInternalServerError, err)
 				return
 			}
-			if request.Visited {
+			// Only passengers still waiting to be picked up need a stop
+			if request.Status != domain.RequestStatusMatched {
 				continue
 			}
 
@@ -129,10 +155,17 @@ func (a *api) getRouteForRideHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	route, err := a.mapsRepo.GetRouteFromWaypoints(ctx,
+	routeOptions, err := a.accountsRepo.GetDriverRouteOptions(ctx, driver.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	route, err := a.mapsRepo.GetRouteFromWaypointsWithOptions(ctx,
 		domain.Coordinates{Lat: *driver.CurrentLatitude, Lon: *driver.CurrentLongitude},
 		waypoints,
 		destination,
+		routeOptions,
 	)
 
 	if err != nil {
@@ -148,6 +181,7 @@ func (a *api) getRouteForRideHandler(w http.ResponseWriter, r *http.Request) {
 		Distance: route.Distance,
 		Duration: route.Duration,
 		Polyline: route.Polyline,
+		Tolls:    routeTollsResponse(route.Tolls),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
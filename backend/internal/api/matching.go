@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Arjun113/nOPark/internal/domain"
+	"github.com/Arjun113/nOPark/internal/utils"
+)
+
+type MatchedRequestResponse struct {
+	ID               int64              `json:"id"`
+	PickupLocation   string             `json:"pickup_location"`
+	PickupLatitude   float64            `json:"pickup_latitude"`
+	PickupLongitude  float64            `json:"pickup_longitude"`
+	DropoffLocation  string             `json:"dropoff_location"`
+	DropoffLatitude  float64            `json:"dropoff_latitude"`
+	DropoffLongitude float64            `json:"dropoff_longitude"`
+	Compensation     float64            `json:"compensation"`
+	PartySize        int                `json:"party_size"`
+	WindowKind       *domain.WindowKind `json:"window_kind,omitempty"`
+	WindowStart      *time.Time         `json:"window_start,omitempty"`
+	WindowEnd        *time.Time         `json:"window_end,omitempty"`
+	// Detour for this request on its own
+	DetourDuration        int64   `json:"detour_duration"`
+	DetourDistance        float64 `json:"detour_distance"`
+	CompensationPerMinute float64 `json:"compensation_per_minute"`
+}
+
+type MatchBundleResponse struct {
+	RequestIDs            []int64                  `json:"request_ids"`
+	Requests              []MatchedRequestResponse `json:"requests"`
+	Compensation          domain.Money             `json:"compensation"`
+	DetourDuration        int64                    `json:"detour_duration"`
+	DetourDistance        float64                  `json:"detour_distance"`
+	CompensationPerMinute float64                  `json:"compensation_per_minute"`
+	Polyline              string                   `json:"polyline"`
+}
+
+type GetMatchesResponse struct {
+	Bundle MatchBundleResponse `json:"bundle"`
+	// Every feasible request, best compensation per detour minute first
+	Candidates []MatchedRequestResponse `json:"candidates"`
+}
+
+func matchedRequestResponse(candidate *domain.MatchCandidate) MatchedRequestResponse {
+	request := candidate.Request
+	return MatchedRequestResponse{
+		ID:                    request.ID,
+		PickupLocation:        request.PickupLocation,
+		PickupLatitude:        request.PickupLatitude,
+		PickupLongitude:       request.PickupLongitude,
+		DropoffLocation:       request.DropoffLocation,
+		DropoffLatitude:       request.DropoffLatitude,
+		DropoffLongitude:      request.DropoffLongitude,
+		Compensation:          request.Compensation,
+		PartySize:             request.PartySize,
+		WindowKind:            request.WindowKind,
+		WindowStart:           request.WindowStart,
+		WindowEnd:             request.WindowEnd,
+		DetourDuration:        candidate.DetourDuration,
+		DetourDistance:        candidate.DetourDistance,
+		CompensationPerMinute: candidate.Score(),
+	}
+}
+
+// getMatchesHandler suggests which open requests the driver should draft together. The
+// bundle's request_ids can be passed straight to the draft endpoint.
+func (a *api) getMatchesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	dropoffLat, err := utils.FloatFromQueryParam(r, "dropoff_lat", false)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	dropoffLon, err := utils.FloatFromQueryParam(r, "dropoff_lon", false)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	seats, _ := utils.IntFromQueryParam(r, "seats", true)
+	maxDetour, _ := utils.IntFromQueryParam(r, "max_detour_s", true)
+	departureTime, err := utils.TimeFromQueryParam(r, "departure_time", true)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+	if account.Type != "driver" {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("only drivers can get matches"))
+		return
+	}
+	if err := a.checkApprovedDriver(ctx, account.ID); err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+	if account.CurrentLatitude == nil || account.CurrentLongitude == nil {
+		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("current location required to calculate detours"))
+		return
+	}
+
+	// Drivers fill their vehicle unless they say otherwise
+	vehicleSeats, err := a.matchingService.VehicleSeats(ctx, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	params := domain.MatchParams{
+		Start:     domain.Coordinates{Lat: *account.CurrentLatitude, Lon: *account.CurrentLongitude},
+		Dest:      domain.Coordinates{Lat: *dropoffLat, Lon: *dropoffLon},
+		Seats:     vehicleSeats,
+		MaxDetour: domain.DefaultMaxMatchDetour,
+		DepartAt:  time.Now(),
+		DriverID:  account.ID,
+	}
+	if seats != nil && *seats > 0 {
+		params.Seats = min(int(*seats), vehicleSeats)
+	}
+	if maxDetour != nil && *maxDetour >= 0 {
+		params.MaxDetour = time.Duration(*maxDetour) * time.Second
+	}
+	if departureTime != nil {
+		params.DepartAt = *departureTime
+	}
+
+	bundle, candidates, err := a.matchingService.Match(ctx, params)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := GetMatchesResponse{
+		Bundle: MatchBundleResponse{
+			RequestIDs:            bundle.RequestIDs(),
+			Requests:              make([]MatchedRequestResponse, len(bundle.Requests)),
+			Compensation:          bundle.Compensation,
+			DetourDuration:        bundle.DetourDuration,
+			DetourDistance:        bundle.DetourDistance,
+			CompensationPerMinute: bundle.Score(),
+			Polyline:              bundle.Route.Polyline,
+		},
+		Candidates: make([]MatchedRequestResponse, len(candidates)),
+	}
+	for i, candidate := range bundle.Requests {
+		response.Bundle.Requests[i] = matchedRequestResponse(candidate)
+	}
+	for i, candidate := range candidates {
+		response.Candidates[i] = matchedRequestResponse(candidate)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
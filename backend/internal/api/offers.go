@@ -0,0 +1,718 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Arjun113/nOPark/internal/domain"
+	"github.com/Arjun113/nOPark/internal/utils"
+	"go.uber.org/zap"
+)
+
+type CreateOfferRequest struct {
+	OriginLocation       string       `json:"origin_location" validate:"required,max=100"`
+	OriginLatitude       float64      `json:"origin_latitude" validate:"required,latitude"`
+	OriginLongitude      float64      `json:"origin_longitude" validate:"required,longitude"`
+	DestinationLocation  string       `json:"destination_location" validate:"required,max=100"`
+	DestinationLatitude  float64      `json:"destination_latitude" validate:"required,latitude"`
+	DestinationLongitude float64      `json:"destination_longitude" validate:"required,longitude"`
+	DepartureTime        time.Time    `json:"departure_time" validate:"required"`
+	Seats                int          `json:"seats" validate:"required,min=1,max=8"`
+	PricePerSeat         domain.Money `json:"price_per_seat" validate:"gte=0"`
+}
+
+type OfferResponse struct {
+	ID                   int64              `json:"id"`
+	DriverID             int64              `json:"driver_id"`
+	OriginLocation       string             `json:"origin_location"`
+	OriginLatitude       float64            `json:"origin_latitude"`
+	OriginLongitude      float64            `json:"origin_longitude"`
+	DestinationLocation  string             `json:"destination_location"`
+	DestinationLatitude  float64            `json:"destination_latitude"`
+	DestinationLongitude float64            `json:"destination_longitude"`
+	Polyline             string             `json:"polyline"`
+	Distance             float64            `json:"distance"`
+	Duration             int64              `json:"duration"`
+	DepartureTime        time.Time          `json:"departure_time"`
+	Seats                int                `json:"seats"`
+	SeatsFree            int                `json:"seats_free"`
+	PricePerSeat         domain.Money       `json:"price_per_seat"`
+	Status               domain.OfferStatus `json:"status"`
+	RideID               *int64             `json:"ride_id,omitempty"`
+	// Only set when searching, for the searching passenger's pickup and dropoff
+	DetourDuration *int64   `json:"detour_duration,omitempty"`
+	DetourDistance *float64 `json:"detour_distance,omitempty"`
+	CreatedAt      string   `json:"created_at"`
+}
+
+func offerResponse(offer *domain.OfferDBModel) OfferResponse {
+	return OfferResponse{
+		ID:                   offer.ID,
+		DriverID:             offer.DriverID,
+		OriginLocation:       offer.OriginLocation,
+		OriginLatitude:       offer.OriginLatitude,
+		OriginLongitude:      offer.OriginLongitude,
+		DestinationLocation:  offer.DestinationLocation,
+		DestinationLatitude:  offer.DestinationLatitude,
+		DestinationLongitude: offer.DestinationLongitude,
+		Polyline:             offer.RoutePolyline,
+		Distance:             offer.RouteDistance,
+		Duration:             offer.RouteDuration,
+		DepartureTime:        offer.DepartureTime,
+		Seats:                offer.Seats,
+		SeatsFree:            offer.SeatsFree(),
+		PricePerSeat:         offer.PricePerSeat,
+		Status:               offer.Status,
+		RideID:               offer.RideID,
+		CreatedAt:            offer.CreatedAt,
+	}
+}
+
+func (a *api) createOfferHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var req CreateOfferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	now := time.Now()
+	if req.DepartureTime.Before(now) {
+		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("departure_time must be in the future"))
+		return
+	}
+	if req.DepartureTime.After(now.Add(domain.MaxScheduleAhead)) {
+		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("departure_time must be within %s", domain.MaxScheduleAhead))
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+	if account.Type != "driver" {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("only drivers can publish offers"))
+		return
+	}
+	if err := a.checkApprovedDriver(ctx, account.ID); err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+	// Passengers are only known once they book, so the peer passenger limit is checked on approval
+	if err := a.checkDriverLicense(ctx, account.ID, nil, nil); err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+	vehicleSeats, err := a.matchingService.VehicleSeats(ctx, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if req.Seats > vehicleSeats {
+		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("your vehicle only has %d seats", vehicleSeats))
+		return
+	}
+
+	zones, err := a.zonesRepo.GetZones(ctx)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if !zones.InServiceArea(domain.Coordinates{Lat: req.OriginLatitude, Lon: req.OriginLongitude}) ||
+		!zones.InServiceArea(domain.Coordinates{Lat: req.DestinationLatitude, Lon: req.DestinationLongitude}) {
+		a.errorResponse(w, r, http.StatusBadRequest, domain.ErrOutsideServiceArea)
+		return
+	}
+
+	routeOptions, err := a.accountsRepo.GetDriverRouteOptions(ctx, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	route, err := a.mapsRepo.GetDirectRouteWithOptions(ctx,
+		domain.Coordinates{Lat: req.OriginLatitude, Lon: req.OriginLongitude},
+		domain.Coordinates{Lat: req.DestinationLatitude, Lon: req.DestinationLongitude},
+		routeOptions,
+	)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, fmt.Errorf("error calculating offer route"))
+		return
+	}
+
+	offer, err := a.offersRepo.CreateOffer(ctx, &domain.OfferDBModel{
+		DriverID:             account.ID,
+		OriginLocation:       req.OriginLocation,
+		OriginLatitude:       req.OriginLatitude,
+		OriginLongitude:      req.OriginLongitude,
+		DestinationLocation:  req.DestinationLocation,
+		DestinationLatitude:  req.DestinationLatitude,
+		DestinationLongitude: req.DestinationLongitude,
+		RoutePolyline:        route.Polyline,
+		RouteDistance:        route.Distance,
+		RouteDuration:        route.Duration,
+		DepartureTime:        req.DepartureTime,
+		Seats:                req.Seats,
+		PricePerSeat:         req.PricePerSeat,
+	})
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(offerResponse(offer))
+}
+
+type GetOffersResponse struct {
+	Offers []OfferResponse `json:"offers"`
+}
+
+// getOffersHandler lists a driver's own offers, or searches open offers for a passenger.
+// Passengers only see offers whose detour through their pickup and dropoff is within max_detour_s.
+func (a *api) getOffersHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+
+	response := GetOffersResponse{
+		Offers: make([]OfferResponse, 0),
+	}
+
+	if account.Type == "driver" {
+		offers, err := a.offersRepo.GetOffersByDriverID(ctx, account.ID)
+		if err != nil {
+			a.errorResponse(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		for _, offer := range offers {
+			response.Offers = append(response.Offers, offerResponse(offer))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	pickupLat, err := utils.FloatFromQueryParam(r, "pickup_lat", false)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	pickupLon, err := utils.FloatFromQueryParam(r, "pickup_lon", false)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	dropoffLat, err := utils.FloatFromQueryParam(r, "dropoff_lat", false)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	dropoffLon, err := utils.FloatFromQueryParam(r, "dropoff_lon", false)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	departureAfter, err := utils.TimeFromQueryParam(r, "departure_after", true)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	departureBefore, err := utils.TimeFromQueryParam(r, "departure_before", true)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	maxDetour, _ := utils.IntFromQueryParam(r, "max_detour_s", true)
+	seats, _ := utils.IntFromQueryParam(r, "seats", true)
+
+	now := time.Now()
+	after := now
+	if departureAfter != nil && departureAfter.After(now) {
+		after = *departureAfter
+	}
+	before := now.Add(domain.MaxScheduleAhead)
+	if departureBefore != nil {
+		before = *departureBefore
+	}
+	maxDetourS := int64(domain.DefaultMaxOfferDetour.Seconds())
+	if maxDetour != nil {
+		maxDetourS = *maxDetour
+	}
+	seatsWanted := 1
+	if seats != nil && *seats > 0 {
+		seatsWanted = int(*seats)
+	}
+
+	offers, err := a.offersRepo.SearchOffers(ctx, after, before, seatsWanted)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	pickup := domain.Coordinates{Lat: *pickupLat, Lon: *pickupLon}
+	dropoff := domain.Coordinates{Lat: *dropoffLat, Lon: *dropoffLon}
+	for _, offer := range offers {
+		if offer.DriverID == account.ID {
+			continue
+		}
+
+		detourDuration, detourDistance, err := a.offerDetour(ctx, offer, pickup, dropoff)
+		if err != nil {
+			a.errorResponse(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if detourDuration > maxDetourS {
+			continue
+		}
+
+		result := offerResponse(offer)
+		result.DetourDuration = &detourDuration
+		result.DetourDistance = &detourDistance
+		response.Offers = append(response.Offers, result)
+	}
+
+	// Offers that barely leave their route for this passenger come first
+	sort.SliceStable(response.Offers, func(i, j int) bool {
+		return *response.Offers[i].DetourDuration < *response.Offers[j].DetourDuration
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// offerDetour returns the extra seconds and metres the offer's route takes to pass through pickup and dropoff
+func (a *api) offerDetour(ctx context.Context, offer *domain.OfferDBModel, pickup, dropoff domain.Coordinates) (int64, float64, error) {
+	routeOptions, err := a.accountsRepo.GetDriverRouteOptions(ctx, offer.DriverID)
+	if err != nil {
+		return 0, 0, err
+	}
+	detourRoute, err := a.mapsRepo.GetMultistopRouteWithOptions(ctx,
+		domain.Coordinates{Lat: offer.OriginLatitude, Lon: offer.OriginLongitude},
+		[]domain.Coordinates{pickup, dropoff},
+		domain.Coordinates{Lat: offer.DestinationLatitude, Lon: offer.DestinationLongitude},
+		routeOptions,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return max(detourRoute.Duration-offer.RouteDuration, 0), max(detourRoute.Distance-offer.RouteDistance, 0), nil
+}
+
+type CancelOfferRequest struct {
+	OfferID int64  `json:"offer_id" validate:"required"`
+	Reason  string `json:"reason" validate:"required,max=250"`
+}
+
+func (a *api) cancelOfferHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var req CancelOfferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+
+	offer, err := a.offersRepo.GetOfferByID(ctx, req.OfferID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("offer not found"))
+		return
+	}
+	if offer.DriverID != account.ID {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("you are not authorized to cancel this offer"))
+		return
+	}
+
+	offer, declined, err := a.offersRepo.CancelOffer(ctx, offer.ID)
+	if err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+
+	for _, booking := range declined {
+		a.queueBookingNotification(ctx, booking.PassengerID, booking, "The driver cancelled the offer you asked to join.")
+	}
+
+	// Passengers already on board lose their ride as well
+	if offer.RideID != nil {
+		ride, err := a.ridesRepo.GetRideByID(ctx, *offer.RideID)
+		if err != nil {
+			a.errorResponse(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if ride.Status.Can(domain.RideEventCancel) {
+			cancelled, err := a.ridesRepo.CancelRide(ctx, ride.ID, domain.Cancellation{
+				CancelledBy: account.ID,
+				Reason:      req.Reason,
+			})
+			if err != nil {
+				a.domainErrorResponse(w, r, err)
+				return
+			}
+			a.notifyRideCancelled(ctx, ride.ID, cancelled)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type JoinOfferRequest struct {
+	OfferID          int64   `json:"offer_id" validate:"required"`
+	PickupLocation   string  `json:"pickup_location,omitempty" validate:"max=100"`
+	PickupLatitude   float64 `json:"pickup_latitude" validate:"required,latitude"`
+	PickupLongitude  float64 `json:"pickup_longitude" validate:"required,longitude"`
+	DropoffLocation  string  `json:"dropoff_location" validate:"required,max=100"`
+	DropoffLatitude  float64 `json:"dropoff_latitude" validate:"required,latitude"`
+	DropoffLongitude float64 `json:"dropoff_longitude" validate:"required,longitude"`
+	Seats            int     `json:"seats,omitempty" validate:"omitempty,min=1,max=8"`
+}
+
+type BookingResponse struct {
+	ID               int64                `json:"id"`
+	OfferID          int64                `json:"offer_id"`
+	PassengerID      int64                `json:"passenger_id"`
+	PickupLocation   string               `json:"pickup_location"`
+	PickupLatitude   float64              `json:"pickup_latitude"`
+	PickupLongitude  float64              `json:"pickup_longitude"`
+	DropoffLocation  string               `json:"dropoff_location"`
+	DropoffLatitude  float64              `json:"dropoff_latitude"`
+	DropoffLongitude float64              `json:"dropoff_longitude"`
+	Seats            int                  `json:"seats"`
+	DetourDuration   int64                `json:"detour_duration"`
+	Status           domain.BookingStatus `json:"status"`
+	RequestID        *int64               `json:"request_id,omitempty"`
+	CreatedAt        string               `json:"created_at"`
+}
+
+func bookingResponse(booking *domain.BookingDBModel) BookingResponse {
+	return BookingResponse{
+		ID:               booking.ID,
+		OfferID:          booking.OfferID,
+		PassengerID:      booking.PassengerID,
+		PickupLocation:   booking.PickupLocation,
+		PickupLatitude:   booking.PickupLatitude,
+		PickupLongitude:  booking.PickupLongitude,
+		DropoffLocation:  booking.DropoffLocation,
+		DropoffLatitude:  booking.DropoffLatitude,
+		DropoffLongitude: booking.DropoffLongitude,
+		Seats:            booking.Seats,
+		DetourDuration:   booking.DetourDuration,
+		Status:           booking.Status,
+		RequestID:        booking.RequestID,
+		CreatedAt:        booking.CreatedAt,
+	}
+}
+
+func (a *api) joinOfferHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var req JoinOfferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if req.Seats == 0 {
+		req.Seats = 1
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+	if account.Type != "passenger" {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("only passengers can join offers"))
+		return
+	}
+
+	offer, err := a.offersRepo.GetOfferByID(ctx, req.OfferID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("offer not found"))
+		return
+	}
+	if offer.Status != domain.OfferStatusOpen || offer.DepartureTime.Before(time.Now()) {
+		a.errorResponse(w, r, http.StatusConflict, fmt.Errorf("offer is no longer taking bookings"))
+		return
+	}
+	if req.Seats > offer.SeatsFree() {
+		a.domainErrorResponse(w, r, domain.ErrNotEnoughSeats)
+		return
+	}
+
+	detourDuration, _, err := a.offerDetour(ctx, offer,
+		domain.Coordinates{Lat: req.PickupLatitude, Lon: req.PickupLongitude},
+		domain.Coordinates{Lat: req.DropoffLatitude, Lon: req.DropoffLongitude},
+	)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	booking, err := a.offersRepo.CreateBooking(ctx, &domain.BookingDBModel{
+		OfferID:          offer.ID,
+		PassengerID:      account.ID,
+		PickupLocation:   req.PickupLocation,
+		PickupLatitude:   req.PickupLatitude,
+		PickupLongitude:  req.PickupLongitude,
+		DropoffLocation:  req.DropoffLocation,
+		DropoffLatitude:  req.DropoffLatitude,
+		DropoffLongitude: req.DropoffLongitude,
+		Seats:            req.Seats,
+		DetourDuration:   detourDuration,
+	})
+	if err != nil {
+		a.errorResponse(w, r, http.StatusConflict, fmt.Errorf("you have already asked to join this offer"))
+		return
+	}
+
+	a.queueBookingNotification(ctx, offer.DriverID, booking,
+		fmt.Sprintf("%s %s asked to join your ride (+%d min).", account.FirstName, account.LastName, detourDuration/60))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(bookingResponse(booking))
+}
+
+type GetOfferBookingsResponse struct {
+	Bookings []BookingResponse `json:"bookings"`
+}
+
+// getOfferBookingsHandler lists the bookings on one of the driver's offers, or the passenger's own bookings
+func (a *api) getOfferBookingsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+
+	var bookings []*domain.BookingDBModel
+	if account.Type == "driver" {
+		offerID, err := utils.IntFromQueryParam(r, "offer_id", false)
+		if err != nil {
+			a.errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+		offer, err := a.offersRepo.GetOfferByID(ctx, *offerID)
+		if err != nil {
+			a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("offer not found"))
+			return
+		}
+		if offer.DriverID != account.ID {
+			a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("you are not authorized to view this offer's bookings"))
+			return
+		}
+		bookings, err = a.offersRepo.GetBookingsByOfferID(ctx, offer.ID)
+		if err != nil {
+			a.errorResponse(w, r, http.StatusInternalServerError, err)
+			return
+		}
+	} else {
+		bookings, err = a.offersRepo.GetBookingsByPassengerID(ctx, account.ID)
+		if err != nil {
+			a.errorResponse(w, r, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	response := GetOfferBookingsResponse{
+		Bookings: make([]BookingResponse, len(bookings)),
+	}
+	for i, booking := range bookings {
+		response.Bookings[i] = bookingResponse(booking)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+type RespondToBookingRequest struct {
+	BookingID int64               `json:"booking_id" validate:"required"`
+	Decision  domain.BookingEvent `json:"decision" validate:"required,oneof=approve decline"`
+}
+
+func (a *api) respondToBookingHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var req RespondToBookingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+	if account.Type != "driver" {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("only drivers can answer bookings"))
+		return
+	}
+	if err := a.checkApprovedDriver(ctx, account.ID); err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+
+	booking, err := a.offersRepo.GetBookingByID(ctx, req.BookingID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("booking not found"))
+		return
+	}
+	offer, err := a.offersRepo.GetOfferByID(ctx, booking.OfferID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if offer.DriverID != account.ID {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("you are not authorized to answer this booking"))
+		return
+	}
+	if req.Decision == domain.BookingEventApprove {
+		// Everyone approved so far travels together with this passenger
+		bookings, err := a.offersRepo.GetBookingsByOfferID(ctx, offer.ID)
+		if err != nil {
+			a.errorResponse(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		passengerIDs := []int64{booking.PassengerID}
+		partySizes := []int{booking.Seats}
+		for _, other := range bookings {
+			if other.Status == domain.BookingStatusApproved {
+				passengerIDs = append(passengerIDs, other.PassengerID)
+				partySizes = append(partySizes, other.Seats)
+			}
+		}
+		if err := a.checkDriverLicense(ctx, account.ID, passengerIDs, partySizes); err != nil {
+			a.domainErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	booking, err = a.offersRepo.TransitionBooking(ctx, booking.ID, req.Decision)
+	if err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+
+	message := fmt.Sprintf("Driver %s %s declined your booking.", account.FirstName, account.LastName)
+	if booking.Status == domain.BookingStatusApproved {
+		message = fmt.Sprintf("Driver %s %s approved your booking.", account.FirstName, account.LastName)
+	}
+	a.queueBookingNotification(ctx, booking.PassengerID, booking, message)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(bookingResponse(booking))
+}
+
+type CancelBookingRequest struct {
+	BookingID int64 `json:"booking_id" validate:"required"`
+}
+
+// cancelBookingHandler withdraws a booking the driver has not answered yet. Once approved the
+// passenger has a ride request, which is cancelled through /v1/rides/requests/cancel.
+func (a *api) cancelBookingHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var req CancelBookingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+
+	booking, err := a.offersRepo.GetBookingByID(ctx, req.BookingID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("booking not found"))
+		return
+	}
+	if booking.PassengerID != account.ID {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("you are not authorized to cancel this booking"))
+		return
+	}
+
+	if _, err := a.offersRepo.TransitionBooking(ctx, booking.ID, domain.BookingEventCancel); err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// queueBookingNotification queues an offer update for one side of a booking, logging rather than failing on error
+func (a *api) queueBookingNotification(ctx context.Context, accountID int64, booking *domain.BookingDBModel, message string) {
+	notificationType := domain.NotificationBookingAnswer
+	if booking.Status == domain.BookingStatusPending {
+		notificationType = domain.NotificationBookingRequest
+	}
+	notificationPayload := fmt.Sprintf(`{"offer_id": %d, "booking_id": %d, "notification": "%s"}`, booking.OfferID, booking.ID, notificationType)
+	notification := &domain.NotificationDBModel{
+		NotificationType:    domain.NotificationTypeRideUpdates,
+		NotificationMessage: message,
+		AccountID:           accountID,
+		Payload:             &notificationPayload,
+	}
+
+	_, err := a.notificationsRepo.CreateNotification(ctx, notification)
+	if err != nil {
+		a.logger.Error("Failed to create booking notification",
+			zap.Error(err),
+			zap.Int64("account_id", accountID),
+			zap.Int64("booking_id", booking.ID))
+	}
+}
@@ -0,0 +1,519 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/Arjun113/nOPark/internal/domain"
+	"github.com/Arjun113/nOPark/internal/utils"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+type TariffMultiplierRequest struct {
+	StartTime  string  `json:"start_time" validate:"required,datetime=15:04"`
+	EndTime    string  `json:"end_time" validate:"required,datetime=15:04,nefield=StartTime"`
+	Multiplier float64 `json:"multiplier" validate:"required,gt=0,max=10"`
+	Label      string  `json:"label" validate:"max=50"`
+}
+
+type TariffResponse struct {
+	ID            int64                     `json:"id"`
+	Version       int                       `json:"version"`
+	BaseFare      domain.Money              `json:"base_fare"`
+	PerKm         domain.Money              `json:"per_km"`
+	PerMinute     domain.Money              `json:"per_minute"`
+	MinimumFare   domain.Money              `json:"minimum_fare"`
+	Multipliers   []domain.TariffMultiplier `json:"multipliers"`
+	Timezone      string                    `json:"timezone"`
+	EffectiveFrom time.Time                 `json:"effective_from"`
+	Notes         *string                   `json:"notes,omitempty"`
+	CreatedBy     *int64                    `json:"created_by,omitempty"`
+	CreatedAt     time.Time                 `json:"created_at"`
+}
+
+func tariffResponse(tariff *domain.TariffDBModel) TariffResponse {
+	multipliers := tariff.Multipliers
+	if multipliers == nil {
+		multipliers = []domain.TariffMultiplier{}
+	}
+	return TariffResponse{
+		ID:            tariff.ID,
+		Version:       tariff.Version,
+		BaseFare:      tariff.BaseFare,
+		PerKm:         tariff.PerKm,
+		PerMinute:     tariff.PerMinute,
+		MinimumFare:   tariff.MinimumFare,
+		Multipliers:   multipliers,
+		Timezone:      tariff.Timezone,
+		EffectiveFrom: tariff.EffectiveFrom,
+		Notes:         tariff.Notes,
+		CreatedBy:     tariff.CreatedBy,
+		CreatedAt:     tariff.CreatedAt,
+	}
+}
+
+type TollChargeResponse struct {
+	Road       string       `json:"road"`
+	DistanceKm float64      `json:"distance_km"`
+	Amount     domain.Money `json:"amount"`
+}
+
+// FareBreakdownResponse shows how an estimate was priced
+type FareBreakdownResponse struct {
+	TariffVersion  int                  `json:"tariff_version"`
+	Base           domain.Money         `json:"base"`
+	Distance       domain.Money         `json:"distance"`
+	Time           domain.Money         `json:"time"`
+	Multiplier     float64              `json:"multiplier"`
+	MinimumApplied bool                 `json:"minimum_applied"`
+	Tolls          []TollChargeResponse `json:"tolls"`
+	TollTotal      domain.Money         `json:"toll_total"`
+	Total          domain.Money         `json:"total"`
+}
+
+func fareBreakdownResponse(fare domain.FareBreakdown) FareBreakdownResponse {
+	response := FareBreakdownResponse{
+		TariffVersion:  fare.TariffVersion,
+		Base:           fare.Base,
+		Distance:       fare.Distance,
+		Time:           fare.Time,
+		Multiplier:     fare.Multiplier,
+		MinimumApplied: fare.MinimumApplied,
+		Tolls:          make([]TollChargeResponse, len(fare.Tolls)),
+		TollTotal:      fare.TollTotal,
+		Total:          fare.Total,
+	}
+	for i, toll := range fare.Tolls {
+		response.Tolls[i] = TollChargeResponse{
+			Road:       toll.Road,
+			DistanceKm: math.Round(toll.DistanceKm*100) / 100,
+			Amount:     toll.Amount,
+		}
+	}
+	return response
+}
+
+// priceTrip prices a trip with the tariff in force at departure, adds any tolls on its route,
+// and records the estimate
+func (a *api) priceTrip(ctx context.Context, accountID *int64, trip domain.TripEstimate, departAt time.Time) (*domain.FareEstimateDBModel, *domain.TariffDBModel, domain.FareBreakdown, error) {
+	tariff, err := a.pricingRepo.GetTariffAt(ctx, departAt)
+	if err != nil {
+		return nil, nil, domain.FareBreakdown{}, fmt.Errorf("no tariff in force: %w", err)
+	}
+	fare := tariff.Price(trip.DistanceKm, trip.Duration, departAt)
+
+	if len(trip.Tolls) > 0 {
+		rates, err := a.pricingRepo.GetTollRates(ctx)
+		if err != nil {
+			return nil, nil, domain.FareBreakdown{}, err
+		}
+		fare.AddTolls(domain.PriceTolls(trip.Tolls, rates))
+	}
+
+	estimate, err := a.pricingRepo.RecordFareEstimate(ctx, &domain.FareEstimateDBModel{
+		TariffID:  tariff.ID,
+		AccountID: accountID,
+		Distance:  trip.DistanceKm * 1000,
+		Duration:  int64(trip.Duration.Seconds()),
+		Tolls:     fare.TollTotal,
+		Total:     fare.Total,
+	})
+	if err != nil {
+		return nil, nil, domain.FareBreakdown{}, err
+	}
+	return estimate, tariff, fare, nil
+}
+
+// getCurrentTariffHandler returns the tariff estimates are priced with right now
+func (a *api) getCurrentTariffHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	tariff, err := a.pricingRepo.GetTariffAt(ctx, time.Now())
+	if errors.Is(err, pgx.ErrNoRows) {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("no tariff in force"))
+		return
+	}
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tariffResponse(tariff))
+}
+
+type GetTariffsResponse struct {
+	Tariffs []TariffResponse `json:"tariffs"`
+}
+
+// getTariffsHandler lists every tariff version, newest first. Admin only.
+func (a *api) getTariffsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+	if account.Type != "admin" {
+		a.errorResponse(w, r, http.StatusForbidden, errors.New("admin access required"))
+		return
+	}
+
+	tariffs, err := a.pricingRepo.GetTariffs(ctx)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := GetTariffsResponse{
+		Tariffs: make([]TariffResponse, len(tariffs)),
+	}
+	for i, tariff := range tariffs {
+		response.Tariffs[i] = tariffResponse(tariff)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+type CreateTariffRequest struct {
+	BaseFare    domain.Money              `json:"base_fare" validate:"min=0"`
+	PerKm       domain.Money              `json:"per_km" validate:"min=0"`
+	PerMinute   domain.Money              `json:"per_minute" validate:"min=0"`
+	MinimumFare domain.Money              `json:"minimum_fare" validate:"min=0"`
+	Multipliers []TariffMultiplierRequest `json:"multipliers" validate:"max=24,dive"`
+	Timezone    string                    `json:"timezone" validate:"omitempty,timezone"`
+	// Defaults to now. Cannot be in the past, so estimates already given keep their tariff.
+	EffectiveFrom *time.Time `json:"effective_from"`
+	Notes         *string    `json:"notes" validate:"omitempty,max=200"`
+}
+
+// createTariffHandler adds the next tariff version. Admin only.
+func (a *api) createTariffHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+	if account.Type != "admin" {
+		a.errorResponse(w, r, http.StatusForbidden, errors.New("admin access required"))
+		return
+	}
+
+	var req CreateTariffRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	now := time.Now()
+	effectiveFrom := now
+	if req.EffectiveFrom != nil {
+		if req.EffectiveFrom.Before(now.Add(-time.Minute)) {
+			a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("effective_from cannot be in the past"))
+			return
+		}
+		effectiveFrom = *req.EffectiveFrom
+	}
+
+	tariff := &domain.TariffDBModel{
+		BaseFare:      req.BaseFare,
+		PerKm:         req.PerKm,
+		PerMinute:     req.PerMinute,
+		MinimumFare:   req.MinimumFare,
+		Multipliers:   make([]domain.TariffMultiplier, len(req.Multipliers)),
+		Timezone:      domain.DefaultStatementTimezone,
+		EffectiveFrom: effectiveFrom,
+		Notes:         req.Notes,
+		CreatedBy:     &account.ID,
+	}
+	if req.Timezone != "" {
+		tariff.Timezone = req.Timezone
+	}
+	for i, m := range req.Multipliers {
+		tariff.Multipliers[i] = domain.TariffMultiplier{
+			StartTime:  m.StartTime,
+			EndTime:    m.EndTime,
+			Multiplier: m.Multiplier,
+			Label:      m.Label,
+		}
+	}
+	if err := tariff.Validate(); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	tariff, err = a.pricingRepo.CreateTariff(ctx, tariff)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	a.logger.Info("Tariff created",
+		zap.Int("version", tariff.Version),
+		zap.Time("effective_from", tariff.EffectiveFrom),
+		zap.String("admin", account.Email))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tariffResponse(tariff))
+}
+
+// FairContributionResponse is what a passenger should put towards the driver's fuel and tolls
+type FairContributionResponse struct {
+	FuelType domain.FuelType `json:"fuel_type"`
+	// Per 100 km, in unit
+	Consumption float64 `json:"consumption"`
+	Unit        string  `json:"unit"`
+	// True when the driver has not given their vehicle's fuel details
+	Assumed    bool         `json:"assumed"`
+	UnitPrice  domain.Money `json:"unit_price"`
+	CostPerKm  domain.Money `json:"cost_per_km"`
+	DetourKm   float64      `json:"detour_km"`
+	DetourCost domain.Money `json:"detour_cost"`
+	SharedKm   float64      `json:"shared_km"`
+	SharedCost domain.Money `json:"shared_cost"`
+	// Tolls the detour adds, and half of those on the passenger's leg
+	DetourTolls domain.Money `json:"detour_tolls"`
+	SharedTolls domain.Money `json:"shared_tolls"`
+	Total       domain.Money `json:"total"`
+}
+
+func fairContributionResponse(contribution domain.FairContribution) *FairContributionResponse {
+	return &FairContributionResponse{
+		FuelType:    contribution.Fuel.FuelType,
+		Consumption: contribution.Fuel.Consumption,
+		Unit:        contribution.Fuel.FuelType.Unit(),
+		Assumed:     contribution.Fuel.Assumed,
+		UnitPrice:   contribution.Fuel.UnitPrice,
+		CostPerKm:   contribution.CostPerKm,
+		DetourKm:    math.Round(contribution.DetourKm*100) / 100,
+		DetourCost:  contribution.DetourCost,
+		SharedKm:    math.Round(contribution.SharedKm*100) / 100,
+		SharedCost:  contribution.SharedCost,
+		DetourTolls: contribution.DetourTolls,
+		SharedTolls: contribution.SharedTolls,
+		Total:       contribution.Total,
+	}
+}
+
+type FuelPriceResponse struct {
+	FuelType  domain.FuelType `json:"fuel_type"`
+	Unit      string          `json:"unit"`
+	Price     domain.Money    `json:"price"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+type GetFuelPricesResponse struct {
+	FuelPrices []FuelPriceResponse `json:"fuel_prices"`
+}
+
+func fuelPriceResponse(price *domain.FuelPriceDBModel) FuelPriceResponse {
+	return FuelPriceResponse{
+		FuelType:  price.FuelType,
+		Unit:      price.FuelType.Unit(),
+		Price:     price.Price,
+		UpdatedAt: price.UpdatedAt,
+	}
+}
+
+// getFuelPricesHandler returns the fuel price table fair contributions are worked out from
+func (a *api) getFuelPricesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	prices, err := a.pricingRepo.GetFuelPrices(ctx)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := GetFuelPricesResponse{
+		FuelPrices: make([]FuelPriceResponse, len(prices)),
+	}
+	for i, price := range prices {
+		response.FuelPrices[i] = fuelPriceResponse(price)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+type UpdateFuelPriceRequest struct {
+	FuelType string `json:"fuel_type" validate:"required,oneof=petrol premium_petrol diesel lpg electric"`
+	// Per litre, or per kWh for electric
+	Price domain.Money `json:"price" validate:"min=0"`
+}
+
+// updateFuelPriceHandler sets the price of a fuel type. Admin only.
+func (a *api) updateFuelPriceHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+	if account.Type != "admin" {
+		a.errorResponse(w, r, http.StatusForbidden, errors.New("admin access required"))
+		return
+	}
+
+	var req UpdateFuelPriceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	price, err := a.pricingRepo.UpdateFuelPrice(ctx, &domain.FuelPriceDBModel{
+		FuelType:  domain.FuelType(req.FuelType),
+		Price:     req.Price,
+		UpdatedBy: &account.ID,
+	})
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	a.logger.Info("Fuel price updated",
+		zap.String("fuel_type", string(price.FuelType)),
+		zap.String("price", price.Price.String()),
+		zap.String("admin", account.Email))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(fuelPriceResponse(price))
+}
+
+type CompensationSuggestionRequest struct {
+	PickupLatitude   float64 `validate:"required,min=-90,max=90"`
+	PickupLongitude  float64 `validate:"required,min=-180,max=180"`
+	DropoffLatitude  float64 `validate:"required,min=-90,max=90"`
+	DropoffLongitude float64 `validate:"required,min=-180,max=180"`
+}
+
+type CompensationSuggestionResponse struct {
+	Source    domain.CompensationSuggestionSource `json:"source"`
+	Low       domain.Money                        `json:"low"`
+	Suggested domain.Money                        `json:"suggested"`
+	High      domain.Money                        `json:"high"`
+	Rides     int                                 `json:"rides"`
+	// Left out when suggested from the tariff
+	AcceptRate        *float64   `json:"accept_rate,omitempty"`
+	TypicalAcceptMin  *float64   `json:"typical_accept_min,omitempty"`
+	AggregatesUpdated *time.Time `json:"aggregates_updated,omitempty"`
+}
+
+// compensationSuggestionHandler suggests what to offer for a ride, from what completed rides
+// between the same areas were paid and how quickly they were picked up. With too few of those
+// it looks at the surrounding areas too, then falls back to the tariff.
+func (a *api) compensationSuggestionHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	pickupLat, err := utils.FloatFromQueryParam(r, "pickup_latitude", false)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	pickupLon, err := utils.FloatFromQueryParam(r, "pickup_longitude", false)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	dropoffLat, err := utils.FloatFromQueryParam(r, "dropoff_latitude", false)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	dropoffLon, err := utils.FloatFromQueryParam(r, "dropoff_longitude", false)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := CompensationSuggestionRequest{
+		PickupLatitude:   *pickupLat,
+		PickupLongitude:  *pickupLon,
+		DropoffLatitude:  *dropoffLat,
+		DropoffLongitude: *dropoffLon,
+	}
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	pickup := domain.Coordinates{Lat: req.PickupLatitude, Lon: req.PickupLongitude}
+	dropoff := domain.Coordinates{Lat: req.DropoffLatitude, Lon: req.DropoffLongitude}
+
+	var suggestion *domain.CompensationSuggestion
+	for reach, source := range []domain.CompensationSuggestionSource{domain.CompensationSourceHistory, domain.CompensationSourceNearby} {
+		aggregates, err := a.pricingRepo.GetCompensationAggregates(ctx, pickup, dropoff, reach)
+		if err != nil {
+			a.errorResponse(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if suggestion = domain.SuggestCompensation(source, aggregates); suggestion != nil {
+			break
+		}
+	}
+
+	if suggestion == nil {
+		now := time.Now()
+		tariff, err := a.pricingRepo.GetTariffAt(ctx, now)
+		if err != nil {
+			a.errorResponse(w, r, http.StatusInternalServerError, fmt.Errorf("no tariff in force: %w", err))
+			return
+		}
+		trip := domain.StraightLineTrip(pickup, dropoff)
+		low, high := trip.Range()
+		suggestion = &domain.CompensationSuggestion{
+			Source:    domain.CompensationSourceTariff,
+			Low:       tariff.Price(low.DistanceKm, low.Duration, now).Total,
+			Suggested: tariff.Price(trip.DistanceKm, trip.Duration, now).Total,
+			High:      tariff.Price(high.DistanceKm, high.Duration, now).Total,
+		}
+	}
+
+	response := CompensationSuggestionResponse{
+		Source:            suggestion.Source,
+		Low:               suggestion.Low,
+		Suggested:         suggestion.Suggested,
+		High:              suggestion.High,
+		Rides:             suggestion.Rides,
+		AcceptRate:        suggestion.AcceptRate,
+		AggregatesUpdated: suggestion.RefreshedAt,
+	}
+	if suggestion.TypicalAcceptTime != nil {
+		minutes := math.Round(suggestion.TypicalAcceptTime.Minutes()*10) / 10
+		response.TypicalAcceptMin = &minutes
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,501 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Arjun113/nOPark/internal/domain"
+	"github.com/Arjun113/nOPark/internal/services/documents"
+	"github.com/Arjun113/nOPark/internal/utils"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+type GetReceiptRequest struct {
+	RequestID int64  `validate:"required"`
+	Format    string `validate:"oneof=json pdf csv"`
+}
+
+type ReceiptResponse struct {
+	RequestID       int64               `json:"request_id"`
+	RideID          int64               `json:"ride_id"`
+	PassengerID     int64               `json:"passenger_id"`
+	PassengerName   string              `json:"passenger_name"`
+	DriverID        int64               `json:"driver_id"`
+	DriverName      string              `json:"driver_name"`
+	Vehicle         *RideHistoryVehicle `json:"vehicle,omitempty"`
+	PickupLocation  string              `json:"pickup_location"`
+	PickupLat       float64             `json:"pickup_lat"`
+	PickupLon       float64             `json:"pickup_lon"`
+	DropoffLocation string              `json:"dropoff_location"`
+	DropoffLat      float64             `json:"dropoff_lat"`
+	DropoffLon      float64             `json:"dropoff_lon"`
+	PickedUpAt      *time.Time          `json:"picked_up_at,omitempty"`
+	DroppedOffAt    *time.Time          `json:"dropped_off_at,omitempty"`
+	CompletedAt     time.Time           `json:"completed_at"`
+	// The driver's whole route, in metres and seconds
+	RouteDistance         *float64     `json:"route_distance,omitempty"`
+	RouteDuration         *int64       `json:"route_duration,omitempty"`
+	RequestedCompensation domain.Money `json:"requested_compensation"`
+	Compensation          domain.Money `json:"compensation"`
+	// Absent for rides completed before the split was recorded
+	CostShare        *CostShareResponse `json:"cost_share,omitempty"`
+	TariffVersion    *int               `json:"tariff_version,omitempty"`
+	Passengers       int                `json:"passengers"`
+	RideCompensation domain.Money       `json:"ride_compensation"`
+	Charged          domain.Money       `json:"charged"`
+	Refunded         domain.Money       `json:"refunded"`
+	Total            domain.Money       `json:"total"`
+}
+
+// getReceiptHandler returns a passenger's receipt for a completed ride as JSON, or as a PDF
+// or CSV download with format=pdf or format=csv. The passenger, their driver and admins can
+// see it.
+func (a *api) getReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	requestID, err := utils.IntFromQueryParam(r, "request_id", false)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	format, _ := utils.StringFromQueryParam(r, "format", true)
+
+	reqParams := GetReceiptRequest{
+		RequestID: *requestID,
+		Format:    string(domain.ReceiptFormatJSON),
+	}
+	if format != nil {
+		reqParams.Format = *format
+	}
+	if err := a.validateRequest(reqParams); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+
+	receipt, err := a.receiptsRepo.GetReceipt(ctx, reqParams.RequestID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("no receipt until the ride has completed"))
+		return
+	}
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if account.ID != receipt.PassengerID && account.ID != receipt.DriverID && account.Type != "admin" {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("you were not on this ride"))
+		return
+	}
+
+	response := ReceiptResponse{
+		RequestID:             receipt.RequestID,
+		RideID:                receipt.RideID,
+		PassengerID:           receipt.PassengerID,
+		PassengerName:         receipt.PassengerFirstName + " " + receipt.PassengerLastName,
+		DriverID:              receipt.DriverID,
+		DriverName:            receipt.DriverFirstName + " " + receipt.DriverLastName,
+		PickupLocation:        receipt.PickupLocation,
+		PickupLat:             receipt.PickupLatitude,
+		PickupLon:             receipt.PickupLongitude,
+		DropoffLocation:       receipt.DropoffLocation,
+		DropoffLat:            receipt.DropoffLatitude,
+		DropoffLon:            receipt.DropoffLongitude,
+		PickedUpAt:            receipt.PickedUpAt,
+		DroppedOffAt:          receipt.DroppedOffAt,
+		CompletedAt:           receipt.CompletedAt,
+		RouteDistance:         receipt.RouteDistance,
+		RouteDuration:         receipt.RouteDuration,
+		RequestedCompensation: receipt.RequestedCompensation,
+		Compensation:          receipt.Compensation,
+		CostShare:             storedCostShare(receipt.CostShare, receipt.CostShareDistance, receipt.CostShareSharedDistance),
+		TariffVersion:         receipt.TariffVersion,
+		Passengers:            receipt.Passengers,
+		RideCompensation:      receipt.RideCompensation,
+		Charged:               receipt.Charged,
+		Refunded:              receipt.Refunded,
+		Total:                 receipt.Charged - receipt.Refunded,
+	}
+	if receipt.Vehicle != nil {
+		response.Vehicle = &RideHistoryVehicle{
+			Make:         receipt.Vehicle.Make,
+			Model:        receipt.Vehicle.Model,
+			ModelYear:    receipt.Vehicle.ModelYear,
+			Colour:       receipt.Vehicle.Colour,
+			LicensePlate: receipt.Vehicle.LicensePlate,
+		}
+	}
+
+	if domain.ReceiptFormat(reqParams.Format) == domain.ReceiptFormatJSON {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	a.writeDocument(w, r, receiptDocument(&response), domain.ReceiptFormat(reqParams.Format),
+		fmt.Sprintf("receipt-%d", receipt.RequestID))
+}
+
+type GetEarningsStatementRequest struct {
+	Month    string `validate:"required,datetime=2006-01"`
+	Timezone string `validate:"required,timezone"`
+	Format   string `validate:"oneof=json pdf csv"`
+}
+
+type EarningsLineResponse struct {
+	EntryID         int64                  `json:"entry_id"`
+	Kind            domain.LedgerEntryKind `json:"kind"`
+	RideID          *int64                 `json:"ride_id,omitempty"`
+	RequestID       *int64                 `json:"request_id,omitempty"`
+	PassengerName   string                 `json:"passenger_name,omitempty"`
+	PickupLocation  string                 `json:"pickup_location,omitempty"`
+	DropoffLocation string                 `json:"dropoff_location,omitempty"`
+	Distance        *float64               `json:"distance,omitempty"`
+	Amount          domain.Money           `json:"amount"`
+	CreatedAt       time.Time              `json:"created_at"`
+}
+
+type EarningsStatementResponse struct {
+	DriverID   int64        `json:"driver_id"`
+	DriverName string       `json:"driver_name"`
+	Month      string       `json:"month"`
+	From       time.Time    `json:"from"`
+	To         time.Time    `json:"to"`
+	Rides      int          `json:"rides"`
+	Distance   float64      `json:"distance"`
+	Gross      domain.Money `json:"gross"`
+	Refunds    domain.Money `json:"refunds"`
+	Net        domain.Money `json:"net"`
+	// Still owed to you for rides this month
+	Outstanding domain.Money           `json:"outstanding"`
+	Lines       []EarningsLineResponse `json:"lines"`
+}
+
+// getEarningsStatementHandler returns what a driver earned in a calendar month as JSON, or as
+// a PDF or CSV download with format=pdf or format=csv
+func (a *api) getEarningsStatementHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	month, err := utils.StringFromQueryParam(r, "month", false)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	timezone, _ := utils.StringFromQueryParam(r, "timezone", true)
+	format, _ := utils.StringFromQueryParam(r, "format", true)
+
+	reqParams := GetEarningsStatementRequest{
+		Month:    *month,
+		Timezone: domain.DefaultStatementTimezone,
+		Format:   string(domain.ReceiptFormatJSON),
+	}
+	if timezone != nil {
+		reqParams.Timezone = *timezone
+	}
+	if format != nil {
+		reqParams.Format = *format
+	}
+	if err := a.validateRequest(reqParams); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+	if account.Type != "driver" {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("only drivers have earnings statements"))
+		return
+	}
+	// Statements cover rides already driven, so drivers who are no longer approved still get them
+
+	location, err := time.LoadLocation(reqParams.Timezone)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	from, err := time.ParseInLocation("2006-01", reqParams.Month, location)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	to := from.AddDate(0, 1, 0)
+
+	statement, err := a.receiptsRepo.GetEarningsStatement(ctx, account.ID, from, to)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := EarningsStatementResponse{
+		DriverID:    statement.DriverID,
+		DriverName:  statement.DriverFirstName + " " + statement.DriverLastName,
+		Month:       reqParams.Month,
+		From:        statement.From,
+		To:          statement.To,
+		Rides:       statement.Rides,
+		Distance:    statement.Distance,
+		Gross:       statement.Gross,
+		Refunds:     statement.Refunds,
+		Net:         statement.Net(),
+		Outstanding: statement.Outstanding,
+		Lines:       make([]EarningsLineResponse, len(statement.Lines)),
+	}
+	for i, line := range statement.Lines {
+		response.Lines[i] = EarningsLineResponse{
+			EntryID:   line.EntryID,
+			Kind:      line.Kind,
+			RideID:    line.RideID,
+			RequestID: line.RequestID,
+			Distance:  line.Distance,
+			Amount:    line.Amount,
+			CreatedAt: line.CreatedAt,
+		}
+		if line.PassengerFirstName != nil && line.PassengerLastName != nil {
+			response.Lines[i].PassengerName = *line.PassengerFirstName + " " + *line.PassengerLastName
+		}
+		if line.PickupLocation != nil {
+			response.Lines[i].PickupLocation = *line.PickupLocation
+		}
+		if line.DropoffLocation != nil {
+			response.Lines[i].DropoffLocation = *line.DropoffLocation
+		}
+	}
+
+	if domain.ReceiptFormat(reqParams.Format) == domain.ReceiptFormatJSON {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	a.writeDocument(w, r, earningsDocument(&response, location), domain.ReceiptFormat(reqParams.Format),
+		fmt.Sprintf("earnings-%s", reqParams.Month))
+}
+
+// writeDocument sends a receipt or statement as a file download
+func (a *api) writeDocument(w http.ResponseWriter, r *http.Request, doc *documents.Document, format domain.ReceiptFormat, name string) {
+	var buf bytes.Buffer
+	var contentType string
+	var err error
+	switch format {
+	case domain.ReceiptFormatPDF:
+		contentType = "application/pdf"
+		err = doc.WritePDF(&buf)
+	case domain.ReceiptFormatCSV:
+		contentType = "text/csv; charset=utf-8"
+		err = doc.WriteCSV(&buf)
+	default:
+		err = fmt.Errorf("unsupported format %q", format)
+	}
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// Receipts show times where the rides happen
+var receiptLocation = func() *time.Location {
+	location, err := time.LoadLocation(domain.DefaultStatementTimezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}()
+
+func formatReceiptTime(t *time.Time, location *time.Location) string {
+	if t == nil {
+		return "-"
+	}
+	return t.In(location).Format("2 Jan 2006 3:04 PM MST")
+}
+
+func formatKm(metres *float64) string {
+	if metres == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f km", *metres/1000)
+}
+
+func formatDollars(amount domain.Money) string {
+	return "$" + amount.String()
+}
+
+func receiptDocument(receipt *ReceiptResponse) *documents.Document {
+	duration := "-"
+	if receipt.RouteDuration != nil {
+		duration = fmt.Sprintf("%d min", (*receipt.RouteDuration+30)/60)
+	}
+	vehicle := "-"
+	if receipt.Vehicle != nil {
+		vehicle = fmt.Sprintf("%s %d %s %s (%s)", receipt.Vehicle.Colour, receipt.Vehicle.ModelYear,
+			receipt.Vehicle.Make, receipt.Vehicle.Model, receipt.Vehicle.LicensePlate)
+	}
+
+	doc := &documents.Document{
+		Title:    "nOPark ride receipt",
+		Subtitle: fmt.Sprintf("Receipt %d, ride %d", receipt.RequestID, receipt.RideID),
+		Fields: []documents.Field{
+			{Label: "Passenger", Value: receipt.PassengerName},
+			{Label: "Driver", Value: receipt.DriverName},
+			{Label: "Vehicle", Value: vehicle},
+			{Label: "From", Value: receipt.PickupLocation},
+			{Label: "To", Value: receipt.DropoffLocation},
+			{Label: "Picked up", Value: formatReceiptTime(receipt.PickedUpAt, receiptLocation)},
+			{Label: "Dropped off", Value: formatReceiptTime(receipt.DroppedOffAt, receiptLocation)},
+			{Label: "Ride completed", Value: formatReceiptTime(&receipt.CompletedAt, receiptLocation)},
+			{Label: "Driver's route", Value: formatKm(receipt.RouteDistance) + ", " + duration},
+		},
+	}
+
+	split := []documents.Field{
+		{Label: "Requested", Value: formatDollars(receipt.RequestedCompensation)},
+		{Label: "Agreed", Value: formatDollars(receipt.Compensation)},
+		{Label: "Passengers on ride", Value: strconv.Itoa(receipt.Passengers)},
+		{Label: "Ride total", Value: formatDollars(receipt.RideCompensation)},
+	}
+	if receipt.CostShare != nil {
+		split = append(split,
+			documents.Field{Label: "Your distance", Value: formatKm(&receipt.CostShare.DistanceOnBoard)},
+			documents.Field{Label: "Shared with others", Value: formatKm(&receipt.CostShare.SharedDistance)},
+			documents.Field{Label: "Fair share", Value: formatDollars(receipt.CostShare.Amount)},
+		)
+	}
+	if receipt.TariffVersion != nil {
+		split = append(split, documents.Field{Label: "Tariff", Value: fmt.Sprintf("Version %d", *receipt.TariffVersion)})
+	}
+	doc.Fields = append(doc.Fields, split...)
+
+	doc.Footer = []documents.Field{
+		{Label: "Charged", Value: "$" + receipt.Charged.String()},
+		{Label: "Refunded", Value: "$" + receipt.Refunded.String()},
+		{Label: "Total", Value: "$" + receipt.Total.String()},
+	}
+	return doc
+}
+
+func earningsDocument(statement *EarningsStatementResponse, location *time.Location) *documents.Document {
+	table := &documents.Table{
+		Columns: []documents.Column{
+			{Heading: "Date", Weight: 1.3},
+			{Heading: "Ride", Weight: 0.6},
+			{Heading: "Type", Weight: 0.9},
+			{Heading: "Passenger", Weight: 1.5},
+			{Heading: "From", Weight: 2},
+			{Heading: "To", Weight: 2},
+			{Heading: "Distance", Weight: 0.9, Numeric: true},
+			{Heading: "Amount", Weight: 0.9, Numeric: true},
+		},
+		Rows: make([][]string, len(statement.Lines)),
+	}
+	for i, line := range statement.Lines {
+		ride := ""
+		if line.RideID != nil {
+			ride = strconv.FormatInt(*line.RideID, 10)
+		}
+		kind := "Ride"
+		if line.Kind == domain.LedgerEntryRefund {
+			kind = "Refund"
+		}
+		distance := ""
+		if line.Distance != nil {
+			distance = formatKm(line.Distance)
+		}
+		table.Rows[i] = []string{
+			line.CreatedAt.In(location).Format("2 Jan 3:04 PM"),
+			ride,
+			kind,
+			line.PassengerName,
+			line.PickupLocation,
+			line.DropoffLocation,
+			distance,
+			line.Amount.String(),
+		}
+	}
+
+	return &documents.Document{
+		Title:    "nOPark earnings statement",
+		Subtitle: fmt.Sprintf("%s, %s", statement.DriverName, statement.From.Format("January 2006")),
+		Fields: []documents.Field{
+			{Label: "Driver", Value: statement.DriverName},
+			{Label: "Period", Value: fmt.Sprintf("%s to %s", statement.From.Format("2 Jan 2006"), statement.To.AddDate(0, 0, -1).Format("2 Jan 2006"))},
+			{Label: "Rides", Value: strconv.Itoa(statement.Rides)},
+			{Label: "Distance driven", Value: formatKm(&statement.Distance)},
+		},
+		Table: table,
+		Footer: []documents.Field{
+			{Label: "Gross", Value: "$" + statement.Gross.String()},
+			{Label: "Refunds", Value: "$" + statement.Refunds.String()},
+			{Label: "Net", Value: "$" + statement.Net.String()},
+			{Label: "Outstanding", Value: "$" + statement.Outstanding.String()},
+		},
+	}
+}
+
+// queueDriverRideCompletedNotification tells the driver what they earned once the whole ride is
+// over, logging rather than failing on error
+func (a *api) queueDriverRideCompletedNotification(ctx context.Context, rideID int64) {
+	_, proposals, err := a.ridesRepo.GetRideAndProposals(ctx, rideID)
+	if err != nil || len(proposals) == 0 {
+		a.logger.Error("Failed to load ride for driver completion notification",
+			zap.Error(err),
+			zap.Int64("ride_id", rideID))
+		return
+	}
+
+	var earned domain.Money
+	passengers := 0
+	for _, proposal := range proposals {
+		if proposal.Status != domain.ProposalStatusAccepted {
+			continue
+		}
+		request, err := a.ridesRepo.GetRequestByID(ctx, proposal.RequestID)
+		if err != nil {
+			a.logger.Error("Failed to load request for driver completion notification",
+				zap.Error(err),
+				zap.Int64("ride_id", rideID))
+			return
+		}
+		earned += proposal.SettledCompensation(request)
+		passengers++
+	}
+
+	driverID := proposals[0].DriverID
+	notificationPayload := fmt.Sprintf(`{"ride_id": %d, "notification": "%s"}`, rideID, domain.NotificationRideCompleted)
+	notification := &domain.NotificationDBModel{
+		NotificationType:    domain.NotificationTypeRideUpdates,
+		NotificationMessage: fmt.Sprintf("Ride complete! You earned $%s from %d passenger(s).", earned, passengers),
+		AccountID:           driverID,
+		Payload:             &notificationPayload,
+	}
+
+	_, err = a.notificationsRepo.CreateNotification(ctx, notification)
+	if err != nil {
+		a.logger.Error("Failed to create ride completion notification for driver",
+			zap.Error(err),
+			zap.Int64("driver_id", driverID),
+			zap.Int64("ride_id", rideID))
+	}
+}
@@ -3,14 +3,19 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Arjun113/nOPark/internal/domain"
 	"github.com/Arjun113/nOPark/internal/repository"
 	"github.com/Arjun113/nOPark/internal/utils"
+	"github.com/jackc/pgx/v5"
 	"go.uber.org/zap"
 )
 
@@ -22,19 +27,37 @@ type CreateRideRequestRequest struct {
 	DropoffLatitude  float64 `json:"dropoff_latitude" validate:"required,number"`
 	DropoffLongitude float64 `json:"dropoff_longitude" validate:"required,number"`
 	Compensation     float64 `json:"compensation" validate:"required,gt=0"`
+	// How many people are travelling; one if left out
+	PartySize int `json:"party_size,omitempty" validate:"omitempty,min=1,max=8"`
+	// Optional travel window; leave all three out for a ride as soon as possible
+	WindowKind  *domain.WindowKind `json:"window_kind,omitempty" validate:"omitempty,oneof=departure arrival"`
+	WindowStart *time.Time         `json:"window_start,omitempty" validate:"required_with=WindowKind"`
+	WindowEnd   *time.Time         `json:"window_end,omitempty" validate:"required_with=WindowKind"`
+	// Drivers who do not offer every requirement never see the request. Preferences put the
+	// request higher in the feeds of drivers who offer them.
+	Requirements domain.RideFeatures `json:"requirements,omitempty" validate:"omitempty,dive,oneof=luggage wheelchair_accessible child_seat quiet_ride no_smoking pets_allowed"`
+	Preferences  domain.RideFeatures `json:"preferences,omitempty" validate:"omitempty,dive,oneof=luggage wheelchair_accessible child_seat quiet_ride no_smoking pets_allowed"`
 }
 
 type CreateRideRequestResponse struct {
-	ID               int64   `json:"id"`
-	PickupLocation   string  `json:"pickup_location"`
-	PickupLatitude   float64 `json:"pickup_latitude"`
-	PickupLongitude  float64 `json:"pickup_longitude"`
-	DropoffLocation  string  `json:"dropoff_location"`
-	DropoffLatitude  float64 `json:"dropoff_latitude"`
-	DropoffLongitude float64 `json:"dropoff_longitude"`
-	Compensation     float64 `json:"compensation"`
-	PassengerID      int64   `json:"passenger_id"`
-	CreatedAt        string  `json:"created_at"`
+	ID               int64               `json:"id"`
+	PickupLocation   string              `json:"pickup_location"`
+	PickupLatitude   float64             `json:"pickup_latitude"`
+	PickupLongitude  float64             `json:"pickup_longitude"`
+	DropoffLocation  string              `json:"dropoff_location"`
+	DropoffLatitude  float64             `json:"dropoff_latitude"`
+	DropoffLongitude float64             `json:"dropoff_longitude"`
+	PickupCampus     *string             `json:"pickup_campus,omitempty"`
+	DropoffCampus    *string             `json:"dropoff_campus,omitempty"`
+	Compensation     float64             `json:"compensation"`
+	PartySize        int                 `json:"party_size"`
+	PassengerID      int64               `json:"passenger_id"`
+	WindowKind       *domain.WindowKind  `json:"window_kind,omitempty"`
+	WindowStart      *time.Time          `json:"window_start,omitempty"`
+	WindowEnd        *time.Time          `json:"window_end,omitempty"`
+	Requirements     domain.RideFeatures `json:"requirements"`
+	Preferences      domain.RideFeatures `json:"preferences"`
+	CreatedAt        string              `json:"created_at"`
 }
 
 func (a *api) createRideRequestHandler(w http.ResponseWriter, r *http.Request) {
@@ -62,17 +85,42 @@ func (a *api) createRideRequestHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	existing_requests, err := a.ridesRepo.GetActiveRideRequests(ctx, nil, nil, &account.ID)
+	if req.WindowKind == nil && (req.WindowStart != nil || req.WindowEnd != nil) {
+		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("window_kind is required when a window is given"))
+		return
+	}
+	if req.WindowKind != nil {
+		now := time.Now()
+		if !req.WindowEnd.After(*req.WindowStart) {
+			a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("window_end must be after window_start"))
+			return
+		}
+		if !req.WindowEnd.After(now) {
+			a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("window_end must be in the future"))
+			return
+		}
+		if req.WindowStart.After(now.Add(domain.MaxScheduleAhead)) {
+			a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("rides can be scheduled at most %d days ahead", int(domain.MaxScheduleAhead.Hours()/24)))
+			return
+		}
+	}
+
+	zones, err := a.zonesRepo.GetZones(ctx)
 	if err != nil {
 		a.errorResponse(w, r, http.StatusInternalServerError, err)
 		return
 	}
-
-	if len(existing_requests) > 0 {
-		a.errorResponse(w, r, http.StatusConflict, fmt.Errorf("active ride request already exists for this passenger"))
+	if !zones.InServiceArea(domain.Coordinates{Lat: req.PickupLatitude, Lon: req.PickupLongitude}) ||
+		!zones.InServiceArea(domain.Coordinates{Lat: req.DropoffLatitude, Lon: req.DropoffLongitude}) {
+		a.errorResponse(w, r, http.StatusBadRequest, domain.ErrOutsideServiceArea)
 		return
 	}
 
+	partySize := 1
+	if req.PartySize > 0 {
+		partySize = req.PartySize
+	}
+
 	request := &domain.RequestDBModel{
 		PickupLocation:   req.PickupLocation,
 		PickupLatitude:   req.PickupLatitude,
@@ -81,7 +129,30 @@ func (a *api) createRideRequestHandler(w http.ResponseWriter, r *http.Request) {
 		DropoffLatitude:  req.DropoffLatitude,
 		DropoffLongitude: req.DropoffLongitude,
 		Compensation:     req.Compensation,
+		PartySize:        partySize,
 		PassengerID:      account.ID,
+		WindowKind:       req.WindowKind,
+		WindowStart:      req.WindowStart,
+		WindowEnd:        req.WindowEnd,
+		Requirements:     req.Requirements,
+		Preferences:      req.Preferences,
+	}
+
+	existing_requests, err := a.ridesRepo.GetActiveRideRequests(ctx, nil, nil, &account.ID, nil)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Passengers can have several scheduled requests (e.g. from commutes), but not two for the same time
+	now := time.Now()
+	start, end := request.TravelWindow(now)
+	for _, existing := range existing_requests {
+		existingStart, existingEnd := existing.TravelWindow(now)
+		if existingStart.Before(end) && start.Before(existingEnd) {
+			a.errorResponse(w, r, http.StatusConflict, fmt.Errorf("active ride request already exists for this passenger at that time"))
+			return
+		}
 	}
 
 	createdRequest, err := a.ridesRepo.CreateRideRequest(ctx, request)
@@ -98,8 +169,16 @@ func (a *api) createRideRequestHandler(w http.ResponseWriter, r *http.Request) {
 		DropoffLocation:  createdRequest.DropoffLocation,
 		DropoffLatitude:  createdRequest.DropoffLatitude,
 		DropoffLongitude: createdRequest.DropoffLongitude,
+		PickupCampus:     campusCode(zones, createdRequest.PickupLatitude, createdRequest.PickupLongitude),
+		DropoffCampus:    campusCode(zones, createdRequest.DropoffLatitude, createdRequest.DropoffLongitude),
 		Compensation:     createdRequest.Compensation,
+		PartySize:        createdRequest.PartySize,
 		PassengerID:      createdRequest.PassengerID,
+		WindowKind:       createdRequest.WindowKind,
+		WindowStart:      createdRequest.WindowStart,
+		WindowEnd:        createdRequest.WindowEnd,
+		Requirements:     createdRequest.Requirements,
+		Preferences:      createdRequest.Preferences,
 		CreatedAt:        createdRequest.CreatedAt,
 	}
 
@@ -109,12 +188,14 @@ func (a *api) createRideRequestHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 type GetRideRequestsRequest struct {
-	IDs          *[]string `json:"ids,omitempty"`
-	Compensation *float64  `json:"compensation,omitempty"`
-	DropoffLat   float64   `json:"dropoff_lat" validate:"required,min=-90,max=90"`
-	DropoffLon   float64   `json:"dropoff_lon" validate:"required,min=-180,max=180"`
-	DistanceM    *float64  `json:"distance_m,omitempty"`
-	TimeS        *int64    `json:"time_s,omitempty"`
+	IDs           *[]string `json:"ids,omitempty"`
+	Compensation  *float64  `json:"compensation,omitempty"`
+	DropoffLat    float64   `json:"dropoff_lat" validate:"required,min=-90,max=90"`
+	DropoffLon    float64   `json:"dropoff_lon" validate:"required,min=-180,max=180"`
+	DistanceM     *float64  `json:"distance_m,omitempty"`
+	TimeS         *int64    `json:"time_s,omitempty"`
+	DepartureTime time.Time `json:"departure_time"`
+	Campus        *string   `json:"campus,omitempty"`
 }
 
 type GetRideRequestsResponseIndividual struct {
@@ -125,12 +206,24 @@ type GetRideRequestsResponseIndividual struct {
 	DropoffLocation  string   `json:"dropoff_location"`
 	DropoffLatitude  float64  `json:"dropoff_latitude"`
 	DropoffLongitude float64  `json:"dropoff_longitude"`
+	PickupCampus     *string  `json:"pickup_campus,omitempty"`
+	DropoffCampus    *string  `json:"dropoff_campus,omitempty"`
 	DetourRoute      *string  `json:"detour_route,omitempty"`
 	DetourTimeS      *int64   `json:"detour_time_s,omitempty"`
 	DetourDistanceM  *float64 `json:"detour_distance_m,omitempty"`
 	Compensation     float64  `json:"compensation"`
-	PassengerID      int64    `json:"passenger_id"`
-	CreatedAt        string   `json:"created_at"`
+	PartySize        int      `json:"party_size"`
+	// What the detour and shared distance cost the driver in fuel. Only in the driver's feed.
+	FairContribution *FairContributionResponse `json:"fair_contribution,omitempty"`
+	PassengerID      int64                     `json:"passenger_id"`
+	WindowKind       *domain.WindowKind        `json:"window_kind,omitempty"`
+	WindowStart      *time.Time                `json:"window_start,omitempty"`
+	WindowEnd        *time.Time                `json:"window_end,omitempty"`
+	Requirements     domain.RideFeatures       `json:"requirements"`
+	Preferences      domain.RideFeatures       `json:"preferences"`
+	// The preferences the driver offers. Only in the driver's feed, which is ranked by them.
+	PreferencesMet domain.RideFeatures `json:"preferences_met,omitempty"`
+	CreatedAt      string              `json:"created_at"`
 }
 
 type GetRideRequestsResponse struct {
@@ -150,6 +243,10 @@ func (a *api) getRideRequestsHandler(w http.ResponseWriter, r *http.Request) {
 
 	switch account.Type {
 	case "driver":
+		if err := a.checkApprovedDriver(ctx, account.ID); err != nil {
+			a.domainErrorResponse(w, r, err)
+			return
+		}
 		a.getRideRequestsAsDriver(ctx, account, w, r)
 	case "passenger":
 		a.getRideRequestsAsPassenger(ctx, account, w, r)
@@ -159,7 +256,12 @@ func (a *api) getRideRequestsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *api) getRideRequestsAsPassenger(ctx context.Context, account *domain.AccountDBModel, w http.ResponseWriter, r *http.Request) {
-	rideRequests, err := a.ridesRepo.GetActiveRideRequests(ctx, nil, nil, &account.ID)
+	rideRequests, err := a.ridesRepo.GetActiveRideRequests(ctx, nil, nil, &account.ID, nil)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	zones, err := a.zonesRepo.GetZones(ctx)
 	if err != nil {
 		a.errorResponse(w, r, http.StatusInternalServerError, err)
 		return
@@ -178,8 +280,16 @@ func (a *api) getRideRequestsAsPassenger(ctx context.Context, account *domain.Ac
 			DropoffLocation:  rideRequest.DropoffLocation,
 			DropoffLatitude:  rideRequest.DropoffLatitude,
 			DropoffLongitude: rideRequest.DropoffLongitude,
+			PickupCampus:     campusCode(zones, rideRequest.PickupLatitude, rideRequest.PickupLongitude),
+			DropoffCampus:    campusCode(zones, rideRequest.DropoffLatitude, rideRequest.DropoffLongitude),
 			Compensation:     rideRequest.Compensation,
+			PartySize:        rideRequest.PartySize,
 			PassengerID:      rideRequest.PassengerID,
+			WindowKind:       rideRequest.WindowKind,
+			WindowStart:      rideRequest.WindowStart,
+			WindowEnd:        rideRequest.WindowEnd,
+			Requirements:     rideRequest.Requirements,
+			Preferences:      rideRequest.Preferences,
 			CreatedAt:        rideRequest.CreatedAt,
 		}
 	}
@@ -205,15 +315,29 @@ func (a *api) getRideRequestsAsDriver(ctx context.Context, account *domain.Accou
 	}
 	distanceM, _ := utils.FloatFromQueryParam(r, "distance_m", true)
 	timeS, _ := utils.IntFromQueryParam(r, "time_s", true)
+	campus, _ := utils.StringFromQueryParam(r, "campus", true)
 	ids := r.URL.Query()["ids"]
 
+	// Drivers planning ahead pass when they intend to leave; otherwise they are leaving now
+	departureTime, err := utils.TimeFromQueryParam(r, "departure_time", true)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("departure_time must be an RFC 3339 timestamp"))
+		return
+	}
+	now := time.Now()
+	if departureTime == nil || departureTime.Before(now) {
+		departureTime = &now
+	}
+
 	requestParams := GetRideRequestsRequest{
-		IDs:          &ids,
-		Compensation: ubCompensation,
-		DropoffLat:   *dropoffLat,
-		DropoffLon:   *dropoffLon,
-		DistanceM:    distanceM,
-		TimeS:        timeS,
+		IDs:           &ids,
+		Compensation:  ubCompensation,
+		DropoffLat:    *dropoffLat,
+		DropoffLon:    *dropoffLon,
+		DistanceM:     distanceM,
+		TimeS:         timeS,
+		DepartureTime: *departureTime,
+		Campus:        campus,
 	}
 	if err := a.validateRequest(requestParams); err != nil {
 		a.errorResponse(w, r, http.StatusBadRequest, err)
@@ -225,21 +349,59 @@ func (a *api) getRideRequestsAsDriver(ctx context.Context, account *domain.Accou
 		return
 	}
 
-	rideRequests, err := a.ridesRepo.GetActiveRideRequests(ctx, nil, requestParams.Compensation, nil)
+	zones, err := a.zonesRepo.GetZones(ctx)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if requestParams.Campus != nil && zones.Campus(*requestParams.Campus) == nil {
+		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("unknown campus %q", *requestParams.Campus))
+		return
+	}
+
+	rideRequests, err := a.ridesRepo.GetActiveRideRequests(ctx, nil, requestParams.Compensation, nil, &requestParams.DepartureTime)
 	if err != nil {
 		a.errorResponse(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
 	// Calculate driver's route
+	routeOptions, err := a.accountsRepo.GetDriverRouteOptions(ctx, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
 	start := domain.Coordinates{Lat: *account.CurrentLatitude, Lon: *account.CurrentLongitude}
 	dest := domain.Coordinates{Lat: requestParams.DropoffLat, Lon: requestParams.DropoffLon}
-	driverRoute, err := a.mapsRepo.GetDirectRoute(ctx, start, dest)
+	driverRoute, err := a.mapsRepo.GetDirectRouteWithOptions(ctx, start, dest, routeOptions)
 	if err != nil {
 		a.errorResponse(w, r, http.StatusInternalServerError, fmt.Errorf("error calculating driver's route"))
 		return
 	}
 
+	// Without a fuel price the feed still works, just without fair contributions
+	fuelCost, err := a.costSuggestionService.DriverFuelCost(ctx, account.ID)
+	if err != nil {
+		a.logger.Warn("Failed to price driver's fuel", zap.Error(err), zap.Int64("driver_id", account.ID))
+	}
+	priceFuel := err == nil
+	tollRates, err := a.pricingRepo.GetTollRates(ctx)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	offered, err := a.matchingService.OfferedFeatures(ctx, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	seats, err := a.matchingService.VehicleSeats(ctx, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
 	response := GetRideRequestsResponse{
 		Polyline: driverRoute.Polyline,
 		Requests: make([]GetRideRequestsResponseIndividual, 0),
@@ -247,10 +409,23 @@ func (a *api) getRideRequestsAsDriver(ctx context.Context, account *domain.Accou
 
 	// Calculate distance and time for each request
 	for _, rideRequest := range rideRequests {
-		detourRoute, err := a.mapsRepo.GetMultistopRoute(ctx,
+		// A campus filter keeps requests to or from that campus
+		pickupCampus := campusCode(zones, rideRequest.PickupLatitude, rideRequest.PickupLongitude)
+		dropoffCampus := campusCode(zones, rideRequest.DropoffLatitude, rideRequest.DropoffLongitude)
+		if !rideRequest.RequirementsMet(offered) || rideRequest.PartySize > seats {
+			continue
+		}
+		if requestParams.Campus != nil &&
+			(pickupCampus == nil || *pickupCampus != *requestParams.Campus) &&
+			(dropoffCampus == nil || *dropoffCampus != *requestParams.Campus) {
+			continue
+		}
+
+		detourRoute, err := a.mapsRepo.GetMultistopRouteWithOptions(ctx,
 			start,
 			[]domain.Coordinates{{Lat: rideRequest.PickupLatitude, Lon: rideRequest.PickupLongitude}},
 			dest,
+			routeOptions,
 		)
 		if err != nil {
 			a.errorResponse(w, r, http.StatusInternalServerError, err)
@@ -266,6 +441,16 @@ func (a *api) getRideRequestsAsDriver(ctx context.Context, account *domain.Accou
 			continue
 		}
 
+		// Arrival windows need the driver to reach the destination inside the window
+		if !rideRequest.ArrivesInWindow(requestParams.DepartureTime, detourRoute.Duration) {
+			continue
+		}
+
+		var fairContribution *FairContributionResponse
+		if priceFuel {
+			fairContribution = fairContributionResponse(a.costSuggestionService.FeedContribution(fuelCost, tollRates, driverRoute, detourRoute))
+		}
+
 		response.Requests = append(response.Requests, GetRideRequestsResponseIndividual{
 			ID:               rideRequest.ID,
 			PickupLocation:   rideRequest.PickupLocation,
@@ -274,15 +459,30 @@ func (a *api) getRideRequestsAsDriver(ctx context.Context, account *domain.Accou
 			DropoffLocation:  rideRequest.DropoffLocation,
 			DropoffLatitude:  rideRequest.DropoffLatitude,
 			DropoffLongitude: rideRequest.DropoffLongitude,
+			PickupCampus:     pickupCampus,
+			DropoffCampus:    dropoffCampus,
 			DetourTimeS:      &detourTimeDelta,
 			DetourDistanceM:  &detourDistanceDelta,
 			DetourRoute:      &detourRoute.Polyline,
 			Compensation:     rideRequest.Compensation,
+			PartySize:        rideRequest.PartySize,
+			FairContribution: fairContribution,
 			PassengerID:      rideRequest.PassengerID,
+			WindowKind:       rideRequest.WindowKind,
+			WindowStart:      rideRequest.WindowStart,
+			WindowEnd:        rideRequest.WindowEnd,
+			Requirements:     rideRequest.Requirements,
+			Preferences:      rideRequest.Preferences,
+			PreferencesMet:   rideRequest.Preferences.Met(offered),
 			CreatedAt:        rideRequest.CreatedAt,
 		})
 	}
 
+	// Requests meeting more of their preferences come first; otherwise the order is unchanged
+	sort.SliceStable(response.Requests, func(i, j int) bool {
+		return len(response.Requests[i].PreferencesMet) > len(response.Requests[j].PreferencesMet)
+	})
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
@@ -295,17 +495,17 @@ type CreateRideDraftRequest struct {
 }
 
 type CreateRideProposalIndividual struct {
-	ID        int64  `json:"id"`
-	RequestID int64  `json:"request_id"`
-	Status    string `json:"status"`
-	DriverID  int64  `json:"driver_id"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
+	ID        int64                 `json:"id"`
+	RequestID int64                 `json:"request_id"`
+	Status    domain.ProposalStatus `json:"status"`
+	DriverID  int64                 `json:"driver_id"`
+	CreatedAt string                `json:"created_at"`
+	UpdatedAt string                `json:"updated_at"`
 }
 
 type CreateRideDraftResponse struct {
 	ID             int64                          `json:"id"`
-	Status         string                         `json:"status"`
+	Status         domain.RideStatus              `json:"status"`
 	DestinationLat float64                        `json:"destination_lat"`
 	DestinationLon float64                        `json:"destination_lon"`
 	Proposals      []CreateRideProposalIndividual `json:"proposals"`
@@ -336,40 +536,82 @@ func (a *api) createRideDraftHandler(w http.ResponseWriter, r *http.Request) {
 		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("only drivers can create ride proposals"))
 		return
 	}
+	if err := a.checkApprovedDriver(ctx, account.ID); err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+
+	offered, err := a.matchingService.OfferedFeatures(ctx, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	seats, err := a.matchingService.VehicleSeats(ctx, account.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
 
 	// Check if any of the requests already have an in-progress or completed ride
-	for _, id := range req.RequestIds {
+	seatsNeeded := 0
+	passengerIDs := make([]int64, len(req.RequestIds))
+	partySizes := make([]int, len(req.RequestIds))
+	for i, id := range req.RequestIds {
+		request, err := a.ridesRepo.GetRequestByID(ctx, id)
+		if err != nil {
+			a.errorResponse(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if !request.Status.Can(domain.RequestEventMatch) {
+			a.errorResponse(w, r, http.StatusConflict, fmt.Errorf("request ID %d is no longer open", id))
+			return
+		}
+		if missing := request.Requirements.Missing(offered); len(missing) > 0 {
+			a.errorResponse(w, r, http.StatusConflict, fmt.Errorf("request ID %d requires %s, which you do not offer", id, missing))
+			return
+		}
+		seatsNeeded += request.PartySize
+		passengerIDs[i] = request.PassengerID
+		partySizes[i] = request.PartySize
+
 		rides, err := a.ridesRepo.GetRideByRequestID(ctx, id)
 		if err != nil {
 			a.errorResponse(w, r, http.StatusInternalServerError, err)
 			return
 		}
-		if len(rides) > 0 {
-			for _, ride := range rides {
-				// Check if the ride is already in progress
-				if ride.Status == "in_progress" || ride.Status == "completed" || ride.Status == "awaiting_confirmation" {
-					a.errorResponse(w, r, http.StatusConflict, fmt.Errorf("a ride is already in progress or completed for request ID %d", id))
-					return
-				}
+		for _, ride := range rides {
+			// Any ride that has not been rejected still holds the request
+			if ride.Status != domain.RideStatusRejected {
+				a.errorResponse(w, r, http.StatusConflict, fmt.Errorf("a ride is already in progress or completed for request ID %d", id))
+				return
 			}
 		}
 	}
 
+	if seatsNeeded > seats {
+		a.domainErrorResponse(w, r, fmt.Errorf("%w: the requests need %d seats and your vehicle has %d", domain.ErrNotEnoughSeats, seatsNeeded, seats))
+		return
+	}
+	if err := a.checkDriverLicense(ctx, account.ID, passengerIDs, partySizes); err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+
 	// Create ride proposals
 	var proposals []*domain.ProposalDBModel
 	for _, id := range req.RequestIds {
 		proposal := domain.ProposalDBModel{
 			RequestID: id,
 			DriverID:  account.ID,
-			Status:    "pending",
+			Status:    domain.ProposalStatusPending,
 		}
 		proposals = append(proposals, &proposal)
 	}
 
 	// Pass destination coordinates from request
-	newRide, newProposals, err := a.ridesRepo.CreateRideAndProposals(ctx, proposals, req.DestinationLat, req.DestinationLon)
+	newRide, newProposals, err := a.ridesRepo.CreateRideAndProposals(ctx, proposals, req.DestinationLat, req.DestinationLon, seats)
 	if err != nil {
-		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		a.domainErrorResponse(w, r, err)
 		return
 	}
 
@@ -439,16 +681,20 @@ type GetRideProposalsRequest struct {
 }
 
 type GetRideProposalResponse struct {
-	ID        int64   `json:"id"`
-	RequestID int64   `json:"request_id"`
-	Status    string  `json:"status"`
-	DriverID  int64   `json:"driver_id"`
-	RideID    int64   `json:"ride_id"`
-	Polyline  string  `json:"polyline"`
-	Duration  int64   `json:"duration"`
-	Distance  float64 `json:"distance"`
-	CreatedAt string  `json:"created_at"`
-	UpdatedAt string  `json:"updated_at"`
+	ID        int64                 `json:"id"`
+	RequestID int64                 `json:"request_id"`
+	Status    domain.ProposalStatus `json:"status"`
+	DriverID  int64                 `json:"driver_id"`
+	RideID    int64                 `json:"ride_id"`
+	Polyline  string                `json:"polyline"`
+	Duration  int64                 `json:"duration"`
+	Distance  float64               `json:"distance"`
+	// The request's compensation, or the amount agreed by counter-offer
+	Compensation domain.Money `json:"compensation"`
+	// Worked out the same way as in the driver's feed, from where the driver is now
+	FairContribution *FairContributionResponse `json:"fair_contribution,omitempty"`
+	CreatedAt        string                    `json:"created_at"`
+	UpdatedAt        string                    `json:"updated_at"`
 }
 
 func (a *api) GetRideProposalsHandler(w http.ResponseWriter, r *http.Request) {
@@ -484,10 +730,14 @@ func (a *api) GetRideProposalsHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Get corresponding proposal and request object
 	proposal, err := a.ridesRepo.GetProposalByID(ctx, requestParams.ProposalID)
-	if err != nil {
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		a.errorResponse(w, r, http.StatusInternalServerError, err)
 		return
 	}
+	if proposal == nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("proposal not found"))
+		return
+	}
 	request, err := a.ridesRepo.GetRequestByID(ctx, proposal.RequestID)
 	if err != nil {
 		a.errorResponse(w, r, http.StatusInternalServerError, err)
@@ -509,16 +759,20 @@ func (a *api) GetRideProposalsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Gather pickup points
-	_, proposals, err := a.ridesRepo.GetRideAndProposals(ctx, proposal.RideID)
-	if err != nil {
+	ride, proposals, err := a.ridesRepo.GetRideAndProposals(ctx, proposal.RideID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		a.errorResponse(w, r, http.StatusInternalServerError, err)
 		return
 	}
+	if ride == nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("ride not found"))
+		return
+	}
 
-	// Add pickup point of all proposals except rejected ones
+	// Add pickup point of all proposals still in play
 	var waypoints []domain.Coordinates
 	for _, prop := range proposals {
-		if prop.Status == "rejected" {
+		if prop.Status != domain.ProposalStatusPending && prop.Status != domain.ProposalStatusAccepted {
 			continue
 		}
 
@@ -534,28 +788,47 @@ func (a *api) GetRideProposalsHandler(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
+	routeOptions, err := a.accountsRepo.GetDriverRouteOptions(ctx, driver.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
 	// Start from driver's location and end at any proposal's dropoff location
-	route, err := a.mapsRepo.GetRouteFromWaypoints(ctx,
+	route, err := a.mapsRepo.GetRouteFromWaypointsWithOptions(ctx,
 		domain.Coordinates{Lat: *driver.CurrentLatitude, Lon: *driver.CurrentLongitude},
 		waypoints,
 		domain.Coordinates{Lat: request.DropoffLatitude, Lon: request.DropoffLongitude},
+		routeOptions,
 	)
 	if err != nil {
 		a.errorResponse(w, r, http.StatusInternalServerError, fmt.Errorf("%s", "error calculating route with waypoints: "+err.Error()))
 		return
 	}
 
+	var fairContribution *FairContributionResponse
+	contribution, err := a.proposalFairContribution(ctx, driver, request, ride, routeOptions)
+	if err != nil {
+		a.logger.Warn("Failed to work out fair contribution",
+			zap.Error(err),
+			zap.Int64("proposal_id", proposal.ID))
+	} else {
+		fairContribution = fairContributionResponse(contribution)
+	}
+
 	response := GetRideProposalResponse{
-		ID:        proposal.ID,
-		RequestID: proposal.RequestID,
-		Status:    proposal.Status,
-		DriverID:  proposal.DriverID,
-		RideID:    proposal.RideID,
-		Polyline:  route.Polyline,
-		Duration:  route.Duration,
-		Distance:  route.Distance,
-		CreatedAt: proposal.CreatedAt,
-		UpdatedAt: proposal.UpdatedAt,
+		ID:               proposal.ID,
+		RequestID:        proposal.RequestID,
+		Status:           proposal.Status,
+		DriverID:         proposal.DriverID,
+		RideID:           proposal.RideID,
+		Polyline:         route.Polyline,
+		Duration:         route.Duration,
+		Distance:         route.Distance,
+		Compensation:     proposal.SettledCompensation(request),
+		FairContribution: fairContribution,
+		CreatedAt:        proposal.CreatedAt,
+		UpdatedAt:        proposal.UpdatedAt,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -563,20 +836,52 @@ func (a *api) GetRideProposalsHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// proposalFairContribution prices the passenger's pickup the way the driver's feed does: the
+// driver's route from where they are now to the ride's destination, with and without the
+// pickup, handed to FeedContribution
+func (a *api) proposalFairContribution(ctx context.Context, driver *domain.AccountDBModel, request *domain.RequestDBModel, ride *domain.RideDBModel, routeOptions domain.RouteOptions) (domain.FairContribution, error) {
+	fuelCost, err := a.costSuggestionService.DriverFuelCost(ctx, driver.ID)
+	if err != nil {
+		return domain.FairContribution{}, err
+	}
+	tollRates, err := a.pricingRepo.GetTollRates(ctx)
+	if err != nil {
+		return domain.FairContribution{}, err
+	}
+
+	start := domain.Coordinates{Lat: *driver.CurrentLatitude, Lon: *driver.CurrentLongitude}
+	dest := domain.Coordinates{Lat: ride.DestinationLatitude, Lon: ride.DestinationLongitude}
+	driverRoute, err := a.mapsRepo.GetDirectRouteWithOptions(ctx, start, dest, routeOptions)
+	if err != nil {
+		return domain.FairContribution{}, fmt.Errorf("error calculating driver's route: %w", err)
+	}
+	detourRoute, err := a.mapsRepo.GetMultistopRouteWithOptions(ctx, start,
+		[]domain.Coordinates{{Lat: request.PickupLatitude, Lon: request.PickupLongitude}},
+		dest,
+		routeOptions,
+	)
+	if err != nil {
+		return domain.FairContribution{}, fmt.Errorf("error calculating detour route: %w", err)
+	}
+
+	return a.costSuggestionService.FeedContribution(fuelCost, tollRates, driverRoute, detourRoute), nil
+}
+
 type ConfirmRideProposalRequest struct {
 	ProposalID int64  `json:"proposal_id" validate:"required"`
 	Confirm    string `json:"confirm" validate:"required,oneof=accept reject"`
 }
 
 type ConfirmRideProposalResponse struct {
-	ID             int64  `json:"id"`
-	RequestID      int64  `json:"request_id"`
-	ProposalStatus string `json:"proposal_status"`
-	RideStatus     string `json:"ride_status"`
-	DriverID       int64  `json:"driver_id"`
-	RideID         int64  `json:"ride_id,omitempty"`
-	CreatedAt      string `json:"created_at"`
-	UpdatedAt      string `json:"updated_at"`
+	ID             int64                 `json:"id"`
+	RequestID      int64                 `json:"request_id"`
+	ProposalStatus domain.ProposalStatus `json:"proposal_status"`
+	RideStatus     domain.RideStatus     `json:"ride_status"`
+	DriverID       int64                 `json:"driver_id"`
+	RideID         int64                 `json:"ride_id,omitempty"`
+	BoardingCode   *string               `json:"boarding_code,omitempty"` // show this to the driver at pickup
+	CreatedAt      string                `json:"created_at"`
+	UpdatedAt      string                `json:"updated_at"`
 }
 
 func (a *api) confirmRideProposalHandler(w http.ResponseWriter, r *http.Request) {
@@ -609,8 +914,8 @@ func (a *api) confirmRideProposalHandler(w http.ResponseWriter, r *http.Request)
 		a.errorResponse(w, r, http.StatusInternalServerError, err)
 		return
 	}
-	if proposal.Status != "pending" {
-		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("proposal is not in pending state"))
+	if !proposal.Status.Can(domain.ProposalEvent(req.Confirm)) {
+		a.errorResponse(w, r, http.StatusConflict, fmt.Errorf("proposal is not in pending state"))
 		return
 	}
 
@@ -624,9 +929,9 @@ func (a *api) confirmRideProposalHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	proposal, err = a.ridesRepo.ConfirmRideProposal(ctx, proposal, req.Confirm)
+	proposal, err = a.ridesRepo.ConfirmRideProposal(ctx, proposal, domain.ProposalEvent(req.Confirm))
 	if err != nil {
-		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		a.domainErrorResponse(w, r, err)
 		return
 	}
 
@@ -637,7 +942,7 @@ func (a *api) confirmRideProposalHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Notification to passenger and driver
-	if ride.Status == "in_progress" {
+	if ride.Status == domain.RideStatusInProgress {
 		_, proposals, err := a.ridesRepo.GetRideAndProposals(ctx, ride.ID)
 		if err != nil {
 			a.logger.Error("Failed to get ride proposals for notifications",
@@ -650,6 +955,8 @@ func (a *api) confirmRideProposalHandler(w http.ResponseWriter, r *http.Request)
 					zap.Error(err),
 					zap.Int64("driver_id", proposal.DriverID))
 			} else {
+				a.recordRideRoute(ctx, ride, proposals, driver)
+
 				// Notification to driver about accepted ride
 				notificationPayload := fmt.Sprintf(`{"ride_id": %d, "notification": "%s"}`, ride.ID, domain.NotificationRideFinalized)
 				notification := &domain.NotificationDBModel{
@@ -669,7 +976,7 @@ func (a *api) confirmRideProposalHandler(w http.ResponseWriter, r *http.Request)
 
 				// Notification to all passengers about accepted ride
 				for _, prop := range proposals {
-					if prop.Status == "accepted" {
+					if prop.Status == domain.ProposalStatusAccepted {
 						request, err := a.ridesRepo.GetRequestByID(ctx, prop.RequestID)
 						if err != nil {
 							a.logger.Error("Failed to get request for ride confirmation notification",
@@ -698,7 +1005,7 @@ func (a *api) confirmRideProposalHandler(w http.ResponseWriter, r *http.Request)
 				}
 			}
 		}
-	} else if ride.Status == "rejected" {
+	} else if ride.Status == domain.RideStatusRejected {
 		driver, err := a.accountsRepo.GetAccountByID(ctx, proposal.DriverID)
 		if err != nil {
 			a.logger.Error("Failed to get driver for ride rejection notification",
@@ -730,6 +1037,7 @@ func (a *api) confirmRideProposalHandler(w http.ResponseWriter, r *http.Request)
 		RideStatus:     ride.Status,
 		DriverID:       proposal.DriverID,
 		RideID:         ride.ID,
+		BoardingCode:   proposal.BoardingCode,
 		CreatedAt:      proposal.CreatedAt,
 		UpdatedAt:      proposal.UpdatedAt,
 	}
@@ -739,6 +1047,48 @@ func (a *api) confirmRideProposalHandler(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(response)
 }
 
+// recordRideRoute stores the route the driver is about to take so ride history can show it,
+// logging rather than failing on error
+func (a *api) recordRideRoute(ctx context.Context, ride *domain.RideDBModel, proposals []*domain.ProposalDBModel, driver *domain.AccountDBModel) {
+	if driver.CurrentLatitude == nil || driver.CurrentLongitude == nil {
+		return
+	}
+
+	waypoints := make([]domain.Coordinates, 0)
+	for _, prop := range proposals {
+		if prop.Status != domain.ProposalStatusAccepted {
+			continue
+		}
+		request, err := a.ridesRepo.GetRequestByID(ctx, prop.RequestID)
+		if err != nil {
+			a.logger.Error("Failed to get request for ride route",
+				zap.Error(err),
+				zap.Int64("request_id", prop.RequestID))
+			return
+		}
+		waypoints = append(waypoints, domain.Coordinates{Lat: request.PickupLatitude, Lon: request.PickupLongitude})
+	}
+
+	routeOptions, err := a.accountsRepo.GetDriverRouteOptions(ctx, driver.ID)
+	if err == nil {
+		var route *domain.RouteDBModel
+		route, err = a.mapsRepo.GetRouteFromWaypointsWithOptions(ctx,
+			domain.Coordinates{Lat: *driver.CurrentLatitude, Lon: *driver.CurrentLongitude},
+			waypoints,
+			domain.Coordinates{Lat: ride.DestinationLatitude, Lon: ride.DestinationLongitude},
+			routeOptions,
+		)
+		if err == nil {
+			err = a.ridesRepo.SetRideRoute(ctx, ride.ID, route)
+		}
+	}
+	if err != nil {
+		a.logger.Error("Failed to record ride route",
+			zap.Error(err),
+			zap.Int64("ride_id", ride.ID))
+	}
+}
+
 type GetRideSummaryRequest struct {
 	RideID int64 `json:"ride_id" validate:"required"`
 }
@@ -752,22 +1102,37 @@ type GetRideRequestIndividual struct {
 	DropoffLatitude  float64 `json:"dropoff_latitude"`
 	DropoffLongitude float64 `json:"dropoff_longitude"`
 	Compensation     float64 `json:"compensation"`
+	PartySize        int     `json:"party_size,omitempty"`
 	PassengerID      int64   `json:"passenger_id"`
+	// Set in ride summaries
+	Status       domain.RequestStatus `json:"status,omitempty"`
+	PickedUpAt   *time.Time           `json:"picked_up_at,omitempty"`
+	DroppedOffAt *time.Time           `json:"dropped_off_at,omitempty"`
 }
 
 type GetRideProposalIndividual struct {
 	ID       int64                    `json:"id"`
-	Status   string                   `json:"status"`
+	Status   domain.ProposalStatus    `json:"status"`
 	DriverID int64                    `json:"driver_id"`
 	Request  GetRideRequestIndividual `json:"request"`
+	// The request's compensation, or the amount agreed by counter-offer
+	Compensation domain.Money `json:"compensation"`
+	// Accepted passengers' fair part of the ride's total compensation
+	CostShare *CostShareResponse `json:"cost_share,omitempty"`
+	// Only sent to the passenger the proposal belongs to
+	BoardingCode *string    `json:"boarding_code,omitempty"`
+	BoardedAt    *time.Time `json:"boarded_at,omitempty"`
 }
 
 type GetRideSummaryResponse struct {
-	ID             int64                       `json:"id"`
-	Status         string                      `json:"status"`
-	DestinationLat float64                     `json:"destination_lat"`
-	DestinationLon float64                     `json:"destination_lon"`
-	Proposals      []GetRideProposalIndividual `json:"proposals"`
+	ID             int64             `json:"id"`
+	Status         domain.RideStatus `json:"status"`
+	DestinationLat float64           `json:"destination_lat"`
+	DestinationLon float64           `json:"destination_lon"`
+	Seats          int               `json:"seats"`
+	// Seats not taken by accepted passengers who are yet to be dropped off
+	SeatsFree int                         `json:"seats_free"`
+	Proposals []GetRideProposalIndividual `json:"proposals"`
 }
 
 func (a *api) getRideSummaryHandler(w http.ResponseWriter, r *http.Request) {
@@ -802,26 +1167,61 @@ func (a *api) getRideSummaryHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ride, proposals, err := a.ridesRepo.GetRideAndProposals(ctx, req.RideID)
-	if err != nil {
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		a.errorResponse(w, r, http.StatusInternalServerError, err)
 		return
 	}
+	if ride == nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("ride not found"))
+		return
+	}
+
+	rideRequests := make([]*domain.RequestDBModel, len(proposals))
+	for i, proposal := range proposals {
+		rideRequest, err := a.ridesRepo.GetRequestByID(ctx, proposal.RequestID)
+		if err != nil {
+			a.errorResponse(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		rideRequests[i] = rideRequest
+
+		if rideRequest.PassengerID == session.AccountID || proposal.DriverID == session.AccountID {
+			isAllowed = true
+		}
+	}
+
+	// Check permissions
+	if !isAllowed {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("you do not have permission to view this ride summary"))
+		return
+	}
+
+	// Until the ride completes the split is estimated from the stops so far
+	liveShares := make(map[int64]*domain.CostShare)
+	if ride.Status == domain.RideStatusInProgress {
+		shares, err := a.rideCostShares(ctx, ride, proposals)
+		if err != nil {
+			a.logger.Error("Failed to estimate cost shares",
+				zap.Error(err),
+				zap.Int64("ride_id", ride.ID))
+		}
+		for _, share := range shares {
+			liveShares[share.RequestID] = share
+		}
+	}
 
 	response := GetRideSummaryResponse{
 		ID:             ride.ID,
 		Status:         ride.Status,
 		DestinationLat: ride.DestinationLatitude,
 		DestinationLon: ride.DestinationLongitude,
+		Seats:          ride.Capacity(),
+		SeatsFree:      ride.Capacity(),
 		Proposals:      make([]GetRideProposalIndividual, len(proposals)),
 	}
 
 	for i, proposal := range proposals {
-		rideRequest, err := a.ridesRepo.GetRequestByID(ctx, proposal.RequestID)
-		if err != nil {
-			a.errorResponse(w, r, http.StatusInternalServerError, err)
-			return
-		}
-
+		rideRequest := rideRequests[i]
 		response.Proposals[i] = GetRideProposalIndividual{
 			ID:       proposal.ID,
 			Status:   proposal.Status,
@@ -835,19 +1235,31 @@ func (a *api) getRideSummaryHandler(w http.ResponseWriter, r *http.Request) {
 				DropoffLatitude:  rideRequest.DropoffLatitude,
 				DropoffLongitude: rideRequest.DropoffLongitude,
 				Compensation:     rideRequest.Compensation,
+				PartySize:        rideRequest.PartySize,
 				PassengerID:      rideRequest.PassengerID,
+				Status:           rideRequest.Status,
+				PickedUpAt:       rideRequest.PickedUpAt,
+				DroppedOffAt:     rideRequest.DroppedOffAt,
 			},
+			Compensation: proposal.SettledCompensation(rideRequest),
 		}
-
-		if rideRequest.PassengerID == session.AccountID || proposal.DriverID == session.AccountID {
-			isAllowed = true
+		if proposal.Status == domain.ProposalStatusAccepted && rideRequest.TakesSeat() {
+			response.SeatsFree -= rideRequest.PartySize
 		}
-	}
 
-	// Check permissions
-	if !isAllowed {
-		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("you do not have permission to view this ride summary"))
-		return
+		response.Proposals[i].BoardedAt = proposal.BoardedAt
+		response.Proposals[i].CostShare = storedCostShare(proposal.CostShare, proposal.CostShareDistance, proposal.CostShareSharedDistance)
+		if share, ok := liveShares[proposal.RequestID]; ok && proposal.Status == domain.ProposalStatusAccepted {
+			response.Proposals[i].CostShare = &CostShareResponse{
+				Amount:          share.Amount,
+				DistanceOnBoard: share.DistanceOnBoard,
+				SharedDistance:  share.SharedDistance,
+				Estimate:        true,
+			}
+		}
+		if rideRequest.PassengerID == session.AccountID && rideRequest.Status == domain.RequestStatusMatched {
+			response.Proposals[i].BoardingCode = proposal.BoardingCode
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -856,37 +1268,134 @@ func (a *api) getRideSummaryHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 type CompensationEstimateRequest struct {
-	StartLatitude  float64 `json:"start_latitude" validate:"required,number"`
-	StartLongitude float64 `json:"start_longitude" validate:"required,number"`
-	EndLatitude    float64 `json:"end_latitude" validate:"required,number"`
-	EndLongitude   float64 `json:"end_longitude" validate:"required,number"`
+	StartLatitude  float64 `validate:"required,min=-90,max=90"`
+	StartLongitude float64 `validate:"required,min=-180,max=180"`
+	EndLatitude    float64 `validate:"required,min=-90,max=90"`
+	EndLongitude   float64 `validate:"required,min=-180,max=180"`
+	// When the trip starts, for time-of-day pricing. Defaults to now.
+	DepartAt   *time.Time
+	AvoidTolls bool
+}
+
+// CompensationRange is what the trip should cost if it is quicker or slower than expected
+type CompensationRange struct {
+	Low  domain.Money `json:"low"`
+	High domain.Money `json:"high"`
 }
 
 type CompensationEstimateResponse struct {
-	DistanceKm    float64 `json:"distance_km"`
-	EstimatedComp float64 `json:"estimated_comp"`
+	EstimateID    int64                 `json:"estimate_id"`
+	DistanceKm    float64               `json:"distance_km"`
+	DurationMin   float64               `json:"duration_min"`
+	EstimatedComp float64               `json:"estimated_comp"`
+	Range         CompensationRange     `json:"range"`
+	Fare          FareBreakdownResponse `json:"fare"`
+	// False when routing failed and the distance was guessed from the straight line
+	Routed   bool   `json:"routed"`
+	Polyline string `json:"polyline"`
 }
 
+// compensationEstimateHandler prices the road route between two points with the tariff in
+// force when the trip departs, and records which tariff version that was. Tolls on the route
+// are added as their own line items. When routing fails the straight-line distance is
+// stretched by domain.RoadDetourFactor instead.
 func (a *api) compensationEstimateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
 
-	var req CompensationEstimateRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	startLat, err := utils.FloatFromQueryParam(r, "start_latitude", false)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	startLon, err := utils.FloatFromQueryParam(r, "start_longitude", false)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	endLat, err := utils.FloatFromQueryParam(r, "end_latitude", false)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	endLon, err := utils.FloatFromQueryParam(r, "end_longitude", false)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	departAt, err := utils.TimeFromQueryParam(r, "depart_at", true)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	avoidTolls, err := utils.BoolFromQueryParam(r, "avoid_tolls", true)
+	if err != nil {
 		a.errorResponse(w, r, http.StatusBadRequest, err)
 		return
 	}
 
+	req := CompensationEstimateRequest{
+		StartLatitude:  *startLat,
+		StartLongitude: *startLon,
+		EndLatitude:    *endLat,
+		EndLongitude:   *endLon,
+		DepartAt:       departAt,
+		AvoidTolls:     avoidTolls != nil && *avoidTolls,
+	}
 	if err := a.validateRequest(req); err != nil {
 		a.errorResponse(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	distance := domain.CalculateHaversineDistance(req.StartLatitude, req.StartLongitude, req.EndLatitude, req.EndLongitude)
+	var accountID *int64
+	if session, ok := repository.GetSessionFromContext(r.Context()); ok {
+		accountID = &session.AccountID
+	}
+	departure := time.Now()
+	if req.DepartAt != nil {
+		departure = *req.DepartAt
+	}
+
+	start := domain.Coordinates{Lat: req.StartLatitude, Lon: req.StartLongitude}
+	dest := domain.Coordinates{Lat: req.EndLatitude, Lon: req.EndLongitude}
 
-	estimatedPrice := domain.BaseFare + (distance * domain.PricePerKm)
+	var trip domain.TripEstimate
+	var polyline string
+	route, err := a.mapsRepo.GetDirectRouteWithOptions(ctx, start, dest, domain.RouteOptions{AvoidTolls: req.AvoidTolls})
+	if err == nil && route.Distance > 0 {
+		trip = domain.RoutedTrip(route)
+		polyline = route.Polyline
+	} else {
+		a.logger.Warn("Routing failed, estimating from straight-line distance",
+			zap.Error(err),
+			zap.Float64("start_lat", start.Lat),
+			zap.Float64("start_lon", start.Lon),
+			zap.Float64("end_lat", dest.Lat),
+			zap.Float64("end_lon", dest.Lon))
+		trip = domain.StraightLineTrip(start, dest)
+		polyline = domain.EncodePolyline([][]float64{{start.Lon, start.Lat}, {dest.Lon, dest.Lat}})
+	}
+
+	estimate, tariff, fare, err := a.priceTrip(ctx, accountID, trip, departure)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	// Tolls do not depend on traffic, so both ends of the range pay the same
+	low, high := trip.Range()
 
 	response := CompensationEstimateResponse{
-		DistanceKm:    math.Round(distance*100) / 100,
-		EstimatedComp: math.Round(estimatedPrice*100) / 100,
+		EstimateID:    estimate.ID,
+		DistanceKm:    math.Round(trip.DistanceKm*100) / 100,
+		DurationMin:   math.Round(trip.Duration.Minutes()*10) / 10,
+		EstimatedComp: fare.Total.Float64(),
+		Range: CompensationRange{
+			Low:  tariff.Price(low.DistanceKm, low.Duration, departure).Total + fare.TollTotal,
+			High: tariff.Price(high.DistanceKm, high.Duration, departure).Total + fare.TollTotal,
+		},
+		Fare:     fareBreakdownResponse(fare),
+		Routed:   trip.Routed,
+		Polyline: polyline,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -896,10 +1405,14 @@ func (a *api) compensationEstimateHandler(w http.ResponseWriter, r *http.Request
 
 type ReachPickupRequest struct {
 	RideID           int64   `json:"ride_id" validate:"required"`
+	RequestID        int64   `json:"request_id" validate:"required"`
+	BoardingCode     string  `json:"boarding_code" validate:"required,numeric"`
 	CurrentLatitude  float64 `json:"current_lat" validate:"required,min=-90,max=90"`
 	CurrentLongitude float64 `json:"current_lon" validate:"required,min=-180,max=180"`
 }
 
+// reachPickupHandler marks a passenger picked up. The driver enters the boarding code the
+// passenger shows them, which proves the right passenger got into the right car.
 func (a *api) reachPickupHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
@@ -913,6 +1426,10 @@ func (a *api) reachPickupHandler(w http.ResponseWriter, r *http.Request) {
 		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("only drivers can update their location"))
 		return
 	}
+	if err := a.checkApprovedDriver(ctx, account.ID); err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
 
 	// Parse input
 	var req ReachPickupRequest
@@ -926,13 +1443,21 @@ func (a *api) reachPickupHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check ride exists and is in progress
-	ride, err := a.ridesRepo.GetRideByID(ctx, req.RideID)
-	if err != nil {
+	ride, proposals, err := a.ridesRepo.GetRideAndProposals(ctx, req.RideID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		a.errorResponse(w, r, http.StatusInternalServerError, err)
 		return
 	}
-	if ride.Status != "in_progress" {
-		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("ride is not in progress"))
+	if ride == nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("ride not found"))
+		return
+	}
+	if len(proposals) == 0 || proposals[0].DriverID != account.ID {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("you are not the driver of this ride"))
+		return
+	}
+	if ride.Status != domain.RideStatusInProgress {
+		a.errorResponse(w, r, http.StatusConflict, fmt.Errorf("ride is not in progress"))
 		return
 	}
 
@@ -942,32 +1467,90 @@ func (a *api) reachPickupHandler(w http.ResponseWriter, r *http.Request) {
 		a.errorResponse(w, r, http.StatusInternalServerError, err)
 		return
 	}
-	if len(requests) == 0 {
-		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("no unvisited requests found for this ride"))
+	var pickup *domain.RequestDBModel
+	for _, request := range requests {
+		if request.ID == req.RequestID {
+			pickup = request
+			break
+		}
+	}
+	if pickup == nil {
+		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("request %d is not waiting to be picked up on this ride", req.RequestID))
+		return
+	}
+
+	distance := domain.CalculateHaversineDistance(req.CurrentLatitude, req.CurrentLongitude, pickup.PickupLatitude, pickup.PickupLongitude)
+	if distance > domain.StopRadiusKm {
+		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("you are too far from the pickup location (%.2f km)", distance))
 		return
 	}
 
-	var closest *domain.RequestDBModel
-	var closestDistance float64 = -1
-	for _, request := range requests {
-		distance := domain.CalculateHaversineDistance(req.CurrentLatitude, req.CurrentLongitude, request.PickupLatitude, request.PickupLongitude)
-		if closestDistance == -1 || distance < closestDistance {
-			closestDistance = distance
-			closest = request
-		}
+	if err := a.ridesRepo.BoardPassenger(ctx, ride.ID, pickup.ID, req.BoardingCode); err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type ResetBoardingCodeRequest struct {
+	ProposalID int64 `json:"proposal_id" validate:"required"`
+}
+
+type ResetBoardingCodeResponse struct {
+	ProposalID   int64  `json:"proposal_id"`
+	BoardingCode string `json:"boarding_code"`
+}
+
+// resetBoardingCodeHandler lets a passenger issue a new boarding code, for instance after the
+// driver has used up every attempt
+func (a *api) resetBoardingCodeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var req ResetBoardingCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
 	}
-	if closestDistance > 1000 {
-		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("you are too far from the nearest pickup location (%.2f meters)", closestDistance))
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
 		return
 	}
 
-	// Mark request as visited
-	if err := a.ridesRepo.MarkRequestAsVisited(ctx, closest.ID); err != nil {
+	proposal, err := a.ridesRepo.GetProposalByID(ctx, req.ProposalID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("proposal not found"))
+		return
+	}
+	request, err := a.ridesRepo.GetRequestByID(ctx, proposal.RequestID)
+	if err != nil {
 		a.errorResponse(w, r, http.StatusInternalServerError, err)
 		return
 	}
+	if request.PassengerID != account.ID {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("you are not authorized to reset this boarding code"))
+		return
+	}
 
-	w.WriteHeader(http.StatusNoContent)
+	proposal, err = a.ridesRepo.ResetBoardingCode(ctx, proposal.ID)
+	if err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ResetBoardingCodeResponse{
+		ProposalID:   proposal.ID,
+		BoardingCode: *proposal.BoardingCode,
+	})
 }
 
 type CompleteRideRequest struct {
@@ -987,6 +1570,8 @@ func (a *api) completeRideHandler(w http.ResponseWriter, r *http.Request) {
 		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("only drivers can complete rides"))
 		return
 	}
+	// Not gated on checkApprovedDriver: a driver suspended mid-ride still has passengers in the
+	// car and must be able to finish the trip they started
 
 	var req CompleteRideRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -998,137 +1583,361 @@ func (a *api) completeRideHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ride, err := a.ridesRepo.GetRideByID(ctx, req.RideID)
+	ride, proposals, err := a.ridesRepo.GetRideAndProposals(ctx, req.RideID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if ride == nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("ride not found"))
+		return
+	}
+	if len(proposals) == 0 || proposals[0].DriverID != account.ID {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("you are not the driver of this ride"))
+		return
+	}
+
+	route := a.completionCostShareRoute(ctx, ride, proposals, nil)
+	droppedOff, noShows, err := a.ridesRepo.CompleteRide(ctx, ride.ID, route)
+	if err != nil {
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+
+	// Passengers dropped off earlier were already told
+	for _, request := range droppedOff {
+		a.queueRideCompletedNotification(ctx, ride.ID, request)
+	}
+	for _, request := range noShows {
+		a.queueCancellationNotification(ctx, request.PassengerID, ride.ID,
+			fmt.Sprintf("Driver %s %s finished the ride without picking you up, so your request was cancelled.", account.FirstName, account.LastName))
+	}
+	a.queueDriverRideCompletedNotification(ctx, ride.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type DropoffPassengerRequest struct {
+	RideID           int64   `json:"ride_id" validate:"required"`
+	RequestID        int64   `json:"request_id" validate:"required"`
+	CurrentLatitude  float64 `json:"current_lat" validate:"required,min=-90,max=90"`
+	CurrentLongitude float64 `json:"current_lon" validate:"required,min=-180,max=180"`
+}
+
+type DropoffPassengerResponse struct {
+	RideID     int64             `json:"ride_id"`
+	RideStatus domain.RideStatus `json:"ride_status"`
+	RequestID  int64             `json:"request_id"`
+	PickedUpAt *time.Time        `json:"picked_up_at,omitempty"`
+	DroppedAt  *time.Time        `json:"dropped_off_at,omitempty"`
+}
+
+// dropoffPassengerHandler records one passenger leaving the car. The ride completes by itself
+// once the last accepted passenger is dropped off.
+func (a *api) dropoffPassengerHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
 	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+		return
+	}
+	if account.Type != "driver" {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("only drivers can drop off passengers"))
+		return
+	}
+	// Not gated on checkApprovedDriver, for the same reason as completing the ride: passengers
+	// already on board have to be let out
+
+	var req DropoffPassengerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := a.validateRequest(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	ride, proposals, err := a.ridesRepo.GetRideAndProposals(ctx, req.RideID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		a.errorResponse(w, r, http.StatusInternalServerError, err)
 		return
 	}
-	if ride.Status != "in_progress" {
-		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("ride is not in progress"))
+	if ride == nil {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("ride not found"))
+		return
+	}
+	if len(proposals) == 0 || proposals[0].DriverID != account.ID {
+		a.errorResponse(w, r, http.StatusForbidden, fmt.Errorf("you are not the driver of this ride"))
+		return
+	}
+	onRide := false
+	for _, proposal := range proposals {
+		if proposal.RequestID == req.RequestID && proposal.Status == domain.ProposalStatusAccepted {
+			onRide = true
+			break
+		}
+	}
+	if !onRide {
+		a.errorResponse(w, r, http.StatusNotFound, fmt.Errorf("request %d is not on this ride", req.RequestID))
 		return
 	}
 
-	if err := a.ridesRepo.CompleteRide(ctx, ride.ID); err != nil {
+	request, err := a.ridesRepo.GetRequestByID(ctx, req.RequestID)
+	if err != nil {
 		a.errorResponse(w, r, http.StatusInternalServerError, err)
 		return
 	}
+	distance := domain.CalculateHaversineDistance(req.CurrentLatitude, req.CurrentLongitude, request.DropoffLatitude, request.DropoffLongitude)
+	if distance > domain.StopRadiusKm {
+		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("you are too far from the dropoff location (%.2f km)", distance))
+		return
+	}
 
-	_, proposals, err := a.ridesRepo.GetRideAndProposals(ctx, ride.ID)
+	route := a.completionCostShareRoute(ctx, ride, proposals, &request.ID)
+	ride, err = a.ridesRepo.DropOffRequest(ctx, req.RideID, request.ID, route)
 	if err != nil {
-		a.logger.Error("Failed to get ride proposals for completion notifications",
-			zap.Error(err),
-			zap.Int64("ride_id", ride.ID))
-	} else {
-		for _, proposal := range proposals {
-			if proposal.Status == "accepted" {
-				request, err := a.ridesRepo.GetRequestByID(ctx, proposal.RequestID)
-				if err != nil {
-					a.logger.Error("Failed to get request for ride completion notification",
-						zap.Error(err),
-						zap.Int64("request_id", proposal.RequestID))
-					continue
-				}
+		a.domainErrorResponse(w, r, err)
+		return
+	}
+	if ride.Status == domain.RideStatusCompleted {
+		a.queueDriverRideCompletedNotification(ctx, ride.ID)
+	}
 
-				notificationPayload := fmt.Sprintf(`{"ride_id": %d, "notification": "%s"}`, ride.ID, domain.NotificationRideCompleted)
-				notification := &domain.NotificationDBModel{
-					NotificationType:    domain.NotificationTypeRideUpdates,
-					NotificationMessage: "Your ride has been completed! We hope you had a great experience.",
-					AccountID:           request.PassengerID,
-					Payload:             &notificationPayload,
-				}
+	request, err = a.ridesRepo.GetRequestByID(ctx, request.ID)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	a.queueRideCompletedNotification(ctx, ride.ID, request)
 
-				_, err = a.notificationsRepo.CreateNotification(ctx, notification)
-				if err != nil {
-					a.logger.Error("Failed to create ride completion notification for passenger",
-						zap.Error(err),
-						zap.Int64("passenger_id", request.PassengerID),
-						zap.Int64("ride_id", ride.ID))
-				}
-			}
-		}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(DropoffPassengerResponse{
+		RideID:     ride.ID,
+		RideStatus: ride.Status,
+		RequestID:  request.ID,
+		PickedUpAt: request.PickedUpAt,
+		DroppedAt:  request.DroppedOffAt,
+	})
+}
+
+// queueRideCompletedNotification tells a passenger their trip is over, logging rather than failing on error
+func (a *api) queueRideCompletedNotification(ctx context.Context, rideID int64, request *domain.RequestDBModel) {
+	notificationPayload := fmt.Sprintf(`{"ride_id": %d, "request_id": %d, "notification": "%s"}`, rideID, request.ID, domain.NotificationRideCompleted)
+	notification := &domain.NotificationDBModel{
+		NotificationType:    domain.NotificationTypeRideUpdates,
+		NotificationMessage: "Your ride has been completed! We hope you had a great experience.",
+		AccountID:           request.PassengerID,
+		Payload:             &notificationPayload,
 	}
-	w.WriteHeader(http.StatusNoContent)
 
+	_, err := a.notificationsRepo.CreateNotification(ctx, notification)
+	if err != nil {
+		a.logger.Error("Failed to create ride completion notification for passenger",
+			zap.Error(err),
+			zap.Int64("passenger_id", request.PassengerID),
+			zap.Int64("ride_id", rideID))
+	}
+}
+
+type RideHistoryVehicle struct {
+	Make         string `json:"make"`
+	Model        string `json:"model"`
+	ModelYear    int    `json:"model_year"`
+	Colour       string `json:"colour"`
+	LicensePlate string `json:"license_plate"`
+}
+
+type RideHistoryPassenger struct {
+	ProposalID       int64                 `json:"proposal_id"`
+	ProposalStatus   domain.ProposalStatus `json:"proposal_status"`
+	RequestID        int64                 `json:"request_id"`
+	RequestStatus    domain.RequestStatus  `json:"request_status"`
+	PassengerID      int64                 `json:"passenger_id"`
+	FirstName        string                `json:"first_name"`
+	LastName         string                `json:"last_name"`
+	PickupLocation   string                `json:"pickup_location"`
+	PickupLatitude   float64               `json:"pickup_latitude"`
+	PickupLongitude  float64               `json:"pickup_longitude"`
+	DropoffLocation  string                `json:"dropoff_location"`
+	DropoffLatitude  float64               `json:"dropoff_latitude"`
+	DropoffLongitude float64               `json:"dropoff_longitude"`
+	Compensation     domain.Money          `json:"compensation"`
+	PickedUpAt       *time.Time            `json:"picked_up_at,omitempty"`
+	DroppedOffAt     *time.Time            `json:"dropped_off_at,omitempty"`
+	CostShare        *CostShareResponse    `json:"cost_share,omitempty"`
 }
 
 type RideHistoryItem struct {
-	RideID         int64                      `json:"ride_id"`
-	DestinationLat float64                    `json:"destination_lat"`
-	DestinationLon float64                    `json:"destination_lon"`
-	Status         string                     `json:"status"`
-	DriverID       int64                      `json:"driver_id,omitempty"`
-	Requests       []GetRideRequestIndividual `json:"requests"`
-	CreatedAt      string                     `json:"created_at"`
-	UpdatedAt      string                     `json:"updated_at"`
+	RideID          int64                  `json:"ride_id"`
+	Role            domain.RideHistoryRole `json:"role"`
+	DestinationLat  float64                `json:"destination_lat"`
+	DestinationLon  float64                `json:"destination_lon"`
+	Status          domain.RideStatus      `json:"status"`
+	DriverID        int64                  `json:"driver_id"`
+	DriverFirstName string                 `json:"driver_first_name"`
+	DriverLastName  string                 `json:"driver_last_name"`
+	Vehicle         *RideHistoryVehicle    `json:"vehicle,omitempty"`
+	RouteDistance   *float64               `json:"route_distance,omitempty"`
+	RouteDuration   *int64                 `json:"route_duration,omitempty"`
+	// What the driver earned, or what the passenger paid
+	Compensation domain.Money           `json:"compensation"`
+	Passengers   []RideHistoryPassenger `json:"passengers"`
+	CreatedAt    string                 `json:"created_at"`
+	UpdatedAt    string                 `json:"updated_at"`
+}
+
+type GetRideHistoryRequest struct {
+	Role     string   `validate:"omitempty,oneof=driver passenger"`
+	Statuses []string `validate:"dive,oneof=awaiting_confirmation in_progress completed rejected cancelled"`
+	Limit    int64    `validate:"min=1,max=100"`
 }
 
 type GetRideHistoryResponse struct {
 	Rides []RideHistoryItem `json:"rides"`
+	// Pass as cursor to get the next page. Absent on the last page.
+	NextCursor *int64 `json:"next_cursor,omitempty"`
 }
 
+// getRideHistoryHandler pages through the rides the account drove or asked to join, newest
+// first. Filter with role, status (comma separated), from and to (RFC3339, on when the ride
+// was drafted), and page with limit and the previous page's next_cursor.
 func (a *api) getRideHistoryHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
 
+	role, _ := utils.StringFromQueryParam(r, "role", true)
+	status, _ := utils.StringFromQueryParam(r, "status", true)
+	from, err := utils.TimeFromQueryParam(r, "from", true)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	to, err := utils.TimeFromQueryParam(r, "to", true)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	cursor, err := utils.IntFromQueryParam(r, "cursor", true)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	limit, err := utils.IntFromQueryParam(r, "limit", true)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	reqParams := GetRideHistoryRequest{
+		Statuses: make([]string, 0),
+		Limit:    domain.DefaultRideHistoryLimit,
+	}
+	if role != nil {
+		reqParams.Role = *role
+	}
+	if status != nil {
+		reqParams.Statuses = strings.Split(*status, ",")
+	}
+	if limit != nil {
+		reqParams.Limit = *limit
+	}
+	if err := a.validateRequest(reqParams); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("from must be before to"))
+		return
+	}
+
 	account, err := a.accountsRepo.GetAccountFromSession(r.Context())
 	if err != nil {
 		a.errorResponse(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
 		return
 	}
 
-	rides, err := a.ridesRepo.GetPreviousRides(ctx, account.ID, account.Type, 5, 0)
+	filter := domain.RideHistoryFilter{
+		AccountID:    account.ID,
+		Statuses:     make([]domain.RideStatus, len(reqParams.Statuses)),
+		From:         from,
+		To:           to,
+		BeforeRideID: cursor,
+		// One extra tells us whether there is another page
+		Limit: int(reqParams.Limit) + 1,
+	}
+	if reqParams.Role != "" {
+		historyRole := domain.RideHistoryRole(reqParams.Role)
+		filter.Role = &historyRole
+	}
+	for i, s := range reqParams.Statuses {
+		filter.Statuses[i] = domain.RideStatus(s)
+	}
+
+	rides, err := a.ridesRepo.GetRideHistory(ctx, filter)
 	if err != nil {
 		a.errorResponse(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
 	response := GetRideHistoryResponse{
-		Rides: make([]RideHistoryItem, len(rides)),
-	}
-	for i, ride := range rides {
-		_, proposals, err := a.ridesRepo.GetRideAndProposals(ctx, ride.ID)
-		if err != nil {
-			a.errorResponse(w, r, http.StatusInternalServerError, err)
-			return
+		Rides: make([]RideHistoryItem, 0, len(rides)),
+	}
+	if len(rides) > int(reqParams.Limit) {
+		rides = rides[:reqParams.Limit]
+		response.NextCursor = &rides[len(rides)-1].Ride.ID
+	}
+	for _, ride := range rides {
+		item := RideHistoryItem{
+			RideID:          ride.Ride.ID,
+			Role:            ride.Role,
+			DestinationLat:  ride.Ride.DestinationLatitude,
+			DestinationLon:  ride.Ride.DestinationLongitude,
+			Status:          ride.Ride.Status,
+			DriverID:        ride.DriverID,
+			DriverFirstName: ride.DriverFirstName,
+			DriverLastName:  ride.DriverLastName,
+			RouteDistance:   ride.RouteDistance,
+			RouteDuration:   ride.RouteDuration,
+			Compensation:    ride.Compensation,
+			Passengers:      make([]RideHistoryPassenger, len(ride.Passengers)),
+			CreatedAt:       ride.Ride.CreatedAt,
+			UpdatedAt:       ride.Ride.UpdatedAt,
 		}
-
-		response.Rides[i] = RideHistoryItem{
-			RideID:         ride.ID,
-			Status:         ride.Status,
-			DestinationLat: ride.DestinationLatitude,
-			DestinationLon: ride.DestinationLongitude,
-			DriverID:       0,
-			Requests:       make([]GetRideRequestIndividual, 0),
-			CreatedAt:      ride.CreatedAt,
-			UpdatedAt:      ride.UpdatedAt,
+		if ride.Vehicle != nil {
+			item.Vehicle = &RideHistoryVehicle{
+				Make:         ride.Vehicle.Make,
+				Model:        ride.Vehicle.Model,
+				ModelYear:    ride.Vehicle.ModelYear,
+				Colour:       ride.Vehicle.Colour,
+				LicensePlate: ride.Vehicle.LicensePlate,
+			}
 		}
-		if ride.Status == "in_progress" || ride.Status == "completed" {
-			for _, proposal := range proposals {
-				if proposal.Status != "accepted" {
-					continue
-				}
-
-				request, err := a.ridesRepo.GetRequestByID(ctx, proposal.RequestID)
-				if err != nil {
-					a.errorResponse(w, r, http.StatusInternalServerError, err)
-					return
-				}
-
-				rideRequest := GetRideRequestIndividual{
-					ID:               request.ID,
-					PickupLocation:   request.PickupLocation,
-					PickupLatitude:   request.PickupLatitude,
-					PickupLongitude:  request.PickupLongitude,
-					DropoffLocation:  request.DropoffLocation,
-					DropoffLatitude:  request.DropoffLatitude,
-					DropoffLongitude: request.DropoffLongitude,
-					Compensation:     request.Compensation,
-					PassengerID:      request.PassengerID,
-				}
-
-				response.Rides[i].Requests = append(response.Rides[i].Requests, rideRequest)
+		for i, passenger := range ride.Passengers {
+			item.Passengers[i] = RideHistoryPassenger{
+				ProposalID:       passenger.ProposalID,
+				ProposalStatus:   passenger.ProposalStatus,
+				RequestID:        passenger.RequestID,
+				RequestStatus:    passenger.RequestStatus,
+				PassengerID:      passenger.PassengerID,
+				FirstName:        passenger.FirstName,
+				LastName:         passenger.LastName,
+				PickupLocation:   passenger.PickupLocation,
+				PickupLatitude:   passenger.PickupLatitude,
+				PickupLongitude:  passenger.PickupLongitude,
+				DropoffLocation:  passenger.DropoffLocation,
+				DropoffLatitude:  passenger.DropoffLatitude,
+				DropoffLongitude: passenger.DropoffLongitude,
+				Compensation:     passenger.Compensation,
+				PickedUpAt:       passenger.PickedUpAt,
+				DroppedOffAt:     passenger.DroppedOffAt,
+				CostShare:        storedCostShare(passenger.CostShare, passenger.CostShareDistance, passenger.CostShareSharedDistance),
 			}
 		}
+		response.Rides = append(response.Rides, item)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/Arjun113/nOPark/internal/domain"
+)
+
+type ZoneResponse struct {
+	Code    string          `json:"code"`
+	Name    string          `json:"name"`
+	Kind    domain.ZoneKind `json:"kind"`
+	Polygon [][]float64     `json:"polygon"`
+}
+
+type GetZonesResponse struct {
+	Zones []ZoneResponse `json:"zones"`
+}
+
+// getZonesHandler returns the campuses and the service area, for drawing on the map
+func (a *api) getZonesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	zones, err := a.zonesRepo.GetZones(ctx)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := GetZonesResponse{
+		Zones: make([]ZoneResponse, len(zones)),
+	}
+	for i, zone := range zones {
+		response.Zones[i] = ZoneResponse{
+			Code:    zone.Code,
+			Name:    zone.Name,
+			Kind:    zone.Kind,
+			Polygon: zone.Polygon,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// campusCode returns the code of the campus at the given point, or nil when it is off campus
+func campusCode(zones domain.Zones, lat, lon float64) *string {
+	if campus := zones.CampusAt(domain.Coordinates{Lat: lat, Lon: lon}); campus != nil {
+		return &campus.Code
+	}
+	return nil
+}
@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
@@ -35,6 +37,11 @@ func WorkerCmd(ctx context.Context) *cobra.Command {
 			notificationRepo := repository.NewPostgresNotifications(db)
 			ratelimitRepo := repository.NewPostgresRatelimit(db)
 			ridesRepo := repository.NewPostgresRides(db)
+			commutesRepo := repository.NewPostgresCommutes(db)
+			offersRepo := repository.NewPostgresOffers(db)
+			pricingRepo := repository.NewPostgresPricing(db)
+			driverApplicationsRepo := repository.NewPostgresDriverApplications(db)
+			matchingService := services.NewMatchingService(ridesRepo, repository.NewPostgresMaps(db), accountRepo)
 			fcmService, err := services.NewFCMService(ctx, logger)
 			if err != nil {
 				logger.Error("failed to initialise FCM service", zap.Error(err))
@@ -49,7 +56,7 @@ func WorkerCmd(ctx context.Context) *cobra.Command {
 
 			// Schedule notification creation job every 5 seconds
 			_, err = s.Every(5).Seconds().Do(func() {
-				createNotificationsForNewRideRequests(ctx, logger, accountRepo, notificationRepo)
+				createNotificationsForNewRideRequests(ctx, logger, accountRepo, driverApplicationsRepo, notificationRepo)
 			})
 			if err != nil {
 				return fmt.Errorf("failed to schedule notification creation job: %w", err)
@@ -91,6 +98,70 @@ func WorkerCmd(ctx context.Context) *cobra.Command {
 				return fmt.Errorf("failed to schedule IP block cleanup job: %w", err)
 			}
 
+			// Schedule expiry of stale proposals and requests every minute. Proposals go first so
+			// requests they were holding can lapse in the same run.
+			requestTTL := utils.DurationFromEnv("REQUEST_TTL", domain.DefaultRequestTTL)
+			proposalTTL := utils.DurationFromEnv("PROPOSAL_TTL", domain.DefaultProposalTTL)
+			_, err = s.Every(1).Minute().Do(func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				defer cancel()
+				expireStaleProposals(ctx, logger, accountRepo, ridesRepo, notificationRepo, proposalTTL)
+				expireStaleRequests(ctx, logger, ridesRepo, notificationRepo, requestTTL)
+			})
+			if err != nil {
+				return fmt.Errorf("failed to schedule request and proposal expiry job: %w", err)
+			}
+
+			// Schedule reminders for upcoming scheduled pickups every minute
+			_, err = s.Every(1).Minute().Do(func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				defer cancel()
+				createPickupReminders(ctx, logger, ridesRepo, notificationRepo)
+			})
+			if err != nil {
+				return fmt.Errorf("failed to schedule pickup reminder job: %w", err)
+			}
+
+			// Schedule materialisation of commutes into ride requests every 10 minutes
+			_, err = s.Every(10).Minutes().Do(func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				defer cancel()
+				requests, err := commutesRepo.MaterialiseCommutes(ctx, time.Now().Add(domain.CommuteMaterialiseAhead))
+				if err != nil {
+					logger.Error("failed to materialise commutes", zap.Error(err))
+				} else if len(requests) > 0 {
+					logger.Info("Materialised commutes into ride requests", zap.Int("count", len(requests)))
+				}
+			})
+			if err != nil {
+				return fmt.Errorf("failed to schedule commute materialisation job: %w", err)
+			}
+
+			// Schedule match suggestions for upcoming offers every 5 minutes
+			_, err = s.Every(5).Minutes().Do(func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+				defer cancel()
+				suggestMatchesForOffers(ctx, logger, offersRepo, driverApplicationsRepo, notificationRepo, matchingService)
+			})
+			if err != nil {
+				return fmt.Errorf("failed to schedule match suggestion job: %w", err)
+			}
+
+			// Schedule the rebuild of compensation suggestion aggregates every 30 minutes
+			_, err = s.Every(30).Minutes().Do(func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+				defer cancel()
+				count, err := pricingRepo.RefreshCompensationAggregates(ctx, time.Now().Add(-domain.CompensationHistory))
+				if err != nil {
+					logger.Error("failed to refresh compensation aggregates", zap.Error(err))
+				} else {
+					logger.Info("Refreshed compensation aggregates", zap.Int("area_pairs", count))
+				}
+			})
+			if err != nil {
+				return fmt.Errorf("failed to schedule compensation aggregate job: %w", err)
+			}
+
 			// Schedule notification processing job every 3 seconds
 			var processingMutex sync.Mutex
 			_, err = s.Every(3).Seconds().Do(func() {
@@ -113,7 +184,9 @@ func WorkerCmd(ctx context.Context) *cobra.Command {
 				return fmt.Errorf("failed to schedule notification processing job: %w", err)
 			}
 
-			logger.Info("combined worker started - scheduling notifications every 5s, proximity checks every 5s, processing every 3s, cleanup every 5min")
+			logger.Info("combined worker started - scheduling notifications every 5s, proximity checks every 5s, processing every 3s, expiry and pickup reminders every 1min, cleanup and match suggestions every 5min, commutes every 10min, compensation aggregates every 30min",
+				zap.Duration("request_ttl", requestTTL),
+				zap.Duration("proposal_ttl", proposalTTL))
 			s.StartBlocking()
 
 			return nil
@@ -123,7 +196,7 @@ func WorkerCmd(ctx context.Context) *cobra.Command {
 	return cmd
 }
 
-func createNotificationsForNewRideRequests(ctx context.Context, logger *zap.Logger, accountRepo domain.AccountsRepository, notificationRepo domain.NotificationsRepository) {
+func createNotificationsForNewRideRequests(ctx context.Context, logger *zap.Logger, accountRepo domain.AccountsRepository, driverApplicationsRepo domain.DriverApplicationsRepository, notificationRepo domain.NotificationsRepository) {
 	logger.Debug("checking for new ride requests without notifications")
 
 	newRequests, err := notificationRepo.GetUnnotifiedRideRequests(ctx)
@@ -145,6 +218,16 @@ func createNotificationsForNewRideRequests(ctx context.Context, logger *zap.Logg
 		return
 	}
 
+	// Only approved drivers can take the requests
+	approved, err := approvedDriverIDs(ctx, driverApplicationsRepo)
+	if err != nil {
+		logger.Error("failed to fetch approved drivers", zap.Error(err))
+		return
+	}
+	drivers = slices.DeleteFunc(drivers, func(driver *domain.AccountDBModel) bool {
+		return !approved[driver.ID]
+	})
+
 	if len(drivers) == 0 {
 		logger.Debug("no driver accounts found")
 		return
@@ -364,3 +447,285 @@ func createNotificationsForDriverCloseToPassenger(ctx context.Context, logger *z
 			zap.Int("total_created", notificationsCreated))
 	}
 }
+
+func expireStaleRequests(ctx context.Context, logger *zap.Logger, ridesRepo domain.RidesRepository, notificationRepo domain.NotificationsRepository, ttl time.Duration) {
+	expired, err := ridesRepo.ExpireRideRequests(ctx, time.Now().Add(-ttl))
+	if err != nil {
+		logger.Error("failed to expire stale ride requests", zap.Error(err))
+		return
+	}
+
+	if len(expired) == 0 {
+		logger.Debug("no stale ride requests found")
+		return
+	}
+
+	for _, request := range expired {
+		notificationPayload := fmt.Sprintf(`{"request_id": %d, "notification": "%s"}`, request.ID, domain.NotificationRequestExpired)
+		notification := &domain.NotificationDBModel{
+			NotificationType:    domain.NotificationTypeRideUpdates,
+			NotificationMessage: "Your ride request expired before a driver picked it up.",
+			AccountID:           request.PassengerID,
+			Payload:             &notificationPayload,
+		}
+
+		_, err := notificationRepo.CreateNotification(ctx, notification)
+		if err != nil {
+			logger.Error("failed to create request expiry notification",
+				zap.Error(err),
+				zap.Int64("passenger_id", request.PassengerID),
+				zap.Int64("request_id", request.ID))
+		}
+	}
+
+	logger.Info("expired stale ride requests", zap.Int("count", len(expired)))
+}
+
+func expireStaleProposals(ctx context.Context, logger *zap.Logger, accountRepo domain.AccountsRepository, ridesRepo domain.RidesRepository, notificationRepo domain.NotificationsRepository, ttl time.Duration) {
+	expired, err := ridesRepo.ExpireRideProposals(ctx, time.Now().Add(-ttl))
+	if err != nil {
+		// Rides settled before the failure are still reported below
+		logger.Error("failed to expire stale ride proposals", zap.Error(err))
+	}
+
+	if len(expired) == 0 {
+		logger.Debug("no stale ride proposals found")
+		return
+	}
+
+	logger.Info("expired stale ride proposals", zap.Int("count", len(expired)))
+
+	// Expiry may have settled the ride, so tell the driver and passengers how it ended
+	notified := make(map[int64]bool)
+	for _, proposal := range expired {
+		if notified[proposal.RideID] {
+			continue
+		}
+		notified[proposal.RideID] = true
+
+		ride, proposals, err := ridesRepo.GetRideAndProposals(ctx, proposal.RideID)
+		if err != nil {
+			logger.Error("failed to get ride for expiry notifications",
+				zap.Error(err),
+				zap.Int64("ride_id", proposal.RideID))
+			continue
+		}
+
+		notificationPayload := fmt.Sprintf(`{"ride_id": %d, "notification": "%s"}`, ride.ID, domain.NotificationRideFinalized)
+		switch ride.Status {
+		case domain.RideStatusRejected:
+			notification := &domain.NotificationDBModel{
+				NotificationType:    domain.NotificationTypeRideUpdates,
+				NotificationMessage: "Your planned ride has been rejected.",
+				AccountID:           proposal.DriverID,
+				Payload:             &notificationPayload,
+			}
+			if _, err := notificationRepo.CreateNotification(ctx, notification); err != nil {
+				logger.Error("failed to create ride rejection notification for driver",
+					zap.Error(err),
+					zap.Int64("driver_id", proposal.DriverID),
+					zap.Int64("ride_id", ride.ID))
+			}
+		case domain.RideStatusInProgress:
+			driver, err := accountRepo.GetAccountByID(ctx, proposal.DriverID)
+			if err != nil {
+				logger.Error("failed to get driver for ride confirmation notification",
+					zap.Error(err),
+					zap.Int64("driver_id", proposal.DriverID))
+				continue
+			}
+
+			notification := &domain.NotificationDBModel{
+				NotificationType:    domain.NotificationTypeRideUpdates,
+				NotificationMessage: "Your ride trip has been accepted!",
+				AccountID:           driver.ID,
+				Payload:             &notificationPayload,
+			}
+			if _, err := notificationRepo.CreateNotification(ctx, notification); err != nil {
+				logger.Error("failed to create ride confirmation notification for driver",
+					zap.Error(err),
+					zap.Int64("driver_id", driver.ID),
+					zap.Int64("ride_id", ride.ID))
+			}
+
+			for _, prop := range proposals {
+				if prop.Status != domain.ProposalStatusAccepted {
+					continue
+				}
+
+				request, err := ridesRepo.GetRequestByID(ctx, prop.RequestID)
+				if err != nil {
+					logger.Error("failed to get request for ride confirmation notification",
+						zap.Error(err),
+						zap.Int64("request_id", prop.RequestID))
+					continue
+				}
+
+				notification := &domain.NotificationDBModel{
+					NotificationType: domain.NotificationTypeRideUpdates,
+					NotificationMessage: fmt.Sprintf("Your ride has been confirmed! Driver %s %s will be picking you up.",
+						driver.FirstName, driver.LastName),
+					AccountID: request.PassengerID,
+					Payload:   &notificationPayload,
+				}
+				if _, err := notificationRepo.CreateNotification(ctx, notification); err != nil {
+					logger.Error("failed to create ride confirmation notification for passenger",
+						zap.Error(err),
+						zap.Int64("passenger_id", request.PassengerID),
+						zap.Int64("ride_id", ride.ID))
+				}
+			}
+		}
+	}
+}
+
+func createPickupReminders(ctx context.Context, logger *zap.Logger, ridesRepo domain.RidesRepository, notificationRepo domain.NotificationsRepository) {
+	reminders, err := ridesRepo.GetDuePickupReminders(ctx, time.Now().Add(domain.PickupReminderLead))
+	if err != nil {
+		logger.Error("failed to fetch due pickup reminders", zap.Error(err))
+		return
+	}
+
+	if len(reminders) == 0 {
+		logger.Debug("no pickup reminders due")
+		return
+	}
+
+	remindersSent := 0
+	for _, reminder := range reminders {
+		notificationPayload := fmt.Sprintf(`{"ride_id": %d, "request_id": %d, "notification": "%s"}`, reminder.RideID, reminder.RequestID, domain.NotificationPickupReminder)
+		minutes := max(int(time.Until(reminder.WindowStart).Minutes()), 0)
+
+		notifications := []*domain.NotificationDBModel{
+			{
+				NotificationType:    domain.NotificationTypeRideUpdates,
+				NotificationMessage: fmt.Sprintf("Reminder: your scheduled ride is coming up in %d minutes.", minutes),
+				AccountID:           reminder.PassengerID,
+				Payload:             &notificationPayload,
+			},
+			{
+				NotificationType:    domain.NotificationTypeRideUpdates,
+				NotificationMessage: fmt.Sprintf("Reminder: your scheduled ride with %s is coming up in %d minutes.", reminder.PassengerFirstName, minutes),
+				AccountID:           reminder.DriverID,
+				Payload:             &notificationPayload,
+			},
+		}
+
+		failed := false
+		for _, notification := range notifications {
+			if _, err := notificationRepo.CreateNotification(ctx, notification); err != nil {
+				logger.Error("failed to create pickup reminder notification",
+					zap.Error(err),
+					zap.Int64("account_id", notification.AccountID),
+					zap.Int64("request_id", reminder.RequestID))
+				failed = true
+			}
+		}
+		if failed {
+			continue
+		}
+
+		if err := ridesRepo.MarkPickupReminderSent(ctx, reminder.RequestID); err != nil {
+			logger.Error("failed to mark pickup reminder as sent",
+				zap.Error(err),
+				zap.Int64("request_id", reminder.RequestID))
+			continue
+		}
+		remindersSent++
+	}
+
+	logger.Info("pickup reminders created", zap.Int("count", remindersSent))
+}
+
+// suggestMatchesForOffers runs the matching engine for every open offer departing soon and
+// pushes the best bundle to its driver, once per distinct bundle
+func suggestMatchesForOffers(ctx context.Context, logger *zap.Logger, offersRepo domain.OffersRepository, driverApplicationsRepo domain.DriverApplicationsRepository, notificationRepo domain.NotificationsRepository, matchingService *services.MatchingService) {
+	now := time.Now()
+	offers, err := offersRepo.SearchOffers(ctx, now, now.Add(domain.MatchSuggestionLead), 1)
+	if err != nil {
+		logger.Error("failed to fetch upcoming offers for match suggestions", zap.Error(err))
+		return
+	}
+
+	if len(offers) == 0 {
+		logger.Debug("no upcoming offers to suggest matches for")
+		return
+	}
+
+	// Suspended drivers cannot approve bookings, so there is no point suggesting any
+	approved, err := approvedDriverIDs(ctx, driverApplicationsRepo)
+	if err != nil {
+		logger.Error("failed to fetch approved drivers", zap.Error(err))
+		return
+	}
+
+	suggestionsSent := 0
+	for _, offer := range offers {
+		if !approved[offer.DriverID] {
+			continue
+		}
+		bundle, _, err := matchingService.Match(ctx, domain.MatchParams{
+			Start:     domain.Coordinates{Lat: offer.OriginLatitude, Lon: offer.OriginLongitude},
+			Dest:      domain.Coordinates{Lat: offer.DestinationLatitude, Lon: offer.DestinationLongitude},
+			Seats:     offer.SeatsFree(),
+			MaxDetour: domain.DefaultMaxMatchDetour,
+			DepartAt:  offer.DepartureTime,
+			DriverID:  offer.DriverID,
+		})
+		if err != nil {
+			logger.Error("failed to match requests for offer",
+				zap.Error(err),
+				zap.Int64("offer_id", offer.ID))
+			continue
+		}
+		if len(bundle.Requests) == 0 {
+			continue
+		}
+
+		isNew, err := offersRepo.RecordMatchSuggestion(ctx, offer.ID, bundle)
+		if err != nil {
+			logger.Error("failed to record match suggestion",
+				zap.Error(err),
+				zap.Int64("offer_id", offer.ID))
+			continue
+		}
+		if !isNew {
+			continue
+		}
+
+		requestIDs, _ := json.Marshal(bundle.RequestIDs())
+		notificationPayload := fmt.Sprintf(`{"offer_id": %d, "request_ids": %s, "notification": "%s"}`, offer.ID, requestIDs, domain.NotificationMatchSuggested)
+		notification := &domain.NotificationDBModel{
+			NotificationType: domain.NotificationTypeRideUpdates,
+			NotificationMessage: fmt.Sprintf("%d passengers fit your upcoming trip: $%s for +%d min.",
+				len(bundle.Requests), bundle.Compensation, bundle.DetourDuration/60),
+			AccountID: offer.DriverID,
+			Payload:   &notificationPayload,
+		}
+		if _, err := notificationRepo.CreateNotification(ctx, notification); err != nil {
+			logger.Error("failed to create match suggestion notification",
+				zap.Error(err),
+				zap.Int64("offer_id", offer.ID),
+				zap.Int64("driver_id", offer.DriverID))
+			continue
+		}
+		suggestionsSent++
+	}
+
+	logger.Info("match suggestions created", zap.Int("count", suggestionsSent))
+}
+
+// approvedDriverIDs returns the drivers an admin has approved to take passengers
+func approvedDriverIDs(ctx context.Context, driverApplicationsRepo domain.DriverApplicationsRepository) (map[int64]bool, error) {
+	status := domain.DriverApplicationStatusApproved
+	applications, err := driverApplicationsRepo.GetDriverApplications(ctx, &status)
+	if err != nil {
+		return nil, err
+	}
+
+	approved := make(map[int64]bool, len(applications))
+	for _, application := range applications {
+		approved[application.AccountID] = true
+	}
+	return approved, nil
+}
@@ -33,7 +33,7 @@ type AccountsRepository interface {
 	DeleteAllUserSessions(ctx context.Context, accountID int64) error
 	CleanupExpiredSessions(ctx context.Context) error
 	GetAccountFromSession(ctx context.Context) (*AccountDBModel, error)
-	AddFavouriteAddress(ctx context.Context, accountID int64, addressName string, addressLine string) error
+	AddFavouriteAddress(ctx context.Context, accountID int64, addressName string, addressLine string, lat, lon *float64) error
 	GetFavouriteAddresses(ctx context.Context, accountID int64) ([]AddressDBModel, error)
 	DeleteFavouriteAddress(ctx context.Context, accountID int64, addressID int64) error
 	RemoveUnverifiedExpiredAccounts(ctx context.Context) (int64, error)
@@ -41,6 +41,15 @@ type AccountsRepository interface {
 	UpdateFCMToken(ctx context.Context, accountID int64, fcmToken string) error
 	CreateVehicle(ctx context.Context, vehicle *VehicleDBModel) (*VehicleDBModel, error)
 	GetVehicleByAccountID(ctx context.Context, accountID int64) (*VehicleDBModel, error)
+	UpdateVehicleFeatures(ctx context.Context, accountID int64, features RideFeatures) (*VehicleDBModel, error)
+	GetDriverPreferences(ctx context.Context, accountID int64) (RideFeatures, error)
+	SetDriverPreferences(ctx context.Context, accountID int64, features RideFeatures) (RideFeatures, error)
+	GetDriverRouteOptions(ctx context.Context, accountID int64) (RouteOptions, error)
+	SetDriverRouteOptions(ctx context.Context, accountID int64, options RouteOptions) (RouteOptions, error)
+	CreateLicense(ctx context.Context, license *LicenseDBModel) (*LicenseDBModel, error)
+	GetLicenseByAccountID(ctx context.Context, accountID int64) (*LicenseDBModel, error)
+	UpdateLicense(ctx context.Context, license *LicenseDBModel) (*LicenseDBModel, error)
+	DeleteLicense(ctx context.Context, accountID int64) error
 }
 
 const SessionExpiresInSeconds = 7 * 24 * 60 * 60       // 7 days
@@ -51,6 +60,8 @@ type AddressDBModel struct {
 	ID          int64
 	AddressName string
 	AddressLine string
+	Latitude    *float64 // can be nil, but commutes need it
+	Longitude   *float64 // can be nil, but commutes need it
 }
 
 type AccountDBModel struct {
@@ -66,8 +77,9 @@ type AccountDBModel struct {
 	EmailVerificationExpiresAt string
 	PasswordResetToken         string
 	PasswordResetExpiresAt     string
-	CurrentLatitude            *float64 // can be nil
-	CurrentLongitude           *float64 // can be nil
+	CurrentLatitude            *float64   // can be nil
+	CurrentLongitude           *float64   // can be nil
+	DateOfBirth                *time.Time // can be nil, but P1 drivers' passengers are then counted as peers
 	FCMToken                   string
 	CreatedAt                  string
 	UpdatedAt                  string
@@ -93,8 +105,13 @@ type VehicleDBModel struct {
 	Colour       string
 	LicensePlate string
 	AccountID    int64
-	CreatedAt    string
-	UpdatedAt    string
+	// Nil for vehicles added before fuel details were asked for
+	FuelType        *FuelType
+	FuelConsumption *float64 // per 100 km, in the fuel's unit
+	Features        RideFeatures
+	Seats           int // passenger seats, not counting the driver's
+	CreatedAt       string
+	UpdatedAt       string
 }
 
 func HashPassword(password string) (string, error) {
@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// Boarding codes are shown to the passenger once their proposal is accepted and entered by
+// the driver at pickup, so a pickup is only recorded with the right passenger in the car
+const (
+	BoardingCodeDigits  = 4
+	MaxBoardingAttempts = 5
+)
+
+// BoardingCodeError is returned when the driver enters the wrong boarding code. The API maps
+// it to 403 Forbidden, or 429 Too Many Requests once no attempts are left.
+type BoardingCodeError struct {
+	AttemptsLeft int
+}
+
+func (e *BoardingCodeError) Error() string {
+	if e.AttemptsLeft <= 0 {
+		return "too many incorrect boarding codes; the passenger must issue a new one"
+	}
+	return fmt.Sprintf("incorrect boarding code, %d attempts left", e.AttemptsLeft)
+}
+
+func GenerateBoardingCode() (string, error) {
+	limit := big.NewInt(1)
+	for range BoardingCodeDigits {
+		limit.Mul(limit, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", BoardingCodeDigits, n), nil
+}
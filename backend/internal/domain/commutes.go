@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// How far ahead the worker turns commute schedules into ride requests
+const CommuteMaterialiseAhead = 48 * time.Hour
+
+type CommutesRepository interface {
+	CreateCommute(ctx context.Context, commute *CommuteDBModel) (*CommuteDBModel, error)
+	GetCommuteByID(ctx context.Context, commuteID int64) (*CommuteDBModel, error)
+	GetCommutesByAccountID(ctx context.Context, accountID int64) ([]*CommuteDBModel, error)
+	TransitionCommute(ctx context.Context, commuteID int64, event CommuteEvent) (*CommuteDBModel, error)
+	SkipCommuteDate(ctx context.Context, commuteID int64, date string) error
+	GetUpcomingCommuteRequestIDs(ctx context.Context, commuteID int64, date *string) ([]int64, error)
+	MaterialiseCommutes(ctx context.Context, until time.Time) ([]*RequestDBModel, error)
+}
+
+// CommuteDBModel is a passenger's recurring trip from one of their saved addresses.
+// StartTime is a local "15:04" time in Timezone, and dates are "2006-01-02".
+type CommuteDBModel struct {
+	ID               int64
+	AccountID        int64
+	PickupAddressID  int64
+	PickupLocation   string
+	DropoffLocation  string
+	DropoffLatitude  float64
+	DropoffLongitude float64
+	Compensation     Money
+	Weekdays         []int16 // time.Weekday values, Sunday = 0
+	StartTime        string
+	WindowKind       WindowKind
+	WindowMinutes    int
+	Timezone         string
+	Status           CommuteStatus
+	StartsOn         string
+	EndsOn           *string
+	SkippedDates     []string
+	CreatedAt        string
+	UpdatedAt        string
+}
@@ -0,0 +1,193 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+type CostShareStopKind string
+
+const (
+	CostShareStopPickup  CostShareStopKind = "pickup"
+	CostShareStopDropoff CostShareStopKind = "dropoff"
+)
+
+// CostShareStop is where a passenger gets in or out, in the order the driver reaches them
+type CostShareStop struct {
+	RequestID int64
+	Kind      CostShareStopKind
+	Location  Coordinates
+	at        *time.Time
+}
+
+// CostShare is a passenger's part of the driver's trip cost. Distances are in metres.
+type CostShare struct {
+	RequestID       int64
+	DistanceOnBoard float64
+	// The part of DistanceOnBoard other passengers were also in the car for
+	SharedDistance float64
+	// Fraction of the trip cost, summing to 1 across passengers
+	Share  float64
+	Amount Money
+}
+
+// CostShareRoute is a route through a ride's stops in order. It is worked out before the ride
+// completes, so the split can be settled without routing while the ride's rows are locked.
+type CostShareRoute struct {
+	Stops []CostShareStop
+	Legs  []RouteLeg
+}
+
+// Fits reports whether the route still goes through stops, in the same order
+func (r *CostShareRoute) Fits(stops []CostShareStop) bool {
+	if r == nil || len(r.Stops) != len(stops) || len(r.Legs) != len(stops)-1 {
+		return false
+	}
+	for i, stop := range stops {
+		if r.Stops[i].RequestID != stop.RequestID || r.Stops[i].Kind != stop.Kind {
+			return false
+		}
+	}
+	return true
+}
+
+// CostShareStops orders the pickups and dropoffs of a ride's passengers. Once everyone has been
+// picked up and dropped off the recorded times give the order. Until then everyone is assumed
+// to be picked up before anyone is dropped off, furthest from the ride's destination first.
+func CostShareStops(requests []*RequestDBModel, dest Coordinates) []CostShareStop {
+	stops := make([]CostShareStop, 0, 2*len(requests))
+	recorded := true
+	for _, request := range requests {
+		stops = append(stops,
+			CostShareStop{
+				RequestID: request.ID,
+				Kind:      CostShareStopPickup,
+				Location:  Coordinates{Lat: request.PickupLatitude, Lon: request.PickupLongitude},
+				at:        request.PickedUpAt,
+			},
+			CostShareStop{
+				RequestID: request.ID,
+				Kind:      CostShareStopDropoff,
+				Location:  Coordinates{Lat: request.DropoffLatitude, Lon: request.DropoffLongitude},
+				at:        request.DroppedOffAt,
+			},
+		)
+		if request.PickedUpAt == nil || request.DroppedOffAt == nil {
+			recorded = false
+		}
+	}
+
+	sort.SliceStable(stops, func(i, j int) bool {
+		if recorded {
+			return stops[i].at.Before(*stops[j].at)
+		}
+		if stops[i].Kind != stops[j].Kind {
+			return stops[i].Kind == CostShareStopPickup
+		}
+		return distanceTo(stops[i].Location, dest) > distanceTo(stops[j].Location, dest)
+	})
+
+	return stops
+}
+
+func distanceTo(from, to Coordinates) float64 {
+	return CalculateHaversineDistance(from.Lat, from.Lon, to.Lat, to.Lon)
+}
+
+// SplitTripCost apportions totalCost between the passengers getting in and out at stops, given
+// the legs of the route through them in order. Each leg's distance is split evenly between
+// whoever is on board for it, and passengers pay in proportion to what they were allotted.
+// Amounts are rounded to cents and always add up to totalCost.
+func SplitTripCost(totalCost Money, stops []CostShareStop, legs []RouteLeg) ([]*CostShare, error) {
+	if len(stops) == 0 {
+		return []*CostShare{}, nil
+	}
+	if len(legs) != len(stops)-1 {
+		return nil, fmt.Errorf("route has %d legs for %d stops", len(legs), len(stops))
+	}
+
+	shares := make([]*CostShare, 0)
+	byRequest := make(map[int64]*CostShare)
+	weights := make(map[int64]float64)
+	onBoard := make([]int64, 0)
+
+	for i, stop := range stops {
+		switch stop.Kind {
+		case CostShareStopPickup:
+			if _, ok := byRequest[stop.RequestID]; !ok {
+				share := &CostShare{RequestID: stop.RequestID}
+				byRequest[stop.RequestID] = share
+				shares = append(shares, share)
+			}
+			onBoard = append(onBoard, stop.RequestID)
+		case CostShareStopDropoff:
+			for j, id := range onBoard {
+				if id == stop.RequestID {
+					onBoard = append(onBoard[:j], onBoard[j+1:]...)
+					break
+				}
+			}
+		}
+
+		if i == len(legs) || len(onBoard) == 0 {
+			continue
+		}
+		leg := legs[i]
+		for _, id := range onBoard {
+			share := byRequest[id]
+			share.DistanceOnBoard += leg.Distance
+			if len(onBoard) > 1 {
+				share.SharedDistance += leg.Distance
+			}
+			weights[id] += leg.Distance / float64(len(onBoard))
+		}
+	}
+
+	if len(shares) == 0 {
+		return shares, nil
+	}
+
+	totalWeight := 0.0
+	for _, weight := range weights {
+		totalWeight += weight
+	}
+	for _, share := range shares {
+		if totalWeight > 0 {
+			share.Share = weights[share.RequestID] / totalWeight
+		} else {
+			// Nobody went anywhere, so nobody owes more than anyone else
+			share.Share = 1 / float64(len(shares))
+		}
+	}
+
+	allotCents(totalCost, shares)
+
+	return shares, nil
+}
+
+// allotCents rounds each share of totalCost down to the cent and hands the cents left over to
+// the largest remainders
+func allotCents(totalCost Money, shares []*CostShare) {
+	remainders := make([]float64, len(shares))
+	allotted := Money(0)
+	for i, share := range shares {
+		exact := float64(totalCost) * share.Share
+		cents := Money(math.Floor(exact))
+		remainders[i] = exact - float64(cents)
+		share.Amount = cents
+		allotted += cents
+	}
+
+	order := make([]int, len(shares))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return remainders[order[i]] > remainders[order[j]]
+	})
+	for i := Money(0); i < totalCost-allotted; i++ {
+		shares[order[int(i)%len(order)]].Amount++
+	}
+}
@@ -0,0 +1,142 @@
+package domain
+
+import "testing"
+
+func TestAllotCents(t *testing.T) {
+	tests := []struct {
+		name   string
+		total  Money
+		shares []float64
+		want   []Money
+	}{
+		{"even split", 1000, []float64{0.5, 0.5}, []Money{500, 500}},
+		{"thirds", 100, []float64{1.0 / 3, 1.0 / 3, 1.0 / 3}, []Money{34, 33, 33}},
+		{"thirds of a cent", 1, []float64{1.0 / 3, 1.0 / 3, 1.0 / 3}, []Money{1, 0, 0}},
+		{"nothing to split", 0, []float64{0.25, 0.75}, []Money{0, 0}},
+		{"largest remainder first", 1001, []float64{0.1, 0.3, 0.6}, []Money{100, 300, 601}},
+		{"sole passenger", 1999, []float64{1}, []Money{1999}},
+		{"uneven sevenths", 2500, []float64{1.0 / 7, 2.0 / 7, 4.0 / 7}, []Money{357, 714, 1429}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shares := make([]*CostShare, len(tt.shares))
+			for i, share := range tt.shares {
+				shares[i] = &CostShare{RequestID: int64(i + 1), Share: share}
+			}
+
+			allotCents(tt.total, shares)
+
+			sum := Money(0)
+			for i, share := range shares {
+				sum += share.Amount
+				if share.Amount != tt.want[i] {
+					t.Errorf("share %d is %s, want %s", i, share.Amount, tt.want[i])
+				}
+			}
+			if sum != tt.total {
+				t.Errorf("shares add up to %s, want %s", sum, tt.total)
+			}
+		})
+	}
+}
+
+func TestSplitTripCost(t *testing.T) {
+	pickup := func(id int64) CostShareStop { return CostShareStop{RequestID: id, Kind: CostShareStopPickup} }
+	dropoff := func(id int64) CostShareStop { return CostShareStop{RequestID: id, Kind: CostShareStopDropoff} }
+	legs := func(distances ...float64) []RouteLeg {
+		legs := make([]RouteLeg, len(distances))
+		for i, distance := range distances {
+			legs[i] = RouteLeg{Distance: distance}
+		}
+		return legs
+	}
+
+	tests := []struct {
+		name  string
+		total Money
+		stops []CostShareStop
+		legs  []RouteLeg
+		// Per request, in the order they were picked up
+		want       []Money
+		wantShared []float64
+	}{
+		{
+			name:       "alone",
+			total:      1234,
+			stops:      []CostShareStop{pickup(1), dropoff(1)},
+			legs:       legs(5000),
+			want:       []Money{1234},
+			wantShared: []float64{0},
+		},
+		{
+			name:       "together the whole way",
+			total:      1001,
+			stops:      []CostShareStop{pickup(1), pickup(2), dropoff(1), dropoff(2)},
+			legs:       legs(0, 4000, 0),
+			want:       []Money{501, 500},
+			wantShared: []float64{4000, 4000},
+		},
+		{
+			// 1 is allotted 1000m alone and half of 3000m shared, 2 the other half and 2000m alone
+			name:       "overlapping",
+			total:      1000,
+			stops:      []CostShareStop{pickup(1), pickup(2), dropoff(1), dropoff(2)},
+			legs:       legs(1000, 3000, 2000),
+			want:       []Money{417, 583},
+			wantShared: []float64{3000, 3000},
+		},
+		{
+			name:       "one after the other",
+			total:      999,
+			stops:      []CostShareStop{pickup(1), dropoff(1), pickup(2), dropoff(2)},
+			legs:       legs(1000, 500, 2000),
+			want:       []Money{333, 666},
+			wantShared: []float64{0, 0},
+		},
+		{
+			name:       "nobody went anywhere",
+			total:      1000,
+			stops:      []CostShareStop{pickup(1), pickup(2), pickup(3), dropoff(1), dropoff(2), dropoff(3)},
+			legs:       legs(0, 0, 0, 0, 0),
+			want:       []Money{334, 333, 333},
+			wantShared: []float64{0, 0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shares, err := SplitTripCost(tt.total, tt.stops, tt.legs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(shares) != len(tt.want) {
+				t.Fatalf("got %d shares, want %d", len(shares), len(tt.want))
+			}
+
+			sum := Money(0)
+			for i, share := range shares {
+				sum += share.Amount
+				if share.Amount != tt.want[i] {
+					t.Errorf("request %d pays %s, want %s", share.RequestID, share.Amount, tt.want[i])
+				}
+				if share.SharedDistance != tt.wantShared[i] {
+					t.Errorf("request %d shared %vm, want %vm", share.RequestID, share.SharedDistance, tt.wantShared[i])
+				}
+			}
+			if sum != tt.total {
+				t.Errorf("shares add up to %s, want %s", sum, tt.total)
+			}
+		})
+	}
+}
+
+func TestSplitTripCostLegMismatch(t *testing.T) {
+	stops := []CostShareStop{
+		{RequestID: 1, Kind: CostShareStopPickup},
+		{RequestID: 1, Kind: CostShareStopDropoff},
+	}
+	if _, err := SplitTripCost(1000, stops, nil); err == nil {
+		t.Error("got no error for a route without legs")
+	}
+}
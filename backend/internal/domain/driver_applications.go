@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrDriverNotApproved is returned when a driver who has not been approved, or has been
+// suspended, tries to take passengers. The API maps it to 403 Forbidden.
+var ErrDriverNotApproved = errors.New("your driver application has not been approved")
+
+type DriverApplicationsRepository interface {
+	CreateDriverApplication(ctx context.Context, accountID int64) (*DriverApplicationDBModel, error)
+	GetDriverApplication(ctx context.Context, accountID int64) (*DriverApplicationDBModel, error)
+	GetDriverApplications(ctx context.Context, status *DriverApplicationStatus) ([]*DriverApplicationDBModel, error)
+	ReviewDriverApplication(ctx context.Context, accountID int64, event DriverApplicationEvent, reviewerID int64, reason *string) (*DriverApplicationDBModel, error)
+}
+
+// DriverApplicationDBModel is a driver's request to take passengers and the admin's last decision on it
+type DriverApplicationDBModel struct {
+	AccountID  int64
+	Status     DriverApplicationStatus
+	Reason     *string // given by the admin with their decision
+	ReviewedBy *int64
+	ReviewedAt *time.Time
+	// The driver, for admins reviewing the application
+	FirstName string
+	LastName  string
+	Email     string
+	CreatedAt string
+	UpdatedAt string
+}
+
+// CheckApproved returns ErrDriverNotApproved unless the application exists and is approved
+func (a *DriverApplicationDBModel) CheckApproved() error {
+	if a == nil || a.Status != DriverApplicationStatusApproved {
+		return ErrDriverNotApproved
+	}
+	return nil
+}
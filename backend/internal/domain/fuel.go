@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"math"
+	"time"
+)
+
+// FuelType is what a vehicle runs on
+type FuelType string
+
+const (
+	FuelTypePetrol        FuelType = "petrol"
+	FuelTypePremiumPetrol FuelType = "premium_petrol"
+	FuelTypeDiesel        FuelType = "diesel"
+	FuelTypeLPG           FuelType = "lpg"
+	FuelTypeElectric      FuelType = "electric"
+)
+
+// Vehicles without fuel details are costed as an average Australian petrol car
+const (
+	DefaultFuelType        = FuelTypePetrol
+	DefaultFuelConsumption = 10.0
+)
+
+// The passenger shares the distance they ride with the driver, so pays this fraction of it.
+// Their detour is theirs alone.
+const SharedDistanceShare = 0.5
+
+// Unit is what the fuel is priced and consumed by
+func (f FuelType) Unit() string {
+	if f == FuelTypeElectric {
+		return "kWh"
+	}
+	return "L"
+}
+
+// FuelPriceDBModel is what a litre, or a kWh for electric, costs
+type FuelPriceDBModel struct {
+	FuelType  FuelType
+	Price     Money
+	UpdatedBy *int64
+	UpdatedAt time.Time
+}
+
+// FuelCost is what a driver's vehicle costs to run
+type FuelCost struct {
+	FuelType FuelType
+	// Per 100 km, in the fuel's unit
+	Consumption float64
+	// Set when the vehicle had no fuel details and the defaults were used
+	Assumed   bool
+	UnitPrice Money
+}
+
+// VehicleFuel returns the vehicle's fuel type and consumption, or the defaults when it has none
+func VehicleFuel(vehicle *VehicleDBModel) (FuelType, float64, bool) {
+	if vehicle == nil || vehicle.FuelType == nil || vehicle.FuelConsumption == nil {
+		return DefaultFuelType, DefaultFuelConsumption, true
+	}
+	return *vehicle.FuelType, *vehicle.FuelConsumption, false
+}
+
+// PerKm is the fuel cost of a kilometre, in cents
+func (c FuelCost) PerKm() float64 {
+	return c.Consumption / 100 * float64(c.UnitPrice)
+}
+
+// FairContribution is what a passenger should put towards the driver's running cost
+type FairContribution struct {
+	Fuel       FuelCost
+	CostPerKm  Money
+	DetourKm   float64
+	DetourCost Money
+	SharedKm   float64
+	SharedCost Money
+	// Tolls are split the same way as fuel
+	DetourTolls Money
+	SharedTolls Money
+	Total       Money
+}
+
+// FairContribution costs a passenger's detour, in full, and their share of the distance they
+// ride with the driver, both in metres
+func (c FuelCost) FairContribution(detourDistance, sharedDistance float64) FairContribution {
+	contribution := FairContribution{
+		Fuel:      c,
+		CostPerKm: Money(math.Round(c.PerKm())),
+		DetourKm:  math.Max(detourDistance, 0) / 1000,
+		SharedKm:  math.Max(sharedDistance, 0) / 1000,
+	}
+	contribution.DetourCost = Money(math.Round(contribution.DetourKm * c.PerKm()))
+	contribution.SharedCost = Money(math.Round(contribution.SharedKm * c.PerKm() * SharedDistanceShare))
+	contribution.Total = contribution.DetourCost + contribution.SharedCost
+	return contribution
+}
+
+// AddTolls adds what the passenger owes in tolls: any the detour adds, in full, and their share
+// of those on the leg they ride
+func (c *FairContribution) AddTolls(detourTolls, sharedTolls Money) {
+	c.DetourTolls = max(detourTolls, 0)
+	c.SharedTolls = Money(math.Round(float64(sharedTolls) * SharedDistanceShare))
+	c.Total += c.DetourTolls + c.SharedTolls
+}
@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrRefundExceedsCharge is returned when a refund would give back more than is left of the
+// charge. The API maps it to 409 Conflict.
+var ErrRefundExceedsCharge = errors.New("refund is more than what is left of the charge")
+
+// Statement pages hold this many lines unless the client says otherwise
+const DefaultStatementLimit = 50
+
+// LedgerEntryKind is why money moved between accounts
+type LedgerEntryKind string
+
+const (
+	LedgerEntryRideCompensation LedgerEntryKind = "ride_compensation" // passenger owes the driver for a completed ride
+	LedgerEntrySettlement       LedgerEntryKind = "settlement"        // what was owed has been paid
+	LedgerEntryRefund           LedgerEntryKind = "refund"            // some or all of a ride's compensation given back
+	LedgerEntryAdjustment       LedgerEntryKind = "adjustment"        // correction made by an admin
+)
+
+// LedgerPosting moves Amount into an account's balance, or out of it when negative. A user's
+// balance is what they are owed, so passengers go negative when they ride.
+type LedgerPosting struct {
+	AccountID int64
+	Amount    Money
+}
+
+// LedgerEntryDBModel is a set of postings that add up to zero
+type LedgerEntryDBModel struct {
+	ID           int64
+	Kind         LedgerEntryKind
+	RideID       *int64
+	RequestID    *int64
+	SettlementID *int64
+	RefundOf     *int64 // the ride compensation entry a refund gives back
+	Description  string
+	CreatedBy    *int64
+	Postings     []LedgerPosting
+	CreatedAt    time.Time
+}
+
+// Balanced reports whether the postings form a valid entry: at least two, none empty, summing to zero
+func (e *LedgerEntryDBModel) Balanced() bool {
+	if len(e.Postings) < 2 {
+		return false
+	}
+	var sum Money
+	for _, posting := range e.Postings {
+		if posting.Amount == 0 {
+			return false
+		}
+		sum += posting.Amount
+	}
+	return sum == 0
+}
+
+// StatementLineDBModel is one posting to an account with the balance it left
+type StatementLineDBModel struct {
+	PostingID    int64
+	EntryID      int64
+	Kind         LedgerEntryKind
+	RideID       *int64
+	RequestID    *int64
+	SettlementID *int64
+	Description  string
+	Amount       Money
+	BalanceAfter Money
+	// The other side of the entry, when there was exactly one
+	CounterpartyID *int64
+	CreatedAt      time.Time
+}
+
+// StatementFilter narrows an account's statement. Empty fields do not filter.
+type StatementFilter struct {
+	AccountID int64
+	From      *time.Time
+	To        *time.Time
+	// Cursor: only postings older than this one
+	BeforePostingID *int64
+	Limit           int
+}
+
+// SettlementDBModel is money one user owes another, recorded when a ride completes and marked
+// paid once the payee has received it
+type SettlementDBModel struct {
+	ID        int64
+	PayerID   int64
+	PayeeID   int64
+	RideID    *int64
+	RequestID *int64
+	Amount    Money
+	Status    SettlementStatus
+	PaidAt    *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type LedgerRepository interface {
+	GetBalance(ctx context.Context, accountID int64) (Money, error)
+	GetStatement(ctx context.Context, filter StatementFilter) ([]*StatementLineDBModel, error)
+	GetSettlements(ctx context.Context, accountID int64, status *SettlementStatus) ([]*SettlementDBModel, error)
+	GetSettlementByID(ctx context.Context, settlementID int64) (*SettlementDBModel, error)
+	MarkSettlementPaid(ctx context.Context, settlementID int64) (*SettlementDBModel, error)
+	GetRideCharge(ctx context.Context, requestID int64) (*LedgerEntryDBModel, Money, error)
+	Refund(ctx context.Context, chargeID int64, amount Money, reason string, createdBy int64) (*LedgerEntryDBModel, error)
+	PostAdjustment(ctx context.Context, fromAccountID, toAccountID int64, amount Money, reason string, createdBy int64) (*LedgerEntryDBModel, error)
+}
@@ -0,0 +1,116 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Errors for drivers whose licence does not let them take a ride. The API maps them to
+// 403 Forbidden.
+var (
+	ErrLicenseRequired    = errors.New("a driver licence is required to drive")
+	ErrLicenseExpired     = errors.New("your driver licence has expired")
+	ErrLearnerLicense     = errors.New("learner drivers cannot drive passengers")
+	ErrPeerPassengerLimit = errors.New("P1 drivers may carry only one peer-age passenger")
+)
+
+// LicenseType is the Victorian licence stage
+type LicenseType string
+
+const (
+	LicenseTypeLearner LicenseType = "l"
+	LicenseTypeP1      LicenseType = "p1"
+	LicenseTypeP2      LicenseType = "p2"
+	LicenseTypeFull    LicenseType = "full"
+)
+
+// LicenseCondition is a condition code printed on the licence card
+type LicenseCondition string
+
+const (
+	LicenseConditionA LicenseCondition = "A"
+	LicenseConditionI LicenseCondition = "I"
+	LicenseConditionS LicenseCondition = "S"
+	LicenseConditionV LicenseCondition = "V"
+	LicenseConditionX LicenseCondition = "X"
+	LicenseConditionZ LicenseCondition = "Z"
+)
+
+// P1 drivers may carry at most this many passengers aged between PeerAgeMin and PeerAgeMax
+const (
+	P1PeerPassengerLimit = 1
+	PeerAgeMin           = 16
+	PeerAgeMax           = 21
+)
+
+type LicenseDBModel struct {
+	ID          int64
+	LicenseNo   string
+	Type        LicenseType
+	Conditions  []LicenseCondition
+	DateOfIssue time.Time
+	Expiry      time.Time
+	CardNumber  string
+	AccountID   int64
+	CreatedAt   string
+	UpdatedAt   string
+}
+
+// Licences are Victorian, so their dates are checked against the day in Melbourne
+const LicenseTimezone = "Australia/Melbourne"
+
+// Expired reports whether the licence has run out. It is valid through its expiry date.
+func (l *LicenseDBModel) Expired(now time.Time) bool {
+	return now.Format(time.DateOnly) > l.Expiry.Format(time.DateOnly)
+}
+
+// CheckLicense returns why the driver cannot drive passengers at all, or nil if they can
+func CheckLicense(license *LicenseDBModel, now time.Time) error {
+	switch {
+	case license == nil:
+		return ErrLicenseRequired
+	case license.Expired(now):
+		return ErrLicenseExpired
+	case license.Type == LicenseTypeLearner:
+		return ErrLearnerLicense
+	}
+	return nil
+}
+
+// PassengerParty is a request's passenger and whoever travels with them
+type PassengerParty struct {
+	DateOfBirth *time.Time
+	PartySize   int
+}
+
+// PeerPassengers counts the people in the parties who are, or may be, of peer age. Passengers
+// who have not given a date of birth, and everyone travelling with them, are counted as peers.
+func PeerPassengers(parties []PassengerParty, now time.Time) int {
+	peers := 0
+	for _, party := range parties {
+		if party.DateOfBirth == nil {
+			peers++
+		} else if age := AgeOn(*party.DateOfBirth, now); age >= PeerAgeMin && age <= PeerAgeMax {
+			peers++
+		}
+		peers += max(party.PartySize-1, 0)
+	}
+	return peers
+}
+
+// CheckPassengers returns why the licence does not allow carrying the parties together
+func (l *LicenseDBModel) CheckPassengers(parties []PassengerParty, now time.Time) error {
+	if l.Type == LicenseTypeP1 && PeerPassengers(parties, now) > P1PeerPassengerLimit {
+		return ErrPeerPassengerLimit
+	}
+	return nil
+}
+
+// AgeOn returns how old someone born on dob is on the given day
+func AgeOn(dob, now time.Time) int {
+	age := now.Year() - dob.Year()
+	if now.Month() < dob.Month() || (now.Month() == dob.Month() && now.Day() < dob.Day()) {
+		age--
+	}
+	return age
+}
@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var licenseTestNow = time.Date(2025, time.June, 15, 10, 0, 0, 0, time.UTC)
+
+func TestCheckLicense(t *testing.T) {
+	day := 24 * time.Hour
+	license := func(licenseType LicenseType, expiry time.Time) *LicenseDBModel {
+		return &LicenseDBModel{Type: licenseType, Expiry: expiry}
+	}
+
+	tests := []struct {
+		name    string
+		license *LicenseDBModel
+		want    error
+	}{
+		{"no licence", nil, ErrLicenseRequired},
+		{"full", license(LicenseTypeFull, licenseTestNow.Add(365*day)), nil},
+		{"P1", license(LicenseTypeP1, licenseTestNow.Add(365*day)), nil},
+		{"P2", license(LicenseTypeP2, licenseTestNow.Add(365*day)), nil},
+		{"learner", license(LicenseTypeLearner, licenseTestNow.Add(365*day)), ErrLearnerLicense},
+		{"expires today", license(LicenseTypeFull, licenseTestNow.Truncate(day)), nil},
+		{"expired yesterday", license(LicenseTypeFull, licenseTestNow.Add(-day)), ErrLicenseExpired},
+		{"expired P1", license(LicenseTypeP1, licenseTestNow.Add(-30*day)), ErrLicenseExpired},
+		{"expired learner", license(LicenseTypeLearner, licenseTestNow.Add(-day)), ErrLicenseExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckLicense(tt.license, licenseTestNow); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPassengers(t *testing.T) {
+	bornAged := func(years int) *time.Time {
+		dob := licenseTestNow.AddDate(-years, 0, 0)
+		return &dob
+	}
+	peer := PassengerParty{DateOfBirth: bornAged(19), PartySize: 1}
+	adult := PassengerParty{DateOfBirth: bornAged(30), PartySize: 1}
+	child := PassengerParty{DateOfBirth: bornAged(15), PartySize: 1}
+	undisclosed := PassengerParty{PartySize: 1}
+
+	tests := []struct {
+		name        string
+		licenseType LicenseType
+		parties     []PassengerParty
+		want        error
+	}{
+		{"P1 with nobody", LicenseTypeP1, nil, nil},
+		{"P1 with one peer", LicenseTypeP1, []PassengerParty{peer}, nil},
+		{"P1 with two peers", LicenseTypeP1, []PassengerParty{peer, peer}, ErrPeerPassengerLimit},
+		{"P1 with a peer and adults", LicenseTypeP1, []PassengerParty{peer, adult, adult}, nil},
+		{"P1 with a peer and a child", LicenseTypeP1, []PassengerParty{peer, child}, nil},
+		{"P1 with a passenger of unknown age", LicenseTypeP1, []PassengerParty{undisclosed}, nil},
+		{"P1 with a peer and a passenger of unknown age", LicenseTypeP1, []PassengerParty{peer, undisclosed}, ErrPeerPassengerLimit},
+		{"P1 with an adult and a companion", LicenseTypeP1, []PassengerParty{{DateOfBirth: bornAged(30), PartySize: 2}}, nil},
+		{"P1 with an adult and two companions", LicenseTypeP1, []PassengerParty{{DateOfBirth: bornAged(30), PartySize: 3}}, ErrPeerPassengerLimit},
+		{"P1 with a peer aged 16", LicenseTypeP1, []PassengerParty{peer, {DateOfBirth: bornAged(16), PartySize: 1}}, ErrPeerPassengerLimit},
+		{"P1 with a peer aged 21", LicenseTypeP1, []PassengerParty{peer, {DateOfBirth: bornAged(21), PartySize: 1}}, ErrPeerPassengerLimit},
+		{"P1 with a passenger aged 22", LicenseTypeP1, []PassengerParty{peer, {DateOfBirth: bornAged(22), PartySize: 1}}, nil},
+		{"P2 with four peers", LicenseTypeP2, []PassengerParty{peer, peer, peer, undisclosed}, nil},
+		{"full with four peers", LicenseTypeFull, []PassengerParty{peer, peer, peer, peer}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			license := &LicenseDBModel{Type: tt.licenseType}
+			if err := license.CheckPassengers(tt.parties, licenseTestNow); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAgeOn(t *testing.T) {
+	dob := time.Date(2004, time.June, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		on   time.Time
+		want int
+	}{
+		{time.Date(2025, time.June, 14, 0, 0, 0, 0, time.UTC), 20},
+		{time.Date(2025, time.June, 15, 0, 0, 0, 0, time.UTC), 21},
+		{time.Date(2025, time.May, 20, 0, 0, 0, 0, time.UTC), 20},
+		{time.Date(2025, time.December, 1, 0, 0, 0, 0, time.UTC), 21},
+	}
+
+	for _, tt := range tests {
+		if got := AgeOn(dob, tt.on); got != tt.want {
+			t.Errorf("age on %s is %d, want %d", tt.on.Format(time.DateOnly), got, tt.want)
+		}
+	}
+}
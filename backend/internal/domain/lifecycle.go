@@ -0,0 +1,414 @@
+package domain
+
+import "fmt"
+
+type RideStatus string
+
+const (
+	RideStatusAwaitingConfirmation RideStatus = "awaiting_confirmation"
+	RideStatusInProgress           RideStatus = "in_progress"
+	RideStatusCompleted            RideStatus = "completed"
+	RideStatusRejected             RideStatus = "rejected"
+	RideStatusCancelled            RideStatus = "cancelled"
+)
+
+type RideEvent string
+
+const (
+	RideEventConfirm  RideEvent = "confirm"  // at least one proposal accepted once all have answered
+	RideEventReject   RideEvent = "reject"   // every proposal rejected
+	RideEventComplete RideEvent = "complete" // driver finished the trip
+	RideEventCancel   RideEvent = "cancel"   // driver called it off, or every passenger dropped out
+)
+
+type ProposalStatus string
+
+const (
+	ProposalStatusPending   ProposalStatus = "pending"
+	ProposalStatusAccepted  ProposalStatus = "accepted"
+	ProposalStatusRejected  ProposalStatus = "rejected"
+	ProposalStatusCancelled ProposalStatus = "cancelled"
+	ProposalStatusExpired   ProposalStatus = "expired" // left unanswered past the proposal TTL
+)
+
+// ProposalEventAccept and ProposalEventReject match the "confirm" field accepted by the API
+type ProposalEvent string
+
+const (
+	ProposalEventAccept ProposalEvent = "accept"
+	ProposalEventReject ProposalEvent = "reject"
+	ProposalEventCancel ProposalEvent = "cancel"
+	ProposalEventExpire ProposalEvent = "expire"
+)
+
+type RequestStatus string
+
+const (
+	RequestStatusOpen       RequestStatus = "open"        // discoverable by drivers
+	RequestStatusMatched    RequestStatus = "matched"     // attached to an in-progress ride
+	RequestStatusPickedUp   RequestStatus = "picked_up"   // passenger is in the car
+	RequestStatusDroppedOff RequestStatus = "dropped_off" // passenger has left the car at their dropoff
+	RequestStatusCancelled  RequestStatus = "cancelled"
+	RequestStatusExpired    RequestStatus = "expired" // stayed open past the request TTL
+)
+
+type RequestEvent string
+
+const (
+	RequestEventMatch   RequestEvent = "match"
+	RequestEventPickup  RequestEvent = "pickup"
+	RequestEventDropoff RequestEvent = "dropoff"
+	RequestEventCancel  RequestEvent = "cancel"
+	RequestEventRelease RequestEvent = "release" // put back into the pool when its ride falls through
+	RequestEventExpire  RequestEvent = "expire"
+)
+
+type CommuteStatus string
+
+const (
+	CommuteStatusActive CommuteStatus = "active"
+	CommuteStatusPaused CommuteStatus = "paused"
+	CommuteStatusEnded  CommuteStatus = "ended"
+)
+
+type CommuteEvent string
+
+const (
+	CommuteEventPause  CommuteEvent = "pause"
+	CommuteEventResume CommuteEvent = "resume"
+	CommuteEventEnd    CommuteEvent = "end"
+)
+
+type OfferStatus string
+
+const (
+	OfferStatusOpen      OfferStatus = "open" // taking bookings until departure
+	OfferStatusCancelled OfferStatus = "cancelled"
+)
+
+type OfferEvent string
+
+const (
+	OfferEventCancel OfferEvent = "cancel"
+)
+
+type BookingStatus string
+
+const (
+	BookingStatusPending   BookingStatus = "pending"
+	BookingStatusApproved  BookingStatus = "approved" // passenger has a matched request on the offer's ride
+	BookingStatusDeclined  BookingStatus = "declined"
+	BookingStatusCancelled BookingStatus = "cancelled"
+)
+
+// BookingEventApprove and BookingEventDecline match the "decision" field accepted by the API
+type BookingEvent string
+
+const (
+	BookingEventApprove BookingEvent = "approve"
+	BookingEventDecline BookingEvent = "decline"
+	BookingEventCancel  BookingEvent = "cancel"
+)
+
+type CounterOfferStatus string
+
+const (
+	CounterOfferStatusPending   CounterOfferStatus = "pending"
+	CounterOfferStatusAccepted  CounterOfferStatus = "accepted" // the amount is what the proposal settles on
+	CounterOfferStatusRejected  CounterOfferStatus = "rejected" // the last agreed amount stands
+	CounterOfferStatusCountered CounterOfferStatus = "countered"
+)
+
+// CounterOfferEvent values match the "response" field accepted by the API
+type CounterOfferEvent string
+
+const (
+	CounterOfferEventAccept  CounterOfferEvent = "accept"
+	CounterOfferEventReject  CounterOfferEvent = "reject"
+	CounterOfferEventCounter CounterOfferEvent = "counter"
+)
+
+type SettlementStatus string
+
+const (
+	SettlementStatusPending   SettlementStatus = "pending"
+	SettlementStatusPaid      SettlementStatus = "paid"
+	SettlementStatusCancelled SettlementStatus = "cancelled" // refunded in full before it was paid
+)
+
+type SettlementEvent string
+
+const (
+	SettlementEventPay    SettlementEvent = "pay"
+	SettlementEventCancel SettlementEvent = "cancel"
+)
+
+type DriverApplicationStatus string
+
+const (
+	DriverApplicationStatusPending   DriverApplicationStatus = "pending"
+	DriverApplicationStatusApproved  DriverApplicationStatus = "approved" // can take passengers
+	DriverApplicationStatusSuspended DriverApplicationStatus = "suspended"
+)
+
+// DriverApplicationEvent values match the "decision" field accepted by the admin API
+type DriverApplicationEvent string
+
+const (
+	DriverApplicationEventApprove DriverApplicationEvent = "approve"
+	DriverApplicationEventSuspend DriverApplicationEvent = "suspend"
+)
+
+var rideMachine = stateMachine[RideStatus, RideEvent]{
+	entity: "ride",
+	transitions: map[RideStatus]map[RideEvent]RideStatus{
+		RideStatusAwaitingConfirmation: {
+			RideEventConfirm: RideStatusInProgress,
+			RideEventReject:  RideStatusRejected,
+			RideEventCancel:  RideStatusCancelled,
+		},
+		RideStatusInProgress: {
+			RideEventComplete: RideStatusCompleted,
+			RideEventCancel:   RideStatusCancelled,
+		},
+	},
+}
+
+var proposalMachine = stateMachine[ProposalStatus, ProposalEvent]{
+	entity: "proposal",
+	transitions: map[ProposalStatus]map[ProposalEvent]ProposalStatus{
+		ProposalStatusPending: {
+			ProposalEventAccept: ProposalStatusAccepted,
+			ProposalEventReject: ProposalStatusRejected,
+			ProposalEventCancel: ProposalStatusCancelled,
+			ProposalEventExpire: ProposalStatusExpired,
+		},
+		ProposalStatusAccepted: {
+			ProposalEventCancel: ProposalStatusCancelled,
+		},
+	},
+}
+
+var requestMachine = stateMachine[RequestStatus, RequestEvent]{
+	entity: "request",
+	transitions: map[RequestStatus]map[RequestEvent]RequestStatus{
+		RequestStatusOpen: {
+			RequestEventMatch:  RequestStatusMatched,
+			RequestEventCancel: RequestStatusCancelled,
+			RequestEventExpire: RequestStatusExpired,
+		},
+		RequestStatusMatched: {
+			RequestEventPickup:  RequestStatusPickedUp,
+			RequestEventCancel:  RequestStatusCancelled,
+			RequestEventRelease: RequestStatusOpen,
+		},
+		RequestStatusPickedUp: {
+			RequestEventDropoff: RequestStatusDroppedOff,
+		},
+	},
+}
+
+var commuteMachine = stateMachine[CommuteStatus, CommuteEvent]{
+	entity: "commute",
+	transitions: map[CommuteStatus]map[CommuteEvent]CommuteStatus{
+		CommuteStatusActive: {
+			CommuteEventPause: CommuteStatusPaused,
+			CommuteEventEnd:   CommuteStatusEnded,
+		},
+		CommuteStatusPaused: {
+			CommuteEventResume: CommuteStatusActive,
+			CommuteEventEnd:    CommuteStatusEnded,
+		},
+	},
+}
+
+var offerMachine = stateMachine[OfferStatus, OfferEvent]{
+	entity: "offer",
+	transitions: map[OfferStatus]map[OfferEvent]OfferStatus{
+		OfferStatusOpen: {
+			OfferEventCancel: OfferStatusCancelled,
+		},
+	},
+}
+
+var bookingMachine = stateMachine[BookingStatus, BookingEvent]{
+	entity: "booking",
+	transitions: map[BookingStatus]map[BookingEvent]BookingStatus{
+		BookingStatusPending: {
+			BookingEventApprove: BookingStatusApproved,
+			BookingEventDecline: BookingStatusDeclined,
+			BookingEventCancel:  BookingStatusCancelled,
+		},
+	},
+}
+
+var counterOfferMachine = stateMachine[CounterOfferStatus, CounterOfferEvent]{
+	entity: "counter-offer",
+	transitions: map[CounterOfferStatus]map[CounterOfferEvent]CounterOfferStatus{
+		CounterOfferStatusPending: {
+			CounterOfferEventAccept:  CounterOfferStatusAccepted,
+			CounterOfferEventReject:  CounterOfferStatusRejected,
+			CounterOfferEventCounter: CounterOfferStatusCountered,
+		},
+	},
+}
+
+var settlementMachine = stateMachine[SettlementStatus, SettlementEvent]{
+	entity: "settlement",
+	transitions: map[SettlementStatus]map[SettlementEvent]SettlementStatus{
+		SettlementStatusPending: {
+			SettlementEventPay:    SettlementStatusPaid,
+			SettlementEventCancel: SettlementStatusCancelled,
+		},
+	},
+}
+
+var driverApplicationMachine = stateMachine[DriverApplicationStatus, DriverApplicationEvent]{
+	entity: "driver application",
+	transitions: map[DriverApplicationStatus]map[DriverApplicationEvent]DriverApplicationStatus{
+		DriverApplicationStatusPending: {
+			DriverApplicationEventApprove: DriverApplicationStatusApproved,
+			DriverApplicationEventSuspend: DriverApplicationStatusSuspended,
+		},
+		DriverApplicationStatusApproved: {
+			DriverApplicationEventSuspend: DriverApplicationStatusSuspended,
+		},
+		DriverApplicationStatusSuspended: {
+			DriverApplicationEventApprove: DriverApplicationStatusApproved,
+		},
+	},
+}
+
+// InvalidTransitionError is returned when an event is not allowed from the current state.
+// The API maps it to 409 Conflict.
+type InvalidTransitionError struct {
+	Entity string
+	From   string
+	Event  string
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("cannot %s %s in state %q", e.Event, e.Entity, e.From)
+}
+
+type stateMachine[S ~string, E ~string] struct {
+	entity      string
+	transitions map[S]map[E]S
+}
+
+func (m stateMachine[S, E]) next(from S, event E) (S, error) {
+	if to, ok := m.transitions[from][event]; ok {
+		return to, nil
+	}
+	return from, &InvalidTransitionError{Entity: m.entity, From: string(from), Event: string(event)}
+}
+
+// Next returns the state reached by applying event, or an *InvalidTransitionError
+func (s RideStatus) Next(event RideEvent) (RideStatus, error) {
+	return rideMachine.next(s, event)
+}
+
+func (s RideStatus) Can(event RideEvent) bool {
+	_, err := s.Next(event)
+	return err == nil
+}
+
+func (s ProposalStatus) Next(event ProposalEvent) (ProposalStatus, error) {
+	return proposalMachine.next(s, event)
+}
+
+func (s ProposalStatus) Can(event ProposalEvent) bool {
+	_, err := s.Next(event)
+	return err == nil
+}
+
+func (s RequestStatus) Next(event RequestEvent) (RequestStatus, error) {
+	return requestMachine.next(s, event)
+}
+
+func (s RequestStatus) Can(event RequestEvent) bool {
+	_, err := s.Next(event)
+	return err == nil
+}
+
+func (s CommuteStatus) Next(event CommuteEvent) (CommuteStatus, error) {
+	return commuteMachine.next(s, event)
+}
+
+func (s CommuteStatus) Can(event CommuteEvent) bool {
+	_, err := s.Next(event)
+	return err == nil
+}
+
+func (s OfferStatus) Next(event OfferEvent) (OfferStatus, error) {
+	return offerMachine.next(s, event)
+}
+
+func (s OfferStatus) Can(event OfferEvent) bool {
+	_, err := s.Next(event)
+	return err == nil
+}
+
+func (s BookingStatus) Next(event BookingEvent) (BookingStatus, error) {
+	return bookingMachine.next(s, event)
+}
+
+func (s BookingStatus) Can(event BookingEvent) bool {
+	_, err := s.Next(event)
+	return err == nil
+}
+
+func (s CounterOfferStatus) Next(event CounterOfferEvent) (CounterOfferStatus, error) {
+	return counterOfferMachine.next(s, event)
+}
+
+func (s CounterOfferStatus) Can(event CounterOfferEvent) bool {
+	_, err := s.Next(event)
+	return err == nil
+}
+
+func (s SettlementStatus) Next(event SettlementEvent) (SettlementStatus, error) {
+	return settlementMachine.next(s, event)
+}
+
+func (s SettlementStatus) Can(event SettlementEvent) bool {
+	_, err := s.Next(event)
+	return err == nil
+}
+
+func (s DriverApplicationStatus) Next(event DriverApplicationEvent) (DriverApplicationStatus, error) {
+	return driverApplicationMachine.next(s, event)
+}
+
+func (s DriverApplicationStatus) Can(event DriverApplicationEvent) bool {
+	_, err := s.Next(event)
+	return err == nil
+}
+
+// Apply moves the ride to its next state in place
+func (r *RideDBModel) Apply(event RideEvent) error {
+	next, err := r.Status.Next(event)
+	if err != nil {
+		return err
+	}
+	r.Status = next
+	return nil
+}
+
+func (p *ProposalDBModel) Apply(event ProposalEvent) error {
+	next, err := p.Status.Next(event)
+	if err != nil {
+		return err
+	}
+	p.Status = next
+	return nil
+}
+
+func (r *RequestDBModel) Apply(event RequestEvent) error {
+	next, err := r.Status.Next(event)
+	if err != nil {
+		return err
+	}
+	r.Status = next
+	return nil
+}
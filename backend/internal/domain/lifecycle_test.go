@@ -0,0 +1,213 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+// checkMachine tries every event from every status, expecting exactly the transitions in want
+// and an *InvalidTransitionError that leaves the status alone for everything else
+func checkMachine[S ~string, E ~string](t *testing.T, entity string, statuses []S, events []E, want map[S]map[E]S, next func(S, E) (S, error)) {
+	t.Helper()
+
+	for _, from := range statuses {
+		for _, event := range events {
+			got, err := next(from, event)
+			to, valid := want[from][event]
+			if valid {
+				if err != nil {
+					t.Errorf("%s %s on %s: unexpected error: %v", entity, from, event, err)
+				} else if got != to {
+					t.Errorf("%s %s on %s: got %s, want %s", entity, from, event, got, to)
+				}
+				continue
+			}
+
+			var transitionErr *InvalidTransitionError
+			if !errors.As(err, &transitionErr) {
+				t.Errorf("%s %s on %s: got %s, %v, want an invalid transition", entity, from, event, got, err)
+				continue
+			}
+			if got != from {
+				t.Errorf("%s %s on %s: status moved to %s on an invalid transition", entity, from, event, got)
+			}
+			if transitionErr.Entity != entity || transitionErr.From != string(from) || transitionErr.Event != string(event) {
+				t.Errorf("%s %s on %s: error describes %+v", entity, from, event, *transitionErr)
+			}
+		}
+	}
+}
+
+func TestRideTransitions(t *testing.T) {
+	statuses := []RideStatus{RideStatusAwaitingConfirmation, RideStatusInProgress, RideStatusCompleted, RideStatusRejected, RideStatusCancelled}
+	events := []RideEvent{RideEventConfirm, RideEventReject, RideEventComplete, RideEventCancel}
+	want := map[RideStatus]map[RideEvent]RideStatus{
+		RideStatusAwaitingConfirmation: {
+			RideEventConfirm: RideStatusInProgress,
+			RideEventReject:  RideStatusRejected,
+			RideEventCancel:  RideStatusCancelled,
+		},
+		RideStatusInProgress: {
+			RideEventComplete: RideStatusCompleted,
+			RideEventCancel:   RideStatusCancelled,
+		},
+	}
+	checkMachine(t, "ride", statuses, events, want, RideStatus.Next)
+}
+
+func TestProposalTransitions(t *testing.T) {
+	statuses := []ProposalStatus{ProposalStatusPending, ProposalStatusAccepted, ProposalStatusRejected, ProposalStatusCancelled, ProposalStatusExpired}
+	events := []ProposalEvent{ProposalEventAccept, ProposalEventReject, ProposalEventCancel, ProposalEventExpire}
+	want := map[ProposalStatus]map[ProposalEvent]ProposalStatus{
+		ProposalStatusPending: {
+			ProposalEventAccept: ProposalStatusAccepted,
+			ProposalEventReject: ProposalStatusRejected,
+			ProposalEventCancel: ProposalStatusCancelled,
+			ProposalEventExpire: ProposalStatusExpired,
+		},
+		ProposalStatusAccepted: {
+			ProposalEventCancel: ProposalStatusCancelled,
+		},
+	}
+	checkMachine(t, "proposal", statuses, events, want, ProposalStatus.Next)
+}
+
+func TestRequestTransitions(t *testing.T) {
+	statuses := []RequestStatus{RequestStatusOpen, RequestStatusMatched, RequestStatusPickedUp, RequestStatusDroppedOff, RequestStatusCancelled, RequestStatusExpired}
+	events := []RequestEvent{RequestEventMatch, RequestEventPickup, RequestEventDropoff, RequestEventCancel, RequestEventRelease, RequestEventExpire}
+	want := map[RequestStatus]map[RequestEvent]RequestStatus{
+		RequestStatusOpen: {
+			RequestEventMatch:  RequestStatusMatched,
+			RequestEventCancel: RequestStatusCancelled,
+			RequestEventExpire: RequestStatusExpired,
+		},
+		RequestStatusMatched: {
+			RequestEventPickup:  RequestStatusPickedUp,
+			RequestEventCancel:  RequestStatusCancelled,
+			RequestEventRelease: RequestStatusOpen,
+		},
+		RequestStatusPickedUp: {
+			RequestEventDropoff: RequestStatusDroppedOff,
+		},
+	}
+	checkMachine(t, "request", statuses, events, want, RequestStatus.Next)
+}
+
+func TestCommuteTransitions(t *testing.T) {
+	statuses := []CommuteStatus{CommuteStatusActive, CommuteStatusPaused, CommuteStatusEnded}
+	events := []CommuteEvent{CommuteEventPause, CommuteEventResume, CommuteEventEnd}
+	want := map[CommuteStatus]map[CommuteEvent]CommuteStatus{
+		CommuteStatusActive: {
+			CommuteEventPause: CommuteStatusPaused,
+			CommuteEventEnd:   CommuteStatusEnded,
+		},
+		CommuteStatusPaused: {
+			CommuteEventResume: CommuteStatusActive,
+			CommuteEventEnd:    CommuteStatusEnded,
+		},
+	}
+	checkMachine(t, "commute", statuses, events, want, CommuteStatus.Next)
+}
+
+func TestOfferTransitions(t *testing.T) {
+	statuses := []OfferStatus{OfferStatusOpen, OfferStatusCancelled}
+	events := []OfferEvent{OfferEventCancel}
+	want := map[OfferStatus]map[OfferEvent]OfferStatus{
+		OfferStatusOpen: {
+			OfferEventCancel: OfferStatusCancelled,
+		},
+	}
+	checkMachine(t, "offer", statuses, events, want, OfferStatus.Next)
+}
+
+func TestBookingTransitions(t *testing.T) {
+	statuses := []BookingStatus{BookingStatusPending, BookingStatusApproved, BookingStatusDeclined, BookingStatusCancelled}
+	events := []BookingEvent{BookingEventApprove, BookingEventDecline, BookingEventCancel}
+	want := map[BookingStatus]map[BookingEvent]BookingStatus{
+		BookingStatusPending: {
+			BookingEventApprove: BookingStatusApproved,
+			BookingEventDecline: BookingStatusDeclined,
+			BookingEventCancel:  BookingStatusCancelled,
+		},
+	}
+	checkMachine(t, "booking", statuses, events, want, BookingStatus.Next)
+}
+
+func TestCounterOfferTransitions(t *testing.T) {
+	statuses := []CounterOfferStatus{CounterOfferStatusPending, CounterOfferStatusAccepted, CounterOfferStatusRejected, CounterOfferStatusCountered}
+	events := []CounterOfferEvent{CounterOfferEventAccept, CounterOfferEventReject, CounterOfferEventCounter}
+	want := map[CounterOfferStatus]map[CounterOfferEvent]CounterOfferStatus{
+		CounterOfferStatusPending: {
+			CounterOfferEventAccept:  CounterOfferStatusAccepted,
+			CounterOfferEventReject:  CounterOfferStatusRejected,
+			CounterOfferEventCounter: CounterOfferStatusCountered,
+		},
+	}
+	checkMachine(t, "counter-offer", statuses, events, want, CounterOfferStatus.Next)
+}
+
+func TestSettlementTransitions(t *testing.T) {
+	statuses := []SettlementStatus{SettlementStatusPending, SettlementStatusPaid, SettlementStatusCancelled}
+	events := []SettlementEvent{SettlementEventPay, SettlementEventCancel}
+	want := map[SettlementStatus]map[SettlementEvent]SettlementStatus{
+		SettlementStatusPending: {
+			SettlementEventPay:    SettlementStatusPaid,
+			SettlementEventCancel: SettlementStatusCancelled,
+		},
+	}
+	checkMachine(t, "settlement", statuses, events, want, SettlementStatus.Next)
+}
+
+func TestDriverApplicationTransitions(t *testing.T) {
+	statuses := []DriverApplicationStatus{DriverApplicationStatusPending, DriverApplicationStatusApproved, DriverApplicationStatusSuspended}
+	events := []DriverApplicationEvent{DriverApplicationEventApprove, DriverApplicationEventSuspend}
+	want := map[DriverApplicationStatus]map[DriverApplicationEvent]DriverApplicationStatus{
+		DriverApplicationStatusPending: {
+			DriverApplicationEventApprove: DriverApplicationStatusApproved,
+			DriverApplicationEventSuspend: DriverApplicationStatusSuspended,
+		},
+		DriverApplicationStatusApproved: {
+			DriverApplicationEventSuspend: DriverApplicationStatusSuspended,
+		},
+		DriverApplicationStatusSuspended: {
+			DriverApplicationEventApprove: DriverApplicationStatusApproved,
+		},
+	}
+	checkMachine(t, "driver application", statuses, events, want, DriverApplicationStatus.Next)
+}
+
+func TestApply(t *testing.T) {
+	ride := &RideDBModel{Status: RideStatusAwaitingConfirmation}
+	completedRide := &RideDBModel{Status: RideStatusCompleted}
+	proposal := &ProposalDBModel{Status: ProposalStatusPending}
+	expiredProposal := &ProposalDBModel{Status: ProposalStatusExpired}
+	request := &RequestDBModel{Status: RequestStatusMatched}
+	pickedUp := &RequestDBModel{Status: RequestStatusPickedUp}
+
+	tests := []struct {
+		name    string
+		apply   func() error
+		status  func() string
+		want    string
+		wantErr bool
+	}{
+		{"ride confirmed", func() error { return ride.Apply(RideEventConfirm) }, func() string { return string(ride.Status) }, string(RideStatusInProgress), false},
+		{"completed ride cancelled", func() error { return completedRide.Apply(RideEventCancel) }, func() string { return string(completedRide.Status) }, string(RideStatusCompleted), true},
+		{"proposal accepted", func() error { return proposal.Apply(ProposalEventAccept) }, func() string { return string(proposal.Status) }, string(ProposalStatusAccepted), false},
+		{"expired proposal accepted", func() error { return expiredProposal.Apply(ProposalEventAccept) }, func() string { return string(expiredProposal.Status) }, string(ProposalStatusExpired), true},
+		{"request picked up", func() error { return request.Apply(RequestEventPickup) }, func() string { return string(request.Status) }, string(RequestStatusPickedUp), false},
+		{"picked up request cancelled", func() error { return pickedUp.Apply(RequestEventCancel) }, func() string { return string(pickedUp.Status) }, string(RequestStatusPickedUp), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.apply()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error: %v", err, tt.wantErr)
+			}
+			if got := tt.status(); got != tt.want {
+				t.Errorf("status is %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -20,6 +20,40 @@ type RouteDBModel struct {
 	Distance       float64
 	Duration       int64
 	Polyline       string
+	// One per pair of consecutive stops
+	Legs []RouteLeg
+	// Distance driven on each toll road, in the order they are reached
+	Tolls []RouteToll
+}
+
+type RouteLeg struct {
+	Distance float64
+	Duration int64
+	// The toll roads on this leg alone
+	Tolls []RouteToll
+}
+
+// RouteToll is how far, in metres, a route runs along one toll road
+type RouteToll struct {
+	Road     string
+	Distance float64
+}
+
+// AddRouteToll adds distance on a toll road, keeping one entry per road
+func AddRouteToll(tolls []RouteToll, road string, distance float64) []RouteToll {
+	for i := range tolls {
+		if tolls[i].Road == road {
+			tolls[i].Distance += distance
+			return tolls
+		}
+	}
+	return append(tolls, RouteToll{Road: road, Distance: distance})
+}
+
+// RouteOptions changes how a route is chosen
+type RouteOptions struct {
+	// Only use toll roads when there is no sensible way around them
+	AvoidTolls bool
 }
 
 type Coordinates struct {
@@ -28,8 +62,11 @@ type Coordinates struct {
 
 type MapsRepository interface {
 	GetDirectRoute(ctx context.Context, start Coordinates, dest Coordinates) (*RouteDBModel, error)
+	GetDirectRouteWithOptions(ctx context.Context, start Coordinates, dest Coordinates, options RouteOptions) (*RouteDBModel, error)
 	GetMultistopRoute(ctx context.Context, start Coordinates, waypoints []Coordinates, dest Coordinates) (*RouteDBModel, error)
+	GetMultistopRouteWithOptions(ctx context.Context, start Coordinates, waypoints []Coordinates, dest Coordinates, options RouteOptions) (*RouteDBModel, error)
 	GetRouteFromWaypoints(ctx context.Context, start Coordinates, waypoints []Coordinates, dest Coordinates) (*RouteDBModel, error)
+	GetRouteFromWaypointsWithOptions(ctx context.Context, start Coordinates, waypoints []Coordinates, dest Coordinates, options RouteOptions) (*RouteDBModel, error)
 }
 
 // ExtractCoordinatesFromGeoJSON parses a GeoJSON LineString and returns [][]float64
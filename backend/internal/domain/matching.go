@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"math"
+	"time"
+)
+
+// Defaults for matching when the driver does not say otherwise
+const DefaultMaxMatchDetour = 15 * time.Minute
+
+// Only this many of the best individual requests are considered for a bundle, as every
+// bundle tried is routed through each ordering of its pickups
+const MatchCandidateLimit = 6
+
+// Detours shorter than this are scored as if they took this long, so a request right on the
+// driver's route does not outrank everything else by dividing by almost nothing
+const MinMatchDetour = time.Minute
+
+// No road trip beats this average speed, so the straight-line detour at this speed is a lower
+// bound on the real one and lets hopeless requests be skipped before routing them
+const maxDetourSpeedKmh = 80.0
+
+// The worker suggests bundles for offers departing within this
+const MatchSuggestionLead = 2 * time.Hour
+
+type MatchParams struct {
+	Start     Coordinates
+	Dest      Coordinates
+	Seats     int
+	MaxDetour time.Duration
+	DepartAt  time.Time
+	DriverID  int64
+	// The driver's own, filled in by the matching service
+	RouteOptions RouteOptions
+}
+
+// MatchCandidate is an open request with the detour it costs the driver on its own
+type MatchCandidate struct {
+	Request        *RequestDBModel
+	DetourDuration int64
+	DetourDistance float64
+}
+
+// MatchBundle is the set of requests the driver should draft together, with the route
+// picking them all up
+type MatchBundle struct {
+	Requests       []*MatchCandidate
+	Route          *RouteDBModel
+	Compensation   Money
+	DetourDuration int64
+	DetourDistance float64
+}
+
+// CompensationPerDetourMinute is what matching maximises
+func CompensationPerDetourMinute(compensation Money, detourSeconds int64) float64 {
+	minutes := math.Max(float64(detourSeconds), MinMatchDetour.Seconds()) / 60
+	return compensation.Float64() / minutes
+}
+
+func (c *MatchCandidate) Score() float64 {
+	return CompensationPerDetourMinute(MoneyFromFloat(c.Request.Compensation), c.DetourDuration)
+}
+
+func (b *MatchBundle) Score() float64 {
+	if len(b.Requests) == 0 {
+		return 0
+	}
+	return CompensationPerDetourMinute(b.Compensation, b.DetourDuration)
+}
+
+// Seats is how many seats the bundle's parties fill
+func (b *MatchBundle) Seats() int {
+	seats := 0
+	for _, candidate := range b.Requests {
+		seats += candidate.Request.PartySize
+	}
+	return seats
+}
+
+func (b *MatchBundle) RequestIDs() []int64 {
+	ids := make([]int64, len(b.Requests))
+	for i, candidate := range b.Requests {
+		ids[i] = candidate.Request.ID
+	}
+	return ids
+}
+
+// MinDetourDuration is a lower bound on the extra time taken to go from start to dest via waypoint
+func MinDetourDuration(start, waypoint, dest Coordinates) time.Duration {
+	extraKm := CalculateHaversineDistance(start.Lat, start.Lon, waypoint.Lat, waypoint.Lon) +
+		CalculateHaversineDistance(waypoint.Lat, waypoint.Lon, dest.Lat, dest.Lon) -
+		CalculateHaversineDistance(start.Lat, start.Lon, dest.Lat, dest.Lon)
+	return time.Duration(extraKm / maxDetourSpeedKmh * float64(time.Hour))
+}
+
+// ArrivesInWindow reports whether a trip leaving at departAt and taking durationS seconds
+// reaches the destination inside the request's arrival window. Requests without one always fit.
+func (r *RequestDBModel) ArrivesInWindow(departAt time.Time, durationS int64) bool {
+	if r.WindowKind == nil || *r.WindowKind != WindowKindArrival {
+		return true
+	}
+	arrival := departAt.Add(time.Duration(durationS) * time.Second)
+	return !arrival.Before(*r.WindowStart) && !arrival.After(*r.WindowEnd)
+}
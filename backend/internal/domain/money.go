@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount of Australian dollars held as a whole number of cents, so sums never pick
+// up floating point error. It is stored as BIGINT cents and written to JSON as a number with
+// two decimal places.
+type Money int64
+
+// MoneyFromFloat converts a dollar amount, rounding to the nearest cent. Compensation is still
+// a float on requests, so this is where it becomes Money.
+func MoneyFromFloat(dollars float64) Money {
+	return Money(math.Round(dollars * 100))
+}
+
+// ParseMoney reads a dollar amount such as "12", "12.5" or "-0.05". More than two decimal
+// places is an error rather than being rounded away.
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	digits := strings.TrimPrefix(s, "-")
+
+	whole, frac, _ := strings.Cut(digits, ".")
+	if whole == "" && frac == "" || len(frac) > 2 || strings.ContainsAny(whole+frac, "+-") {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+	if whole == "" {
+		whole = "0"
+	}
+
+	cents, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if negative {
+		cents = -cents
+	}
+	return Money(cents), nil
+}
+
+func (m Money) Cents() int64 {
+	return int64(m)
+}
+
+// Float64 is for DECIMAL columns, which hold dollars, and for mixing with compensation on
+// requests, which is still a float
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+func (m Money) String() string {
+	sign := ""
+	if m < 0 {
+		sign = "-"
+	}
+	abs := m.Abs()
+	return fmt.Sprintf("%s%d.%02d", sign, abs/100, abs%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a number or a string holding one
+func (m *Money) UnmarshalJSON(data []byte) error {
+	parsed, err := ParseMoney(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrCounterOfferPending is returned when a counter-offer is made or a proposal accepted while
+// an earlier counter-offer on it is still waiting for an answer. The API maps it to 409 Conflict.
+var ErrCounterOfferPending = errors.New("a counter-offer on this proposal is waiting for an answer")
+
+// CounterOfferParty is who made a counter-offer. Drivers open a negotiation on a pending
+// proposal; passengers can only counter the driver's price.
+type CounterOfferParty string
+
+const (
+	CounterOfferPartyDriver    CounterOfferParty = "driver"
+	CounterOfferPartyPassenger CounterOfferParty = "passenger"
+)
+
+// Other is the party expected to answer a counter-offer
+func (p CounterOfferParty) Other() CounterOfferParty {
+	if p == CounterOfferPartyDriver {
+		return CounterOfferPartyPassenger
+	}
+	return CounterOfferPartyDriver
+}
+
+// CounterOfferDBModel is one price put forward while negotiating a proposal. Together they
+// are the proposal's negotiation history.
+type CounterOfferDBModel struct {
+	ID         int64
+	ProposalID int64
+	OfferedBy  CounterOfferParty
+	Amount     Money
+	Status     CounterOfferStatus
+	AnsweredAt *time.Time
+	CreatedAt  string
+}
+
+// SettledCompensation is what the passenger pays the driver for the proposal's request
+func (p *ProposalDBModel) SettledCompensation(request *RequestDBModel) Money {
+	if p.Compensation != nil {
+		return *p.Compensation
+	}
+	return MoneyFromFloat(request.Compensation)
+}
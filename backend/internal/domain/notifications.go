@@ -41,10 +41,21 @@ type NotificationWithAccountDBModel struct {
 
 // For payload to distinguish different notifications in Ride Update channel
 const (
-	NotificationRequestCreated = "request_created"
-	NotificationRideCreated    = "ride_created"
-	NotificationRideFinalized  = "ride_finalized"
-	NotificationRideCompleted  = "ride_completed"
+	NotificationRequestCreated    = "request_created"
+	NotificationRideCreated       = "ride_created"
+	NotificationRideFinalized     = "ride_finalized"
+	NotificationRideCompleted     = "ride_completed"
+	NotificationRideCancelled     = "ride_cancelled"
+	NotificationRequestExpired    = "request_expired"
+	NotificationPickupReminder    = "pickup_reminder"
+	NotificationBookingRequest    = "booking_requested"
+	NotificationBookingAnswer     = "booking_answered"
+	NotificationMatchSuggested    = "match_suggested"
+	NotificationCounterOffer      = "counter_offer"
+	NotificationCounterAnswer     = "counter_offer_answered"
+	NotificationSettlementPaid    = "settlement_paid"
+	NotificationRefundIssued      = "refund_issued"
+	NotificationDriverApplication = "driver_application_reviewed"
 )
 
 // For channel IDs in FCM messages
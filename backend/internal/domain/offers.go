@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotEnoughSeats is returned when a booking would take more seats than are free.
+// The API maps it to 409 Conflict.
+var ErrNotEnoughSeats = errors.New("not enough free seats")
+
+// Passengers searching offers only see those whose detour for them is within this, unless they ask otherwise
+const DefaultMaxOfferDetour = 10 * time.Minute
+
+type OffersRepository interface {
+	CreateOffer(ctx context.Context, offer *OfferDBModel) (*OfferDBModel, error)
+	GetOfferByID(ctx context.Context, offerID int64) (*OfferDBModel, error)
+	GetOffersByDriverID(ctx context.Context, driverID int64) ([]*OfferDBModel, error)
+	SearchOffers(ctx context.Context, departAfter, departBefore time.Time, seats int) ([]*OfferDBModel, error)
+	CancelOffer(ctx context.Context, offerID int64) (*OfferDBModel, []*BookingDBModel, error)
+	CreateBooking(ctx context.Context, booking *BookingDBModel) (*BookingDBModel, error)
+	GetBookingByID(ctx context.Context, bookingID int64) (*BookingDBModel, error)
+	GetBookingsByOfferID(ctx context.Context, offerID int64) ([]*BookingDBModel, error)
+	GetBookingsByPassengerID(ctx context.Context, passengerID int64) ([]*BookingDBModel, error)
+	TransitionBooking(ctx context.Context, bookingID int64, event BookingEvent) (*BookingDBModel, error)
+	RecordMatchSuggestion(ctx context.Context, offerID int64, bundle *MatchBundle) (bool, error)
+}
+
+// OfferDBModel is a trip a driver has published for passengers to join.
+// Approved bookings become matched requests on the offer's ride, so the usual
+// pickup, completion and cancellation endpoints apply once a passenger is on board.
+type OfferDBModel struct {
+	ID                   int64
+	DriverID             int64
+	OriginLocation       string
+	OriginLatitude       float64
+	OriginLongitude      float64
+	DestinationLocation  string
+	DestinationLatitude  float64
+	DestinationLongitude float64
+	RoutePolyline        string
+	RouteDistance        float64
+	RouteDuration        int64
+	DepartureTime        time.Time
+	Seats                int
+	SeatsTaken           int
+	PricePerSeat         Money
+	Status               OfferStatus
+	RideID               *int64
+	CreatedAt            string
+	UpdatedAt            string
+}
+
+// BookingDBModel is a passenger's request to join an offer
+type BookingDBModel struct {
+	ID               int64
+	OfferID          int64
+	PassengerID      int64
+	PickupLocation   string
+	PickupLatitude   float64
+	PickupLongitude  float64
+	DropoffLocation  string
+	DropoffLatitude  float64
+	DropoffLongitude float64
+	Seats            int
+	DetourDuration   int64
+	Status           BookingStatus
+	RequestID        *int64
+	CreatedAt        string
+	UpdatedAt        string
+}
+
+func (o *OfferDBModel) SeatsFree() int {
+	return max(o.Seats-o.SeatsTaken, 0)
+}
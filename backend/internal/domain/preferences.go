@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"slices"
+	"strings"
+)
+
+// RideFeature is something a ride can offer. Passengers require or prefer features on their
+// requests; drivers offer them through their vehicle and their preferences.
+type RideFeature string
+
+const (
+	// Offered by the vehicle
+	RideFeatureLuggage              RideFeature = "luggage"
+	RideFeatureWheelchairAccessible RideFeature = "wheelchair_accessible"
+	RideFeatureChildSeat            RideFeature = "child_seat"
+	// Offered by the driver
+	RideFeatureQuietRide   RideFeature = "quiet_ride"
+	RideFeatureNoSmoking   RideFeature = "no_smoking"
+	RideFeaturePetsAllowed RideFeature = "pets_allowed"
+)
+
+// IsVehicleFeature reports whether the feature belongs to the vehicle rather than the driver
+func (f RideFeature) IsVehicleFeature() bool {
+	switch f {
+	case RideFeatureLuggage, RideFeatureWheelchairAccessible, RideFeatureChildSeat:
+		return true
+	}
+	return false
+}
+
+type RideFeatures []RideFeature
+
+func (fs RideFeatures) String() string {
+	names := make([]string, len(fs))
+	for i, f := range fs {
+		names[i] = string(f)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Missing returns the features not in offered
+func (fs RideFeatures) Missing(offered RideFeatures) RideFeatures {
+	missing := make(RideFeatures, 0)
+	for _, f := range fs {
+		if !slices.Contains(offered, f) {
+			missing = append(missing, f)
+		}
+	}
+	return missing
+}
+
+// Met returns the features that are in offered
+func (fs RideFeatures) Met(offered RideFeatures) RideFeatures {
+	met := make(RideFeatures, 0)
+	for _, f := range fs {
+		if slices.Contains(offered, f) {
+			met = append(met, f)
+		}
+	}
+	return met
+}
+
+// OfferedFeatures is what a driver offers: their vehicle's features and their own
+// preferences. Drivers without a vehicle offer only their preferences.
+func OfferedFeatures(vehicle *VehicleDBModel, preferences RideFeatures) RideFeatures {
+	offered := slices.Clone(preferences)
+	if vehicle != nil {
+		offered = append(offered, vehicle.Features...)
+	}
+	return offered
+}
+
+// Requirements must all be offered for a driver to see or draft the request. Preferences
+// only rank it.
+func (r *RequestDBModel) RequirementsMet(offered RideFeatures) bool {
+	return len(r.Requirements.Missing(offered)) == 0
+}
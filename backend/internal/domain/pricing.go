@@ -0,0 +1,279 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Used to estimate how long a trip takes when there is only a straight-line distance
+const EstimatedAverageSpeedKmh = 40.0
+
+// Roads around Melbourne are about this much longer than the straight line between two points.
+// Only used when routing fails.
+const RoadDetourFactor = 1.4
+
+// How far either way an estimate's range reaches. A road route is mostly uncertain in time,
+// from traffic; a straight-line guess is uncertain in distance too.
+const (
+	RoutedEstimateSpread   = 0.15
+	FallbackEstimateSpread = 0.3
+)
+
+// TariffMultiplier scales fares that start between StartTime and EndTime, local "15:04" times
+// in the tariff's time zone. A window ending before it starts runs past midnight. It is stored
+// as JSON on the tariff, hence the tags.
+type TariffMultiplier struct {
+	StartTime  string  `json:"start_time"`
+	EndTime    string  `json:"end_time"`
+	Multiplier float64 `json:"multiplier"`
+	Label      string  `json:"label"`
+}
+
+// TariffDBModel is one version of how compensation is priced. Versions are never changed; a
+// new one takes over from its EffectiveFrom.
+type TariffDBModel struct {
+	ID            int64
+	Version       int
+	BaseFare      Money
+	PerKm         Money
+	PerMinute     Money
+	MinimumFare   Money
+	Multipliers   []TariffMultiplier
+	Timezone      string
+	EffectiveFrom time.Time
+	Notes         *string
+	CreatedBy     *int64
+	CreatedAt     time.Time
+}
+
+// FareBreakdown is how a tariff priced a trip
+type FareBreakdown struct {
+	TariffID      int64
+	TariffVersion int
+	Base          Money
+	Distance      Money
+	Time          Money
+	Multiplier    float64
+	// Set when the fare was raised to the tariff's minimum
+	MinimumApplied bool
+	// Passed on as they are, after the multiplier and minimum
+	Tolls     []TollCharge
+	TollTotal Money
+	Total     Money
+}
+
+// AddTolls adds toll charges to the fare's total
+func (f *FareBreakdown) AddTolls(tolls []TollCharge) {
+	for _, toll := range tolls {
+		f.Tolls = append(f.Tolls, toll)
+		f.TollTotal += toll.Amount
+		f.Total += toll.Amount
+	}
+}
+
+// Validate checks the multiplier windows, which the database cannot
+func (t *TariffDBModel) Validate() error {
+	if _, err := time.LoadLocation(t.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone %q", t.Timezone)
+	}
+	for _, m := range t.Multipliers {
+		if _, err := time.Parse("15:04", m.StartTime); err != nil {
+			return fmt.Errorf("invalid multiplier start time %q", m.StartTime)
+		}
+		if _, err := time.Parse("15:04", m.EndTime); err != nil {
+			return fmt.Errorf("invalid multiplier end time %q", m.EndTime)
+		}
+		if m.StartTime == m.EndTime {
+			return fmt.Errorf("multiplier window %s to %s is empty", m.StartTime, m.EndTime)
+		}
+		if m.Multiplier <= 0 {
+			return fmt.Errorf("multiplier must be positive")
+		}
+	}
+	return nil
+}
+
+// Multiplier returns the multiplier for a trip starting at the given time. Where windows
+// overlap the largest applies; outside them all it is 1.
+func (t *TariffDBModel) Multiplier(at time.Time) float64 {
+	location, err := time.LoadLocation(t.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	clock := at.In(location).Format("15:04")
+
+	multiplier := 1.0
+	matched := false
+	for _, m := range t.Multipliers {
+		var in bool
+		if m.StartTime < m.EndTime {
+			in = clock >= m.StartTime && clock < m.EndTime
+		} else {
+			in = clock >= m.StartTime || clock < m.EndTime
+		}
+		if in && (!matched || m.Multiplier > multiplier) {
+			multiplier = m.Multiplier
+			matched = true
+		}
+	}
+	return multiplier
+}
+
+// Price works out the fare for a trip of distanceKm taking duration, starting at the given time
+func (t *TariffDBModel) Price(distanceKm float64, duration time.Duration, at time.Time) FareBreakdown {
+	fare := FareBreakdown{
+		TariffID:      t.ID,
+		TariffVersion: t.Version,
+		Base:          t.BaseFare,
+		Distance:      Money(math.Round(distanceKm * float64(t.PerKm))),
+		Time:          Money(math.Round(duration.Minutes() * float64(t.PerMinute))),
+		Multiplier:    t.Multiplier(at),
+	}
+
+	fare.Total = Money(math.Round(float64(fare.Base+fare.Distance+fare.Time) * fare.Multiplier))
+	if fare.Total < t.MinimumFare {
+		fare.Total = t.MinimumFare
+		fare.MinimumApplied = true
+	}
+	return fare
+}
+
+// TripEstimate is how far, in km, and how long a trip is expected to be
+type TripEstimate struct {
+	DistanceKm float64
+	Duration   time.Duration
+	// False when it was guessed from the straight-line distance
+	Routed bool
+	// Toll roads on the route. Always empty for a straight-line guess.
+	Tolls []RouteToll
+}
+
+// RoutedTrip takes the distance and duration from a road route
+func RoutedTrip(route *RouteDBModel) TripEstimate {
+	return TripEstimate{
+		DistanceKm: route.Distance / 1000,
+		Duration:   time.Duration(route.Duration) * time.Second,
+		Routed:     true,
+		Tolls:      route.Tolls,
+	}
+}
+
+// StraightLineTrip guesses the road distance from the straight line with RoadDetourFactor
+func StraightLineTrip(start, dest Coordinates) TripEstimate {
+	distance := CalculateHaversineDistance(start.Lat, start.Lon, dest.Lat, dest.Lon) * RoadDetourFactor
+	return TripEstimate{
+		DistanceKm: distance,
+		Duration:   EstimateDuration(distance),
+	}
+}
+
+// Range returns the shortest and longest the trip is likely to be
+func (t TripEstimate) Range() (TripEstimate, TripEstimate) {
+	low, high := t, t
+	spread := FallbackEstimateSpread
+	if t.Routed {
+		spread = RoutedEstimateSpread
+	} else {
+		low.DistanceKm *= 1 - spread
+		high.DistanceKm *= 1 + spread
+	}
+	low.Duration = time.Duration(float64(t.Duration) * (1 - spread))
+	high.Duration = time.Duration(float64(t.Duration) * (1 + spread))
+	return low, high
+}
+
+// EstimateDuration guesses how long a straight-line distance takes to drive
+func EstimateDuration(distanceKm float64) time.Duration {
+	return time.Duration(distanceKm / EstimatedAverageSpeedKmh * float64(time.Hour))
+}
+
+// TollRateDBModel is what a car pays to use a toll road
+type TollRateDBModel struct {
+	ID       int64
+	TollRoad string
+	PerKm    Money
+	Minimum  Money
+	// Trips are capped at this, if set
+	Maximum   *Money
+	UpdatedAt time.Time
+}
+
+// Tolled ways not on a road with its own rate are priced with this one
+const OtherTollRoad = "Other"
+
+// TollCharge is a toll for one road, shown as its own line item
+type TollCharge struct {
+	Road       string
+	DistanceKm float64
+	Amount     Money
+}
+
+// PriceTolls prices the toll roads on a route. Roads without a rate use OtherTollRoad's, and
+// are left out if there is none.
+func PriceTolls(tolls []RouteToll, rates []*TollRateDBModel) []TollCharge {
+	byRoad := make(map[string]*TollRateDBModel, len(rates))
+	for _, rate := range rates {
+		byRoad[rate.TollRoad] = rate
+	}
+
+	charges := make([]TollCharge, 0, len(tolls))
+	for _, toll := range tolls {
+		rate, ok := byRoad[toll.Road]
+		if !ok {
+			rate, ok = byRoad[OtherTollRoad]
+		}
+		if !ok || toll.Distance <= 0 {
+			continue
+		}
+
+		distanceKm := toll.Distance / 1000
+		amount := Money(math.Round(distanceKm * float64(rate.PerKm)))
+		if amount < rate.Minimum {
+			amount = rate.Minimum
+		}
+		if rate.Maximum != nil && amount > *rate.Maximum {
+			amount = *rate.Maximum
+		}
+		charges = append(charges, TollCharge{Road: toll.Road, DistanceKm: distanceKm, Amount: amount})
+	}
+	return charges
+}
+
+// TollTotal is what the charges add up to
+func TollTotal(charges []TollCharge) Money {
+	var total Money
+	for _, charge := range charges {
+		total += charge.Amount
+	}
+	return total
+}
+
+// FareEstimateDBModel records an estimate handed out and the tariff that priced it
+type FareEstimateDBModel struct {
+	ID        int64
+	TariffID  int64
+	AccountID *int64
+	Distance  float64 // metres
+	Duration  int64   // seconds
+	// Tolls are included in Total
+	Tolls     Money
+	Total     Money
+	CreatedAt time.Time
+}
+
+type PricingRepository interface {
+	GetTariffAt(ctx context.Context, at time.Time) (*TariffDBModel, error)
+	GetTariffs(ctx context.Context) ([]*TariffDBModel, error)
+	GetTariffByVersion(ctx context.Context, version int) (*TariffDBModel, error)
+	CreateTariff(ctx context.Context, tariff *TariffDBModel) (*TariffDBModel, error)
+	RecordFareEstimate(ctx context.Context, estimate *FareEstimateDBModel) (*FareEstimateDBModel, error)
+	GetTollRates(ctx context.Context) ([]*TollRateDBModel, error)
+	GetFuelPrices(ctx context.Context) ([]*FuelPriceDBModel, error)
+	GetFuelPrice(ctx context.Context, fuelType FuelType) (*FuelPriceDBModel, error)
+	UpdateFuelPrice(ctx context.Context, price *FuelPriceDBModel) (*FuelPriceDBModel, error)
+	// Aggregates between the areas of pickup and dropoff and those up to reach cells around them
+	GetCompensationAggregates(ctx context.Context, pickup, dropoff Coordinates, reach int) ([]*CompensationAggregateDBModel, error)
+	RefreshCompensationAggregates(ctx context.Context, since time.Time) (int, error)
+}
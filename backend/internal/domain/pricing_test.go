@@ -0,0 +1,133 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func testTariff() *TariffDBModel {
+	return &TariffDBModel{
+		ID:          1,
+		Version:     3,
+		BaseFare:    300,
+		PerKm:       150,
+		PerMinute:   40,
+		MinimumFare: 800,
+		Multipliers: []TariffMultiplier{
+			{StartTime: "07:00", EndTime: "09:00", Multiplier: 1.5, Label: "Morning peak"},
+			{StartTime: "08:00", EndTime: "08:30", Multiplier: 2, Label: "School run"},
+			{StartTime: "22:00", EndTime: "05:00", Multiplier: 1.25, Label: "Late night"},
+		},
+		Timezone: "UTC",
+	}
+}
+
+func TestTariffPrice(t *testing.T) {
+	at := func(clock string) time.Time {
+		parsed, err := time.Parse("2006-01-02 15:04", "2025-03-05 "+clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name           string
+		distanceKm     float64
+		duration       time.Duration
+		at             time.Time
+		wantDistance   Money
+		wantTime       Money
+		wantMultiplier float64
+		wantTotal      Money
+		wantMinimum    bool
+	}{
+		{"off peak", 10, 15 * time.Minute, at("12:00"), 1500, 600, 1, 2400, false},
+		{"morning peak", 10, 15 * time.Minute, at("07:30"), 1500, 600, 1.5, 3600, false},
+		{"overlapping windows take the largest", 10, 15 * time.Minute, at("08:15"), 1500, 600, 2, 4800, false},
+		{"window start is inclusive", 10, 15 * time.Minute, at("22:00"), 1500, 600, 1.25, 3000, false},
+		{"window past midnight", 10, 15 * time.Minute, at("02:00"), 1500, 600, 1.25, 3000, false},
+		{"window end is exclusive", 10, 15 * time.Minute, at("05:00"), 1500, 600, 1, 2400, false},
+		{"rounded to the cent", 3.333, 7*time.Minute + 30*time.Second, at("12:00"), 500, 300, 1, 1100, false},
+		{"raised to the minimum", 1, 2 * time.Minute, at("12:00"), 150, 80, 1, 800, true},
+		{"minimum applies after the multiplier", 1, 2 * time.Minute, at("07:30"), 150, 80, 1.5, 800, true},
+		{"multiplier lifts it past the minimum", 2, 2 * time.Minute, at("08:15"), 300, 80, 2, 1360, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fare := testTariff().Price(tt.distanceKm, tt.duration, tt.at)
+			if fare.TariffID != 1 || fare.TariffVersion != 3 {
+				t.Errorf("priced by tariff %d version %d, want 1 version 3", fare.TariffID, fare.TariffVersion)
+			}
+			if fare.Base != 300 || fare.Distance != tt.wantDistance || fare.Time != tt.wantTime {
+				t.Errorf("got base %s, distance %s, time %s, want 3.00, %s, %s", fare.Base, fare.Distance, fare.Time, tt.wantDistance, tt.wantTime)
+			}
+			if fare.Multiplier != tt.wantMultiplier {
+				t.Errorf("got multiplier %v, want %v", fare.Multiplier, tt.wantMultiplier)
+			}
+			if fare.Total != tt.wantTotal || fare.MinimumApplied != tt.wantMinimum {
+				t.Errorf("got total %s (minimum applied: %v), want %s (%v)", fare.Total, fare.MinimumApplied, tt.wantTotal, tt.wantMinimum)
+			}
+		})
+	}
+}
+
+func TestTariffMultiplierTimezone(t *testing.T) {
+	tariff := testTariff()
+	tariff.Timezone = "Australia/Melbourne"
+	if _, err := time.LoadLocation(tariff.Timezone); err != nil {
+		t.Skip("no time zone data")
+	}
+
+	// 20:30 UTC is 07:30 the next morning in Melbourne during daylight saving
+	at := time.Date(2025, time.January, 14, 20, 30, 0, 0, time.UTC)
+	if got := tariff.Multiplier(at); got != 1.5 {
+		t.Errorf("got multiplier %v, want the morning peak's 1.5", got)
+	}
+}
+
+func TestFareAddTolls(t *testing.T) {
+	fare := testTariff().Price(1, 2*time.Minute, time.Date(2025, time.March, 5, 12, 0, 0, 0, time.UTC))
+	fare.AddTolls([]TollCharge{
+		{Road: "CityLink", DistanceKm: 3, Amount: 250},
+		{Road: "EastLink", DistanceKm: 5, Amount: 420},
+	})
+
+	// Tolls are passed on after the minimum, not lost inside it
+	if fare.TollTotal != 670 || fare.Total != 1470 {
+		t.Errorf("got tolls %s and total %s, want 6.70 and 14.70", fare.TollTotal, fare.Total)
+	}
+	if len(fare.Tolls) != 2 {
+		t.Errorf("got %d toll lines, want 2", len(fare.Tolls))
+	}
+}
+
+func TestTariffValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		timezone    string
+		multipliers []TariffMultiplier
+		wantErr     bool
+	}{
+		{"valid", "UTC", testTariff().Multipliers, false},
+		{"no multipliers", "UTC", nil, false},
+		{"unknown timezone", "Mars/Olympus_Mons", nil, true},
+		{"bad start time", "UTC", []TariffMultiplier{{StartTime: "7am", EndTime: "09:00", Multiplier: 1.5}}, true},
+		{"bad end time", "UTC", []TariffMultiplier{{StartTime: "07:00", EndTime: "25:00", Multiplier: 1.5}}, true},
+		{"empty window", "UTC", []TariffMultiplier{{StartTime: "07:00", EndTime: "07:00", Multiplier: 1.5}}, true},
+		{"zero multiplier", "UTC", []TariffMultiplier{{StartTime: "07:00", EndTime: "09:00", Multiplier: 0}}, true},
+		{"negative multiplier", "UTC", []TariffMultiplier{{StartTime: "07:00", EndTime: "09:00", Multiplier: -1}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tariff := testTariff()
+			tariff.Timezone = tt.timezone
+			tariff.Multipliers = tt.multipliers
+			if err := tariff.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Earnings statements cover a calendar month in this time zone unless the driver asks for another
+const DefaultStatementTimezone = "Australia/Melbourne"
+
+// ReceiptFormat is how a receipt or statement is downloaded
+type ReceiptFormat string
+
+const (
+	ReceiptFormatJSON ReceiptFormat = "json"
+	ReceiptFormatPDF  ReceiptFormat = "pdf"
+	ReceiptFormatCSV  ReceiptFormat = "csv"
+)
+
+// ReceiptDBModel is everything a passenger's receipt for a completed ride shows
+type ReceiptDBModel struct {
+	RequestID          int64
+	RideID             int64
+	PassengerID        int64
+	PassengerFirstName string
+	PassengerLastName  string
+	DriverID           int64
+	DriverFirstName    string
+	DriverLastName     string
+	Vehicle            *VehicleDBModel // the driver's current vehicle, if they still have one
+	PickupLocation     string
+	PickupLatitude     float64
+	PickupLongitude    float64
+	DropoffLocation    string
+	DropoffLatitude    float64
+	DropoffLongitude   float64
+	PickedUpAt         *time.Time
+	DroppedOffAt       *time.Time
+	CompletedAt        time.Time
+	// The driver's whole route, in metres and seconds
+	RouteDistance *float64
+	RouteDuration *int64
+	// What the passenger asked to pay, and what was agreed after counter-offers
+	RequestedCompensation Money
+	Compensation          Money
+	// The split, see SplitTripCost. Nil for rides completed before it was recorded or that
+	// could not be routed.
+	CostShare               *Money
+	CostShareDistance       *float64
+	CostShareSharedDistance *float64
+	// The tariff in force when the ride was created
+	TariffVersion *int
+	// Everyone on the ride and what the driver was paid in all
+	Passengers       int
+	RideCompensation Money
+	// From the ledger
+	Charged  Money
+	Refunded Money
+}
+
+// EarningsLineDBModel is money a driver earned from, or gave back to, one passenger
+type EarningsLineDBModel struct {
+	EntryID            int64
+	Kind               LedgerEntryKind
+	RideID             *int64
+	RequestID          *int64
+	PassengerFirstName *string
+	PassengerLastName  *string
+	PickupLocation     *string
+	DropoffLocation    *string
+	// How far the passenger rode, in metres
+	Distance  *float64
+	Amount    Money
+	CreatedAt time.Time
+}
+
+// EarningsStatementDBModel is what a driver earned in a period
+type EarningsStatementDBModel struct {
+	DriverID        int64
+	DriverFirstName string
+	DriverLastName  string
+	From            time.Time
+	To              time.Time
+	Lines           []*EarningsLineDBModel
+	Rides           int
+	// Total route distance of the rides, in metres
+	Distance float64
+	Gross    Money
+	Refunds  Money
+	// Still owed to the driver for rides in the period
+	Outstanding Money
+}
+
+// Net is what the driver kept
+func (s *EarningsStatementDBModel) Net() Money {
+	return s.Gross - s.Refunds
+}
+
+type ReceiptsRepository interface {
+	GetReceipt(ctx context.Context, requestID int64) (*ReceiptDBModel, error)
+	GetEarningsStatement(ctx context.Context, driverID int64, from, to time.Time) (*EarningsStatementDBModel, error)
+}
@@ -2,34 +2,77 @@ package domain
 
 import (
 	"context"
+	"errors"
 	"math"
+	"time"
 )
 
-const BaseFare = 2
-const PricePerKm = 0.25
+// How long requests stay open and proposals stay pending before the worker expires them.
+// Overridable with the REQUEST_TTL and PROPOSAL_TTL environment variables.
+const DefaultRequestTTL = 2 * time.Hour
+const DefaultProposalTTL = 15 * time.Minute
+
+// WindowKind says whether a scheduled request's window bounds departure from the pickup
+// or arrival at the dropoff. Requests without a window are for as soon as possible.
+type WindowKind string
+
+const (
+	WindowKindDeparture WindowKind = "departure"
+	WindowKindArrival   WindowKind = "arrival"
+)
+
+// Drivers only see unscheduled requests when they are leaving within this window
+const ImmediateDepartureWindow = 30 * time.Minute
+
+// Furthest ahead a passenger can schedule a request
+const MaxScheduleAhead = 7 * 24 * time.Hour
+
+// Drivers must be within this distance of a passenger's pickup or dropoff to mark it
+const StopRadiusKm = 1.0
+
+// How long before a scheduled window opens the worker reminds the driver and passenger
+const PickupReminderLead = 30 * time.Minute
+
+// Passenger seats assumed for rides drafted before seats were tracked
+const DefaultVehicleSeats = 4
 
 type RidesRepository interface {
 	CreateRideRequest(ctx context.Context, req *RequestDBModel) (*RequestDBModel, error)
-	GetActiveRideRequests(ctx context.Context, ids *[]string, ub_compensation *float64, passenger_id *int64) ([]*RequestDBModel, error)
-	CreateRideAndProposals(ctx context.Context, proposals []*ProposalDBModel, destLat, destLon float64) (*RideDBModel, []*ProposalDBModel, error)
+	GetActiveRideRequests(ctx context.Context, ids *[]string, ub_compensation *float64, passenger_id *int64, depart_at *time.Time) ([]*RequestDBModel, error)
+	CreateRideAndProposals(ctx context.Context, proposals []*ProposalDBModel, destLat, destLon float64, seats int) (*RideDBModel, []*ProposalDBModel, error)
 	GetRideAndProposals(ctx context.Context, rideID int64) (*RideDBModel, []*ProposalDBModel, error)
-	ConfirmRideProposal(ctx context.Context, proposal *ProposalDBModel, confirm string) (*ProposalDBModel, error)
+	ConfirmRideProposal(ctx context.Context, proposal *ProposalDBModel, event ProposalEvent) (*ProposalDBModel, error)
 	GetRideByID(ctx context.Context, rideID int64) (*RideDBModel, error)
 	GetRideByRequestID(ctx context.Context, requestID int64) ([]*RideDBModel, error)
 	GetProposalByID(ctx context.Context, proposalID int64) (*ProposalDBModel, error)
 	GetRequestByID(ctx context.Context, requestID int64) (*RequestDBModel, error)
 	GetUnvisitedRequestsByRideID(ctx context.Context, rideID int64) ([]*RequestDBModel, error)
-	MarkRequestAsVisited(ctx context.Context, requestID int64) error
-	CompleteRide(ctx context.Context, rideID int64) error
-	GetPreviousRides(ctx context.Context, accountID int64, accountType string, limit int, offset int) ([]*RideDBModel, error)
+	CreateCounterOffer(ctx context.Context, proposalID int64, offeredBy CounterOfferParty, amount Money) (*CounterOfferDBModel, error)
+	RespondToCounterOffer(ctx context.Context, counterOfferID int64, event CounterOfferEvent, amount *Money) (*CounterOfferDBModel, *CounterOfferDBModel, error)
+	GetCounterOfferByID(ctx context.Context, counterOfferID int64) (*CounterOfferDBModel, error)
+	GetCounterOffersByProposalID(ctx context.Context, proposalID int64) ([]*CounterOfferDBModel, error)
+	BoardPassenger(ctx context.Context, rideID, requestID int64, code string) error
+	ResetBoardingCode(ctx context.Context, proposalID int64) (*ProposalDBModel, error)
+	CompleteRide(ctx context.Context, rideID int64, route *CostShareRoute) ([]*RequestDBModel, []*RequestDBModel, error)
+	DropOffRequest(ctx context.Context, rideID, requestID int64, route *CostShareRoute) (*RideDBModel, error)
+	SetRideRoute(ctx context.Context, rideID int64, route *RouteDBModel) error
+	GetRideHistory(ctx context.Context, filter RideHistoryFilter) ([]*RideHistoryDBModel, error)
 	GetInProgressRidesWithLocations(ctx context.Context) ([]*RideWithLocationsDBModel, error)
+	CancelRequest(ctx context.Context, requestID int64, cancellation Cancellation) ([]*ProposalDBModel, error)
+	CancelProposal(ctx context.Context, proposalID int64, cancellation Cancellation) (*ProposalDBModel, error)
+	CancelRide(ctx context.Context, rideID int64, cancellation Cancellation) ([]*ProposalDBModel, error)
+	ExpireRideRequests(ctx context.Context, createdBefore time.Time) ([]*RequestDBModel, error)
+	ExpireRideProposals(ctx context.Context, createdBefore time.Time) ([]*ProposalDBModel, error)
+	GetDuePickupReminders(ctx context.Context, windowStartsBefore time.Time) ([]*PickupReminderDBModel, error)
+	MarkPickupReminderSent(ctx context.Context, requestID int64) error
 }
 
 type RideDBModel struct {
 	ID                   int64
-	Status               string
+	Status               RideStatus
 	DestinationLatitude  float64
 	DestinationLongitude float64
+	Seats                *int // nil for rides drafted before seats were tracked
 	CreatedAt            string
 	UpdatedAt            string
 }
@@ -43,21 +86,124 @@ type RequestDBModel struct {
 	DropoffLatitude         float64
 	DropoffLongitude        float64
 	Compensation            float64
+	PartySize               int // passengers travelling together on the request
 	PassengerID             int64
 	RideID                  *int64
+	Status                  RequestStatus
+	WindowKind              *WindowKind
+	WindowStart             *time.Time
+	WindowEnd               *time.Time
+	Requirements            RideFeatures
+	Preferences             RideFeatures
+	PickedUpAt              *time.Time
+	DroppedOffAt            *time.Time
 	AreNotificationsCreated bool
-	Visited                 bool
 	CreatedAt               string
 }
 
 type ProposalDBModel struct {
-	ID        int64
-	RequestID int64
-	Status    string
-	DriverID  int64
-	RideID    int64
-	CreatedAt string
-	UpdatedAt string
+	ID               int64
+	RequestID        int64
+	Status           ProposalStatus
+	DriverID         int64
+	RideID           int64
+	Compensation     *Money  // agreed by counter-offer, otherwise the request's
+	BoardingCode     *string // only ever shown to the passenger
+	BoardingAttempts int
+	BoardedAt        *time.Time
+	// Set when the ride completes if it could be routed, see SplitTripCost
+	CostShare               *Money
+	CostShareDistance       *float64
+	CostShareSharedDistance *float64
+	CreatedAt               string
+	UpdatedAt               string
+}
+
+// Cancellation records who called off a request, proposal or ride and why
+type Cancellation struct {
+	CancelledBy int64
+	Reason      string
+}
+
+// NoShowReason is recorded against passengers never picked up before their driver completed
+// the ride. The driver is recorded as cancelling.
+const NoShowReason = "Not picked up before the ride completed"
+
+// PickupReminderDBModel is a confirmed scheduled pickup the worker has yet to remind people about
+type PickupReminderDBModel struct {
+	RequestID          int64
+	RideID             int64
+	DriverID           int64
+	PassengerID        int64
+	PassengerFirstName string
+	PickupLocation     string
+	WindowStart        time.Time
+}
+
+// How many rides a page of history holds when the client does not say, and at most
+const (
+	DefaultRideHistoryLimit = 20
+	MaxRideHistoryLimit     = 100
+)
+
+// RideHistoryRole is which side of a ride the account was on
+type RideHistoryRole string
+
+const (
+	RideHistoryRoleDriver    RideHistoryRole = "driver"
+	RideHistoryRolePassenger RideHistoryRole = "passenger"
+)
+
+// RideHistoryFilter narrows an account's ride history. Empty fields do not filter.
+type RideHistoryFilter struct {
+	AccountID int64
+	Role      *RideHistoryRole
+	Statuses  []RideStatus
+	From      *time.Time
+	To        *time.Time
+	// Cursor: only rides older than this one
+	BeforeRideID *int64
+	Limit        int
+}
+
+// RideHistoryDBModel is one ride in an account's history with everyone on it
+type RideHistoryDBModel struct {
+	Ride            RideDBModel
+	Role            RideHistoryRole
+	DriverID        int64
+	DriverFirstName string
+	DriverLastName  string
+	Vehicle         *VehicleDBModel // the driver's current vehicle, if they still have one
+	RouteDistance   *float64
+	RouteDuration   *int64
+	// Everything the driver earned, or what the passenger paid
+	Compensation Money
+	Passengers   []*RideHistoryPassengerDBModel
+}
+
+// RideHistoryPassengerDBModel is a passenger's proposal on a ride in someone's history. It is
+// aggregated as JSON in the history query, hence the tags.
+type RideHistoryPassengerDBModel struct {
+	ProposalID       int64          `json:"proposal_id"`
+	ProposalStatus   ProposalStatus `json:"proposal_status"`
+	RequestID        int64          `json:"request_id"`
+	RequestStatus    RequestStatus  `json:"request_status"`
+	PassengerID      int64          `json:"passenger_id"`
+	FirstName        string         `json:"first_name"`
+	LastName         string         `json:"last_name"`
+	PickupLocation   string         `json:"pickup_location"`
+	PickupLatitude   float64        `json:"pickup_latitude"`
+	PickupLongitude  float64        `json:"pickup_longitude"`
+	DropoffLocation  string         `json:"dropoff_location"`
+	DropoffLatitude  float64        `json:"dropoff_latitude"`
+	DropoffLongitude float64        `json:"dropoff_longitude"`
+	Compensation     Money          `json:"compensation"`
+	PickedUpAt       *time.Time     `json:"picked_up_at"`
+	DroppedOffAt     *time.Time     `json:"dropped_off_at"`
+	// Set once the ride completes
+	CostShare               *Money   `json:"cost_share"`
+	CostShareDistance       *float64 `json:"cost_share_distance"`
+	CostShareSharedDistance *float64 `json:"cost_share_shared_distance"`
 }
 
 type RideWithLocationsDBModel struct {
@@ -72,6 +218,39 @@ type RideWithLocationsDBModel struct {
 	PickupLongitude    float64
 }
 
+// Passengers can have several live requests, but not two for the same time
+var ErrOverlappingRequest = errors.New("active ride request already exists for this passenger at that time")
+
+// TravelWindow returns when the request is for. Unscheduled requests are for leaving now.
+func (r *RequestDBModel) TravelWindow(now time.Time) (time.Time, time.Time) {
+	if r.WindowStart == nil || r.WindowEnd == nil {
+		return now, now.Add(ImmediateDepartureWindow)
+	}
+	return *r.WindowStart, *r.WindowEnd
+}
+
+// Capacity is how many passengers the ride can carry at once
+func (r *RideDBModel) Capacity() int {
+	if r.Seats == nil {
+		return DefaultVehicleSeats
+	}
+	return *r.Seats
+}
+
+// TakesSeat reports whether the request's party is in the car or still to be picked up
+func (r *RequestDBModel) TakesSeat() bool {
+	return r.Status == RequestStatusMatched || r.Status == RequestStatusPickedUp
+}
+
+// SeatsNeeded is how many seats the requests' parties fill together
+func SeatsNeeded(requests []*RequestDBModel) int {
+	seats := 0
+	for _, request := range requests {
+		seats += request.PartySize
+	}
+	return seats
+}
+
 func CalculateHaversineDistance(lat1, lng1, lat2, lng2 float64) float64 {
 	const earthRadiusKm = 6371.0
 
@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"math"
+	"time"
+)
+
+// Pickups and dropoffs this close, about 2 km around Melbourne, count as the same area
+const CompensationCellDegrees = 0.02
+
+// How far back the aggregates look
+const CompensationHistory = 90 * 24 * time.Hour
+
+// Requests a driver proposed on within this long were picked up quickly
+const QuickAcceptance = 15 * time.Minute
+
+// A suggestion needs at least this many completed rides behind it
+const MinCompensationSamples = 5
+
+// CompensationSuggestionSource is what a suggestion was worked out from
+type CompensationSuggestionSource string
+
+const (
+	// Rides between the same areas
+	CompensationSourceHistory CompensationSuggestionSource = "history"
+	// Rides between the areas and those around them
+	CompensationSourceNearby CompensationSuggestionSource = "nearby"
+	// Too few rides, so the current tariff instead
+	CompensationSourceTariff CompensationSuggestionSource = "tariff"
+)
+
+// CompensationCell returns the area a point falls in
+func CompensationCell(c Coordinates) (int, int) {
+	return int(math.Floor(c.Lat / CompensationCellDegrees)), int(math.Floor(c.Lon / CompensationCellDegrees))
+}
+
+// CompensationAggregateDBModel summarises recent requests between two areas
+type CompensationAggregateDBModel struct {
+	OriginCellLat        int
+	OriginCellLon        int
+	DestinationCellLat   int
+	DestinationCellLon   int
+	Rides                int
+	CompensationP25      *float64
+	CompensationP50      *float64
+	CompensationP75      *float64
+	QuickCompensationP50 *float64
+	Requests             int
+	Accepted             int
+	AcceptSecondsP50     *float64
+	RefreshedAt          time.Time
+}
+
+// CompensationSuggestion is the range a passenger should ask for
+type CompensationSuggestion struct {
+	Source    CompensationSuggestionSource
+	Low       Money
+	Suggested Money
+	High      Money
+	// Completed rides it was worked out from; none for the tariff
+	Rides int
+	// Share of similar requests that were accepted, and how long that typically took
+	AcceptRate        *float64
+	TypicalAcceptTime *time.Duration
+	RefreshedAt       *time.Time
+}
+
+// SuggestCompensation combines the aggregates, weighting each by its rides. The suggestion is
+// what quickly accepted requests offered, when there were any, so it is not a guess that sits
+// open. It returns nil when there are fewer than MinCompensationSamples rides.
+func SuggestCompensation(source CompensationSuggestionSource, aggregates []*CompensationAggregateDBModel) *CompensationSuggestion {
+	var low, mid, high, quick, acceptSeconds float64
+	var rides, quickRides, requests, accepted, timedAccepted int
+	var refreshedAt time.Time
+	for _, aggregate := range aggregates {
+		if aggregate.RefreshedAt.After(refreshedAt) {
+			refreshedAt = aggregate.RefreshedAt
+		}
+		requests += aggregate.Requests
+		accepted += aggregate.Accepted
+		if aggregate.AcceptSecondsP50 != nil {
+			acceptSeconds += *aggregate.AcceptSecondsP50 * float64(aggregate.Accepted)
+			timedAccepted += aggregate.Accepted
+		}
+		if aggregate.Rides == 0 || aggregate.CompensationP50 == nil {
+			continue
+		}
+		weight := float64(aggregate.Rides)
+		low += *aggregate.CompensationP25 * weight
+		mid += *aggregate.CompensationP50 * weight
+		high += *aggregate.CompensationP75 * weight
+		rides += aggregate.Rides
+		if aggregate.QuickCompensationP50 != nil {
+			quick += *aggregate.QuickCompensationP50 * weight
+			quickRides += aggregate.Rides
+		}
+	}
+	if rides < MinCompensationSamples {
+		return nil
+	}
+
+	suggestion := &CompensationSuggestion{
+		Source:      source,
+		Low:         MoneyFromFloat(low / float64(rides)),
+		Suggested:   MoneyFromFloat(mid / float64(rides)),
+		High:        MoneyFromFloat(high / float64(rides)),
+		Rides:       rides,
+		RefreshedAt: &refreshedAt,
+	}
+	if quickRides > 0 {
+		suggestion.Suggested = MoneyFromFloat(quick / float64(quickRides))
+	}
+	suggestion.High = max(suggestion.High, suggestion.Suggested)
+	if requests > 0 {
+		rate := float64(accepted) / float64(requests)
+		suggestion.AcceptRate = &rate
+	}
+	if timedAccepted > 0 {
+		typical := time.Duration(acceptSeconds / float64(timedAccepted) * float64(time.Second))
+		suggestion.TypicalAcceptTime = &typical
+	}
+	return suggestion
+}
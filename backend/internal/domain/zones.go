@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrOutsideServiceArea = errors.New("location is outside the nOPark service area")
+
+// ZoneKind is what a zone marks out
+type ZoneKind string
+
+const (
+	ZoneKindCampus      ZoneKind = "campus"
+	ZoneKindServiceArea ZoneKind = "service_area"
+)
+
+// ZoneDBModel is a named area on the map. Polygon is a ring of [longitude, latitude] points.
+type ZoneDBModel struct {
+	ID        int64
+	Code      string
+	Name      string
+	Kind      ZoneKind
+	Polygon   [][]float64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Contains reports whether the point is inside the zone's polygon, by counting how many of its
+// edges a ray from the point crosses
+func (z *ZoneDBModel) Contains(c Coordinates) bool {
+	inside := false
+	n := len(z.Polygon)
+	for i, j := 0, n-1; i < n; j, i = i, i+1 {
+		lonI, latI := z.Polygon[i][0], z.Polygon[i][1]
+		lonJ, latJ := z.Polygon[j][0], z.Polygon[j][1]
+		if (latI > c.Lat) != (latJ > c.Lat) &&
+			c.Lon < (lonJ-lonI)*(c.Lat-latI)/(latJ-latI)+lonI {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Zones is every zone, campuses and service area alike
+type Zones []*ZoneDBModel
+
+// InServiceArea reports whether the point is inside any service area. With none set up,
+// everywhere is.
+func (zs Zones) InServiceArea(c Coordinates) bool {
+	found := false
+	for _, zone := range zs {
+		if zone.Kind != ZoneKindServiceArea {
+			continue
+		}
+		if zone.Contains(c) {
+			return true
+		}
+		found = true
+	}
+	return !found
+}
+
+// CampusAt returns the campus the point is on, or nil
+func (zs Zones) CampusAt(c Coordinates) *ZoneDBModel {
+	for _, zone := range zs {
+		if zone.Kind == ZoneKindCampus && zone.Contains(c) {
+			return zone
+		}
+	}
+	return nil
+}
+
+// Campus returns the campus with the given code, or nil
+func (zs Zones) Campus(code string) *ZoneDBModel {
+	for _, zone := range zs {
+		if zone.Kind == ZoneKindCampus && zone.Code == code {
+			return zone
+		}
+	}
+	return nil
+}
+
+type ZonesRepository interface {
+	GetZones(ctx context.Context) (Zones, error)
+}
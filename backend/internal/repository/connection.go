@@ -7,8 +7,26 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// Connection is satisfied by both *pgxpool.Pool and pgx.Tx, so repositories can be
+// bound to either. Calling Begin on a pgx.Tx starts a savepoint.
 type Connection interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
 	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
 	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
 	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
 }
+
+// WithTx runs fn inside a transaction, committing if fn returns nil and rolling back otherwise
+func WithTx(ctx context.Context, conn Connection, fn func(tx pgx.Tx) error) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
@@ -40,14 +40,14 @@ func (p *postgresAccountsRepository) CreateAccount(ctx context.Context, acc *dom
 func (p *postgresAccountsRepository) GetAccountByEmail(ctx context.Context, email string) (*domain.AccountDBModel, error) {
 	row := p.conn.QueryRow(ctx,
 		`SELECT id, type, email, password_hash, firstname, middlename, lastname, email_verified,
-		        current_latitude, current_longitude, fcm_token, created_at, updated_at 
+		        current_latitude, current_longitude, date_of_birth, fcm_token, created_at, updated_at 
 		 FROM accounts WHERE email = $1`,
 		email)
 
 	var account domain.AccountDBModel
 	err := row.Scan(&account.ID, &account.Type, &account.Email, &account.PasswordHash, &account.FirstName, &account.MiddleName,
 		&account.LastName, &account.EmailVerified, &account.CurrentLatitude, &account.CurrentLongitude,
-		&account.FCMToken, &account.CreatedAt, &account.UpdatedAt)
+		&account.DateOfBirth, &account.FCMToken, &account.CreatedAt, &account.UpdatedAt)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil // Account not found
@@ -61,14 +61,14 @@ func (p *postgresAccountsRepository) GetAccountByEmail(ctx context.Context, emai
 func (p *postgresAccountsRepository) GetAccountByID(ctx context.Context, accountID int64) (*domain.AccountDBModel, error) {
 	row := p.conn.QueryRow(ctx,
 		`SELECT id, type, email, password_hash, firstname, middlename, lastname, email_verified,
-		        current_latitude, current_longitude, fcm_token, created_at, updated_at 
+		        current_latitude, current_longitude, date_of_birth, fcm_token, created_at, updated_at 
 		 FROM accounts WHERE id = $1`,
 		accountID)
 
 	var account domain.AccountDBModel
 	err := row.Scan(&account.ID, &account.Type, &account.Email, &account.PasswordHash, &account.FirstName, &account.MiddleName,
 		&account.LastName, &account.EmailVerified, &account.CurrentLatitude, &account.CurrentLongitude,
-		&account.FCMToken, &account.CreatedAt, &account.UpdatedAt)
+		&account.DateOfBirth, &account.FCMToken, &account.CreatedAt, &account.UpdatedAt)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil // Account not found
@@ -82,7 +82,7 @@ func (p *postgresAccountsRepository) GetAccountByID(ctx context.Context, account
 func (p *postgresAccountsRepository) GetAccountsByType(ctx context.Context, accountType string) ([]*domain.AccountDBModel, error) {
 	rows, err := p.conn.Query(ctx,
 		`SELECT id, type, email, firstname, middlename, lastname, email_verified,
-		        current_latitude, current_longitude, fcm_token, created_at, updated_at 
+		        current_latitude, current_longitude, date_of_birth, fcm_token, created_at, updated_at 
 		 FROM accounts WHERE type = $1`,
 		accountType)
 	if err != nil {
@@ -94,7 +94,7 @@ func (p *postgresAccountsRepository) GetAccountsByType(ctx context.Context, acco
 		var account domain.AccountDBModel
 		err := rows.Scan(&account.ID, &account.Type, &account.Email, &account.FirstName, &account.MiddleName,
 			&account.LastName, &account.EmailVerified, &account.CurrentLatitude, &account.CurrentLongitude,
-			&account.FCMToken, &account.CreatedAt, &account.UpdatedAt)
+			&account.DateOfBirth, &account.FCMToken, &account.CreatedAt, &account.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -150,19 +150,19 @@ func (p *postgresAccountsRepository) UpdateAccount(ctx context.Context, acc *dom
 		`UPDATE accounts SET type = $1, email = $2, firstname = $3, middlename = $4, lastname = $5, email_verified = $6, 
 		 email_verification_token = $7, email_verification_expires_at = $8, 
 		 password_reset_token = $9, password_reset_expires_at = $10,
-		 current_latitude = $11, current_longitude = $12, fcm_token = $13
+		 current_latitude = $11, current_longitude = $12, fcm_token = $13, date_of_birth = $15
 		 WHERE id = $14
 		 RETURNING id, type, email, firstname, middlename, lastname, email_verified, 
-		           current_latitude, current_longitude, fcm_token, created_at, updated_at`,
+		           current_latitude, current_longitude, date_of_birth, fcm_token, created_at, updated_at`,
 		acc.Type, acc.Email, acc.FirstName, acc.MiddleName, acc.LastName, acc.EmailVerified,
 		NullString(acc.EmailVerificationToken), NullTime(acc.EmailVerificationExpiresAt),
 		NullString(acc.PasswordResetToken), NullTime(acc.PasswordResetExpiresAt),
 		NullFloat64(acc.CurrentLatitude), NullFloat64(acc.CurrentLongitude), acc.FCMToken,
-		acc.ID)
+		acc.ID, acc.DateOfBirth)
 
 	var account domain.AccountDBModel
 	err := row.Scan(&account.ID, &account.Type, &account.Email, &account.FirstName, &account.MiddleName, &account.LastName,
-		&account.EmailVerified, &account.CurrentLatitude, &account.CurrentLongitude, &account.FCMToken,
+		&account.EmailVerified, &account.CurrentLatitude, &account.CurrentLongitude, &account.DateOfBirth, &account.FCMToken,
 		&account.CreatedAt, &account.UpdatedAt)
 	if err != nil {
 		return nil, err
@@ -286,16 +286,16 @@ func (p *postgresAccountsRepository) CleanupExpiredSessions(ctx context.Context)
 	return err
 }
 
-func (p *postgresAccountsRepository) AddFavouriteAddress(ctx context.Context, accountID int64, addressName string, addressLine string) error {
+func (p *postgresAccountsRepository) AddFavouriteAddress(ctx context.Context, accountID int64, addressName string, addressLine string, lat, lon *float64) error {
 	_, err := p.conn.Exec(ctx,
-		"INSERT INTO account_addresses (account_id, address_name, address_line) VALUES ($1, $2, $3)",
-		accountID, addressName, addressLine)
+		"INSERT INTO account_addresses (account_id, address_name, address_line, latitude, longitude) VALUES ($1, $2, $3, $4, $5)",
+		accountID, addressName, addressLine, lat, lon)
 	return err
 }
 
 func (p *postgresAccountsRepository) GetFavouriteAddresses(ctx context.Context, accountID int64) ([]domain.AddressDBModel, error) {
 	rows, err := p.conn.Query(ctx,
-		"SELECT id, address_name, address_line FROM account_addresses WHERE account_id = $1",
+		"SELECT id, address_name, address_line, latitude, longitude FROM account_addresses WHERE account_id = $1",
 		accountID)
 	if err != nil {
 		return nil, err
@@ -305,7 +305,7 @@ func (p *postgresAccountsRepository) GetFavouriteAddresses(ctx context.Context,
 	addresses := make([]domain.AddressDBModel, 0)
 	for rows.Next() {
 		var address domain.AddressDBModel
-		if err := rows.Scan(&address.ID, &address.AddressName, &address.AddressLine); err != nil {
+		if err := rows.Scan(&address.ID, &address.AddressName, &address.AddressLine, &address.Latitude, &address.Longitude); err != nil {
 			return nil, err
 		}
 		addresses = append(addresses, address)
@@ -365,13 +365,15 @@ func (p *postgresAccountsRepository) CreateVehicle(ctx context.Context, vehicle
 	}
 
 	row := p.conn.QueryRow(ctx,
-		`INSERT INTO vehicles (make, model, model_year, colour, license_plate, account_id) 
-		 VALUES ($1, $2, $3, $4, $5, $6) 
-		 RETURNING id, make, model, model_year, colour, license_plate, account_id, created_at, updated_at`,
-		vehicle.Make, vehicle.Model, vehicle.ModelYear, vehicle.Colour, vehicle.LicensePlate, vehicle.AccountID)
+		`INSERT INTO vehicles (make, model, model_year, colour, license_plate, account_id, fuel_type, fuel_consumption, features, seats) 
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, COALESCE($9, '{}'::TEXT[]), $10) 
+		 RETURNING id, make, model, model_year, colour, license_plate, account_id, fuel_type, fuel_consumption, features, seats, created_at, updated_at`,
+		vehicle.Make, vehicle.Model, vehicle.ModelYear, vehicle.Colour, vehicle.LicensePlate, vehicle.AccountID,
+		vehicle.FuelType, vehicle.FuelConsumption, vehicle.Features, vehicle.Seats)
 
 	err = row.Scan(&createdVehicle.ID, &createdVehicle.Make, &createdVehicle.Model, &createdVehicle.ModelYear,
 		&createdVehicle.Colour, &createdVehicle.LicensePlate, &createdVehicle.AccountID,
+		&createdVehicle.FuelType, &createdVehicle.FuelConsumption, &createdVehicle.Features, &createdVehicle.Seats,
 		&createdVehicle.CreatedAt, &createdVehicle.UpdatedAt)
 	if err != nil {
 		return nil, err
@@ -382,13 +384,14 @@ func (p *postgresAccountsRepository) CreateVehicle(ctx context.Context, vehicle
 
 func (p *postgresAccountsRepository) GetVehicleByAccountID(ctx context.Context, accountID int64) (*domain.VehicleDBModel, error) {
 	row := p.conn.QueryRow(ctx,
-		`SELECT id, make, model, model_year, colour, license_plate, account_id, created_at, updated_at 
+		`SELECT id, make, model, model_year, colour, license_plate, account_id, fuel_type, fuel_consumption, features, seats, created_at, updated_at 
 		 FROM vehicles WHERE account_id = $1`,
 		accountID)
 
 	var vehicle domain.VehicleDBModel
 	err := row.Scan(&vehicle.ID, &vehicle.Make, &vehicle.Model, &vehicle.ModelYear,
 		&vehicle.Colour, &vehicle.LicensePlate, &vehicle.AccountID,
+		&vehicle.FuelType, &vehicle.FuelConsumption, &vehicle.Features, &vehicle.Seats,
 		&vehicle.CreatedAt, &vehicle.UpdatedAt)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -399,3 +402,139 @@ func (p *postgresAccountsRepository) GetVehicleByAccountID(ctx context.Context,
 
 	return &vehicle, nil
 }
+
+func (p *postgresAccountsRepository) UpdateVehicleFeatures(ctx context.Context, accountID int64, features domain.RideFeatures) (*domain.VehicleDBModel, error) {
+	row := p.conn.QueryRow(ctx,
+		`UPDATE vehicles SET features = COALESCE($1, '{}'::TEXT[]) WHERE account_id = $2
+		 RETURNING id, make, model, model_year, colour, license_plate, account_id, fuel_type, fuel_consumption, features, seats, created_at, updated_at`,
+		features, accountID)
+
+	var vehicle domain.VehicleDBModel
+	err := row.Scan(&vehicle.ID, &vehicle.Make, &vehicle.Model, &vehicle.ModelYear,
+		&vehicle.Colour, &vehicle.LicensePlate, &vehicle.AccountID,
+		&vehicle.FuelType, &vehicle.FuelConsumption, &vehicle.Features, &vehicle.Seats,
+		&vehicle.CreatedAt, &vehicle.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil // Vehicle not found
+		}
+		return nil, err
+	}
+
+	return &vehicle, nil
+}
+
+// GetDriverPreferences returns the features the driver offers themselves. Drivers who have
+// not set any offer none.
+func (p *postgresAccountsRepository) GetDriverPreferences(ctx context.Context, accountID int64) (domain.RideFeatures, error) {
+	features := make(domain.RideFeatures, 0)
+	err := p.conn.QueryRow(ctx,
+		`SELECT features FROM driver_preferences WHERE account_id = $1`,
+		accountID).Scan(&features)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, err
+	}
+
+	return features, nil
+}
+
+func (p *postgresAccountsRepository) SetDriverPreferences(ctx context.Context, accountID int64, features domain.RideFeatures) (domain.RideFeatures, error) {
+	var saved domain.RideFeatures
+	err := p.conn.QueryRow(ctx,
+		`INSERT INTO driver_preferences (account_id, features)
+		 VALUES ($1, COALESCE($2, '{}'::TEXT[]))
+		 ON CONFLICT (account_id) DO UPDATE SET features = EXCLUDED.features
+		 RETURNING features`,
+		accountID, features).Scan(&saved)
+	if err != nil {
+		return nil, err
+	}
+
+	return saved, nil
+}
+
+// GetDriverRouteOptions returns how the driver's routes should be chosen. Drivers who have not
+// said take the default routes.
+func (p *postgresAccountsRepository) GetDriverRouteOptions(ctx context.Context, accountID int64) (domain.RouteOptions, error) {
+	var options domain.RouteOptions
+	err := p.conn.QueryRow(ctx,
+		`SELECT avoid_tolls FROM driver_preferences WHERE account_id = $1`,
+		accountID).Scan(&options.AvoidTolls)
+	if err != nil && err != pgx.ErrNoRows {
+		return domain.RouteOptions{}, err
+	}
+
+	return options, nil
+}
+
+func (p *postgresAccountsRepository) SetDriverRouteOptions(ctx context.Context, accountID int64, options domain.RouteOptions) (domain.RouteOptions, error) {
+	var saved domain.RouteOptions
+	err := p.conn.QueryRow(ctx,
+		`INSERT INTO driver_preferences (account_id, avoid_tolls)
+		 VALUES ($1, $2)
+		 ON CONFLICT (account_id) DO UPDATE SET avoid_tolls = EXCLUDED.avoid_tolls
+		 RETURNING avoid_tolls`,
+		accountID, options.AvoidTolls).Scan(&saved.AvoidTolls)
+	if err != nil {
+		return domain.RouteOptions{}, err
+	}
+
+	return saved, nil
+}
+
+const licenseColumns = `id, license_no, license_type, conditions, date_of_issue, expiry, card_number, account_id, created_at, updated_at`
+
+func scanLicense(row pgx.Row) (*domain.LicenseDBModel, error) {
+	var license domain.LicenseDBModel
+	err := row.Scan(&license.ID, &license.LicenseNo, &license.Type, &license.Conditions,
+		&license.DateOfIssue, &license.Expiry, &license.CardNumber, &license.AccountID,
+		&license.CreatedAt, &license.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &license, nil
+}
+
+func (p *postgresAccountsRepository) CreateLicense(ctx context.Context, license *domain.LicenseDBModel) (*domain.LicenseDBModel, error) {
+	return scanLicense(p.conn.QueryRow(ctx,
+		`INSERT INTO licenses (license_no, license_type, conditions, date_of_issue, expiry, card_number, account_id)
+		 VALUES ($1, $2, COALESCE($3, '{}'::TEXT[]), $4, $5, $6, $7)
+		 RETURNING `+licenseColumns,
+		license.LicenseNo, license.Type, license.Conditions, license.DateOfIssue, license.Expiry, license.CardNumber, license.AccountID))
+}
+
+func (p *postgresAccountsRepository) GetLicenseByAccountID(ctx context.Context, accountID int64) (*domain.LicenseDBModel, error) {
+	license, err := scanLicense(p.conn.QueryRow(ctx,
+		`SELECT `+licenseColumns+` FROM licenses WHERE account_id = $1`,
+		accountID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil // License not found
+		}
+		return nil, err
+	}
+
+	return license, nil
+}
+
+func (p *postgresAccountsRepository) UpdateLicense(ctx context.Context, license *domain.LicenseDBModel) (*domain.LicenseDBModel, error) {
+	updated, err := scanLicense(p.conn.QueryRow(ctx,
+		`UPDATE licenses
+		 SET license_no = $1, license_type = $2, conditions = COALESCE($3, '{}'::TEXT[]), date_of_issue = $4, expiry = $5, card_number = $6
+		 WHERE account_id = $7
+		 RETURNING `+licenseColumns,
+		license.LicenseNo, license.Type, license.Conditions, license.DateOfIssue, license.Expiry, license.CardNumber, license.AccountID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil // License not found
+		}
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+func (p *postgresAccountsRepository) DeleteLicense(ctx context.Context, accountID int64) error {
+	_, err := p.conn.Exec(ctx, "DELETE FROM licenses WHERE account_id = $1", accountID)
+	return err
+}
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Arjun113/nOPark/internal/domain"
+	"github.com/jackc/pgx/v5"
+)
+
+type postgresCommutesRepository struct {
+	conn Connection
+}
+
+func NewPostgresCommutes(conn Connection) domain.CommutesRepository {
+	return &postgresCommutesRepository{conn: conn}
+}
+
+// commuteColumns selects a commute with its pickup address and skipped dates, aliased as c
+const commuteColumns = `c.id, c.account_id, c.pickup_address_id, a.address_line, c.dropoff_location, c.dropoff_latitude, c.dropoff_longitude,
+	c.compensation, c.weekdays, to_char(c.start_time, 'HH24:MI'), c.window_kind, c.window_minutes, c.timezone, c.status,
+	c.starts_on::text, c.ends_on::text,
+	ARRAY(SELECT s.skip_date::text FROM commute_skips s WHERE s.commute_id = c.id AND s.skip_date >= CURRENT_DATE ORDER BY s.skip_date),
+	c.created_at, c.updated_at`
+
+func scanCommute(row pgx.Row) (*domain.CommuteDBModel, error) {
+	var commute domain.CommuteDBModel
+	err := row.Scan(&commute.ID, &commute.AccountID, &commute.PickupAddressID, &commute.PickupLocation, &commute.DropoffLocation, &commute.DropoffLatitude, &commute.DropoffLongitude,
+		Dollars(&commute.Compensation), &commute.Weekdays, &commute.StartTime, &commute.WindowKind, &commute.WindowMinutes, &commute.Timezone, &commute.Status,
+		&commute.StartsOn, &commute.EndsOn, &commute.SkippedDates, &commute.CreatedAt, &commute.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &commute, nil
+}
+
+func (p *postgresCommutesRepository) CreateCommute(ctx context.Context, commute *domain.CommuteDBModel) (*domain.CommuteDBModel, error) {
+	var commuteID int64
+	err := p.conn.QueryRow(ctx,
+		`INSERT INTO commutes (account_id, pickup_address_id, dropoff_location, dropoff_latitude, dropoff_longitude, compensation, weekdays, start_time, window_kind, window_minutes, starts_on, ends_on)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, COALESCE($11::date, CURRENT_DATE), $12)
+		 RETURNING id`,
+		commute.AccountID, commute.PickupAddressID, commute.DropoffLocation, commute.DropoffLatitude, commute.DropoffLongitude, commute.Compensation.Float64(),
+		commute.Weekdays, commute.StartTime, commute.WindowKind, commute.WindowMinutes, NullString(commute.StartsOn), commute.EndsOn).Scan(&commuteID)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.GetCommuteByID(ctx, commuteID)
+}
+
+func (p *postgresCommutesRepository) GetCommuteByID(ctx context.Context, commuteID int64) (*domain.CommuteDBModel, error) {
+	row := p.conn.QueryRow(ctx,
+		`SELECT `+commuteColumns+`
+		FROM commutes c
+		JOIN account_addresses a ON a.id = c.pickup_address_id
+		WHERE c.id = $1`,
+		commuteID)
+
+	return scanCommute(row)
+}
+
+func (p *postgresCommutesRepository) GetCommutesByAccountID(ctx context.Context, accountID int64) ([]*domain.CommuteDBModel, error) {
+	rows, err := p.conn.Query(ctx,
+		`SELECT `+commuteColumns+`
+		FROM commutes c
+		JOIN account_addresses a ON a.id = c.pickup_address_id
+		WHERE c.account_id = $1 AND c.status != $2
+		ORDER BY c.created_at DESC`,
+		accountID, domain.CommuteStatusEnded)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	commutes := make([]*domain.CommuteDBModel, 0)
+	for rows.Next() {
+		commute, err := scanCommute(rows)
+		if err != nil {
+			return nil, err
+		}
+		commutes = append(commutes, commute)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return commutes, nil
+}
+
+func (p *postgresCommutesRepository) TransitionCommute(ctx context.Context, commuteID int64, event domain.CommuteEvent) (*domain.CommuteDBModel, error) {
+	err := WithTx(ctx, p.conn, func(tx pgx.Tx) error {
+		var current domain.CommuteStatus
+		err := tx.QueryRow(ctx,
+			`SELECT status FROM commutes WHERE id = $1 FOR UPDATE`,
+			commuteID).Scan(&current)
+		if err != nil {
+			return err
+		}
+
+		status, err := current.Next(event)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx,
+			`UPDATE commutes SET status = $1 WHERE id = $2`,
+			status, commuteID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p.GetCommuteByID(ctx, commuteID)
+}
+
+func (p *postgresCommutesRepository) SkipCommuteDate(ctx context.Context, commuteID int64, date string) error {
+	_, err := p.conn.Exec(ctx,
+		`INSERT INTO commute_skips (commute_id, skip_date) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		commuteID, date)
+	return err
+}
+
+// GetUpcomingCommuteRequestIDs returns the live requests already materialised for a commute,
+// optionally only those on the given local date
+func (p *postgresCommutesRepository) GetUpcomingCommuteRequestIDs(ctx context.Context, commuteID int64, date *string) ([]int64, error) {
+	rows, err := p.conn.Query(ctx,
+		`SELECT req.id
+		FROM requests req
+		JOIN commutes c ON c.id = req.commute_id
+		WHERE req.commute_id = $1
+			AND req.status IN ($2, $3)
+			AND req.window_start > CURRENT_TIMESTAMP
+			AND ($4::date IS NULL OR (req.window_start AT TIME ZONE c.timezone)::date = $4::date)
+		ORDER BY req.id`,
+		commuteID, domain.RequestStatusOpen, domain.RequestStatusMatched, date)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[int64])
+}
+
+// MaterialiseCommutes creates a ride request for every active commute occurrence starting
+// before until. Occurrences that already have a request are left alone, so it is safe to
+// run repeatedly.
+func (p *postgresCommutesRepository) MaterialiseCommutes(ctx context.Context, until time.Time) ([]*domain.RequestDBModel, error) {
+	query := `
+		INSERT INTO requests (pickup_location, pickup_latitude, pickup_longitude, dropoff_location, dropoff_latitude, dropoff_longitude, compensation, passenger_id, notifs_crtd, window_kind, window_start, window_end, commute_id)
+		SELECT a.address_line, a.latitude, a.longitude, c.dropoff_location, c.dropoff_latitude, c.dropoff_longitude, c.compensation, c.account_id, FALSE,
+			c.window_kind, occurrence.starts_at, occurrence.starts_at + make_interval(mins => c.window_minutes), c.id
+		FROM commutes c
+		JOIN account_addresses a ON a.id = c.pickup_address_id
+		CROSS JOIN LATERAL (
+			SELECT day::date AS day, (day::date + c.start_time) AT TIME ZONE c.timezone AS starts_at
+			FROM generate_series(
+				(CURRENT_TIMESTAMP AT TIME ZONE c.timezone)::date,
+				($2::timestamptz AT TIME ZONE c.timezone)::date,
+				INTERVAL '1 day'
+			) AS day
+		) occurrence
+		WHERE c.status = $1
+			AND a.latitude IS NOT NULL AND a.longitude IS NOT NULL
+			AND EXTRACT(DOW FROM occurrence.day)::smallint = ANY(c.weekdays)
+			AND occurrence.day >= c.starts_on
+			AND (c.ends_on IS NULL OR occurrence.day <= c.ends_on)
+			AND occurrence.starts_at > CURRENT_TIMESTAMP
+			AND occurrence.starts_at <= $2
+			AND NOT EXISTS (
+				SELECT 1 FROM commute_skips s WHERE s.commute_id = c.id AND s.skip_date = occurrence.day
+			)
+		ON CONFLICT (commute_id, window_start) DO NOTHING
+		RETURNING id, pickup_location, pickup_latitude, pickup_longitude, dropoff_location, dropoff_latitude, dropoff_longitude, compensation, passenger_id, status, window_kind, window_start, window_end, notifs_crtd, created_at`
+
+	rows, err := p.conn.Query(ctx, query, domain.CommuteStatusActive, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := make([]*domain.RequestDBModel, 0)
+	for rows.Next() {
+		var req domain.RequestDBModel
+		if err := rows.Scan(&req.ID, &req.PickupLocation, &req.PickupLatitude, &req.PickupLongitude, &req.DropoffLocation, &req.DropoffLatitude, &req.DropoffLongitude, &req.Compensation, &req.PassengerID, &req.Status, &req.WindowKind, &req.WindowStart, &req.WindowEnd, &req.AreNotificationsCreated, &req.CreatedAt); err != nil {
+			return nil, err
+		}
+		requests = append(requests, &req)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return requests, nil
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Arjun113/nOPark/internal/domain"
+	"github.com/jackc/pgx/v5"
+)
+
+type postgresDriverApplicationsRepository struct {
+	conn Connection
+}
+
+func NewPostgresDriverApplications(conn Connection) domain.DriverApplicationsRepository {
+	return &postgresDriverApplicationsRepository{conn: conn}
+}
+
+// driverApplicationColumns selects an application with its driver, aliased as d and a
+const driverApplicationColumns = `d.account_id, d.status, d.reason, d.reviewed_by, d.reviewed_at,
+	a.firstname, a.lastname, a.email, d.created_at, d.updated_at`
+
+func scanDriverApplication(row pgx.Row) (*domain.DriverApplicationDBModel, error) {
+	var application domain.DriverApplicationDBModel
+	err := row.Scan(&application.AccountID, &application.Status, &application.Reason, &application.ReviewedBy, &application.ReviewedAt,
+		&application.FirstName, &application.LastName, &application.Email, &application.CreatedAt, &application.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &application, nil
+}
+
+func (p *postgresDriverApplicationsRepository) CreateDriverApplication(ctx context.Context, accountID int64) (*domain.DriverApplicationDBModel, error) {
+	_, err := p.conn.Exec(ctx,
+		`INSERT INTO driver_applications (account_id) VALUES ($1)`,
+		accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.GetDriverApplication(ctx, accountID)
+}
+
+func (p *postgresDriverApplicationsRepository) GetDriverApplication(ctx context.Context, accountID int64) (*domain.DriverApplicationDBModel, error) {
+	application, err := scanDriverApplication(p.conn.QueryRow(ctx,
+		`SELECT `+driverApplicationColumns+`
+		FROM driver_applications d
+		JOIN accounts a ON a.id = d.account_id
+		WHERE d.account_id = $1`,
+		accountID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil // No application yet
+		}
+		return nil, err
+	}
+
+	return application, nil
+}
+
+// GetDriverApplications returns applications oldest first, optionally only those in one status
+func (p *postgresDriverApplicationsRepository) GetDriverApplications(ctx context.Context, status *domain.DriverApplicationStatus) ([]*domain.DriverApplicationDBModel, error) {
+	rows, err := p.conn.Query(ctx,
+		`SELECT `+driverApplicationColumns+`
+		FROM driver_applications d
+		JOIN accounts a ON a.id = d.account_id
+		WHERE ($1::text IS NULL OR d.status = $1)
+		ORDER BY d.created_at, d.account_id`,
+		status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applications := make([]*domain.DriverApplicationDBModel, 0)
+	for rows.Next() {
+		application, err := scanDriverApplication(rows)
+		if err != nil {
+			return nil, err
+		}
+		applications = append(applications, application)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return applications, nil
+}
+
+// ReviewDriverApplication records an admin's decision, replacing any earlier reason
+func (p *postgresDriverApplicationsRepository) ReviewDriverApplication(ctx context.Context, accountID int64, event domain.DriverApplicationEvent, reviewerID int64, reason *string) (*domain.DriverApplicationDBModel, error) {
+	err := WithTx(ctx, p.conn, func(tx pgx.Tx) error {
+		var current domain.DriverApplicationStatus
+		err := tx.QueryRow(ctx,
+			`SELECT status FROM driver_applications WHERE account_id = $1 FOR UPDATE`,
+			accountID).Scan(&current)
+		if err != nil {
+			return err
+		}
+
+		status, err := current.Next(event)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx,
+			`UPDATE driver_applications
+			 SET status = $1, reason = $2, reviewed_by = $3, reviewed_at = CURRENT_TIMESTAMP
+			 WHERE account_id = $4`,
+			status, reason, reviewerID, accountID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p.GetDriverApplication(ctx, accountID)
+}
@@ -0,0 +1,489 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Arjun113/nOPark/internal/domain"
+	"github.com/jackc/pgx/v5"
+)
+
+type postgresLedgerRepository struct {
+	conn Connection
+}
+
+func NewPostgresLedger(conn Connection) domain.LedgerRepository {
+	return &postgresLedgerRepository{conn: conn}
+}
+
+// Money is passed to queries as Cents(), since pgx would otherwise send its String()
+
+const ledgerEntryColumns = `id, kind, ride_id, request_id, settlement_id, refund_of, description, created_by, created_at`
+
+const settlementColumns = `id, payer_id, payee_id, ride_id, request_id, amount_cents, status, paid_at, created_at, updated_at`
+
+func scanSettlement(row pgx.Row) (*domain.SettlementDBModel, error) {
+	var settlement domain.SettlementDBModel
+	err := row.Scan(&settlement.ID, &settlement.PayerID, &settlement.PayeeID, &settlement.RideID, &settlement.RequestID, &settlement.Amount, &settlement.Status, &settlement.PaidAt, &settlement.CreatedAt, &settlement.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &settlement, nil
+}
+
+// postEntry writes a balanced entry and moves each account's balance. Must run inside a
+// transaction; the balance check also runs at commit.
+func (p *postgresLedgerRepository) postEntry(ctx context.Context, entry *domain.LedgerEntryDBModel) error {
+	if !entry.Balanced() {
+		return fmt.Errorf("ledger entry does not balance")
+	}
+
+	err := p.conn.QueryRow(ctx,
+		`INSERT INTO ledger_entries (kind, ride_id, request_id, settlement_id, refund_of, description, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`,
+		entry.Kind, entry.RideID, entry.RequestID, entry.SettlementID, entry.RefundOf, entry.Description, entry.CreatedBy,
+	).Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	// Lock accounts in a fixed order so concurrent entries between the same people cannot deadlock
+	postings := append([]domain.LedgerPosting{}, entry.Postings...)
+	sort.Slice(postings, func(i, j int) bool {
+		return postings[i].AccountID < postings[j].AccountID
+	})
+	for _, posting := range postings {
+		var ledgerAccountID int64
+		var balance int64
+		err := p.conn.QueryRow(ctx,
+			`INSERT INTO ledger_accounts (account_id, balance_cents)
+			VALUES ($1, $2)
+			ON CONFLICT (account_id) DO UPDATE SET balance_cents = ledger_accounts.balance_cents + EXCLUDED.balance_cents
+			RETURNING id, balance_cents`,
+			posting.AccountID, posting.Amount.Cents()).Scan(&ledgerAccountID, &balance)
+		if err != nil {
+			return err
+		}
+
+		_, err = p.conn.Exec(ctx,
+			`INSERT INTO ledger_postings (entry_id, ledger_account_id, amount_cents, balance_after_cents)
+			VALUES ($1, $2, $3, $4)`,
+			entry.ID, ledgerAccountID, posting.Amount.Cents(), balance)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *postgresLedgerRepository) insertSettlement(ctx context.Context, settlement *domain.SettlementDBModel) (*domain.SettlementDBModel, error) {
+	return scanSettlement(p.conn.QueryRow(ctx,
+		`INSERT INTO settlements (payer_id, payee_id, ride_id, request_id, amount_cents)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+settlementColumns,
+		settlement.PayerID, settlement.PayeeID, settlement.RideID, settlement.RequestID, settlement.Amount.Cents()))
+}
+
+// postRideCompensation charges every passenger dropped off on a completed ride what they agreed
+// to pay, and records what each owes the driver. Passengers already charged are skipped. Must
+// run inside the transaction completing the ride.
+func (p *postgresLedgerRepository) postRideCompensation(ctx context.Context, rideID int64) error {
+	type charge struct {
+		driverID, passengerID, requestID int64
+		compensation                     float64
+	}
+
+	rows, err := p.conn.Query(ctx,
+		`SELECT p.driver_id, req.passenger_id, req.id, COALESCE(p.compensation, req.compensation)
+		FROM proposals p
+		JOIN requests req ON req.id = p.request_id
+		WHERE p.ride_id = $1 AND p.status = $2 AND req.status = $3
+			AND NOT EXISTS (SELECT 1 FROM ledger_entries e WHERE e.request_id = req.id AND e.kind = $4)
+		ORDER BY req.id`,
+		rideID, domain.ProposalStatusAccepted, domain.RequestStatusDroppedOff, domain.LedgerEntryRideCompensation)
+	if err != nil {
+		return err
+	}
+	charges, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (charge, error) {
+		var c charge
+		err := row.Scan(&c.driverID, &c.passengerID, &c.requestID, &c.compensation)
+		return c, err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, c := range charges {
+		amount := domain.MoneyFromFloat(c.compensation)
+		if amount <= 0 {
+			continue
+		}
+
+		settlement, err := p.insertSettlement(ctx, &domain.SettlementDBModel{
+			PayerID:   c.passengerID,
+			PayeeID:   c.driverID,
+			RideID:    &rideID,
+			RequestID: &c.requestID,
+			Amount:    amount,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = p.postEntry(ctx, &domain.LedgerEntryDBModel{
+			Kind:         domain.LedgerEntryRideCompensation,
+			RideID:       &rideID,
+			RequestID:    &c.requestID,
+			SettlementID: &settlement.ID,
+			Description:  fmt.Sprintf("Ride #%d", rideID),
+			Postings: []domain.LedgerPosting{
+				{AccountID: c.passengerID, Amount: -amount},
+				{AccountID: c.driverID, Amount: amount},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *postgresLedgerRepository) GetBalance(ctx context.Context, accountID int64) (domain.Money, error) {
+	var balance domain.Money
+	err := p.conn.QueryRow(ctx,
+		`SELECT balance_cents FROM ledger_accounts WHERE account_id = $1`,
+		accountID).Scan(&balance)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Nothing has been posted for them yet
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+// GetStatement returns a page of the postings to an account, newest first
+func (p *postgresLedgerRepository) GetStatement(ctx context.Context, filter domain.StatementFilter) ([]*domain.StatementLineDBModel, error) {
+	rows, err := p.conn.Query(ctx,
+		`SELECT lp.id, e.id, e.kind, e.ride_id, e.request_id, e.settlement_id, e.description,
+			lp.amount_cents, lp.balance_after_cents,
+			(SELECT MIN(other.account_id)
+				FROM ledger_postings op
+				JOIN ledger_accounts other ON other.id = op.ledger_account_id
+				WHERE op.entry_id = e.id AND op.id != lp.id
+				HAVING COUNT(*) = 1),
+			lp.created_at
+		FROM ledger_postings lp
+		JOIN ledger_accounts la ON la.id = lp.ledger_account_id
+		JOIN ledger_entries e ON e.id = lp.entry_id
+		WHERE la.account_id = $1
+			AND ($2::timestamptz IS NULL OR lp.created_at >= $2)
+			AND ($3::timestamptz IS NULL OR lp.created_at < $3)
+			AND ($4::bigint IS NULL OR lp.id < $4)
+		ORDER BY lp.id DESC
+		LIMIT $5`,
+		filter.AccountID, filter.From, filter.To, filter.BeforePostingID, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := make([]*domain.StatementLineDBModel, 0)
+	for rows.Next() {
+		var line domain.StatementLineDBModel
+		if err := rows.Scan(&line.PostingID, &line.EntryID, &line.Kind, &line.RideID, &line.RequestID, &line.SettlementID, &line.Description,
+			&line.Amount, &line.BalanceAfter, &line.CounterpartyID, &line.CreatedAt); err != nil {
+			return nil, err
+		}
+		lines = append(lines, &line)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// GetSettlements returns what the account owes or is owed, newest first
+func (p *postgresLedgerRepository) GetSettlements(ctx context.Context, accountID int64, status *domain.SettlementStatus) ([]*domain.SettlementDBModel, error) {
+	rows, err := p.conn.Query(ctx,
+		`SELECT `+settlementColumns+` FROM settlements
+		WHERE (payer_id = $1 OR payee_id = $1) AND ($2::text IS NULL OR status = $2)
+		ORDER BY id DESC`,
+		accountID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settlements := make([]*domain.SettlementDBModel, 0)
+	for rows.Next() {
+		settlement, err := scanSettlement(rows)
+		if err != nil {
+			return nil, err
+		}
+		settlements = append(settlements, settlement)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return settlements, nil
+}
+
+func (p *postgresLedgerRepository) GetSettlementByID(ctx context.Context, settlementID int64) (*domain.SettlementDBModel, error) {
+	return scanSettlement(p.conn.QueryRow(ctx,
+		`SELECT `+settlementColumns+` FROM settlements WHERE id = $1`,
+		settlementID))
+}
+
+func (p *postgresLedgerRepository) getSettlementForUpdate(ctx context.Context, settlementID int64) (*domain.SettlementDBModel, error) {
+	return scanSettlement(p.conn.QueryRow(ctx,
+		`SELECT `+settlementColumns+` FROM settlements WHERE id = $1 FOR UPDATE`,
+		settlementID))
+}
+
+// MarkSettlementPaid records that the payer has paid, which clears what they owed on the ledger
+func (p *postgresLedgerRepository) MarkSettlementPaid(ctx context.Context, settlementID int64) (*domain.SettlementDBModel, error) {
+	var settlement *domain.SettlementDBModel
+
+	err := WithTx(ctx, p.conn, func(tx pgx.Tx) error {
+		txRepo := &postgresLedgerRepository{conn: tx}
+
+		current, err := txRepo.getSettlementForUpdate(ctx, settlementID)
+		if err != nil {
+			return err
+		}
+		status, err := current.Status.Next(domain.SettlementEventPay)
+		if err != nil {
+			return err
+		}
+
+		settlement, err = scanSettlement(tx.QueryRow(ctx,
+			`UPDATE settlements SET status = $1, paid_at = CURRENT_TIMESTAMP WHERE id = $2
+			RETURNING `+settlementColumns,
+			status, settlementID))
+		if err != nil {
+			return err
+		}
+
+		return txRepo.postEntry(ctx, &domain.LedgerEntryDBModel{
+			Kind:         domain.LedgerEntrySettlement,
+			RideID:       settlement.RideID,
+			RequestID:    settlement.RequestID,
+			SettlementID: &settlement.ID,
+			Description:  fmt.Sprintf("Settlement #%d paid", settlement.ID),
+			CreatedBy:    &settlement.PayeeID,
+			Postings: []domain.LedgerPosting{
+				{AccountID: settlement.PayerID, Amount: settlement.Amount},
+				{AccountID: settlement.PayeeID, Amount: -settlement.Amount},
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return settlement, nil
+}
+
+func (p *postgresLedgerRepository) getEntry(ctx context.Context, entryID int64, forUpdate bool) (*domain.LedgerEntryDBModel, error) {
+	query := `SELECT ` + ledgerEntryColumns + ` FROM ledger_entries WHERE id = $1`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+
+	var entry domain.LedgerEntryDBModel
+	err := p.conn.QueryRow(ctx, query, entryID).Scan(&entry.ID, &entry.Kind, &entry.RideID, &entry.RequestID, &entry.SettlementID, &entry.RefundOf, &entry.Description, &entry.CreatedBy, &entry.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := p.conn.Query(ctx,
+		`SELECT la.account_id, lp.amount_cents
+		FROM ledger_postings lp
+		JOIN ledger_accounts la ON la.id = lp.ledger_account_id
+		WHERE lp.entry_id = $1
+		ORDER BY lp.id`,
+		entryID)
+	if err != nil {
+		return nil, err
+	}
+	entry.Postings, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (domain.LedgerPosting, error) {
+		var posting domain.LedgerPosting
+		err := row.Scan(&posting.AccountID, &posting.Amount)
+		return posting, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// refundable returns who paid and who was paid for a ride charge and how much of it is left
+// to refund
+func (p *postgresLedgerRepository) refundable(ctx context.Context, charge *domain.LedgerEntryDBModel) (int64, int64, domain.Money, error) {
+	var passengerID, driverID int64
+	var amount domain.Money
+	for _, posting := range charge.Postings {
+		if posting.Amount < 0 {
+			passengerID = posting.AccountID
+		} else {
+			driverID = posting.AccountID
+			amount = posting.Amount
+		}
+	}
+
+	var refunded domain.Money
+	err := p.conn.QueryRow(ctx,
+		`SELECT COALESCE(SUM(lp.amount_cents), 0)
+		FROM ledger_entries e
+		JOIN ledger_postings lp ON lp.entry_id = e.id
+		JOIN ledger_accounts la ON la.id = lp.ledger_account_id
+		WHERE e.refund_of = $1 AND la.account_id = $2`,
+		charge.ID, passengerID).Scan(&refunded)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	return passengerID, driverID, amount - refunded, nil
+}
+
+// GetRideCharge returns what the passenger was charged for a request and how much of it is
+// left to refund
+func (p *postgresLedgerRepository) GetRideCharge(ctx context.Context, requestID int64) (*domain.LedgerEntryDBModel, domain.Money, error) {
+	var entryID int64
+	err := p.conn.QueryRow(ctx,
+		`SELECT id FROM ledger_entries WHERE request_id = $1 AND kind = $2`,
+		requestID, domain.LedgerEntryRideCompensation).Scan(&entryID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	charge, err := p.getEntry(ctx, entryID, false)
+	if err != nil {
+		return nil, 0, err
+	}
+	_, _, remaining, err := p.refundable(ctx, charge)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return charge, remaining, nil
+}
+
+// Refund gives back part or all of a ride charge. An unpaid settlement for the charge shrinks
+// by the refund; once it has been paid the driver owes the refund back in a new settlement.
+// Cancellations never call for one: passengers are only charged as a ride completes, and by
+// then nothing on the ride can be cancelled.
+func (p *postgresLedgerRepository) Refund(ctx context.Context, chargeID int64, amount domain.Money, reason string, createdBy int64) (*domain.LedgerEntryDBModel, error) {
+	var refund *domain.LedgerEntryDBModel
+
+	err := WithTx(ctx, p.conn, func(tx pgx.Tx) error {
+		txRepo := &postgresLedgerRepository{conn: tx}
+
+		// Locking the charge serialises refunds of it
+		charge, err := txRepo.getEntry(ctx, chargeID, true)
+		if err != nil {
+			return err
+		}
+		if charge.Kind != domain.LedgerEntryRideCompensation {
+			return fmt.Errorf("only ride compensation can be refunded")
+		}
+		passengerID, driverID, remaining, err := txRepo.refundable(ctx, charge)
+		if err != nil {
+			return err
+		}
+		if amount <= 0 || amount > remaining {
+			return domain.ErrRefundExceedsCharge
+		}
+
+		settlementID := charge.SettlementID
+		if charge.SettlementID != nil {
+			settlement, err := txRepo.getSettlementForUpdate(ctx, *charge.SettlementID)
+			if err != nil {
+				return err
+			}
+
+			switch settlement.Status {
+			case domain.SettlementStatusPending:
+				status := settlement.Status
+				if settlement.Amount-amount <= 0 {
+					status, err = status.Next(domain.SettlementEventCancel)
+					if err != nil {
+						return err
+					}
+				}
+				_, err = tx.Exec(ctx,
+					`UPDATE settlements SET amount_cents = $1, status = $2 WHERE id = $3`,
+					max(settlement.Amount-amount, 0).Cents(), status, settlement.ID)
+				if err != nil {
+					return err
+				}
+			case domain.SettlementStatusPaid:
+				owed, err := txRepo.insertSettlement(ctx, &domain.SettlementDBModel{
+					PayerID:   driverID,
+					PayeeID:   passengerID,
+					RideID:    charge.RideID,
+					RequestID: charge.RequestID,
+					Amount:    amount,
+				})
+				if err != nil {
+					return err
+				}
+				settlementID = &owed.ID
+			}
+		}
+
+		refund = &domain.LedgerEntryDBModel{
+			Kind:         domain.LedgerEntryRefund,
+			RideID:       charge.RideID,
+			RequestID:    charge.RequestID,
+			SettlementID: settlementID,
+			RefundOf:     &charge.ID,
+			Description:  reason,
+			CreatedBy:    &createdBy,
+			Postings: []domain.LedgerPosting{
+				{AccountID: passengerID, Amount: amount},
+				{AccountID: driverID, Amount: -amount},
+			},
+		}
+		return txRepo.postEntry(ctx, refund)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return refund, nil
+}
+
+// PostAdjustment moves amount from one user's balance to another's
+func (p *postgresLedgerRepository) PostAdjustment(ctx context.Context, fromAccountID, toAccountID int64, amount domain.Money, reason string, createdBy int64) (*domain.LedgerEntryDBModel, error) {
+	adjustment := &domain.LedgerEntryDBModel{
+		Kind:        domain.LedgerEntryAdjustment,
+		Description: reason,
+		CreatedBy:   &createdBy,
+		Postings: []domain.LedgerPosting{
+			{AccountID: fromAccountID, Amount: -amount},
+			{AccountID: toAccountID, Amount: amount},
+		},
+	}
+
+	err := WithTx(ctx, p.conn, func(tx pgx.Tx) error {
+		return (&postgresLedgerRepository{conn: tx}).postEntry(ctx, adjustment)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return adjustment, nil
+}
@@ -15,7 +15,11 @@ func NewPostgresMaps(conn Connection) domain.MapsRepository {
 }
 
 func (p *postgresMapsRepository) GetDirectRoute(ctx context.Context, start domain.Coordinates, dest domain.Coordinates) (*domain.RouteDBModel, error) {
-	rows, err := p.conn.Query(ctx, `SELECT * FROM get_route_between($1, $2, $3, $4)`, start.Lon, start.Lat, dest.Lon, dest.Lat)
+	return p.GetDirectRouteWithOptions(ctx, start, dest, domain.RouteOptions{})
+}
+
+func (p *postgresMapsRepository) GetDirectRouteWithOptions(ctx context.Context, start domain.Coordinates, dest domain.Coordinates, options domain.RouteOptions) (*domain.RouteDBModel, error) {
+	rows, err := p.conn.Query(ctx, `SELECT * FROM get_route_between($1, $2, $3, $4, $5)`, start.Lon, start.Lat, dest.Lon, dest.Lat, options.AvoidTolls)
 	if err != nil {
 		return nil, err
 	}
@@ -24,15 +28,20 @@ func (p *postgresMapsRepository) GetDirectRoute(ctx context.Context, start domai
 	var coordinates [][]float64
 	var totalDistance float64
 	var estimatedDuration float64
+	var tolls []domain.RouteToll
 
 	for rows.Next() {
 		var seq int
 		var node, edge *int64
 		var cost, costS, aggCost, aggCostS float64
 		var geomText string
-		if err := rows.Scan(&seq, &node, &edge, &cost, &costS, &aggCost, &aggCostS, &geomText); err != nil {
+		var tollRoad *string
+		if err := rows.Scan(&seq, &node, &edge, &cost, &costS, &aggCost, &aggCostS, &geomText, &tollRoad); err != nil {
 			return nil, err
 		}
+		if tollRoad != nil {
+			tolls = domain.AddRouteToll(tolls, *tollRoad, cost)
+		}
 
 		// Parse GeoJSON string to extract coordinates
 		lineString := domain.ExtractCoordinatesFromGeoJSON(geomText)
@@ -80,20 +89,28 @@ func (p *postgresMapsRepository) GetDirectRoute(ctx context.Context, start domai
 		Distance:       totalDistance,
 		Duration:       int64(estimatedDuration),
 		Polyline:       polyline,
+		Legs:           []domain.RouteLeg{{Distance: totalDistance, Duration: int64(estimatedDuration), Tolls: tolls}},
+		Tolls:          tolls,
 	}
 
 	return &route, nil
 }
 
 func (p *postgresMapsRepository) GetMultistopRoute(ctx context.Context, start domain.Coordinates, waypoints []domain.Coordinates, dest domain.Coordinates) (*domain.RouteDBModel, error) {
+	return p.GetMultistopRouteWithOptions(ctx, start, waypoints, dest, domain.RouteOptions{})
+}
+
+func (p *postgresMapsRepository) GetMultistopRouteWithOptions(ctx context.Context, start domain.Coordinates, waypoints []domain.Coordinates, dest domain.Coordinates, options domain.RouteOptions) (*domain.RouteDBModel, error) {
 	// Traverses waypoints in-order
 	if len(waypoints) == 0 {
-		return p.GetDirectRoute(ctx, start, dest)
+		return p.GetDirectRouteWithOptions(ctx, start, dest, options)
 	}
 
 	var polylines []string
+	var legs []domain.RouteLeg
 	var totalDistance float64
 	var totalDuration int64
+	var tolls []domain.RouteToll
 
 	stops := []domain.Coordinates{start}
 	stops = append(stops, waypoints...)
@@ -101,11 +118,15 @@ func (p *postgresMapsRepository) GetMultistopRoute(ctx context.Context, start do
 
 	legCount := len(stops) - 1
 	for i := range legCount {
-		legRoute, err := p.GetDirectRoute(ctx, stops[i], stops[i+1])
+		legRoute, err := p.GetDirectRouteWithOptions(ctx, stops[i], stops[i+1], options)
 		if err != nil {
 			return nil, err
 		}
 		polylines = append(polylines, legRoute.Polyline)
+		legs = append(legs, legRoute.Legs...)
+		for _, toll := range legRoute.Tolls {
+			tolls = domain.AddRouteToll(tolls, toll.Road, toll.Distance)
+		}
 
 		totalDistance += legRoute.Distance
 		totalDuration += legRoute.Duration
@@ -124,20 +145,26 @@ func (p *postgresMapsRepository) GetMultistopRoute(ctx context.Context, start do
 		Distance:       totalDistance,
 		Duration:       totalDuration,
 		Polyline:       combinedPolyline,
+		Legs:           legs,
+		Tolls:          tolls,
 	}, nil
 }
 
 func (p *postgresMapsRepository) GetRouteFromWaypoints(ctx context.Context, start domain.Coordinates, waypoints []domain.Coordinates, dest domain.Coordinates) (*domain.RouteDBModel, error) {
+	return p.GetRouteFromWaypointsWithOptions(ctx, start, waypoints, dest, domain.RouteOptions{})
+}
+
+func (p *postgresMapsRepository) GetRouteFromWaypointsWithOptions(ctx context.Context, start domain.Coordinates, waypoints []domain.Coordinates, dest domain.Coordinates, options domain.RouteOptions) (*domain.RouteDBModel, error) {
 	// waypoints are in any-order
 	var bestRoute *domain.RouteDBModel
 
 	if len(waypoints) == 0 {
-		return p.GetDirectRoute(ctx, start, dest)
+		return p.GetDirectRouteWithOptions(ctx, start, dest, options)
 	}
 
 	// Exhaustive search
 	for _, waypointOrder := range permutations(waypoints) {
-		route, err := p.GetMultistopRoute(ctx, start, waypointOrder, dest)
+		route, err := p.GetMultistopRouteWithOptions(ctx, start, waypointOrder, dest, options)
 		if err != nil {
 			return nil, err
 		}
@@ -60,10 +60,11 @@ func (p *postgresNotificationsRepository) GetNotificationsByAccountID(ctx contex
 
 func (p *postgresNotificationsRepository) GetUnnotifiedRideRequests(ctx context.Context) ([]*domain.RequestDBModel, error) {
 	rows, err := p.conn.Query(ctx,
-		`SELECT id, pickup_location, dropoff_location, compensation, passenger_id, notifs_crtd, created_at 
+		`SELECT id, pickup_location, dropoff_location, compensation, passenger_id, status, notifs_crtd, created_at 
 		 FROM requests 
-		 WHERE ride_id IS NULL AND notifs_crtd = FALSE
-		 ORDER BY created_at DESC`)
+		 WHERE status = $1 AND notifs_crtd = FALSE
+		 ORDER BY created_at DESC`,
+		domain.RequestStatusOpen)
 	if err != nil {
 		return nil, err
 	}
@@ -72,7 +73,7 @@ func (p *postgresNotificationsRepository) GetUnnotifiedRideRequests(ctx context.
 	var requests []*domain.RequestDBModel
 	for rows.Next() {
 		var req domain.RequestDBModel
-		if err := rows.Scan(&req.ID, &req.PickupLocation, &req.DropoffLocation, &req.Compensation, &req.PassengerID, &req.AreNotificationsCreated, &req.CreatedAt); err != nil {
+		if err := rows.Scan(&req.ID, &req.PickupLocation, &req.DropoffLocation, &req.Compensation, &req.PassengerID, &req.Status, &req.AreNotificationsCreated, &req.CreatedAt); err != nil {
 			return nil, err
 		}
 		requests = append(requests, &req)
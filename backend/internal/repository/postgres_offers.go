@@ -0,0 +1,427 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Arjun113/nOPark/internal/domain"
+	"github.com/jackc/pgx/v5"
+)
+
+type postgresOffersRepository struct {
+	conn Connection
+}
+
+func NewPostgresOffers(conn Connection) domain.OffersRepository {
+	return &postgresOffersRepository{conn: conn}
+}
+
+// offerColumns selects an offer aliased as o. Seats count as taken while the passenger's
+// request is still attached to the ride, so cancelled or released passengers free them up.
+var offerColumns = fmt.Sprintf(`o.id, o.driver_id, o.origin_location, o.origin_latitude, o.origin_longitude,
+	o.destination_location, o.destination_latitude, o.destination_longitude,
+	o.route_polyline, o.route_distance, o.route_duration, o.departure_time, o.seats,
+	(SELECT COALESCE(SUM(b.seats), 0)
+		FROM offer_bookings b
+		JOIN requests req ON req.id = b.request_id
+		WHERE b.offer_id = o.id AND req.status IN ('%s', '%s')) AS seats_taken,
+	o.price_per_seat, o.status, o.ride_id, o.created_at, o.updated_at`,
+	domain.RequestStatusMatched, domain.RequestStatusPickedUp)
+
+func scanOffer(row pgx.Row) (*domain.OfferDBModel, error) {
+	var offer domain.OfferDBModel
+	err := row.Scan(&offer.ID, &offer.DriverID, &offer.OriginLocation, &offer.OriginLatitude, &offer.OriginLongitude,
+		&offer.DestinationLocation, &offer.DestinationLatitude, &offer.DestinationLongitude,
+		&offer.RoutePolyline, &offer.RouteDistance, &offer.RouteDuration, &offer.DepartureTime, &offer.Seats,
+		&offer.SeatsTaken, Dollars(&offer.PricePerSeat), &offer.Status, &offer.RideID, &offer.CreatedAt, &offer.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &offer, nil
+}
+
+func collectOffers(rows pgx.Rows) ([]*domain.OfferDBModel, error) {
+	defer rows.Close()
+
+	offers := make([]*domain.OfferDBModel, 0)
+	for rows.Next() {
+		offer, err := scanOffer(rows)
+		if err != nil {
+			return nil, err
+		}
+		offers = append(offers, offer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return offers, nil
+}
+
+const bookingColumns = `id, offer_id, passenger_id, COALESCE(pickup_location, ''), pickup_latitude, pickup_longitude,
+	dropoff_location, dropoff_latitude, dropoff_longitude, seats, detour_duration, status, request_id, created_at, updated_at`
+
+func scanBooking(row pgx.Row) (*domain.BookingDBModel, error) {
+	var booking domain.BookingDBModel
+	err := row.Scan(&booking.ID, &booking.OfferID, &booking.PassengerID, &booking.PickupLocation, &booking.PickupLatitude, &booking.PickupLongitude,
+		&booking.DropoffLocation, &booking.DropoffLatitude, &booking.DropoffLongitude, &booking.Seats, &booking.DetourDuration, &booking.Status,
+		&booking.RequestID, &booking.CreatedAt, &booking.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
+
+func collectBookings(rows pgx.Rows) ([]*domain.BookingDBModel, error) {
+	defer rows.Close()
+
+	bookings := make([]*domain.BookingDBModel, 0)
+	for rows.Next() {
+		booking, err := scanBooking(rows)
+		if err != nil {
+			return nil, err
+		}
+		bookings = append(bookings, booking)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return bookings, nil
+}
+
+func (p *postgresOffersRepository) CreateOffer(ctx context.Context, offer *domain.OfferDBModel) (*domain.OfferDBModel, error) {
+	var offerID int64
+	err := p.conn.QueryRow(ctx,
+		`INSERT INTO offers (driver_id, origin_location, origin_latitude, origin_longitude, destination_location, destination_latitude, destination_longitude,
+			route_polyline, route_distance, route_duration, departure_time, seats, price_per_seat)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		 RETURNING id`,
+		offer.DriverID, offer.OriginLocation, offer.OriginLatitude, offer.OriginLongitude, offer.DestinationLocation, offer.DestinationLatitude, offer.DestinationLongitude,
+		offer.RoutePolyline, offer.RouteDistance, offer.RouteDuration, offer.DepartureTime, offer.Seats, offer.PricePerSeat.Float64()).Scan(&offerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.GetOfferByID(ctx, offerID)
+}
+
+func (p *postgresOffersRepository) GetOfferByID(ctx context.Context, offerID int64) (*domain.OfferDBModel, error) {
+	row := p.conn.QueryRow(ctx,
+		`SELECT `+offerColumns+` FROM offers o WHERE o.id = $1`,
+		offerID)
+
+	return scanOffer(row)
+}
+
+func (p *postgresOffersRepository) GetOffersByDriverID(ctx context.Context, driverID int64) ([]*domain.OfferDBModel, error) {
+	rows, err := p.conn.Query(ctx,
+		`SELECT `+offerColumns+` FROM offers o WHERE o.driver_id = $1 ORDER BY o.departure_time DESC`,
+		driverID)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectOffers(rows)
+}
+
+// SearchOffers returns open offers departing in the given range with at least seats free.
+// Route fit is left to the caller, which needs the routing engine for it.
+func (p *postgresOffersRepository) SearchOffers(ctx context.Context, departAfter, departBefore time.Time, seats int) ([]*domain.OfferDBModel, error) {
+	rows, err := p.conn.Query(ctx,
+		`SELECT * FROM (
+			SELECT `+offerColumns+` FROM offers o
+			WHERE o.status = $1 AND o.departure_time BETWEEN $2 AND $3
+		) offer
+		WHERE offer.seats - offer.seats_taken >= $4
+		ORDER BY offer.departure_time`,
+		domain.OfferStatusOpen, departAfter, departBefore, seats)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectOffers(rows)
+}
+
+func (p *postgresOffersRepository) CancelOffer(ctx context.Context, offerID int64) (*domain.OfferDBModel, []*domain.BookingDBModel, error) {
+	var declined []*domain.BookingDBModel
+
+	err := WithTx(ctx, p.conn, func(tx pgx.Tx) error {
+		var current domain.OfferStatus
+		err := tx.QueryRow(ctx,
+			`SELECT status FROM offers WHERE id = $1 FOR UPDATE`,
+			offerID).Scan(&current)
+		if err != nil {
+			return err
+		}
+		status, err := current.Next(domain.OfferEventCancel)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx,
+			`UPDATE offers SET status = $1 WHERE id = $2`,
+			status, offerID)
+		if err != nil {
+			return err
+		}
+
+		// Anyone still waiting on the driver is turned away
+		bookingStatus, err := domain.BookingStatusPending.Next(domain.BookingEventDecline)
+		if err != nil {
+			return err
+		}
+		rows, err := tx.Query(ctx,
+			`UPDATE offer_bookings SET status = $1
+			WHERE offer_id = $2 AND status = $3
+			RETURNING `+bookingColumns,
+			bookingStatus, offerID, domain.BookingStatusPending)
+		if err != nil {
+			return err
+		}
+		declined, err = collectBookings(rows)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	offer, err := p.GetOfferByID(ctx, offerID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return offer, declined, nil
+}
+
+func (p *postgresOffersRepository) CreateBooking(ctx context.Context, booking *domain.BookingDBModel) (*domain.BookingDBModel, error) {
+	row := p.conn.QueryRow(ctx,
+		`INSERT INTO offer_bookings (offer_id, passenger_id, pickup_location, pickup_latitude, pickup_longitude, dropoff_location, dropoff_latitude, dropoff_longitude, seats, detour_duration)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		 RETURNING `+bookingColumns,
+		booking.OfferID, booking.PassengerID, NullString(booking.PickupLocation), booking.PickupLatitude, booking.PickupLongitude,
+		booking.DropoffLocation, booking.DropoffLatitude, booking.DropoffLongitude, booking.Seats, booking.DetourDuration)
+
+	return scanBooking(row)
+}
+
+func (p *postgresOffersRepository) GetBookingByID(ctx context.Context, bookingID int64) (*domain.BookingDBModel, error) {
+	row := p.conn.QueryRow(ctx,
+		`SELECT `+bookingColumns+` FROM offer_bookings WHERE id = $1`,
+		bookingID)
+
+	return scanBooking(row)
+}
+
+func (p *postgresOffersRepository) GetBookingsByOfferID(ctx context.Context, offerID int64) ([]*domain.BookingDBModel, error) {
+	rows, err := p.conn.Query(ctx,
+		`SELECT `+bookingColumns+` FROM offer_bookings WHERE offer_id = $1 ORDER BY created_at`,
+		offerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectBookings(rows)
+}
+
+func (p *postgresOffersRepository) GetBookingsByPassengerID(ctx context.Context, passengerID int64) ([]*domain.BookingDBModel, error) {
+	rows, err := p.conn.Query(ctx,
+		`SELECT `+bookingColumns+` FROM offer_bookings WHERE passenger_id = $1 ORDER BY created_at DESC`,
+		passengerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectBookings(rows)
+}
+
+// TransitionBooking answers or withdraws a pending booking. Approving it puts the passenger on
+// the offer's ride as a matched request with an accepted proposal, creating the ride on the
+// first approval.
+func (p *postgresOffersRepository) TransitionBooking(ctx context.Context, bookingID int64, event domain.BookingEvent) (*domain.BookingDBModel, error) {
+	booking, err := p.GetBookingByID(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = WithTx(ctx, p.conn, func(tx pgx.Tx) error {
+		txRepo := &postgresOffersRepository{conn: tx}
+
+		// Lock the offer first so concurrent approvals cannot oversell its seats
+		offer, err := scanOffer(tx.QueryRow(ctx,
+			`SELECT `+offerColumns+` FROM offers o WHERE o.id = $1 FOR UPDATE`,
+			booking.OfferID))
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRow(ctx,
+			`SELECT status FROM offer_bookings WHERE id = $1 FOR UPDATE`,
+			bookingID).Scan(&booking.Status)
+		if err != nil {
+			return err
+		}
+		status, err := booking.Status.Next(event)
+		if err != nil {
+			return err
+		}
+
+		if event == domain.BookingEventApprove {
+			if offer.Status != domain.OfferStatusOpen {
+				return &domain.InvalidTransitionError{Entity: "offer", From: string(offer.Status), Event: string(event)}
+			}
+			if booking.Seats > offer.SeatsFree() {
+				return domain.ErrNotEnoughSeats
+			}
+
+			requestID, err := txRepo.boardPassenger(ctx, offer, booking)
+			if err != nil {
+				return err
+			}
+			booking.RequestID = &requestID
+		}
+
+		_, err = tx.Exec(ctx,
+			`UPDATE offer_bookings SET status = $1, request_id = $2 WHERE id = $3`,
+			status, booking.RequestID, bookingID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p.GetBookingByID(ctx, bookingID)
+}
+
+// boardPassenger attaches an approved booking to the offer's ride and returns the new request's ID.
+// Must run inside TransitionBooking's transaction with the offer locked.
+func (p *postgresOffersRepository) boardPassenger(ctx context.Context, offer *domain.OfferDBModel, booking *domain.BookingDBModel) (int64, error) {
+	ridesRepo := &postgresRidesRepository{conn: p.conn}
+
+	var ride *domain.RideDBModel
+	if offer.RideID != nil {
+		existing, err := ridesRepo.getRideForUpdate(ctx, *offer.RideID)
+		if err != nil {
+			return 0, err
+		}
+		if existing.Status == domain.RideStatusCompleted {
+			return 0, &domain.InvalidTransitionError{Entity: "ride", From: string(existing.Status), Event: "join"}
+		}
+		if existing.Status == domain.RideStatusInProgress {
+			ride = existing
+		}
+	}
+
+	// First passenger, or every earlier passenger dropped out and the ride was called off
+	if ride == nil {
+		ride = &domain.RideDBModel{}
+		err := p.conn.QueryRow(ctx,
+			`INSERT INTO rides (destination_latitude, destination_longitude, route_distance, route_duration, seats)
+			 VALUES ($1, $2, $3, $4, $5)
+			 RETURNING id, status`,
+			offer.DestinationLatitude, offer.DestinationLongitude, offer.RouteDistance, offer.RouteDuration, offer.Seats).Scan(&ride.ID, &ride.Status)
+		if err != nil {
+			return 0, err
+		}
+		if err := ride.Apply(domain.RideEventConfirm); err != nil {
+			return 0, err
+		}
+		_, err = p.conn.Exec(ctx,
+			`UPDATE rides SET status = $1 WHERE id = $2`,
+			ride.Status, ride.ID)
+		if err != nil {
+			return 0, err
+		}
+		_, err = p.conn.Exec(ctx,
+			`UPDATE offers SET ride_id = $1 WHERE id = $2`,
+			ride.ID, offer.ID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	windowKind := domain.WindowKindDeparture
+	windowEnd := offer.DepartureTime.Add(domain.ImmediateDepartureWindow)
+	if err := p.lockPassengerWindow(ctx, booking.PassengerID, offer.DepartureTime, windowEnd); err != nil {
+		return 0, err
+	}
+
+	var requestID int64
+	err := p.conn.QueryRow(ctx,
+		`INSERT INTO requests (pickup_location, pickup_latitude, pickup_longitude, dropoff_location, dropoff_latitude, dropoff_longitude, compensation, passenger_id, notifs_crtd, window_kind, window_start, window_end, party_size)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, TRUE, $9, $10, $11, $12)
+		 RETURNING id`,
+		booking.PickupLocation, booking.PickupLatitude, booking.PickupLongitude, booking.DropoffLocation, booking.DropoffLatitude, booking.DropoffLongitude,
+		(offer.PricePerSeat * domain.Money(booking.Seats)).Float64(), booking.PassengerID, windowKind, offer.DepartureTime, windowEnd, booking.Seats).Scan(&requestID)
+	if err != nil {
+		return 0, err
+	}
+
+	proposalStatus, err := domain.ProposalStatusPending.Next(domain.ProposalEventAccept)
+	if err != nil {
+		return 0, err
+	}
+	boardingCode, err := domain.GenerateBoardingCode()
+	if err != nil {
+		return 0, err
+	}
+	_, err = p.conn.Exec(ctx,
+		`INSERT INTO proposals (request_id, driver_id, status, ride_id, boarding_code) VALUES ($1, $2, $3, $4, $5)`,
+		requestID, offer.DriverID, proposalStatus, ride.ID, boardingCode)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := ridesRepo.matchRequests(ctx, ride.ID, []int64{requestID}); err != nil {
+		return 0, err
+	}
+
+	return requestID, nil
+}
+
+// lockPassengerWindow locks the passenger's live requests and refuses a booking that would
+// overlap one of them, as creating a request would
+func (p *postgresOffersRepository) lockPassengerWindow(ctx context.Context, passengerID int64, start, end time.Time) error {
+	rows, err := p.conn.Query(ctx,
+		`SELECT id, status, window_start, window_end FROM requests
+		 WHERE passenger_id = $1 AND status IN ($2, $3, $4)
+		 ORDER BY id FOR UPDATE`,
+		passengerID, domain.RequestStatusOpen, domain.RequestStatusMatched, domain.RequestStatusPickedUp)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	for rows.Next() {
+		var existing domain.RequestDBModel
+		if err := rows.Scan(&existing.ID, &existing.Status, &existing.WindowStart, &existing.WindowEnd); err != nil {
+			return err
+		}
+		existingStart, existingEnd := existing.TravelWindow(now)
+		if existingStart.Before(end) && start.Before(existingEnd) {
+			return domain.ErrOverlappingRequest
+		}
+	}
+	return rows.Err()
+}
+
+// RecordMatchSuggestion stores a bundle suggested for an offer, reporting false if the same
+// requests were already suggested for it
+func (p *postgresOffersRepository) RecordMatchSuggestion(ctx context.Context, offerID int64, bundle *domain.MatchBundle) (bool, error) {
+	requestIDs := bundle.RequestIDs()
+	slices.Sort(requestIDs)
+
+	tag, err := p.conn.Exec(ctx,
+		`INSERT INTO match_suggestions (offer_id, request_ids, compensation, detour_duration)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (offer_id, request_ids) DO NOTHING`,
+		offerID, requestIDs, bundle.Compensation.Float64(), bundle.DetourDuration)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,300 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Arjun113/nOPark/internal/domain"
+	"github.com/jackc/pgx/v5"
+)
+
+type postgresPricingRepository struct {
+	conn Connection
+}
+
+func NewPostgresPricing(conn Connection) domain.PricingRepository {
+	return &postgresPricingRepository{conn: conn}
+}
+
+const tariffColumns = `id, version, base_fare_cents, per_km_cents, per_minute_cents, minimum_fare_cents, multipliers, timezone, effective_from, notes, created_by, created_at`
+
+// tariffAtQuery finds the tariff in force at %s
+const tariffAtQuery = `SELECT id FROM tariffs WHERE effective_from <= %s ORDER BY effective_from DESC, version DESC LIMIT 1`
+
+func scanTariff(row pgx.Row) (*domain.TariffDBModel, error) {
+	var tariff domain.TariffDBModel
+	err := row.Scan(&tariff.ID, &tariff.Version, &tariff.BaseFare, &tariff.PerKm, &tariff.PerMinute, &tariff.MinimumFare,
+		&tariff.Multipliers, &tariff.Timezone, &tariff.EffectiveFrom, &tariff.Notes, &tariff.CreatedBy, &tariff.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &tariff, nil
+}
+
+// GetTariffAt returns the tariff in force at the given time
+func (p *postgresPricingRepository) GetTariffAt(ctx context.Context, at time.Time) (*domain.TariffDBModel, error) {
+	return scanTariff(p.conn.QueryRow(ctx,
+		`SELECT `+tariffColumns+` FROM tariffs WHERE id = (`+fmt.Sprintf(tariffAtQuery, "$1")+`)`, at))
+}
+
+// GetTariffs returns every version, newest first
+func (p *postgresPricingRepository) GetTariffs(ctx context.Context) ([]*domain.TariffDBModel, error) {
+	rows, err := p.conn.Query(ctx, `SELECT `+tariffColumns+` FROM tariffs ORDER BY version DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tariffs := make([]*domain.TariffDBModel, 0)
+	for rows.Next() {
+		tariff, err := scanTariff(rows)
+		if err != nil {
+			return nil, err
+		}
+		tariffs = append(tariffs, tariff)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tariffs, nil
+}
+
+func (p *postgresPricingRepository) GetTariffByVersion(ctx context.Context, version int) (*domain.TariffDBModel, error) {
+	return scanTariff(p.conn.QueryRow(ctx, `SELECT `+tariffColumns+` FROM tariffs WHERE version = $1`, version))
+}
+
+// CreateTariff adds the next version. The table is locked so two admins cannot take the same
+// version number.
+func (p *postgresPricingRepository) CreateTariff(ctx context.Context, tariff *domain.TariffDBModel) (*domain.TariffDBModel, error) {
+	var created *domain.TariffDBModel
+
+	err := WithTx(ctx, p.conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `LOCK TABLE tariffs IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+			return err
+		}
+
+		multipliers := tariff.Multipliers
+		if multipliers == nil {
+			multipliers = []domain.TariffMultiplier{}
+		}
+
+		var err error
+		created, err = scanTariff(tx.QueryRow(ctx,
+			`INSERT INTO tariffs (version, base_fare_cents, per_km_cents, per_minute_cents, minimum_fare_cents, multipliers, timezone, effective_from, notes, created_by)
+			SELECT COALESCE(MAX(version), 0) + 1, $1, $2, $3, $4, $5, $6, $7, $8, $9 FROM tariffs
+			RETURNING `+tariffColumns,
+			tariff.BaseFare.Cents(), tariff.PerKm.Cents(), tariff.PerMinute.Cents(), tariff.MinimumFare.Cents(),
+			multipliers, tariff.Timezone, tariff.EffectiveFrom, tariff.Notes, tariff.CreatedBy))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+func (p *postgresPricingRepository) RecordFareEstimate(ctx context.Context, estimate *domain.FareEstimateDBModel) (*domain.FareEstimateDBModel, error) {
+	var recorded domain.FareEstimateDBModel
+	err := p.conn.QueryRow(ctx,
+		`INSERT INTO fare_estimates (tariff_id, account_id, distance, duration, toll_cents, total_cents)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, tariff_id, account_id, distance, duration, toll_cents, total_cents, created_at`,
+		estimate.TariffID, estimate.AccountID, estimate.Distance, estimate.Duration, estimate.Tolls.Cents(), estimate.Total.Cents()).Scan(
+		&recorded.ID, &recorded.TariffID, &recorded.AccountID, &recorded.Distance, &recorded.Duration, &recorded.Tolls, &recorded.Total, &recorded.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &recorded, nil
+}
+
+// GetTollRates returns the rate for every toll road
+func (p *postgresPricingRepository) GetTollRates(ctx context.Context) ([]*domain.TollRateDBModel, error) {
+	rows, err := p.conn.Query(ctx,
+		`SELECT id, toll_road, per_km_cents, minimum_cents, maximum_cents, updated_at FROM toll_rates ORDER BY toll_road`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := make([]*domain.TollRateDBModel, 0)
+	for rows.Next() {
+		var rate domain.TollRateDBModel
+		if err := rows.Scan(&rate.ID, &rate.TollRoad, &rate.PerKm, &rate.Minimum, &rate.Maximum, &rate.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rates = append(rates, &rate)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rates, nil
+}
+
+// recordRideTariff notes which tariff was in force when the ride was created. Run as the ride
+// completes, inside its transaction.
+func (p *postgresPricingRepository) recordRideTariff(ctx context.Context, rideID int64) error {
+	_, err := p.conn.Exec(ctx,
+		`UPDATE rides SET tariff_id = (`+fmt.Sprintf(tariffAtQuery, "rides.created_at")+`) WHERE id = $1`,
+		rideID)
+	return err
+}
+
+const fuelPriceColumns = `fuel_type, price_cents, updated_by, updated_at`
+
+func scanFuelPrice(row pgx.Row) (*domain.FuelPriceDBModel, error) {
+	var price domain.FuelPriceDBModel
+	if err := row.Scan(&price.FuelType, &price.Price, &price.UpdatedBy, &price.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &price, nil
+}
+
+func (p *postgresPricingRepository) GetFuelPrices(ctx context.Context) ([]*domain.FuelPriceDBModel, error) {
+	rows, err := p.conn.Query(ctx, `SELECT `+fuelPriceColumns+` FROM fuel_prices ORDER BY fuel_type`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := make([]*domain.FuelPriceDBModel, 0)
+	for rows.Next() {
+		price, err := scanFuelPrice(rows)
+		if err != nil {
+			return nil, err
+		}
+		prices = append(prices, price)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return prices, nil
+}
+
+func (p *postgresPricingRepository) GetFuelPrice(ctx context.Context, fuelType domain.FuelType) (*domain.FuelPriceDBModel, error) {
+	return scanFuelPrice(p.conn.QueryRow(ctx, `SELECT `+fuelPriceColumns+` FROM fuel_prices WHERE fuel_type = $1`, fuelType))
+}
+
+// UpdateFuelPrice sets the price of a fuel type, adding it if it has none yet
+func (p *postgresPricingRepository) UpdateFuelPrice(ctx context.Context, price *domain.FuelPriceDBModel) (*domain.FuelPriceDBModel, error) {
+	return scanFuelPrice(p.conn.QueryRow(ctx,
+		`INSERT INTO fuel_prices (fuel_type, price_cents, updated_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (fuel_type) DO UPDATE SET price_cents = EXCLUDED.price_cents, updated_by = EXCLUDED.updated_by
+		RETURNING `+fuelPriceColumns,
+		price.FuelType, price.Price.Cents(), price.UpdatedBy))
+}
+
+// GetCompensationAggregates returns the aggregates for pickups within reach cells of pickup's
+// area going to dropoffs within reach cells of dropoff's
+func (p *postgresPricingRepository) GetCompensationAggregates(ctx context.Context, pickup, dropoff domain.Coordinates, reach int) ([]*domain.CompensationAggregateDBModel, error) {
+	originLat, originLon := domain.CompensationCell(pickup)
+	destinationLat, destinationLon := domain.CompensationCell(dropoff)
+
+	rows, err := p.conn.Query(ctx, `
+		SELECT origin_cell_lat, origin_cell_lon, destination_cell_lat, destination_cell_lon,
+			rides, compensation_p25, compensation_p50, compensation_p75, quick_compensation_p50,
+			requests, accepted, accept_seconds_p50, refreshed_at
+		FROM compensation_aggregates
+		WHERE origin_cell_lat BETWEEN $1 - $5 AND $1 + $5
+			AND origin_cell_lon BETWEEN $2 - $5 AND $2 + $5
+			AND destination_cell_lat BETWEEN $3 - $5 AND $3 + $5
+			AND destination_cell_lon BETWEEN $4 - $5 AND $4 + $5`,
+		originLat, originLon, destinationLat, destinationLon, reach)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aggregates := make([]*domain.CompensationAggregateDBModel, 0)
+	for rows.Next() {
+		var aggregate domain.CompensationAggregateDBModel
+		if err := rows.Scan(&aggregate.OriginCellLat, &aggregate.OriginCellLon, &aggregate.DestinationCellLat, &aggregate.DestinationCellLon,
+			&aggregate.Rides, &aggregate.CompensationP25, &aggregate.CompensationP50, &aggregate.CompensationP75, &aggregate.QuickCompensationP50,
+			&aggregate.Requests, &aggregate.Accepted, &aggregate.AcceptSecondsP50, &aggregate.RefreshedAt); err != nil {
+			return nil, err
+		}
+		aggregates = append(aggregates, &aggregate)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return aggregates, nil
+}
+
+// RefreshCompensationAggregates rebuilds the aggregates from requests created since the given
+// time that have settled, by being accepted or expiring. It returns how many area pairs there are.
+func (p *postgresPricingRepository) RefreshCompensationAggregates(ctx context.Context, since time.Time) (int, error) {
+	var count int64
+
+	err := WithTx(ctx, p.conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM compensation_aggregates`); err != nil {
+			return err
+		}
+
+		tag, err := tx.Exec(ctx, `
+			WITH settled AS (
+				SELECT
+					floor(req.pickup_latitude / $1)::int AS origin_cell_lat,
+					floor(req.pickup_longitude / $1)::int AS origin_cell_lon,
+					floor(req.dropoff_latitude / $1)::int AS destination_cell_lat,
+					floor(req.dropoff_longitude / $1)::int AS destination_cell_lon,
+					req.window_kind,
+					acc.compensation,
+					acc.ride_status,
+					EXTRACT(EPOCH FROM acc.created_at - req.created_at)::double precision AS accept_seconds
+				FROM requests req
+				LEFT JOIN LATERAL (
+					SELECT COALESCE(p.compensation, req.compensation)::double precision AS compensation, p.created_at, r.status AS ride_status
+					FROM proposals p
+					JOIN rides r ON r.id = p.ride_id
+					WHERE p.request_id = req.id AND p.status = $3
+					ORDER BY p.created_at DESC
+					LIMIT 1
+				) acc ON TRUE
+				WHERE req.created_at >= $2
+					AND (acc.compensation IS NOT NULL OR req.status = $4)
+			)
+			INSERT INTO compensation_aggregates (
+				origin_cell_lat, origin_cell_lon, destination_cell_lat, destination_cell_lon,
+				rides, compensation_p25, compensation_p50, compensation_p75, quick_compensation_p50,
+				requests, accepted, accept_seconds_p50
+			)
+			SELECT
+				origin_cell_lat, origin_cell_lon, destination_cell_lat, destination_cell_lon,
+				COUNT(*) FILTER (WHERE ride_status = $5),
+				percentile_cont(0.25) WITHIN GROUP (ORDER BY compensation) FILTER (WHERE ride_status = $5),
+				percentile_cont(0.5) WITHIN GROUP (ORDER BY compensation) FILTER (WHERE ride_status = $5),
+				percentile_cont(0.75) WITHIN GROUP (ORDER BY compensation) FILTER (WHERE ride_status = $5),
+				percentile_cont(0.5) WITHIN GROUP (ORDER BY compensation)
+					FILTER (WHERE ride_status = $5 AND window_kind IS NULL AND accept_seconds <= $6),
+				COUNT(*),
+				COUNT(*) FILTER (WHERE compensation IS NOT NULL),
+				percentile_cont(0.5) WITHIN GROUP (ORDER BY accept_seconds)
+					FILTER (WHERE compensation IS NOT NULL AND window_kind IS NULL)
+			FROM settled
+			GROUP BY origin_cell_lat, origin_cell_lon, destination_cell_lat, destination_cell_lon`,
+			domain.CompensationCellDegrees, since, domain.ProposalStatusAccepted, domain.RequestStatusExpired,
+			domain.RideStatusCompleted, domain.QuickAcceptance.Seconds())
+		if err != nil {
+			return err
+		}
+		count = tag.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return int(count), nil
+}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Arjun113/nOPark/internal/domain"
+)
+
+type postgresReceiptsRepository struct {
+	conn Connection
+}
+
+func NewPostgresReceipts(conn Connection) domain.ReceiptsRepository {
+	return &postgresReceiptsRepository{conn: conn}
+}
+
+// GetReceipt returns the receipt for a passenger's request once its ride has completed
+func (p *postgresReceiptsRepository) GetReceipt(ctx context.Context, requestID int64) (*domain.ReceiptDBModel, error) {
+	query := `
+		SELECT
+			req.id, r.id, req.passenger_id, pa.firstname, pa.lastname,
+			p.driver_id, d.firstname, d.lastname,
+			v.id, v.make, v.model, v.model_year, v.colour, v.license_plate,
+			COALESCE(req.pickup_location, ''), req.pickup_latitude, req.pickup_longitude,
+			req.dropoff_location, req.dropoff_latitude, req.dropoff_longitude,
+			req.picked_up_at, req.dropped_off_at, COALESCE(charge.created_at, r.updated_at),
+			r.route_distance, r.route_duration,
+			req.compensation, COALESCE(p.compensation, req.compensation),
+			p.cost_share, p.cost_share_distance, p.cost_share_shared_distance, t.version,
+			(SELECT COUNT(*) FROM proposals op WHERE op.ride_id = r.id AND op.status = $2),
+			(SELECT COALESCE(SUM(COALESCE(op.compensation, oreq.compensation)), 0)
+				FROM proposals op
+				JOIN requests oreq ON oreq.id = op.request_id
+				WHERE op.ride_id = r.id AND op.status = $2),
+			COALESCE((SELECT lp.amount_cents
+				FROM ledger_postings lp
+				JOIN ledger_accounts la ON la.id = lp.ledger_account_id
+				WHERE lp.entry_id = charge.id AND la.account_id = p.driver_id), 0),
+			COALESCE((SELECT SUM(lp.amount_cents)
+				FROM ledger_entries refund
+				JOIN ledger_postings lp ON lp.entry_id = refund.id
+				JOIN ledger_accounts la ON la.id = lp.ledger_account_id
+				WHERE refund.refund_of = charge.id AND la.account_id = req.passenger_id), 0)
+		FROM requests req
+		JOIN proposals p ON p.request_id = req.id AND p.status = $2
+		JOIN rides r ON r.id = p.ride_id
+		JOIN accounts pa ON pa.id = req.passenger_id
+		JOIN accounts d ON d.id = p.driver_id
+		LEFT JOIN vehicles v ON v.account_id = p.driver_id
+		LEFT JOIN tariffs t ON t.id = r.tariff_id
+		LEFT JOIN ledger_entries charge ON charge.request_id = req.id AND charge.kind = $3
+		WHERE req.id = $1 AND r.status = $4`
+
+	var receipt domain.ReceiptDBModel
+	var vehicleID *int64
+	var vehicleMake, vehicleModel, vehicleColour, vehiclePlate *string
+	var vehicleYear *int
+	err := p.conn.QueryRow(ctx, query, requestID, domain.ProposalStatusAccepted, domain.LedgerEntryRideCompensation, domain.RideStatusCompleted).Scan(
+		&receipt.RequestID, &receipt.RideID, &receipt.PassengerID, &receipt.PassengerFirstName, &receipt.PassengerLastName,
+		&receipt.DriverID, &receipt.DriverFirstName, &receipt.DriverLastName,
+		&vehicleID, &vehicleMake, &vehicleModel, &vehicleYear, &vehicleColour, &vehiclePlate,
+		&receipt.PickupLocation, &receipt.PickupLatitude, &receipt.PickupLongitude,
+		&receipt.DropoffLocation, &receipt.DropoffLatitude, &receipt.DropoffLongitude,
+		&receipt.PickedUpAt, &receipt.DroppedOffAt, &receipt.CompletedAt,
+		&receipt.RouteDistance, &receipt.RouteDuration,
+		Dollars(&receipt.RequestedCompensation), Dollars(&receipt.Compensation),
+		NullDollars(&receipt.CostShare), &receipt.CostShareDistance, &receipt.CostShareSharedDistance, &receipt.TariffVersion,
+		&receipt.Passengers, Dollars(&receipt.RideCompensation),
+		&receipt.Charged, &receipt.Refunded,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if vehicleID != nil {
+		receipt.Vehicle = &domain.VehicleDBModel{
+			ID:           *vehicleID,
+			Make:         *vehicleMake,
+			Model:        *vehicleModel,
+			ModelYear:    *vehicleYear,
+			Colour:       *vehicleColour,
+			LicensePlate: *vehiclePlate,
+			AccountID:    receipt.DriverID,
+		}
+	}
+
+	return &receipt, nil
+}
+
+// GetEarningsStatement totals what a driver was credited for rides and gave back in refunds
+// between from and to, going by when the ledger recorded it
+func (p *postgresReceiptsRepository) GetEarningsStatement(ctx context.Context, driverID int64, from, to time.Time) (*domain.EarningsStatementDBModel, error) {
+	statement := domain.EarningsStatementDBModel{
+		DriverID: driverID,
+		From:     from,
+		To:       to,
+		Lines:    make([]*domain.EarningsLineDBModel, 0),
+	}
+	err := p.conn.QueryRow(ctx, `SELECT firstname, lastname FROM accounts WHERE id = $1`, driverID).Scan(
+		&statement.DriverFirstName, &statement.DriverLastName)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := p.conn.Query(ctx, `
+		SELECT e.id, e.kind, e.ride_id, e.request_id,
+			pa.firstname, pa.lastname, req.pickup_location, req.dropoff_location,
+			p.cost_share_distance, lp.amount_cents, lp.created_at
+		FROM ledger_postings lp
+		JOIN ledger_accounts la ON la.id = lp.ledger_account_id
+		JOIN ledger_entries e ON e.id = lp.entry_id
+		LEFT JOIN requests req ON req.id = e.request_id
+		LEFT JOIN accounts pa ON pa.id = req.passenger_id
+		LEFT JOIN proposals p ON p.request_id = e.request_id AND p.ride_id = e.ride_id
+		WHERE la.account_id = $1
+			AND e.kind = ANY($2)
+			AND lp.created_at >= $3 AND lp.created_at < $4
+		ORDER BY lp.id`,
+		driverID, []string{string(domain.LedgerEntryRideCompensation), string(domain.LedgerEntryRefund)}, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line domain.EarningsLineDBModel
+		if err := rows.Scan(&line.EntryID, &line.Kind, &line.RideID, &line.RequestID,
+			&line.PassengerFirstName, &line.PassengerLastName, &line.PickupLocation, &line.DropoffLocation,
+			&line.Distance, &line.Amount, &line.CreatedAt); err != nil {
+			return nil, err
+		}
+		if line.Amount > 0 {
+			statement.Gross += line.Amount
+		} else {
+			statement.Refunds -= line.Amount
+		}
+		statement.Lines = append(statement.Lines, &line)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Rides count towards the period their compensation was credited in
+	err = p.conn.QueryRow(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(r.route_distance), 0)
+		FROM rides r
+		WHERE r.id IN (
+			SELECT e.ride_id
+			FROM ledger_entries e
+			JOIN ledger_postings lp ON lp.entry_id = e.id
+			JOIN ledger_accounts la ON la.id = lp.ledger_account_id
+			WHERE la.account_id = $1 AND e.kind = $2
+				AND lp.created_at >= $3 AND lp.created_at < $4
+		)`,
+		driverID, domain.LedgerEntryRideCompensation, from, to).Scan(&statement.Rides, &statement.Distance)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.conn.QueryRow(ctx, `
+		SELECT COALESCE(SUM(amount_cents), 0)
+		FROM settlements
+		WHERE payee_id = $1 AND status = $2
+			AND created_at >= $3 AND created_at < $4`,
+		driverID, domain.SettlementStatusPending, from, to).Scan(&statement.Outstanding)
+	if err != nil {
+		return nil, err
+	}
+
+	return &statement, nil
+}
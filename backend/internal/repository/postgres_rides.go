@@ -2,9 +2,12 @@ package repository
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
+	"time"
 
 	"github.com/Arjun113/nOPark/internal/domain"
+	"github.com/jackc/pgx/v5"
 )
 
 type postgresRidesRepository struct {
@@ -17,13 +20,13 @@ func NewPostgresRides(conn Connection) domain.RidesRepository {
 
 func (p *postgresRidesRepository) CreateRideRequest(ctx context.Context, req *domain.RequestDBModel) (*domain.RequestDBModel, error) {
 	row := p.conn.QueryRow(ctx,
-		`INSERT INTO requests (pickup_location, pickup_latitude, pickup_longitude, dropoff_location, dropoff_latitude, dropoff_longitude, compensation, passenger_id, notifs_crtd) 
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) 
-		 RETURNING id, pickup_location, pickup_latitude, pickup_longitude, dropoff_location, dropoff_latitude, dropoff_longitude, compensation, passenger_id, notifs_crtd, created_at`,
-		req.PickupLocation, req.PickupLatitude, req.PickupLongitude, req.DropoffLocation, req.DropoffLatitude, req.DropoffLongitude, req.Compensation, req.PassengerID, false)
+		`INSERT INTO requests (pickup_location, pickup_latitude, pickup_longitude, dropoff_location, dropoff_latitude, dropoff_longitude, compensation, passenger_id, notifs_crtd, window_kind, window_start, window_end, requirements, preferences, party_size) 
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, COALESCE($13, '{}'::TEXT[]), COALESCE($14, '{}'::TEXT[]), $15) 
+		 RETURNING id, pickup_location, pickup_latitude, pickup_longitude, dropoff_location, dropoff_latitude, dropoff_longitude, compensation, party_size, passenger_id, status, window_kind, window_start, window_end, requirements, preferences, notifs_crtd, created_at`,
+		req.PickupLocation, req.PickupLatitude, req.PickupLongitude, req.DropoffLocation, req.DropoffLatitude, req.DropoffLongitude, req.Compensation, req.PassengerID, false, req.WindowKind, req.WindowStart, req.WindowEnd, req.Requirements, req.Preferences, req.PartySize)
 
 	var request domain.RequestDBModel
-	err := row.Scan(&request.ID, &request.PickupLocation, &request.PickupLatitude, &request.PickupLongitude, &request.DropoffLocation, &request.DropoffLatitude, &request.DropoffLongitude, &request.Compensation, &request.PassengerID, &request.AreNotificationsCreated, &request.CreatedAt)
+	err := row.Scan(&request.ID, &request.PickupLocation, &request.PickupLatitude, &request.PickupLongitude, &request.DropoffLocation, &request.DropoffLatitude, &request.DropoffLongitude, &request.Compensation, &request.PartySize, &request.PassengerID, &request.Status, &request.WindowKind, &request.WindowStart, &request.WindowEnd, &request.Requirements, &request.Preferences, &request.AreNotificationsCreated, &request.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -31,12 +34,12 @@ func (p *postgresRidesRepository) CreateRideRequest(ctx context.Context, req *do
 	return &request, nil
 }
 
-func (p *postgresRidesRepository) GetActiveRideRequests(ctx context.Context, ids *[]string, ub_compensation *float64, passenger_id *int64) ([]*domain.RequestDBModel, error) {
-	query := `SELECT id, pickup_location, pickup_latitude, pickup_longitude, dropoff_location, dropoff_latitude, dropoff_longitude, compensation, passenger_id, notifs_crtd, created_at 
+func (p *postgresRidesRepository) GetActiveRideRequests(ctx context.Context, ids *[]string, ub_compensation *float64, passenger_id *int64, depart_at *time.Time) ([]*domain.RequestDBModel, error) {
+	query := `SELECT id, pickup_location, pickup_latitude, pickup_longitude, dropoff_location, dropoff_latitude, dropoff_longitude, compensation, party_size, passenger_id, status, window_kind, window_start, window_end, requirements, preferences, notifs_crtd, created_at 
 			  FROM requests 
-			  WHERE ride_id IS NULL`
-	args := []any{}
-	argIdx := 1
+			  WHERE status = $1`
+	args := []any{domain.RequestStatusOpen}
+	argIdx := 2
 
 	if ids != nil && len(*ids) > 0 {
 		query += fmt.Sprintf(` AND id = ANY($%d)`, argIdx)
@@ -53,6 +56,17 @@ func (p *postgresRidesRepository) GetActiveRideRequests(ctx context.Context, ids
 		args = append(args, *passenger_id)
 		argIdx++
 	}
+	if depart_at != nil {
+		// Arrival windows are only narrowed to those still reachable here; the caller checks
+		// the estimated arrival once it knows the route duration
+		query += fmt.Sprintf(` AND (
+			(window_kind IS NULL AND $%[1]d <= CURRENT_TIMESTAMP + make_interval(secs => $%[2]d))
+			OR (window_kind = $%[3]d AND $%[1]d BETWEEN window_start AND window_end)
+			OR (window_kind = $%[4]d AND $%[1]d < window_end)
+		)`, argIdx, argIdx+1, argIdx+2, argIdx+3)
+		args = append(args, *depart_at, domain.ImmediateDepartureWindow.Seconds(), domain.WindowKindDeparture, domain.WindowKindArrival)
+		argIdx += 4
+	}
 
 	rows, err := p.conn.Query(ctx, query, args...)
 	if err != nil {
@@ -63,7 +77,7 @@ func (p *postgresRidesRepository) GetActiveRideRequests(ctx context.Context, ids
 	var requests []*domain.RequestDBModel
 	for rows.Next() {
 		var req domain.RequestDBModel
-		if err := rows.Scan(&req.ID, &req.PickupLocation, &req.PickupLatitude, &req.PickupLongitude, &req.DropoffLocation, &req.DropoffLatitude, &req.DropoffLongitude, &req.Compensation, &req.PassengerID, &req.AreNotificationsCreated, &req.CreatedAt); err != nil {
+		if err := rows.Scan(&req.ID, &req.PickupLocation, &req.PickupLatitude, &req.PickupLongitude, &req.DropoffLocation, &req.DropoffLatitude, &req.DropoffLongitude, &req.Compensation, &req.PartySize, &req.PassengerID, &req.Status, &req.WindowKind, &req.WindowStart, &req.WindowEnd, &req.Requirements, &req.Preferences, &req.AreNotificationsCreated, &req.CreatedAt); err != nil {
 			return nil, err
 		}
 		requests = append(requests, &req)
@@ -76,33 +90,77 @@ func (p *postgresRidesRepository) GetActiveRideRequests(ctx context.Context, ids
 	return requests, nil
 }
 
-func (p *postgresRidesRepository) CreateRideAndProposals(ctx context.Context, proposals []*domain.ProposalDBModel, destLat, destLon float64) (*domain.RideDBModel, []*domain.ProposalDBModel, error) {
-	row := p.conn.QueryRow(ctx,
-		`INSERT INTO rides (destination_latitude, destination_longitude) 
-		 VALUES ($1, $2) 
-		 RETURNING id, status, destination_latitude, destination_longitude, created_at, updated_at`,
-		destLat, destLon)
-
+func (p *postgresRidesRepository) CreateRideAndProposals(ctx context.Context, proposals []*domain.ProposalDBModel, destLat, destLon float64, seats int) (*domain.RideDBModel, []*domain.ProposalDBModel, error) {
 	var ride domain.RideDBModel
-	err := row.Scan(&ride.ID, &ride.Status, &ride.DestinationLatitude, &ride.DestinationLongitude, &ride.CreatedAt, &ride.UpdatedAt)
-	if err != nil {
-		return nil, nil, err
-	}
-	query := `INSERT INTO proposals (request_id, driver_id, status, ride_id)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, request_id, status, driver_id, ride_id, created_at, updated_at`
-
 	var createdProposals []*domain.ProposalDBModel
-	for _, proposal := range proposals {
-		var createdProposal domain.ProposalDBModel
-		row := p.conn.QueryRow(ctx, query,
-			proposal.RequestID, proposal.DriverID, proposal.Status, ride.ID)
 
-		err := row.Scan(&createdProposal.ID, &createdProposal.RequestID, &createdProposal.Status, &createdProposal.DriverID, &createdProposal.RideID, &createdProposal.CreatedAt, &createdProposal.UpdatedAt)
+	err := WithTx(ctx, p.conn, func(tx pgx.Tx) error {
+		// Lock the requests so they cannot be matched elsewhere while we draft. They are locked
+		// in id order, like matchRequests, so drafts listing the same requests cannot deadlock.
+		requestIDs := make([]int64, len(proposals))
+		for i, proposal := range proposals {
+			requestIDs[i] = proposal.RequestID
+		}
+		rows, err := tx.Query(ctx,
+			`SELECT id, status FROM requests WHERE id = ANY($1) ORDER BY id FOR UPDATE`,
+			requestIDs)
 		if err != nil {
-			return nil, nil, err
+			return err
+		}
+		locked := make(map[int64]domain.RequestStatus, len(requestIDs))
+		for rows.Next() {
+			var id int64
+			var status domain.RequestStatus
+			if err := rows.Scan(&id, &status); err != nil {
+				rows.Close()
+				return err
+			}
+			locked[id] = status
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, id := range requestIDs {
+			status, ok := locked[id]
+			if !ok {
+				return pgx.ErrNoRows
+			}
+			if _, err := status.Next(domain.RequestEventMatch); err != nil {
+				return err
+			}
+		}
+
+		row := tx.QueryRow(ctx,
+			`INSERT INTO rides (destination_latitude, destination_longitude, seats) 
+			 VALUES ($1, $2, $3) 
+			 RETURNING id, status, destination_latitude, destination_longitude, seats, created_at, updated_at`,
+			destLat, destLon, seats)
+
+		err = row.Scan(&ride.ID, &ride.Status, &ride.DestinationLatitude, &ride.DestinationLongitude, &ride.Seats, &ride.CreatedAt, &ride.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		query := `INSERT INTO proposals (request_id, driver_id, status, ride_id)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, request_id, status, driver_id, ride_id, created_at, updated_at`
+
+		for _, proposal := range proposals {
+			var createdProposal domain.ProposalDBModel
+			row := tx.QueryRow(ctx, query,
+				proposal.RequestID, proposal.DriverID, proposal.Status, ride.ID)
+
+			err := row.Scan(&createdProposal.ID, &createdProposal.RequestID, &createdProposal.Status, &createdProposal.DriverID, &createdProposal.RideID, &createdProposal.CreatedAt, &createdProposal.UpdatedAt)
+			if err != nil {
+				return err
+			}
+			createdProposals = append(createdProposals, &createdProposal)
 		}
-		createdProposals = append(createdProposals, &createdProposal)
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
 	return &ride, createdProposals, nil
@@ -114,17 +172,17 @@ func (p *postgresRidesRepository) GetRideAndProposals(ctx context.Context, rideI
 
 	// Get the ride details
 	row := p.conn.QueryRow(ctx,
-		`SELECT id, status, destination_latitude, destination_longitude, created_at, updated_at FROM rides WHERE id = $1`,
+		`SELECT id, status, destination_latitude, destination_longitude, seats, created_at, updated_at FROM rides WHERE id = $1`,
 		rideID)
 
-	err := row.Scan(&ride.ID, &ride.Status, &ride.DestinationLatitude, &ride.DestinationLongitude, &ride.CreatedAt, &ride.UpdatedAt)
+	err := row.Scan(&ride.ID, &ride.Status, &ride.DestinationLatitude, &ride.DestinationLongitude, &ride.Seats, &ride.CreatedAt, &ride.UpdatedAt)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	// Get the proposals for the ride
 	rows, err := p.conn.Query(ctx,
-		`SELECT id, request_id, status, driver_id, ride_id, created_at, updated_at FROM proposals WHERE ride_id = $1`,
+		`SELECT id, request_id, status, driver_id, ride_id, compensation, boarding_code, boarding_attempts, boarded_at, cost_share, cost_share_distance, cost_share_shared_distance, created_at, updated_at FROM proposals WHERE ride_id = $1`,
 		rideID)
 	if err != nil {
 		return nil, nil, err
@@ -133,7 +191,7 @@ func (p *postgresRidesRepository) GetRideAndProposals(ctx context.Context, rideI
 
 	for rows.Next() {
 		var proposal domain.ProposalDBModel
-		if err := rows.Scan(&proposal.ID, &proposal.RequestID, &proposal.Status, &proposal.DriverID, &proposal.RideID, &proposal.CreatedAt, &proposal.UpdatedAt); err != nil {
+		if err := rows.Scan(&proposal.ID, &proposal.RequestID, &proposal.Status, &proposal.DriverID, &proposal.RideID, NullDollars(&proposal.Compensation), &proposal.BoardingCode, &proposal.BoardingAttempts, &proposal.BoardedAt, NullDollars(&proposal.CostShare), &proposal.CostShareDistance, &proposal.CostShareSharedDistance, &proposal.CreatedAt, &proposal.UpdatedAt); err != nil {
 			return nil, nil, err
 		}
 		proposals = append(proposals, &proposal)
@@ -146,87 +204,287 @@ func (p *postgresRidesRepository) GetRideAndProposals(ctx context.Context, rideI
 	return &ride, proposals, nil
 }
 
-func (p *postgresRidesRepository) ConfirmRideProposal(ctx context.Context, proposal *domain.ProposalDBModel, confirm string) (*domain.ProposalDBModel, error) {
-	var status string
-	switch confirm {
-	case "accept":
-		status = "accepted"
-	case "reject":
-		status = "rejected"
-	default:
-		return nil, fmt.Errorf("invalid confirmation value: %s", confirm)
-	}
+func (p *postgresRidesRepository) ConfirmRideProposal(ctx context.Context, proposal *domain.ProposalDBModel, event domain.ProposalEvent) (*domain.ProposalDBModel, error) {
+	var updatedProposal domain.ProposalDBModel
 
-	row := p.conn.QueryRow(ctx,
-		`UPDATE proposals SET status = $1 WHERE id = $2 RETURNING id, request_id, status, driver_id, ride_id, created_at, updated_at`,
-		status, proposal.ID)
+	err := WithTx(ctx, p.conn, func(tx pgx.Tx) error {
+		txRepo := &postgresRidesRepository{conn: tx}
 
-	var updatedProposal domain.ProposalDBModel
-	err := row.Scan(&updatedProposal.ID, &updatedProposal.RequestID, &updatedProposal.Status, &updatedProposal.DriverID, &updatedProposal.RideID, &updatedProposal.CreatedAt, &updatedProposal.UpdatedAt)
+		// Lock the ride first so concurrent confirmations for the same ride are serialised
+		// and only one of them observes the last pending proposal.
+		ride, err := txRepo.getRideForUpdate(ctx, proposal.RideID)
+		if err != nil {
+			return err
+		}
+
+		var current domain.ProposalStatus
+		err = tx.QueryRow(ctx,
+			`SELECT status FROM proposals WHERE id = $1 FOR UPDATE`,
+			proposal.ID).Scan(&current)
+		if err != nil {
+			return err
+		}
+		status, err := current.Next(event)
+		if err != nil {
+			return err
+		}
+
+		// The price has to be settled before the passenger can accept. Rejecting the proposal
+		// turns down whatever was still on the table.
+		switch status {
+		case domain.ProposalStatusAccepted:
+			var negotiating bool
+			err = tx.QueryRow(ctx,
+				`SELECT EXISTS (SELECT 1 FROM counter_offers WHERE proposal_id = $1 AND status = $2)`,
+				proposal.ID, domain.CounterOfferStatusPending).Scan(&negotiating)
+			if err != nil {
+				return err
+			}
+			if negotiating {
+				return domain.ErrCounterOfferPending
+			}
+
+			// Everyone already accepted, plus this party, has to fit in the car
+			var seatsNeeded int
+			err = tx.QueryRow(ctx,
+				`SELECT COALESCE(SUM(req.party_size), 0)
+				FROM proposals p
+				JOIN requests req ON req.id = p.request_id
+				WHERE p.ride_id = $1 AND (p.status = $2 OR p.id = $3) AND req.dropped_off_at IS NULL`,
+				ride.ID, domain.ProposalStatusAccepted, proposal.ID).Scan(&seatsNeeded)
+			if err != nil {
+				return err
+			}
+			if seatsNeeded > ride.Capacity() {
+				return domain.ErrNotEnoughSeats
+			}
+		case domain.ProposalStatusRejected:
+			_, err = tx.Exec(ctx,
+				`UPDATE counter_offers SET status = $1, answered_at = CURRENT_TIMESTAMP
+				WHERE proposal_id = $2 AND status = $3`,
+				domain.CounterOfferStatusRejected, proposal.ID, domain.CounterOfferStatusPending)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Accepted passengers get the code they show the driver at pickup
+		var boardingCode *string
+		if status == domain.ProposalStatusAccepted {
+			code, err := domain.GenerateBoardingCode()
+			if err != nil {
+				return err
+			}
+			boardingCode = &code
+		}
+
+		row := tx.QueryRow(ctx,
+			`UPDATE proposals SET status = $1, boarding_code = COALESCE($3, boarding_code) WHERE id = $2
+			RETURNING id, request_id, status, driver_id, ride_id, compensation, boarding_code, boarding_attempts, boarded_at, cost_share, cost_share_distance, cost_share_shared_distance, created_at, updated_at`,
+			status, proposal.ID, boardingCode)
+
+		err = row.Scan(&updatedProposal.ID, &updatedProposal.RequestID, &updatedProposal.Status, &updatedProposal.DriverID, &updatedProposal.RideID, NullDollars(&updatedProposal.Compensation), &updatedProposal.BoardingCode, &updatedProposal.BoardingAttempts, &updatedProposal.BoardedAt, NullDollars(&updatedProposal.CostShare), &updatedProposal.CostShareDistance, &updatedProposal.CostShareSharedDistance, &updatedProposal.CreatedAt, &updatedProposal.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		return txRepo.settleRide(ctx, ride, nil)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	row = p.conn.QueryRow(ctx,
-		`SELECT COALESCE(count(id),0) FROM proposals WHERE ride_id = $1 AND status = 'pending'`,
-		proposal.RideID)
+	return &updatedProposal, nil
+}
+
+// settleRide moves a locked ride on once its proposals no longer need an answer. An awaiting
+// ride is confirmed or rejected when nothing is pending, and an in-progress ride is cancelled
+// once it has lost every accepted passenger.
+func (p *postgresRidesRepository) settleRide(ctx context.Context, ride *domain.RideDBModel, cancellation *domain.Cancellation) error {
+	row := p.conn.QueryRow(ctx,
+		`SELECT COUNT(id) FILTER (WHERE status = $2),
+			COALESCE(ARRAY_AGG(request_id) FILTER (WHERE status = $3), '{}')
+		FROM proposals
+		WHERE ride_id = $1`,
+		ride.ID, domain.ProposalStatusPending, domain.ProposalStatusAccepted,
+	)
 
 	var pendingCount int64
-	err = row.Scan(&pendingCount)
-	if err != nil {
-		return nil, err
+	var acceptedReqIDs []int64
+	if err := row.Scan(&pendingCount, &acceptedReqIDs); err != nil {
+		return err
 	}
 
-	// Update ride status if all proposals responded.
-	if pendingCount == 0 {
-		row := p.conn.QueryRow(ctx,
-			`SELECT COUNT(id), ARRAY_AGG(request_id)
-			FROM proposals
-			WHERE ride_id = $1 AND status = 'accepted'`,
-			proposal.RideID,
-		)
-
-		var acceptedCount int64
-		var AcceptedReqIDs []int64
-		err = row.Scan(&acceptedCount, &AcceptedReqIDs)
-		if err != nil {
-			return nil, err
+	switch ride.Status {
+	case domain.RideStatusAwaitingConfirmation:
+		// Update ride status if all proposals responded.
+		if pendingCount > 0 {
+			return nil
 		}
 
-		var rideStatus string
-		if acceptedCount == 0 {
-			rideStatus = "rejected"
-		} else {
-			rideStatus = "in_progress"
+		rideEvent := domain.RideEventConfirm
+		if len(acceptedReqIDs) == 0 {
+			rideEvent = domain.RideEventReject
+		}
+		if err := ride.Apply(rideEvent); err != nil {
+			return err
+		}
 
+		if rideEvent == domain.RideEventConfirm {
 			// Update requests to be undiscoverable
-			_, err = p.conn.Exec(ctx,
-				`UPDATE requests SET ride_id = $1 WHERE id = ANY($2)`,
-				proposal.RideID, AcceptedReqIDs)
-			if err != nil {
-				return nil, err
+			if err := p.matchRequests(ctx, ride.ID, acceptedReqIDs); err != nil {
+				return err
 			}
 		}
 
-		// Update ride status
-		_, err = p.conn.Exec(ctx,
+		_, err := p.conn.Exec(ctx,
 			`UPDATE rides SET status = $1 WHERE id = $2`,
-			rideStatus, proposal.RideID)
-		if err != nil {
+			ride.Status, ride.ID)
+		return err
+	case domain.RideStatusInProgress:
+		if len(acceptedReqIDs) > 0 || cancellation == nil {
+			return nil
+		}
+		if err := ride.Apply(domain.RideEventCancel); err != nil {
+			return err
+		}
+		return p.markCancelled(ctx, "rides", ride.ID, ride.Status, *cancellation)
+	}
+
+	return nil
+}
+
+// markCancelled stores the new status alongside who cancelled and why. table is never user input.
+func (p *postgresRidesRepository) markCancelled(ctx context.Context, table string, id int64, status any, cancellation domain.Cancellation) error {
+	_, err := p.conn.Exec(ctx,
+		fmt.Sprintf(`UPDATE %s
+		SET status = $1, cancelled_by = $2, cancellation_reason = $3, cancelled_at = CURRENT_TIMESTAMP
+		WHERE id = $4`, table),
+		status, cancellation.CancelledBy, NullString(cancellation.Reason), id)
+	return err
+}
+
+// releaseRequest puts a matched request back into the pool so other drivers can pick it up
+func (p *postgresRidesRepository) releaseRequest(ctx context.Context, requestID int64) error {
+	var current domain.RequestStatus
+	err := p.conn.QueryRow(ctx,
+		`SELECT status FROM requests WHERE id = $1 FOR UPDATE`,
+		requestID).Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	status, err := current.Next(domain.RequestEventRelease)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.conn.Exec(ctx,
+		`UPDATE requests SET status = $1, ride_id = NULL WHERE id = $2`,
+		status, requestID)
+	return err
+}
+
+// cancelRequest row-locks a request and marks it cancelled
+func (p *postgresRidesRepository) cancelRequest(ctx context.Context, requestID int64, cancellation domain.Cancellation) error {
+	var current domain.RequestStatus
+	err := p.conn.QueryRow(ctx,
+		`SELECT status FROM requests WHERE id = $1 FOR UPDATE`,
+		requestID).Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	status, err := current.Next(domain.RequestEventCancel)
+	if err != nil {
+		return err
+	}
+
+	return p.markCancelled(ctx, "requests", requestID, status, cancellation)
+}
+
+// lockLiveProposals row-locks the pending and accepted proposals matching the given column
+func (p *postgresRidesRepository) lockLiveProposals(ctx context.Context, column string, id int64) ([]*domain.ProposalDBModel, error) {
+	rows, err := p.conn.Query(ctx,
+		fmt.Sprintf(`SELECT id, request_id, status, driver_id, ride_id, created_at, updated_at
+		FROM proposals
+		WHERE %s = $1 AND status IN ($2, $3)
+		ORDER BY id
+		FOR UPDATE`, column),
+		id, domain.ProposalStatusPending, domain.ProposalStatusAccepted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	proposals := make([]*domain.ProposalDBModel, 0)
+	for rows.Next() {
+		var proposal domain.ProposalDBModel
+		if err := rows.Scan(&proposal.ID, &proposal.RequestID, &proposal.Status, &proposal.DriverID, &proposal.RideID, &proposal.CreatedAt, &proposal.UpdatedAt); err != nil {
 			return nil, err
 		}
+		proposals = append(proposals, &proposal)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return &updatedProposal, nil
+	return proposals, nil
+}
+
+// getRideForUpdate reads and row-locks a ride. Only meaningful inside a transaction.
+func (p *postgresRidesRepository) getRideForUpdate(ctx context.Context, rideID int64) (*domain.RideDBModel, error) {
+	row := p.conn.QueryRow(ctx,
+		`SELECT id, status, destination_latitude, destination_longitude, seats, created_at, updated_at FROM rides WHERE id = $1 FOR UPDATE`,
+		rideID)
+
+	var ride domain.RideDBModel
+	err := row.Scan(&ride.ID, &ride.Status, &ride.DestinationLatitude, &ride.DestinationLongitude, &ride.Seats, &ride.CreatedAt, &ride.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ride, nil
+}
+
+// matchRequests attaches open requests to a ride, failing if any of them has already been matched
+func (p *postgresRidesRepository) matchRequests(ctx context.Context, rideID int64, requestIDs []int64) error {
+	rows, err := p.conn.Query(ctx,
+		`SELECT id, status FROM requests WHERE id = ANY($1) ORDER BY id FOR UPDATE`,
+		requestIDs)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var status domain.RequestStatus
+	for rows.Next() {
+		var id int64
+		var current domain.RequestStatus
+		if err := rows.Scan(&id, &current); err != nil {
+			return err
+		}
+		if status, err = current.Next(domain.RequestEventMatch); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = p.conn.Exec(ctx,
+		`UPDATE requests SET ride_id = $1, status = $2 WHERE id = ANY($3)`,
+		rideID, status, requestIDs)
+	return err
 }
 
 func (p *postgresRidesRepository) GetRideByID(ctx context.Context, rideID int64) (*domain.RideDBModel, error) {
 	row := p.conn.QueryRow(ctx,
-		`SELECT id, status, destination_latitude, destination_longitude, created_at, updated_at FROM rides WHERE id = $1`,
+		`SELECT id, status, destination_latitude, destination_longitude, seats, created_at, updated_at FROM rides WHERE id = $1`,
 		rideID)
 
 	var ride domain.RideDBModel
-	err := row.Scan(&ride.ID, &ride.Status, &ride.DestinationLatitude, &ride.DestinationLongitude, &ride.CreatedAt, &ride.UpdatedAt)
+	err := row.Scan(&ride.ID, &ride.Status, &ride.DestinationLatitude, &ride.DestinationLongitude, &ride.Seats, &ride.CreatedAt, &ride.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -236,11 +494,11 @@ func (p *postgresRidesRepository) GetRideByID(ctx context.Context, rideID int64)
 
 func (p *postgresRidesRepository) GetRideByRequestID(ctx context.Context, requestID int64) ([]*domain.RideDBModel, error) {
 	rows, err := p.conn.Query(ctx,
-		`SELECT r.id, r.status, r.destination_latitude, r.destination_longitude, r.created_at, r.updated_at
+		`SELECT r.id, r.status, r.destination_latitude, r.destination_longitude, r.seats, r.created_at, r.updated_at
 		 FROM rides r
 		 JOIN proposals p ON r.id = p.ride_id
-		 WHERE p.request_id = $1 AND p.status != 'rejected'`,
-		requestID)
+		 WHERE p.request_id = $1 AND p.status IN ($2, $3)`,
+		requestID, domain.ProposalStatusPending, domain.ProposalStatusAccepted)
 	if err != nil {
 		return nil, err
 	}
@@ -249,7 +507,7 @@ func (p *postgresRidesRepository) GetRideByRequestID(ctx context.Context, reques
 	rides := make([]*domain.RideDBModel, 0)
 	for rows.Next() {
 		var ride domain.RideDBModel
-		if err := rows.Scan(&ride.ID, &ride.Status, &ride.DestinationLatitude, &ride.DestinationLongitude, &ride.CreatedAt, &ride.UpdatedAt); err != nil {
+		if err := rows.Scan(&ride.ID, &ride.Status, &ride.DestinationLatitude, &ride.DestinationLongitude, &ride.Seats, &ride.CreatedAt, &ride.UpdatedAt); err != nil {
 			return nil, err
 		}
 		rides = append(rides, &ride)
@@ -263,11 +521,11 @@ func (p *postgresRidesRepository) GetRideByRequestID(ctx context.Context, reques
 
 func (p *postgresRidesRepository) GetProposalByID(ctx context.Context, proposalID int64) (*domain.ProposalDBModel, error) {
 	row := p.conn.QueryRow(ctx,
-		`SELECT id, request_id, status, driver_id, ride_id, created_at, updated_at FROM proposals WHERE id = $1`,
+		`SELECT id, request_id, status, driver_id, ride_id, compensation, boarding_code, boarding_attempts, boarded_at, cost_share, cost_share_distance, cost_share_shared_distance, created_at, updated_at FROM proposals WHERE id = $1`,
 		proposalID)
 
 	var proposal domain.ProposalDBModel
-	err := row.Scan(&proposal.ID, &proposal.RequestID, &proposal.Status, &proposal.DriverID, &proposal.RideID, &proposal.CreatedAt, &proposal.UpdatedAt)
+	err := row.Scan(&proposal.ID, &proposal.RequestID, &proposal.Status, &proposal.DriverID, &proposal.RideID, NullDollars(&proposal.Compensation), &proposal.BoardingCode, &proposal.BoardingAttempts, &proposal.BoardedAt, NullDollars(&proposal.CostShare), &proposal.CostShareDistance, &proposal.CostShareSharedDistance, &proposal.CreatedAt, &proposal.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -277,11 +535,11 @@ func (p *postgresRidesRepository) GetProposalByID(ctx context.Context, proposalI
 
 func (p *postgresRidesRepository) GetRequestByID(ctx context.Context, requestID int64) (*domain.RequestDBModel, error) {
 	row := p.conn.QueryRow(ctx,
-		`SELECT id, pickup_location, pickup_latitude, pickup_longitude, dropoff_location, dropoff_latitude, dropoff_longitude, compensation, passenger_id, ride_id, visited, created_at FROM requests WHERE id = $1`,
+		`SELECT id, pickup_location, pickup_latitude, pickup_longitude, dropoff_location, dropoff_latitude, dropoff_longitude, compensation, party_size, passenger_id, ride_id, status, window_kind, window_start, window_end, requirements, preferences, picked_up_at, dropped_off_at, created_at FROM requests WHERE id = $1`,
 		requestID)
 
 	var request domain.RequestDBModel
-	err := row.Scan(&request.ID, &request.PickupLocation, &request.PickupLatitude, &request.PickupLongitude, &request.DropoffLocation, &request.DropoffLatitude, &request.DropoffLongitude, &request.Compensation, &request.PassengerID, &request.RideID, &request.Visited, &request.CreatedAt)
+	err := row.Scan(&request.ID, &request.PickupLocation, &request.PickupLatitude, &request.PickupLongitude, &request.DropoffLocation, &request.DropoffLatitude, &request.DropoffLongitude, &request.Compensation, &request.PartySize, &request.PassengerID, &request.RideID, &request.Status, &request.WindowKind, &request.WindowStart, &request.WindowEnd, &request.Requirements, &request.Preferences, &request.PickedUpAt, &request.DroppedOffAt, &request.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -289,137 +547,1077 @@ func (p *postgresRidesRepository) GetRequestByID(ctx context.Context, requestID
 	return &request, nil
 }
 
-func (p *postgresRidesRepository) CompleteRide(ctx context.Context, rideID int64) error {
-	_, err := p.conn.Exec(ctx,
-		`UPDATE rides SET status = 'completed' WHERE id = $1 AND status = 'in_progress'`,
-		rideID)
-	return err
-}
+// CompleteRide finishes the ride and drops off everyone still in the car, returning those
+// passengers' requests. Passengers dropped off earlier are not returned. Accepted passengers who
+// were never picked up have their requests and proposals cancelled, and are returned separately.
+// Everyone who rode is charged on the ledger, and the ride records the tariff it was priced
+// under. route is the ride routed through its stops beforehand for the cost split, see
+// settleCostShares.
+func (p *postgresRidesRepository) CompleteRide(ctx context.Context, rideID int64, route *domain.CostShareRoute) ([]*domain.RequestDBModel, []*domain.RequestDBModel, error) {
+	var droppedOff, noShows []*domain.RequestDBModel
 
-func (p *postgresRidesRepository) GetPreviousRides(ctx context.Context, accountID int64, accountType string, limit int, offset int) ([]*domain.RideDBModel, error) {
-	query := `
-        SELECT r.id, r.status, r.destination_latitude, r.destination_longitude, r.created_at, r.updated_at
-        FROM rides r
-        JOIN proposals p ON r.id = p.ride_id
-        JOIN requests req ON p.request_id = req.id
-        WHERE 
-            (
-                ($1 = 'passenger' AND req.passenger_id = $2)
-                OR
-                ($1 = 'driver' AND p.driver_id = $2)
-            )
-            AND r.status IN ('completed')
-			AND p.status IN ('accepted')
-        ORDER BY r.created_at DESC
-        LIMIT $3 OFFSET $4`
-	rows, err := p.conn.Query(ctx, query, accountType, accountID, limit, offset)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+	err := WithTx(ctx, p.conn, func(tx pgx.Tx) error {
+		txRepo := &postgresRidesRepository{conn: tx}
+		ride, err := txRepo.getRideForUpdate(ctx, rideID)
+		if err != nil {
+			return err
+		}
 
-	var rides []*domain.RideDBModel
-	for rows.Next() {
-		var ride domain.RideDBModel
-		if err := rows.Scan(&ride.ID, &ride.Status, &ride.DestinationLatitude, &ride.DestinationLongitude, &ride.CreatedAt, &ride.UpdatedAt); err != nil {
-			return nil, err
+		if err := ride.Apply(domain.RideEventComplete); err != nil {
+			return err
 		}
-		rides = append(rides, &ride)
-	}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
+		_, err = tx.Exec(ctx,
+			`UPDATE rides SET status = $1 WHERE id = $2`,
+			ride.Status, rideID)
+		if err != nil {
+			return err
+		}
+
+		droppedOff, err = txRepo.dropOffRemaining(ctx, rideID)
+		if err != nil {
+			return err
+		}
+		noShows, err = txRepo.cancelNoShows(ctx, rideID)
+		if err != nil {
+			return err
+		}
+
+		if err := (&postgresPricingRepository{conn: tx}).recordRideTariff(ctx, rideID); err != nil {
+			return err
+		}
+
+		if err := txRepo.settleCostShares(ctx, ride, route); err != nil {
+			return err
+		}
+		return (&postgresLedgerRepository{conn: tx}).postRideCompensation(ctx, rideID)
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return rides, nil
+	return droppedOff, noShows, nil
 }
 
-func (p *postgresRidesRepository) GetInProgressRidesWithLocations(ctx context.Context) ([]*domain.RideWithLocationsDBModel, error) {
-	query := `
-		SELECT 
-			r.id as ride_id,
-			p.driver_id,
-			driver_acc.current_latitude as driver_latitude,
-			driver_acc.current_longitude as driver_longitude,
-			req.passenger_id,
-			passenger_acc.current_latitude as passenger_latitude,
-			passenger_acc.current_longitude as passenger_longitude,
-			req.pickup_latitude,
-			req.pickup_longitude
-		FROM rides r
-		JOIN proposals p ON p.ride_id = r.id AND p.status = 'accepted'
-		JOIN requests req ON req.id = p.request_id
-		JOIN accounts driver_acc ON driver_acc.id = p.driver_id
-		JOIN accounts passenger_acc ON passenger_acc.id = req.passenger_id
-		WHERE r.status = 'in_progress'`
+// dropOffRemaining drops off every passenger still in the car on a ride
+func (p *postgresRidesRepository) dropOffRemaining(ctx context.Context, rideID int64) ([]*domain.RequestDBModel, error) {
+	status, err := domain.RequestStatusPickedUp.Next(domain.RequestEventDropoff)
+	if err != nil {
+		return nil, err
+	}
 
-	rows, err := p.conn.Query(ctx, query)
+	rows, err := p.conn.Query(ctx,
+		`UPDATE requests
+		SET status = $1, dropped_off_at = CURRENT_TIMESTAMP
+		WHERE status = $2
+			AND id IN (SELECT request_id FROM proposals WHERE ride_id = $3 AND status = $4)
+		RETURNING id, passenger_id, status, picked_up_at, dropped_off_at`,
+		status, domain.RequestStatusPickedUp, rideID, domain.ProposalStatusAccepted)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var results []*domain.RideWithLocationsDBModel
+	requests := make([]*domain.RequestDBModel, 0)
 	for rows.Next() {
-		var r domain.RideWithLocationsDBModel
-		if err := rows.Scan(
-			&r.RideID,
-			&r.DriverID,
-			&r.DriverLatitude,
-			&r.DriverLongitude,
-			&r.PassengerID,
-			&r.PassengerLatitude,
-			&r.PassengerLongitude,
-			&r.PickupLatitude,
-			&r.PickupLongitude,
-		); err != nil {
+		var req domain.RequestDBModel
+		if err := rows.Scan(&req.ID, &req.PassengerID, &req.Status, &req.PickedUpAt, &req.DroppedOffAt); err != nil {
 			return nil, err
 		}
-		results = append(results, &r)
+		requests = append(requests, &req)
 	}
-
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return results, nil
+	return requests, nil
 }
 
-func (p *postgresRidesRepository) GetUnvisitedRequestsByRideID(ctx context.Context, rideID int64) ([]*domain.RequestDBModel, error) {
-	query := `
-		SELECT req.id, req.pickup_location, req.pickup_latitude, req.pickup_longitude, req.dropoff_location, req.dropoff_latitude, req.dropoff_longitude, req.compensation, req.passenger_id, req.ride_id, req.notifs_crtd, req.created_at
-		FROM requests req
-		JOIN proposals p ON p.request_id = req.id
-		WHERE p.ride_id = $1 AND p.status = 'accepted' AND req.visited = FALSE 
-	`
-	rows, err := p.conn.Query(ctx, query, rideID)
+// cancelNoShows cancels the requests and proposals of accepted passengers on a completing ride
+// who were never picked up, so nothing is left waiting on a pickup that will not happen
+func (p *postgresRidesRepository) cancelNoShows(ctx context.Context, rideID int64) ([]*domain.RequestDBModel, error) {
+	requestStatus, err := domain.RequestStatusMatched.Next(domain.RequestEventCancel)
+	if err != nil {
+		return nil, err
+	}
+	proposalStatus, err := domain.ProposalStatusAccepted.Next(domain.ProposalEventCancel)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := p.conn.Query(ctx,
+		`UPDATE requests
+		SET status = $1, cancelled_by = p.driver_id, cancellation_reason = $2, cancelled_at = CURRENT_TIMESTAMP
+		FROM proposals p
+		WHERE p.request_id = requests.id AND p.ride_id = $3 AND p.status = $4 AND requests.status = $5
+		RETURNING requests.id, requests.passenger_id, requests.status`,
+		requestStatus, domain.NoShowReason, rideID, domain.ProposalStatusAccepted, domain.RequestStatusMatched)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var requests []*domain.RequestDBModel
+	requests := make([]*domain.RequestDBModel, 0)
+	requestIDs := make([]int64, 0)
 	for rows.Next() {
 		var req domain.RequestDBModel
-		if err := rows.Scan(&req.ID, &req.PickupLocation, &req.PickupLatitude, &req.PickupLongitude, &req.DropoffLocation, &req.DropoffLatitude, &req.DropoffLongitude, &req.Compensation, &req.PassengerID, &req.RideID, &req.AreNotificationsCreated, &req.CreatedAt); err != nil {
+		if err := rows.Scan(&req.ID, &req.PassengerID, &req.Status); err != nil {
 			return nil, err
 		}
 		requests = append(requests, &req)
+		requestIDs = append(requestIDs, req.ID)
 	}
-
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
+	if len(requests) == 0 {
+		return requests, nil
+	}
+
+	_, err = p.conn.Exec(ctx,
+		`UPDATE proposals
+		SET status = $1, cancelled_by = driver_id, cancellation_reason = $2, cancelled_at = CURRENT_TIMESTAMP
+		WHERE ride_id = $3 AND status = $4 AND request_id = ANY($5)`,
+		proposalStatus, domain.NoShowReason, rideID, domain.ProposalStatusAccepted, requestIDs)
+	if err != nil {
+		return nil, err
+	}
 
 	return requests, nil
 }
 
-func (p *postgresRidesRepository) MarkRequestAsVisited(ctx context.Context, requestID int64) error {
-	query := `
-		UPDATE requests
-		SET visited = TRUE
-		WHERE id = $1
-	`
-	_, err := p.conn.Exec(ctx, query, requestID)
-	return err
+// DropOffRequest records a passenger leaving the car. Once every accepted passenger has been
+// dropped off the ride completes, which the returned ride's status shows, and they are charged
+// on the ledger. route is as for CompleteRide, and only needed when this is the last dropoff.
+func (p *postgresRidesRepository) DropOffRequest(ctx context.Context, rideID, requestID int64, route *domain.CostShareRoute) (*domain.RideDBModel, error) {
+	var ride *domain.RideDBModel
+
+	err := WithTx(ctx, p.conn, func(tx pgx.Tx) error {
+		txRepo := &postgresRidesRepository{conn: tx}
+
+		var err error
+		ride, err = txRepo.getRideForUpdate(ctx, rideID)
+		if err != nil {
+			return err
+		}
+		if ride.Status != domain.RideStatusInProgress {
+			return &domain.InvalidTransitionError{Entity: "ride", From: string(ride.Status), Event: string(domain.RequestEventDropoff)}
+		}
+
+		var current domain.RequestStatus
+		err = tx.QueryRow(ctx,
+			`SELECT req.status
+			FROM requests req
+			JOIN proposals p ON p.request_id = req.id
+			WHERE req.id = $1 AND p.ride_id = $2 AND p.status = $3
+			FOR UPDATE OF req`,
+			requestID, rideID, domain.ProposalStatusAccepted).Scan(&current)
+		if err != nil {
+			return err
+		}
+
+		status, err := current.Next(domain.RequestEventDropoff)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx,
+			`UPDATE requests SET status = $1, dropped_off_at = CURRENT_TIMESTAMP WHERE id = $2`,
+			status, requestID)
+		if err != nil {
+			return err
+		}
+
+		var remaining int64
+		err = tx.QueryRow(ctx,
+			`SELECT COUNT(*)
+			FROM proposals p
+			JOIN requests req ON req.id = p.request_id
+			WHERE p.ride_id = $1 AND p.status = $2 AND req.status != $3`,
+			rideID, domain.ProposalStatusAccepted, domain.RequestStatusDroppedOff).Scan(&remaining)
+		if err != nil {
+			return err
+		}
+		if remaining > 0 {
+			return nil
+		}
+
+		if err := ride.Apply(domain.RideEventComplete); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx,
+			`UPDATE rides SET status = $1 WHERE id = $2`,
+			ride.Status, rideID)
+		if err != nil {
+			return err
+		}
+
+		if err := (&postgresPricingRepository{conn: tx}).recordRideTariff(ctx, rideID); err != nil {
+			return err
+		}
+
+		if err := txRepo.settleCostShares(ctx, ride, route); err != nil {
+			return err
+		}
+		return (&postgresLedgerRepository{conn: tx}).postRideCompensation(ctx, rideID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ride, nil
+}
+
+func (p *postgresRidesRepository) SetRideRoute(ctx context.Context, rideID int64, route *domain.RouteDBModel) error {
+	_, err := p.conn.Exec(ctx,
+		`UPDATE rides SET route_distance = $1, route_duration = $2 WHERE id = $3`,
+		route.Distance, route.Duration, rideID)
+	return err
+}
+
+// settleCostShares splits what the driver is paid for a completed ride between the passengers
+// dropped off on it, by how far each rode and who with, and stores each passenger's share. The
+// split is a breakdown: passengers are still charged the compensation they agreed to. Must run
+// inside the transaction completing the ride.
+//
+// Routing is slow and can fail, so the route is worked out before the transaction. If there is
+// none, or the stops changed since, the ride completes without a split rather than not at all.
+func (p *postgresRidesRepository) settleCostShares(ctx context.Context, ride *domain.RideDBModel, route *domain.CostShareRoute) error {
+	rows, err := p.conn.Query(ctx,
+		`SELECT req.id, req.pickup_latitude, req.pickup_longitude, req.dropoff_latitude, req.dropoff_longitude,
+			req.picked_up_at, req.dropped_off_at, COALESCE(p.compensation, req.compensation)
+		FROM proposals p
+		JOIN requests req ON req.id = p.request_id
+		WHERE p.ride_id = $1 AND p.status = $2 AND req.status = $3
+		ORDER BY req.id`,
+		ride.ID, domain.ProposalStatusAccepted, domain.RequestStatusDroppedOff)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	requests := make([]*domain.RequestDBModel, 0)
+	total := domain.Money(0)
+	for rows.Next() {
+		var request domain.RequestDBModel
+		var compensation domain.Money
+		if err := rows.Scan(&request.ID, &request.PickupLatitude, &request.PickupLongitude, &request.DropoffLatitude, &request.DropoffLongitude,
+			&request.PickedUpAt, &request.DroppedOffAt, Dollars(&compensation)); err != nil {
+			return err
+		}
+		requests = append(requests, &request)
+		total += compensation
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	stops := domain.CostShareStops(requests, domain.Coordinates{Lat: ride.DestinationLatitude, Lon: ride.DestinationLongitude})
+	if len(stops) == 0 || !route.Fits(stops) {
+		return nil
+	}
+
+	shares, err := domain.SplitTripCost(total, stops, route.Legs)
+	if err != nil {
+		return err
+	}
+
+	for _, share := range shares {
+		_, err := p.conn.Exec(ctx,
+			`UPDATE proposals
+			SET cost_share = $1, cost_share_distance = $2, cost_share_shared_distance = $3
+			WHERE ride_id = $4 AND request_id = $5 AND status = $6`,
+			share.Amount.Float64(), share.DistanceOnBoard, share.SharedDistance, ride.ID, share.RequestID, domain.ProposalStatusAccepted)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetRideHistory returns a page of the rides the account drove or had a proposal on, newest
+// first. The driver sees every proposal on their rides; passengers see who rode with them
+// and their own proposal whatever became of it.
+func (p *postgresRidesRepository) GetRideHistory(ctx context.Context, filter domain.RideHistoryFilter) ([]*domain.RideHistoryDBModel, error) {
+	query := `
+		WITH history AS (
+			SELECT r.id, bool_or(p.driver_id = $1) AS is_driver, min(p.driver_id) AS driver_id
+			FROM rides r
+			JOIN proposals p ON p.ride_id = r.id
+			JOIN requests req ON req.id = p.request_id
+			WHERE (
+					($2::text IS NULL AND (p.driver_id = $1 OR req.passenger_id = $1))
+					OR ($2 = 'driver' AND p.driver_id = $1)
+					OR ($2 = 'passenger' AND req.passenger_id = $1)
+				)
+				AND (cardinality($3::text[]) = 0 OR r.status = ANY($3))
+				AND ($4::timestamptz IS NULL OR r.created_at >= $4)
+				AND ($5::timestamptz IS NULL OR r.created_at < $5)
+				AND ($6::bigint IS NULL OR r.id < $6)
+			GROUP BY r.id
+			ORDER BY r.id DESC
+			LIMIT $7
+		)
+		SELECT
+			r.id, r.status, r.destination_latitude, r.destination_longitude, r.created_at, r.updated_at,
+			h.is_driver, h.driver_id, d.firstname, d.lastname,
+			v.id, v.make, v.model, v.model_year, v.colour, v.license_plate,
+			r.route_distance, r.route_duration,
+			COALESCE(SUM(COALESCE(p.compensation, req.compensation)) FILTER (
+				WHERE p.status = $8 AND (h.is_driver OR req.passenger_id = $1)), 0),
+			COALESCE(json_agg(json_build_object(
+				'proposal_id', p.id,
+				'proposal_status', p.status,
+				'request_id', req.id,
+				'request_status', req.status,
+				'passenger_id', req.passenger_id,
+				'first_name', pa.firstname,
+				'last_name', pa.lastname,
+				'pickup_location', COALESCE(req.pickup_location, ''),
+				'pickup_latitude', req.pickup_latitude,
+				'pickup_longitude', req.pickup_longitude,
+				'dropoff_location', req.dropoff_location,
+				'dropoff_latitude', req.dropoff_latitude,
+				'dropoff_longitude', req.dropoff_longitude,
+				'compensation', COALESCE(p.compensation, req.compensation),
+				'picked_up_at', req.picked_up_at,
+				'dropped_off_at', req.dropped_off_at,
+				'cost_share', p.cost_share,
+				'cost_share_distance', p.cost_share_distance,
+				'cost_share_shared_distance', p.cost_share_shared_distance
+			) ORDER BY p.id) FILTER (
+				WHERE h.is_driver OR p.status = $8 OR req.passenger_id = $1), '[]')
+		FROM history h
+		JOIN rides r ON r.id = h.id
+		JOIN accounts d ON d.id = h.driver_id
+		LEFT JOIN vehicles v ON v.account_id = h.driver_id
+		JOIN proposals p ON p.ride_id = r.id
+		JOIN requests req ON req.id = p.request_id
+		JOIN accounts pa ON pa.id = req.passenger_id
+		GROUP BY r.id, h.is_driver, h.driver_id, d.firstname, d.lastname, v.id
+		ORDER BY r.id DESC`
+
+	var role *string
+	if filter.Role != nil {
+		r := string(*filter.Role)
+		role = &r
+	}
+	statuses := make([]string, len(filter.Statuses))
+	for i, status := range filter.Statuses {
+		statuses[i] = string(status)
+	}
+
+	rows, err := p.conn.Query(ctx, query,
+		filter.AccountID, role, statuses, filter.From, filter.To, filter.BeforeRideID, filter.Limit,
+		domain.ProposalStatusAccepted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []*domain.RideHistoryDBModel
+	for rows.Next() {
+		var item domain.RideHistoryDBModel
+		var isDriver bool
+		var vehicleID *int64
+		var vehicleMake, vehicleModel, vehicleColour, vehiclePlate *string
+		var vehicleYear *int
+		if err := rows.Scan(
+			&item.Ride.ID, &item.Ride.Status, &item.Ride.DestinationLatitude, &item.Ride.DestinationLongitude, &item.Ride.CreatedAt, &item.Ride.UpdatedAt,
+			&isDriver, &item.DriverID, &item.DriverFirstName, &item.DriverLastName,
+			&vehicleID, &vehicleMake, &vehicleModel, &vehicleYear, &vehicleColour, &vehiclePlate,
+			&item.RouteDistance, &item.RouteDuration,
+			Dollars(&item.Compensation), &item.Passengers,
+		); err != nil {
+			return nil, err
+		}
+
+		item.Role = domain.RideHistoryRolePassenger
+		if isDriver {
+			item.Role = domain.RideHistoryRoleDriver
+		}
+		if vehicleID != nil {
+			item.Vehicle = &domain.VehicleDBModel{
+				ID:           *vehicleID,
+				Make:         *vehicleMake,
+				Model:        *vehicleModel,
+				ModelYear:    *vehicleYear,
+				Colour:       *vehicleColour,
+				LicensePlate: *vehiclePlate,
+				AccountID:    item.DriverID,
+			}
+		}
+		history = append(history, &item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+func (p *postgresRidesRepository) GetInProgressRidesWithLocations(ctx context.Context) ([]*domain.RideWithLocationsDBModel, error) {
+	query := `
+		SELECT 
+			r.id as ride_id,
+			p.driver_id,
+			driver_acc.current_latitude as driver_latitude,
+			driver_acc.current_longitude as driver_longitude,
+			req.passenger_id,
+			passenger_acc.current_latitude as passenger_latitude,
+			passenger_acc.current_longitude as passenger_longitude,
+			req.pickup_latitude,
+			req.pickup_longitude
+		FROM rides r
+		JOIN proposals p ON p.ride_id = r.id AND p.status = $1
+		JOIN requests req ON req.id = p.request_id
+		JOIN accounts driver_acc ON driver_acc.id = p.driver_id
+		JOIN accounts passenger_acc ON passenger_acc.id = req.passenger_id
+		WHERE r.status = $2`
+
+	rows, err := p.conn.Query(ctx, query, domain.ProposalStatusAccepted, domain.RideStatusInProgress)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*domain.RideWithLocationsDBModel
+	for rows.Next() {
+		var r domain.RideWithLocationsDBModel
+		if err := rows.Scan(
+			&r.RideID,
+			&r.DriverID,
+			&r.DriverLatitude,
+			&r.DriverLongitude,
+			&r.PassengerID,
+			&r.PassengerLatitude,
+			&r.PassengerLongitude,
+			&r.PickupLatitude,
+			&r.PickupLongitude,
+		); err != nil {
+			return nil, err
+		}
+		results = append(results, &r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (p *postgresRidesRepository) GetUnvisitedRequestsByRideID(ctx context.Context, rideID int64) ([]*domain.RequestDBModel, error) {
+	query := `
+		SELECT req.id, req.pickup_location, req.pickup_latitude, req.pickup_longitude, req.dropoff_location, req.dropoff_latitude, req.dropoff_longitude, req.compensation, req.passenger_id, req.ride_id, req.status, req.notifs_crtd, req.created_at
+		FROM requests req
+		JOIN proposals p ON p.request_id = req.id
+		WHERE p.ride_id = $1 AND p.status = $2 AND req.status = $3
+	`
+	rows, err := p.conn.Query(ctx, query, rideID, domain.ProposalStatusAccepted, domain.RequestStatusMatched)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []*domain.RequestDBModel
+	for rows.Next() {
+		var req domain.RequestDBModel
+		if err := rows.Scan(&req.ID, &req.PickupLocation, &req.PickupLatitude, &req.PickupLongitude, &req.DropoffLocation, &req.DropoffLatitude, &req.DropoffLongitude, &req.Compensation, &req.PassengerID, &req.RideID, &req.Status, &req.AreNotificationsCreated, &req.CreatedAt); err != nil {
+			return nil, err
+		}
+		requests = append(requests, &req)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return requests, nil
+}
+
+// BoardPassenger marks a passenger picked up once the driver enters their boarding code.
+// Wrong codes use up an attempt and return a *domain.BoardingCodeError; once none are left
+// the passenger has to issue a new code.
+func (p *postgresRidesRepository) BoardPassenger(ctx context.Context, rideID, requestID int64, code string) error {
+	var codeErr *domain.BoardingCodeError
+
+	err := WithTx(ctx, p.conn, func(tx pgx.Tx) error {
+		ride, err := (&postgresRidesRepository{conn: tx}).getRideForUpdate(ctx, rideID)
+		if err != nil {
+			return err
+		}
+		if ride.Status != domain.RideStatusInProgress {
+			return &domain.InvalidTransitionError{Entity: "ride", From: string(ride.Status), Event: string(domain.RequestEventPickup)}
+		}
+
+		var proposalID int64
+		var boardingCode *string
+		var attempts int
+		var current domain.RequestStatus
+		err = tx.QueryRow(ctx,
+			`SELECT p.id, p.boarding_code, p.boarding_attempts, req.status
+			FROM proposals p
+			JOIN requests req ON req.id = p.request_id
+			WHERE p.ride_id = $1 AND p.request_id = $2 AND p.status = $3
+			FOR UPDATE`,
+			rideID, requestID, domain.ProposalStatusAccepted).Scan(&proposalID, &boardingCode, &attempts, &current)
+		if err != nil {
+			return err
+		}
+
+		status, err := current.Next(domain.RequestEventPickup)
+		if err != nil {
+			return err
+		}
+
+		if attempts >= domain.MaxBoardingAttempts {
+			codeErr = &domain.BoardingCodeError{AttemptsLeft: 0}
+			return nil
+		}
+		if boardingCode == nil || subtle.ConstantTimeCompare([]byte(*boardingCode), []byte(code)) != 1 {
+			// The failed attempt must stick, so it is committed and the error returned afterwards
+			attempts++
+			_, err = tx.Exec(ctx,
+				`UPDATE proposals SET boarding_attempts = $1 WHERE id = $2`,
+				attempts, proposalID)
+			codeErr = &domain.BoardingCodeError{AttemptsLeft: domain.MaxBoardingAttempts - attempts}
+			return err
+		}
+
+		_, err = tx.Exec(ctx,
+			`UPDATE proposals SET boarded_at = CURRENT_TIMESTAMP WHERE id = $1`,
+			proposalID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx,
+			`UPDATE requests SET status = $1, picked_up_at = CURRENT_TIMESTAMP WHERE id = $2`,
+			status, requestID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if codeErr != nil {
+		return codeErr
+	}
+
+	return nil
+}
+
+// ResetBoardingCode issues a new boarding code for an accepted passenger who has not boarded
+// yet and clears the failed attempts
+func (p *postgresRidesRepository) ResetBoardingCode(ctx context.Context, proposalID int64) (*domain.ProposalDBModel, error) {
+	code, err := domain.GenerateBoardingCode()
+	if err != nil {
+		return nil, err
+	}
+
+	tag, err := p.conn.Exec(ctx,
+		`UPDATE proposals p
+		SET boarding_code = $1, boarding_attempts = 0
+		FROM requests req
+		WHERE req.id = p.request_id AND p.id = $2 AND p.status = $3 AND req.status = $4`,
+		code, proposalID, domain.ProposalStatusAccepted, domain.RequestStatusMatched)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, &domain.InvalidTransitionError{Entity: "boarding code", From: "issued", Event: "reset"}
+	}
+
+	return p.GetProposalByID(ctx, proposalID)
+}
+
+func (p *postgresRidesRepository) CancelRequest(ctx context.Context, requestID int64, cancellation domain.Cancellation) ([]*domain.ProposalDBModel, error) {
+	var cancelled []*domain.ProposalDBModel
+
+	err := WithTx(ctx, p.conn, func(tx pgx.Tx) error {
+		txRepo := &postgresRidesRepository{conn: tx}
+
+		// Lock rides before proposals and requests, matching the order ConfirmRideProposal uses
+		rows, err := tx.Query(ctx,
+			`SELECT DISTINCT ride_id FROM proposals WHERE request_id = $1 AND status IN ($2, $3) ORDER BY ride_id`,
+			requestID, domain.ProposalStatusPending, domain.ProposalStatusAccepted)
+		if err != nil {
+			return err
+		}
+		rideIDs, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+		if err != nil {
+			return err
+		}
+
+		rides := make(map[int64]*domain.RideDBModel, len(rideIDs))
+		for _, rideID := range rideIDs {
+			ride, err := txRepo.getRideForUpdate(ctx, rideID)
+			if err != nil {
+				return err
+			}
+			rides[rideID] = ride
+		}
+
+		if err := txRepo.cancelRequest(ctx, requestID, cancellation); err != nil {
+			return err
+		}
+
+		proposals, err := txRepo.lockLiveProposals(ctx, "request_id", requestID)
+		if err != nil {
+			return err
+		}
+		for _, proposal := range proposals {
+			if err := proposal.Apply(domain.ProposalEventCancel); err != nil {
+				return err
+			}
+			if err := txRepo.markCancelled(ctx, "proposals", proposal.ID, proposal.Status, cancellation); err != nil {
+				return err
+			}
+			if _, ok := rides[proposal.RideID]; !ok {
+				ride, err := txRepo.getRideForUpdate(ctx, proposal.RideID)
+				if err != nil {
+					return err
+				}
+				rides[proposal.RideID] = ride
+			}
+		}
+
+		for _, ride := range rides {
+			if err := txRepo.settleRide(ctx, ride, &cancellation); err != nil {
+				return err
+			}
+		}
+
+		cancelled = proposals
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cancelled, nil
+}
+
+func (p *postgresRidesRepository) CancelProposal(ctx context.Context, proposalID int64, cancellation domain.Cancellation) (*domain.ProposalDBModel, error) {
+	proposal, err := p.GetProposalByID(ctx, proposalID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = WithTx(ctx, p.conn, func(tx pgx.Tx) error {
+		txRepo := &postgresRidesRepository{conn: tx}
+
+		ride, err := txRepo.getRideForUpdate(ctx, proposal.RideID)
+		if err != nil {
+			return err
+		}
+		// Once the ride is over its passengers have been charged for the trip, which only a
+		// refund can give back
+		if ride.Status != domain.RideStatusAwaitingConfirmation && ride.Status != domain.RideStatusInProgress {
+			return &domain.InvalidTransitionError{Entity: "proposal on ride", From: string(ride.Status), Event: string(domain.ProposalEventCancel)}
+		}
+
+		err = tx.QueryRow(ctx,
+			`SELECT status FROM proposals WHERE id = $1 FOR UPDATE`,
+			proposalID).Scan(&proposal.Status)
+		if err != nil {
+			return err
+		}
+		wasAccepted := proposal.Status == domain.ProposalStatusAccepted
+		if err := proposal.Apply(domain.ProposalEventCancel); err != nil {
+			return err
+		}
+
+		// An accepted passenger on a confirmed ride goes back into the pool when the driver
+		// withdraws. A passenger who withdraws has given up on the trip, so their request is
+		// cancelled rather than offered to other drivers.
+		if wasAccepted && ride.Status == domain.RideStatusInProgress {
+			if cancellation.CancelledBy == proposal.DriverID {
+				err = txRepo.releaseRequest(ctx, proposal.RequestID)
+			} else {
+				err = txRepo.cancelRequest(ctx, proposal.RequestID, cancellation)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := txRepo.markCancelled(ctx, "proposals", proposal.ID, proposal.Status, cancellation); err != nil {
+			return err
+		}
+
+		return txRepo.settleRide(ctx, ride, &cancellation)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return proposal, nil
+}
+
+func (p *postgresRidesRepository) CancelRide(ctx context.Context, rideID int64, cancellation domain.Cancellation) ([]*domain.ProposalDBModel, error) {
+	var cancelled []*domain.ProposalDBModel
+
+	err := WithTx(ctx, p.conn, func(tx pgx.Tx) error {
+		txRepo := &postgresRidesRepository{conn: tx}
+
+		ride, err := txRepo.getRideForUpdate(ctx, rideID)
+		if err != nil {
+			return err
+		}
+		wasInProgress := ride.Status == domain.RideStatusInProgress
+		if err := ride.Apply(domain.RideEventCancel); err != nil {
+			return err
+		}
+
+		proposals, err := txRepo.lockLiveProposals(ctx, "ride_id", rideID)
+		if err != nil {
+			return err
+		}
+		for _, proposal := range proposals {
+			// Passengers already on board cannot be released, so the ride has to be completed instead
+			if wasInProgress && proposal.Status == domain.ProposalStatusAccepted {
+				if err := txRepo.releaseRequest(ctx, proposal.RequestID); err != nil {
+					return err
+				}
+			}
+			if err := proposal.Apply(domain.ProposalEventCancel); err != nil {
+				return err
+			}
+			if err := txRepo.markCancelled(ctx, "proposals", proposal.ID, proposal.Status, cancellation); err != nil {
+				return err
+			}
+		}
+
+		if err := txRepo.markCancelled(ctx, "rides", ride.ID, ride.Status, cancellation); err != nil {
+			return err
+		}
+
+		cancelled = proposals
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cancelled, nil
+}
+
+func (p *postgresRidesRepository) ExpireRideRequests(ctx context.Context, createdBefore time.Time) ([]*domain.RequestDBModel, error) {
+	status, err := domain.RequestStatusOpen.Next(domain.RequestEventExpire)
+	if err != nil {
+		return nil, err
+	}
+
+	expired := make([]*domain.RequestDBModel, 0)
+	err = WithTx(ctx, p.conn, func(tx pgx.Tx) error {
+		// Scheduled requests live until their window closes rather than for the TTL.
+		// Requests being drafted right now are skipped and picked up on a later run.
+		rows, err := tx.Query(ctx,
+			`SELECT id FROM requests
+			WHERE status = $1 AND ((window_end IS NULL AND created_at < $2) OR window_end < CURRENT_TIMESTAMP)
+			ORDER BY id
+			FOR UPDATE SKIP LOCKED`,
+			domain.RequestStatusOpen, createdBefore)
+		if err != nil {
+			return err
+		}
+		requestIDs, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+		if err != nil {
+			return err
+		}
+		if len(requestIDs) == 0 {
+			return nil
+		}
+
+		// A request with live proposals waits until they are answered or expire themselves.
+		// This is a separate statement so it sees proposals committed while we took the locks.
+		rows, err = tx.Query(ctx,
+			`UPDATE requests SET status = $1, expired_at = CURRENT_TIMESTAMP
+			WHERE id = ANY($2) AND NOT EXISTS (
+				SELECT 1 FROM proposals p WHERE p.request_id = requests.id AND p.status IN ($3, $4)
+			)
+			RETURNING id, pickup_location, pickup_latitude, pickup_longitude, dropoff_location, dropoff_latitude, dropoff_longitude, compensation, passenger_id, status, notifs_crtd, created_at`,
+			status, requestIDs, domain.ProposalStatusPending, domain.ProposalStatusAccepted)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var req domain.RequestDBModel
+			if err := rows.Scan(&req.ID, &req.PickupLocation, &req.PickupLatitude, &req.PickupLongitude, &req.DropoffLocation, &req.DropoffLatitude, &req.DropoffLongitude, &req.Compensation, &req.PassengerID, &req.Status, &req.AreNotificationsCreated, &req.CreatedAt); err != nil {
+				return err
+			}
+			expired = append(expired, &req)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return expired, nil
+}
+
+func (p *postgresRidesRepository) ExpireRideProposals(ctx context.Context, createdBefore time.Time) ([]*domain.ProposalDBModel, error) {
+	status, err := domain.ProposalStatusPending.Next(domain.ProposalEventExpire)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := p.conn.Query(ctx,
+		`SELECT DISTINCT ride_id FROM proposals WHERE status = $1 AND created_at < $2 ORDER BY ride_id`,
+		domain.ProposalStatusPending, createdBefore)
+	if err != nil {
+		return nil, err
+	}
+	rideIDs, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, err
+	}
+
+	// One transaction per ride, so each ride is settled as soon as its stale proposals are gone
+	expired := make([]*domain.ProposalDBModel, 0)
+	for _, rideID := range rideIDs {
+		var rideExpired []*domain.ProposalDBModel
+		err := WithTx(ctx, p.conn, func(tx pgx.Tx) error {
+			txRepo := &postgresRidesRepository{conn: tx}
+
+			ride, err := txRepo.getRideForUpdate(ctx, rideID)
+			if err != nil {
+				return err
+			}
+
+			rows, err := tx.Query(ctx,
+				`UPDATE proposals SET status = $1, expired_at = CURRENT_TIMESTAMP
+				WHERE ride_id = $2 AND status = $3 AND created_at < $4
+				RETURNING id, request_id, status, driver_id, ride_id, created_at, updated_at`,
+				status, rideID, domain.ProposalStatusPending, createdBefore)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+				var proposal domain.ProposalDBModel
+				if err := rows.Scan(&proposal.ID, &proposal.RequestID, &proposal.Status, &proposal.DriverID, &proposal.RideID, &proposal.CreatedAt, &proposal.UpdatedAt); err != nil {
+					return err
+				}
+				rideExpired = append(rideExpired, &proposal)
+			}
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			rows.Close()
+
+			// Expired proposals count as rejected when the ride settles
+			return txRepo.settleRide(ctx, ride, nil)
+		})
+		if err != nil {
+			return expired, err
+		}
+		expired = append(expired, rideExpired...)
+	}
+
+	return expired, nil
+}
+
+// GetDuePickupReminders returns scheduled passengers on confirmed, still running rides who are
+// yet to be picked up or reminded, and whose window starts before windowStartsBefore
+func (p *postgresRidesRepository) GetDuePickupReminders(ctx context.Context, windowStartsBefore time.Time) ([]*domain.PickupReminderDBModel, error) {
+	query := `
+		SELECT req.id, req.ride_id, p.driver_id, req.passenger_id, acc.firstname, COALESCE(req.pickup_location, ''), req.window_start
+		FROM requests req
+		JOIN proposals p ON p.request_id = req.id AND p.ride_id = req.ride_id AND p.status = $1
+		JOIN rides r ON r.id = req.ride_id AND r.status = $4
+		JOIN accounts acc ON acc.id = req.passenger_id
+		WHERE req.status = $2
+			AND req.window_start IS NOT NULL
+			AND req.window_start < $3
+			AND req.pickup_reminder_sent = FALSE`
+	rows, err := p.conn.Query(ctx, query, domain.ProposalStatusAccepted, domain.RequestStatusMatched, windowStartsBefore, domain.RideStatusInProgress)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []*domain.PickupReminderDBModel
+	for rows.Next() {
+		var reminder domain.PickupReminderDBModel
+		if err := rows.Scan(&reminder.RequestID, &reminder.RideID, &reminder.DriverID, &reminder.PassengerID, &reminder.PassengerFirstName, &reminder.PickupLocation, &reminder.WindowStart); err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, &reminder)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reminders, nil
+}
+
+func (p *postgresRidesRepository) MarkPickupReminderSent(ctx context.Context, requestID int64) error {
+	_, err := p.conn.Exec(ctx,
+		`UPDATE requests SET pickup_reminder_sent = TRUE WHERE id = $1`,
+		requestID)
+	return err
+}
+
+const counterOfferColumns = `id, proposal_id, offered_by, amount, status, answered_at, created_at`
+
+func scanCounterOffer(row pgx.Row) (*domain.CounterOfferDBModel, error) {
+	var counter domain.CounterOfferDBModel
+	err := row.Scan(&counter.ID, &counter.ProposalID, &counter.OfferedBy, Dollars(&counter.Amount), &counter.Status, &counter.AnsweredAt, &counter.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &counter, nil
+}
+
+// lockNegotiableProposal locks a proposal so its counter-offers can be changed, failing unless
+// it is still waiting for the passenger's answer
+func (p *postgresRidesRepository) lockNegotiableProposal(ctx context.Context, proposalID int64) error {
+	var status domain.ProposalStatus
+	err := p.conn.QueryRow(ctx,
+		`SELECT status FROM proposals WHERE id = $1 FOR UPDATE`,
+		proposalID).Scan(&status)
+	if err != nil {
+		return err
+	}
+	if status != domain.ProposalStatusPending {
+		return &domain.InvalidTransitionError{Entity: "proposal", From: string(status), Event: "negotiate"}
+	}
+	return nil
+}
+
+func (p *postgresRidesRepository) insertCounterOffer(ctx context.Context, proposalID int64, offeredBy domain.CounterOfferParty, amount domain.Money) (*domain.CounterOfferDBModel, error) {
+	return scanCounterOffer(p.conn.QueryRow(ctx,
+		`INSERT INTO counter_offers (proposal_id, offered_by, amount)
+		VALUES ($1, $2, $3)
+		RETURNING `+counterOfferColumns,
+		proposalID, offeredBy, amount.Float64()))
+}
+
+// CreateCounterOffer puts a new price on a pending proposal, as long as nothing else is
+// waiting for an answer
+func (p *postgresRidesRepository) CreateCounterOffer(ctx context.Context, proposalID int64, offeredBy domain.CounterOfferParty, amount domain.Money) (*domain.CounterOfferDBModel, error) {
+	var counter *domain.CounterOfferDBModel
+
+	err := WithTx(ctx, p.conn, func(tx pgx.Tx) error {
+		txRepo := &postgresRidesRepository{conn: tx}
+
+		if err := txRepo.lockNegotiableProposal(ctx, proposalID); err != nil {
+			return err
+		}
+
+		var negotiating bool
+		err := tx.QueryRow(ctx,
+			`SELECT EXISTS (SELECT 1 FROM counter_offers WHERE proposal_id = $1 AND status = $2)`,
+			proposalID, domain.CounterOfferStatusPending).Scan(&negotiating)
+		if err != nil {
+			return err
+		}
+		if negotiating {
+			return domain.ErrCounterOfferPending
+		}
+
+		counter, err = txRepo.insertCounterOffer(ctx, proposalID, offeredBy, amount)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return counter, nil
+}
+
+// RespondToCounterOffer answers a pending counter-offer. Accepting sets the proposal's
+// compensation to its amount. Countering answers it with a new counter-offer for amount,
+// which is returned alongside.
+func (p *postgresRidesRepository) RespondToCounterOffer(ctx context.Context, counterOfferID int64, event domain.CounterOfferEvent, amount *domain.Money) (*domain.CounterOfferDBModel, *domain.CounterOfferDBModel, error) {
+	var answered, countered *domain.CounterOfferDBModel
+
+	err := WithTx(ctx, p.conn, func(tx pgx.Tx) error {
+		txRepo := &postgresRidesRepository{conn: tx}
+
+		var proposalID int64
+		err := tx.QueryRow(ctx,
+			`SELECT proposal_id FROM counter_offers WHERE id = $1`,
+			counterOfferID).Scan(&proposalID)
+		if err != nil {
+			return err
+		}
+		if err := txRepo.lockNegotiableProposal(ctx, proposalID); err != nil {
+			return err
+		}
+
+		current, err := scanCounterOffer(tx.QueryRow(ctx,
+			`SELECT `+counterOfferColumns+` FROM counter_offers WHERE id = $1 FOR UPDATE`,
+			counterOfferID))
+		if err != nil {
+			return err
+		}
+		status, err := current.Status.Next(event)
+		if err != nil {
+			return err
+		}
+
+		answered, err = scanCounterOffer(tx.QueryRow(ctx,
+			`UPDATE counter_offers SET status = $1, answered_at = CURRENT_TIMESTAMP WHERE id = $2
+			RETURNING `+counterOfferColumns,
+			status, counterOfferID))
+		if err != nil {
+			return err
+		}
+
+		switch event {
+		case domain.CounterOfferEventAccept:
+			_, err = tx.Exec(ctx,
+				`UPDATE proposals SET compensation = $1 WHERE id = $2`,
+				answered.Amount.Float64(), proposalID)
+		case domain.CounterOfferEventCounter:
+			countered, err = txRepo.insertCounterOffer(ctx, proposalID, answered.OfferedBy.Other(), *amount)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return answered, countered, nil
+}
+
+func (p *postgresRidesRepository) GetCounterOfferByID(ctx context.Context, counterOfferID int64) (*domain.CounterOfferDBModel, error) {
+	return scanCounterOffer(p.conn.QueryRow(ctx,
+		`SELECT `+counterOfferColumns+` FROM counter_offers WHERE id = $1`,
+		counterOfferID))
+}
+
+// GetCounterOffersByProposalID returns a proposal's negotiation history, oldest first
+func (p *postgresRidesRepository) GetCounterOffersByProposalID(ctx context.Context, proposalID int64) ([]*domain.CounterOfferDBModel, error) {
+	rows, err := p.conn.Query(ctx,
+		`SELECT `+counterOfferColumns+` FROM counter_offers WHERE proposal_id = $1 ORDER BY id`,
+		proposalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counters := make([]*domain.CounterOfferDBModel, 0)
+	for rows.Next() {
+		counter, err := scanCounterOffer(rows)
+		if err != nil {
+			return nil, err
+		}
+		counters = append(counters, counter)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counters, nil
 }
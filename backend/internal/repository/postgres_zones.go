@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Arjun113/nOPark/internal/domain"
+)
+
+type postgresZonesRepository struct {
+	conn Connection
+}
+
+func NewPostgresZones(conn Connection) domain.ZonesRepository {
+	return &postgresZonesRepository{conn: conn}
+}
+
+// GetZones returns every zone, campuses first
+func (p *postgresZonesRepository) GetZones(ctx context.Context) (domain.Zones, error) {
+	rows, err := p.conn.Query(ctx,
+		`SELECT id, code, name, kind, polygon, created_at, updated_at FROM zones ORDER BY kind, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	zones := make(domain.Zones, 0)
+	for rows.Next() {
+		var zone domain.ZoneDBModel
+		if err := rows.Scan(&zone.ID, &zone.Code, &zone.Name, &zone.Kind, &zone.Polygon, &zone.CreatedAt, &zone.UpdatedAt); err != nil {
+			return nil, err
+		}
+		zones = append(zones, &zone)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return zones, nil
+}
@@ -1,6 +1,12 @@
 package repository
 
-import "time"
+import (
+	"fmt"
+	"time"
+
+	"github.com/Arjun113/nOPark/internal/domain"
+	"github.com/jackc/pgx/v5/pgtype"
+)
 
 // Helper functions to handle nullable fields
 func NullString(s string) *string {
@@ -26,4 +32,41 @@ func NullFloat64(f *float64) *float64 {
 		return nil
 	}
 	return f
-}
\ No newline at end of file
+}
+
+// Dollars scans a DECIMAL dollar amount, such as compensation, into Money. Amounts are written
+// back with Money.Float64.
+func Dollars(m *domain.Money) pgtype.Float64Scanner {
+	return dollarsScanner{money: m}
+}
+
+// NullDollars is Dollars for a nullable column
+func NullDollars(m **domain.Money) pgtype.Float64Scanner {
+	return nullDollarsScanner{money: m}
+}
+
+type dollarsScanner struct {
+	money *domain.Money
+}
+
+func (s dollarsScanner) ScanFloat64(v pgtype.Float8) error {
+	if !v.Valid {
+		return fmt.Errorf("cannot scan NULL into money")
+	}
+	*s.money = domain.MoneyFromFloat(v.Float64)
+	return nil
+}
+
+type nullDollarsScanner struct {
+	money **domain.Money
+}
+
+func (s nullDollarsScanner) ScanFloat64(v pgtype.Float8) error {
+	if !v.Valid {
+		*s.money = nil
+		return nil
+	}
+	m := domain.MoneyFromFloat(v.Float64)
+	*s.money = &m
+	return nil
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Arjun113/nOPark/internal/domain"
+)
+
+// CostSuggestionService works out a fair contribution from what the driver's vehicle costs to
+// run. Drivers see it for each request in their feed and passengers see it on proposals.
+type CostSuggestionService struct {
+	accountsRepo domain.AccountsRepository
+	pricingRepo  domain.PricingRepository
+}
+
+func NewCostSuggestionService(accountsRepo domain.AccountsRepository, pricingRepo domain.PricingRepository) *CostSuggestionService {
+	return &CostSuggestionService{
+		accountsRepo: accountsRepo,
+		pricingRepo:  pricingRepo,
+	}
+}
+
+// DriverFuelCost prices the fuel the driver's vehicle uses from the fuel price table
+func (s *CostSuggestionService) DriverFuelCost(ctx context.Context, driverID int64) (domain.FuelCost, error) {
+	vehicle, err := s.accountsRepo.GetVehicleByAccountID(ctx, driverID)
+	if err != nil {
+		return domain.FuelCost{}, err
+	}
+	fuelType, consumption, assumed := domain.VehicleFuel(vehicle)
+
+	price, err := s.pricingRepo.GetFuelPrice(ctx, fuelType)
+	if err != nil {
+		return domain.FuelCost{}, fmt.Errorf("no price for %s: %w", fuelType, err)
+	}
+
+	return domain.FuelCost{
+		FuelType:    fuelType,
+		Consumption: consumption,
+		Assumed:     assumed,
+		UnitPrice:   price.Price,
+	}, nil
+}
+
+// FeedContribution prices a request in the driver's feed from the routes already worked out
+// for it. The passenger rides the last leg of the detour route, from their pickup on, and
+// pays towards its tolls as well as its fuel.
+func (s *CostSuggestionService) FeedContribution(cost domain.FuelCost, tollRates []*domain.TollRateDBModel, directRoute, detourRoute *domain.RouteDBModel) domain.FairContribution {
+	var shared domain.RouteLeg
+	if len(detourRoute.Legs) > 0 {
+		shared = detourRoute.Legs[len(detourRoute.Legs)-1]
+	}
+	contribution := cost.FairContribution(detourRoute.Distance-directRoute.Distance, shared.Distance)
+
+	detourTolls := domain.TollTotal(domain.PriceTolls(detourRoute.Tolls, tollRates)) -
+		domain.TollTotal(domain.PriceTolls(directRoute.Tolls, tollRates))
+	contribution.AddTolls(detourTolls, domain.TollTotal(domain.PriceTolls(shared.Tolls, tollRates)))
+	return contribution
+}
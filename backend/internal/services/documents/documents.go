@@ -0,0 +1,288 @@
+// Package documents renders receipts and statements as simple PDF and CSV files
+package documents
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Document is a title, a list of labelled values and an optional table below them
+type Document struct {
+	Title    string
+	Subtitle string
+	Fields   []Field
+	Table    *Table
+	// Shown under the table, e.g. totals
+	Footer []Field
+}
+
+type Field struct {
+	Label string
+	Value string
+}
+
+type Table struct {
+	Columns []Column
+	Rows    [][]string
+}
+
+type Column struct {
+	Heading string
+	// Share of the page width, relative to the other columns
+	Weight float64
+	// Right aligned, for amounts
+	Numeric bool
+}
+
+// WriteCSV writes the fields as label,value rows, then a blank row and the table with its
+// headings, then the footer
+func (d *Document) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	for _, field := range d.Fields {
+		cw.Write([]string{field.Label, field.Value})
+	}
+	if d.Table != nil {
+		if len(d.Fields) > 0 {
+			cw.Write([]string{})
+		}
+		headings := make([]string, len(d.Table.Columns))
+		for i, column := range d.Table.Columns {
+			headings[i] = column.Heading
+		}
+		cw.Write(headings)
+		for _, row := range d.Table.Rows {
+			cw.Write(row)
+		}
+	}
+	if len(d.Footer) > 0 {
+		cw.Write([]string{})
+		for _, field := range d.Footer {
+			cw.Write([]string{field.Label, field.Value})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// A4 in points, with the margins used on every page
+const (
+	pageWidth    = 595.0
+	pageHeight   = 842.0
+	margin       = 50.0
+	contentWidth = pageWidth - 2*margin
+)
+
+const (
+	fontRegular = "F1"
+	fontBold    = "F2"
+)
+
+// WritePDF lays the document out on as many A4 pages as it needs, in Helvetica
+func (d *Document) WritePDF(w io.Writer) error {
+	l := &layout{}
+	l.newPage()
+
+	l.text(fontBold, 18, margin, d.Title)
+	l.y -= 24
+	if d.Subtitle != "" {
+		l.text(fontRegular, 10, margin, d.Subtitle)
+		l.y -= 24
+	}
+
+	l.fields(d.Fields)
+
+	if d.Table != nil && len(d.Table.Columns) > 0 {
+		l.y -= 12
+		l.table(d.Table)
+	}
+
+	if len(d.Footer) > 0 {
+		l.y -= 12
+		l.fields(d.Footer)
+	}
+
+	return l.write(w)
+}
+
+type layout struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+func (l *layout) page() *bytes.Buffer {
+	return l.pages[len(l.pages)-1]
+}
+
+func (l *layout) newPage() {
+	l.pages = append(l.pages, &bytes.Buffer{})
+	l.y = pageHeight - margin
+}
+
+// ensure starts a new page when there is not enough room left for height
+func (l *layout) ensure(height float64) bool {
+	if l.y-height < margin {
+		l.newPage()
+		return true
+	}
+	return false
+}
+
+func (l *layout) text(font string, size, x float64, s string) {
+	fmt.Fprintf(l.page(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, l.y-size, escape(s))
+}
+
+func (l *layout) line(y float64) {
+	fmt.Fprintf(l.page(), "0.5 w %.2f %.2f m %.2f %.2f l S\n", margin, y, pageWidth-margin, y)
+}
+
+func (l *layout) fields(fields []Field) {
+	const size = 11.0
+	for _, field := range fields {
+		l.ensure(size + 5)
+		l.text(fontBold, size, margin, fit(field.Label, 150, size))
+		l.text(fontRegular, size, margin+160, fit(field.Value, contentWidth-160, size))
+		l.y -= size + 5
+	}
+}
+
+func (l *layout) table(table *Table) {
+	const size = 9.0
+	const rowHeight = size + 5
+	const padding = 4.0
+
+	total := 0.0
+	for _, column := range table.Columns {
+		total += column.Weight
+	}
+	widths := make([]float64, len(table.Columns))
+	for i, column := range table.Columns {
+		widths[i] = contentWidth * column.Weight / total
+	}
+
+	row := func(font string, cells []string) {
+		x := margin
+		for i, column := range table.Columns {
+			cell := ""
+			if i < len(cells) {
+				cell = fit(cells[i], widths[i]-padding, size)
+			}
+			cx := x
+			if column.Numeric {
+				cx = x + widths[i] - padding - textWidth(cell, size)
+			}
+			l.text(font, size, cx, cell)
+			x += widths[i]
+		}
+		l.y -= rowHeight
+	}
+	headings := func() {
+		cells := make([]string, len(table.Columns))
+		for i, column := range table.Columns {
+			cells[i] = column.Heading
+		}
+		row(fontBold, cells)
+		l.line(l.y + 2)
+	}
+
+	l.ensure(2 * rowHeight)
+	headings()
+	for _, cells := range table.Rows {
+		if l.ensure(rowHeight) {
+			headings()
+		}
+		row(fontRegular, cells)
+	}
+}
+
+// write assembles the pages into a PDF with a cross-reference table
+func (l *layout) write(w io.Writer) error {
+	var out bytes.Buffer
+	offsets := make([]int, 0)
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+
+	// Objects 1 to 4 are fixed, then a page and its contents for each page
+	kids := make([]string, len(l.pages))
+	for i := range l.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(l.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range l.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /%s 3 0 R /%s 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, fontRegular, fontBold, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// escape makes s safe inside a PDF string. The fonts only have Latin-1 glyphs, so anything
+// else is shown as a question mark.
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20:
+			b.WriteByte(' ')
+		case r < 0x80:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// textWidth estimates how wide s is in Helvetica. Digits and most lower case letters are
+// about half the font size; capitals and wide letters more.
+func textWidth(s string, size float64) float64 {
+	width := 0.0
+	for _, r := range s {
+		switch {
+		case strings.ContainsRune("ijlt.,:;|!' ", r):
+			width += 0.28
+		case strings.ContainsRune("mwMW@", r):
+			width += 0.85
+		case r >= 'A' && r <= 'Z':
+			width += 0.68
+		default:
+			width += 0.556
+		}
+	}
+	return width * size
+}
+
+// fit shortens s with an ellipsis until it fits in width
+func fit(s string, width, size float64) string {
+	if textWidth(s, size) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && textWidth(string(runes)+"...", size) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/Arjun113/nOPark/internal/domain"
+)
+
+// MatchingService picks the open requests a driver should draft together. It is shared by
+// the matches endpoint and the worker that pushes suggestions for upcoming offers.
+type MatchingService struct {
+	ridesRepo    domain.RidesRepository
+	mapsRepo     domain.MapsRepository
+	accountsRepo domain.AccountsRepository
+}
+
+func NewMatchingService(ridesRepo domain.RidesRepository, mapsRepo domain.MapsRepository, accountsRepo domain.AccountsRepository) *MatchingService {
+	return &MatchingService{
+		ridesRepo:    ridesRepo,
+		mapsRepo:     mapsRepo,
+		accountsRepo: accountsRepo,
+	}
+}
+
+// OfferedFeatures returns the ride features the driver offers through their vehicle and
+// their preferences
+func (s *MatchingService) OfferedFeatures(ctx context.Context, driverID int64) (domain.RideFeatures, error) {
+	vehicle, err := s.accountsRepo.GetVehicleByAccountID(ctx, driverID)
+	if err != nil {
+		return nil, err
+	}
+	preferences, err := s.accountsRepo.GetDriverPreferences(ctx, driverID)
+	if err != nil {
+		return nil, err
+	}
+	return domain.OfferedFeatures(vehicle, preferences), nil
+}
+
+// VehicleSeats returns how many passengers the driver's vehicle carries
+func (s *MatchingService) VehicleSeats(ctx context.Context, driverID int64) (int, error) {
+	vehicle, err := s.accountsRepo.GetVehicleByAccountID(ctx, driverID)
+	if err != nil {
+		return 0, err
+	}
+	if vehicle == nil {
+		return domain.DefaultVehicleSeats, nil
+	}
+	return vehicle.Seats, nil
+}
+
+// Match returns the best bundle for the driver and every feasible request ranked on its own.
+// Requests are added to the bundle greedily, taking whichever raises compensation per minute
+// of detour the most, until no addition helps, the seats are full or the detour budget is spent.
+func (s *MatchingService) Match(ctx context.Context, params domain.MatchParams) (*domain.MatchBundle, []*domain.MatchCandidate, error) {
+	routeOptions, err := s.accountsRepo.GetDriverRouteOptions(ctx, params.DriverID)
+	if err != nil {
+		return nil, nil, err
+	}
+	params.RouteOptions = routeOptions
+
+	directRoute, err := s.mapsRepo.GetDirectRouteWithOptions(ctx, params.Start, params.Dest, params.RouteOptions)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error calculating driver's route: %w", err)
+	}
+
+	candidates, err := s.rankCandidates(ctx, params, directRoute)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bundle := &domain.MatchBundle{Route: directRoute}
+	remaining := slices.Clone(candidates[:min(len(candidates), domain.MatchCandidateLimit)])
+	for bundle.Seats() < params.Seats && len(remaining) > 0 {
+		var best *domain.MatchBundle
+		bestIdx := -1
+		for i, candidate := range remaining {
+			trial, err := s.extendBundle(ctx, params, directRoute, bundle, candidate)
+			if err != nil {
+				return nil, nil, err
+			}
+			if trial == nil {
+				continue
+			}
+			if best == nil || trial.Score() > best.Score() {
+				best = trial
+				bestIdx = i
+			}
+		}
+		if best == nil || best.Score() <= bundle.Score() {
+			break
+		}
+
+		bundle = best
+		remaining = slices.Delete(remaining, bestIdx, bestIdx+1)
+	}
+
+	return bundle, candidates, nil
+}
+
+// rankCandidates returns the open requests the driver could take on their own, best first
+func (s *MatchingService) rankCandidates(ctx context.Context, params domain.MatchParams, directRoute *domain.RouteDBModel) ([]*domain.MatchCandidate, error) {
+	requests, err := s.ridesRepo.GetActiveRideRequests(ctx, nil, nil, nil, &params.DepartAt)
+	if err != nil {
+		return nil, err
+	}
+	offered, err := s.OfferedFeatures(ctx, params.DriverID)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]*domain.MatchCandidate, 0)
+	for _, request := range requests {
+		if request.PassengerID == params.DriverID {
+			continue
+		}
+		if !request.RequirementsMet(offered) || request.PartySize > params.Seats {
+			continue
+		}
+
+		pickup := domain.Coordinates{Lat: request.PickupLatitude, Lon: request.PickupLongitude}
+		if domain.MinDetourDuration(params.Start, pickup, params.Dest) > params.MaxDetour {
+			continue
+		}
+
+		// Requests another driver has already drafted are spoken for
+		rides, err := s.ridesRepo.GetRideByRequestID(ctx, request.ID)
+		if err != nil {
+			return nil, err
+		}
+		held := false
+		for _, ride := range rides {
+			if ride.Status != domain.RideStatusRejected {
+				held = true
+				break
+			}
+		}
+		if held {
+			continue
+		}
+
+		detourRoute, err := s.mapsRepo.GetMultistopRouteWithOptions(ctx, params.Start, []domain.Coordinates{pickup}, params.Dest, params.RouteOptions)
+		if err != nil {
+			return nil, err
+		}
+		candidate := &domain.MatchCandidate{
+			Request:        request,
+			DetourDuration: max(detourRoute.Duration-directRoute.Duration, 0),
+			DetourDistance: max(detourRoute.Distance-directRoute.Distance, 0),
+		}
+		if candidate.DetourDuration > int64(params.MaxDetour.Seconds()) {
+			continue
+		}
+		if !request.ArrivesInWindow(params.DepartAt, detourRoute.Duration) {
+			continue
+		}
+
+		candidates = append(candidates, candidate)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score() > candidates[j].Score()
+	})
+
+	return candidates, nil
+}
+
+// extendBundle routes the bundle with candidate added, returning nil if that overfills the
+// seats, breaks the detour budget or makes anyone miss their arrival window
+func (s *MatchingService) extendBundle(ctx context.Context, params domain.MatchParams, directRoute *domain.RouteDBModel, bundle *domain.MatchBundle, candidate *domain.MatchCandidate) (*domain.MatchBundle, error) {
+	if bundle.Seats()+candidate.Request.PartySize > params.Seats {
+		return nil, nil
+	}
+
+	requests := append(append([]*domain.MatchCandidate{}, bundle.Requests...), candidate)
+	pickups := make([]domain.Coordinates, len(requests))
+	compensation := domain.Money(0)
+	for i, c := range requests {
+		pickups[i] = domain.Coordinates{Lat: c.Request.PickupLatitude, Lon: c.Request.PickupLongitude}
+		compensation += domain.MoneyFromFloat(c.Request.Compensation)
+	}
+
+	route, err := s.mapsRepo.GetRouteFromWaypointsWithOptions(ctx, params.Start, pickups, params.Dest, params.RouteOptions)
+	if err != nil {
+		return nil, err
+	}
+	detourDuration := max(route.Duration-directRoute.Duration, 0)
+	if detourDuration > int64(params.MaxDetour.Seconds()) {
+		return nil, nil
+	}
+	for _, c := range requests {
+		if !c.Request.ArrivesInWindow(params.DepartAt, route.Duration) {
+			return nil, nil
+		}
+	}
+
+	return &domain.MatchBundle{
+		Requests:       requests,
+		Route:          route,
+		Compensation:   compensation,
+		DetourDuration: detourDuration,
+		DetourDistance: max(route.Distance-directRoute.Distance, 0),
+	}, nil
+}
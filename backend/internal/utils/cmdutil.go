@@ -48,3 +48,12 @@ func NewDatabasePool(ctx context.Context, maxConns int) (*pgxpool.Pool, error) {
 	config.MaxConnIdleTime = 30 * time.Second
 	return pgxpool.NewWithConfig(ctx, config)
 }
+
+// DurationFromEnv parses a duration such as "15m" from the environment, falling back when unset or invalid
+func DurationFromEnv(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 func StringFromQueryParam(r *http.Request, key string, optional bool) (*string, error) {
@@ -45,3 +46,31 @@ func IntFromQueryParam(r *http.Request, key string, optional bool) (*int64, erro
 
 	return &val, nil
 }
+
+func TimeFromQueryParam(r *http.Request, key string, optional bool) (*time.Time, error) {
+	valStr, err := StringFromQueryParam(r, key, optional)
+	if err != nil || valStr == nil {
+		return nil, err
+	}
+
+	val, err := time.Parse(time.RFC3339, *valStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s parameter: %v", key, err)
+	}
+
+	return &val, nil
+}
+
+func BoolFromQueryParam(r *http.Request, key string, optional bool) (*bool, error) {
+	valStr, err := StringFromQueryParam(r, key, optional)
+	if err != nil || valStr == nil {
+		return nil, err
+	}
+
+	val, err := strconv.ParseBool(*valStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s parameter: %v", key, err)
+	}
+
+	return &val, nil
+}
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_requests_status;
+ALTER TABLE requests ADD COLUMN visited BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE requests SET visited = TRUE WHERE status = 'picked_up';
+ALTER TABLE requests DROP COLUMN status;
@@ -0,0 +1,15 @@
+-- Table Definition ----------------------------------------------
+
+ALTER TABLE requests
+    ADD COLUMN status VARCHAR(50) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'matched', 'picked_up'));
+
+UPDATE requests SET status = 'picked_up' WHERE visited = TRUE;
+UPDATE requests SET status = 'matched' WHERE visited = FALSE AND ride_id IS NOT NULL;
+
+ALTER TABLE requests DROP COLUMN visited;
+
+-- Indices -------------------------------------------------------
+
+CREATE INDEX idx_requests_status ON requests(status);
+
+-- Triggers ------------------------------------------------------
@@ -0,0 +1,14 @@
+ALTER TABLE requests DROP COLUMN cancelled_at, DROP COLUMN cancellation_reason, DROP COLUMN cancelled_by;
+ALTER TABLE requests DROP CONSTRAINT requests_status_check;
+UPDATE requests SET status = 'open' WHERE status = 'cancelled';
+ALTER TABLE requests ADD CONSTRAINT requests_status_check CHECK (status IN ('open', 'matched', 'picked_up'));
+
+ALTER TABLE proposals DROP COLUMN cancelled_at, DROP COLUMN cancellation_reason, DROP COLUMN cancelled_by;
+ALTER TABLE proposals DROP CONSTRAINT proposals_status_check;
+UPDATE proposals SET status = 'rejected' WHERE status = 'cancelled';
+ALTER TABLE proposals ADD CONSTRAINT proposals_status_check CHECK (status IN ('pending', 'accepted', 'rejected'));
+
+ALTER TABLE rides DROP COLUMN cancelled_at, DROP COLUMN cancellation_reason, DROP COLUMN cancelled_by;
+ALTER TABLE rides DROP CONSTRAINT rides_status_check;
+UPDATE rides SET status = 'rejected' WHERE status = 'cancelled';
+ALTER TABLE rides ADD CONSTRAINT rides_status_check CHECK (status IN ('awaiting_confirmation', 'in_progress', 'completed', 'rejected'));
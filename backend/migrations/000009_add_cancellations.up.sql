@@ -0,0 +1,26 @@
+-- Table Definition ----------------------------------------------
+
+ALTER TABLE rides DROP CONSTRAINT rides_status_check;
+ALTER TABLE rides
+    ADD CONSTRAINT rides_status_check CHECK (status IN ('awaiting_confirmation', 'in_progress', 'completed', 'rejected', 'cancelled')),
+    ADD COLUMN cancelled_by BIGINT REFERENCES accounts(id) ON DELETE SET NULL,
+    ADD COLUMN cancellation_reason VARCHAR(250),
+    ADD COLUMN cancelled_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE proposals DROP CONSTRAINT proposals_status_check;
+ALTER TABLE proposals
+    ADD CONSTRAINT proposals_status_check CHECK (status IN ('pending', 'accepted', 'rejected', 'cancelled')),
+    ADD COLUMN cancelled_by BIGINT REFERENCES accounts(id) ON DELETE SET NULL,
+    ADD COLUMN cancellation_reason VARCHAR(250),
+    ADD COLUMN cancelled_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE requests DROP CONSTRAINT requests_status_check;
+ALTER TABLE requests
+    ADD CONSTRAINT requests_status_check CHECK (status IN ('open', 'matched', 'picked_up', 'cancelled')),
+    ADD COLUMN cancelled_by BIGINT REFERENCES accounts(id) ON DELETE SET NULL,
+    ADD COLUMN cancellation_reason VARCHAR(250),
+    ADD COLUMN cancelled_at TIMESTAMP WITH TIME ZONE;
+
+-- Indices -------------------------------------------------------
+
+-- Triggers ------------------------------------------------------
@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_requests_status_created_at;
+DROP INDEX IF EXISTS idx_proposals_status_created_at;
+
+ALTER TABLE requests DROP COLUMN expired_at;
+ALTER TABLE requests DROP CONSTRAINT requests_status_check;
+UPDATE requests SET status = 'cancelled' WHERE status = 'expired';
+ALTER TABLE requests ADD CONSTRAINT requests_status_check CHECK (status IN ('open', 'matched', 'picked_up', 'cancelled'));
+
+ALTER TABLE proposals DROP COLUMN expired_at;
+ALTER TABLE proposals DROP CONSTRAINT proposals_status_check;
+UPDATE proposals SET status = 'rejected' WHERE status = 'expired';
+ALTER TABLE proposals ADD CONSTRAINT proposals_status_check CHECK (status IN ('pending', 'accepted', 'rejected', 'cancelled'));
@@ -0,0 +1,18 @@
+-- Table Definition ----------------------------------------------
+
+ALTER TABLE proposals DROP CONSTRAINT proposals_status_check;
+ALTER TABLE proposals
+    ADD CONSTRAINT proposals_status_check CHECK (status IN ('pending', 'accepted', 'rejected', 'cancelled', 'expired')),
+    ADD COLUMN expired_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE requests DROP CONSTRAINT requests_status_check;
+ALTER TABLE requests
+    ADD CONSTRAINT requests_status_check CHECK (status IN ('open', 'matched', 'picked_up', 'cancelled', 'expired')),
+    ADD COLUMN expired_at TIMESTAMP WITH TIME ZONE;
+
+-- Indices -------------------------------------------------------
+
+CREATE INDEX idx_proposals_status_created_at ON proposals(status, created_at);
+CREATE INDEX idx_requests_status_created_at ON requests(status, created_at);
+
+-- Triggers ------------------------------------------------------
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_requests_window_start;
+
+ALTER TABLE requests
+    DROP CONSTRAINT requests_window_check,
+    DROP COLUMN pickup_reminder_sent,
+    DROP COLUMN window_end,
+    DROP COLUMN window_start,
+    DROP COLUMN window_kind;
@@ -0,0 +1,17 @@
+-- Table Definition ----------------------------------------------
+
+ALTER TABLE requests
+    ADD COLUMN window_kind VARCHAR(20) CHECK (window_kind IN ('departure', 'arrival')),
+    ADD COLUMN window_start TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN window_end TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN pickup_reminder_sent BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD CONSTRAINT requests_window_check CHECK (
+        (window_kind IS NULL AND window_start IS NULL AND window_end IS NULL)
+        OR (window_kind IS NOT NULL AND window_start < window_end)
+    );
+
+-- Indices -------------------------------------------------------
+
+CREATE INDEX idx_requests_window_start ON requests(window_start) WHERE window_start IS NOT NULL;
+
+-- Triggers ------------------------------------------------------
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_requests_commute_window;
+ALTER TABLE requests DROP COLUMN commute_id;
+
+DROP TABLE IF EXISTS commute_skips;
+DROP TABLE IF EXISTS commutes;
+
+ALTER TABLE account_addresses DROP COLUMN longitude, DROP COLUMN latitude;
@@ -0,0 +1,49 @@
+-- Table Definition ----------------------------------------------
+
+ALTER TABLE account_addresses
+    ADD COLUMN latitude DECIMAL(9,6) CHECK (latitude BETWEEN -90 AND 90),
+    ADD COLUMN longitude DECIMAL(9,6) CHECK (longitude BETWEEN -180 AND 180);
+
+CREATE TABLE commutes (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    pickup_address_id BIGINT NOT NULL REFERENCES account_addresses(id) ON DELETE CASCADE,
+    dropoff_location VARCHAR(100) NOT NULL,
+    dropoff_latitude DECIMAL(9,6) NOT NULL CHECK (dropoff_latitude BETWEEN -90 AND 90),
+    dropoff_longitude DECIMAL(9,6) NOT NULL CHECK (dropoff_longitude BETWEEN -180 AND 180),
+    compensation DECIMAL(10, 2) NOT NULL,
+    weekdays SMALLINT[] NOT NULL CHECK (cardinality(weekdays) > 0 AND weekdays <@ ARRAY[0, 1, 2, 3, 4, 5, 6]::SMALLINT[]),
+    start_time TIME NOT NULL,
+    window_kind VARCHAR(20) NOT NULL CHECK (window_kind IN ('departure', 'arrival')),
+    window_minutes SMALLINT NOT NULL CHECK (window_minutes > 0),
+    timezone VARCHAR(50) NOT NULL DEFAULT 'Australia/Melbourne',
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'paused', 'ended')),
+    starts_on DATE NOT NULL DEFAULT CURRENT_DATE,
+    ends_on DATE CHECK (ends_on IS NULL OR ends_on >= starts_on),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE TABLE commute_skips (
+    commute_id BIGINT NOT NULL REFERENCES commutes(id) ON DELETE CASCADE,
+    skip_date DATE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (commute_id, skip_date)
+);
+
+ALTER TABLE requests
+    ADD COLUMN commute_id BIGINT REFERENCES commutes(id) ON DELETE SET NULL;
+
+-- Indices -------------------------------------------------------
+
+CREATE INDEX idx_commutes_account_id ON commutes(account_id);
+CREATE INDEX idx_commutes_status ON commutes(status);
+-- Lets the worker materialise each occurrence at most once
+CREATE UNIQUE INDEX idx_requests_commute_window ON requests(commute_id, window_start);
+
+-- Triggers ------------------------------------------------------
+
+CREATE TRIGGER on_commutes_update_set_updated_columns
+BEFORE UPDATE ON commutes
+FOR EACH ROW
+EXECUTE PROCEDURE set_updated_columns();
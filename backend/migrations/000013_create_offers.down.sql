@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS offer_bookings;
+DROP TABLE IF EXISTS offers;
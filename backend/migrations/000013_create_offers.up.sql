@@ -0,0 +1,61 @@
+-- Table Definition ----------------------------------------------
+
+CREATE TABLE offers (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    driver_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    origin_location VARCHAR(100) NOT NULL,
+    origin_latitude DECIMAL(9,6) NOT NULL CHECK (origin_latitude BETWEEN -90 AND 90),
+    origin_longitude DECIMAL(9,6) NOT NULL CHECK (origin_longitude BETWEEN -180 AND 180),
+    destination_location VARCHAR(100) NOT NULL,
+    destination_latitude DECIMAL(9,6) NOT NULL CHECK (destination_latitude BETWEEN -90 AND 90),
+    destination_longitude DECIMAL(9,6) NOT NULL CHECK (destination_longitude BETWEEN -180 AND 180),
+    route_polyline TEXT NOT NULL,
+    route_distance DOUBLE PRECISION NOT NULL,
+    route_duration INTEGER NOT NULL,
+    departure_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    seats SMALLINT NOT NULL CHECK (seats > 0),
+    price_per_seat DECIMAL(10, 2) NOT NULL CHECK (price_per_seat >= 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'cancelled')),
+    ride_id BIGINT REFERENCES rides(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE TABLE offer_bookings (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    offer_id BIGINT NOT NULL REFERENCES offers(id) ON DELETE CASCADE,
+    passenger_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    pickup_location VARCHAR(100),
+    pickup_latitude DECIMAL(9,6) NOT NULL CHECK (pickup_latitude BETWEEN -90 AND 90),
+    pickup_longitude DECIMAL(9,6) NOT NULL CHECK (pickup_longitude BETWEEN -180 AND 180),
+    dropoff_location VARCHAR(100) NOT NULL,
+    dropoff_latitude DECIMAL(9,6) NOT NULL CHECK (dropoff_latitude BETWEEN -90 AND 90),
+    dropoff_longitude DECIMAL(9,6) NOT NULL CHECK (dropoff_longitude BETWEEN -180 AND 180),
+    seats SMALLINT NOT NULL DEFAULT 1 CHECK (seats > 0),
+    detour_duration INTEGER NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'declined', 'cancelled')),
+    request_id BIGINT REFERENCES requests(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Indices -------------------------------------------------------
+
+CREATE INDEX idx_offers_driver_id ON offers(driver_id);
+CREATE INDEX idx_offers_status_departure_time ON offers(status, departure_time);
+CREATE INDEX idx_offer_bookings_offer_id ON offer_bookings(offer_id);
+CREATE INDEX idx_offer_bookings_passenger_id ON offer_bookings(passenger_id);
+-- A passenger can only have one live booking per offer
+CREATE UNIQUE INDEX idx_offer_bookings_live ON offer_bookings(offer_id, passenger_id) WHERE status IN ('pending', 'approved');
+
+-- Triggers ------------------------------------------------------
+
+CREATE TRIGGER on_offers_update_set_updated_columns
+BEFORE UPDATE ON offers
+FOR EACH ROW
+EXECUTE PROCEDURE set_updated_columns();
+
+CREATE TRIGGER on_offer_bookings_update_set_updated_columns
+BEFORE UPDATE ON offer_bookings
+FOR EACH ROW
+EXECUTE PROCEDURE set_updated_columns();
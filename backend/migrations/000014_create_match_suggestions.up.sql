@@ -0,0 +1,15 @@
+-- Table Definition ----------------------------------------------
+
+CREATE TABLE match_suggestions (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    offer_id BIGINT NOT NULL REFERENCES offers(id) ON DELETE CASCADE,
+    request_ids BIGINT[] NOT NULL,
+    compensation DECIMAL(10, 2) NOT NULL,
+    detour_duration INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Indices -------------------------------------------------------
+
+-- The worker only pushes a bundle to the driver the first time it is suggested for an offer
+CREATE UNIQUE INDEX idx_match_suggestions_offer_requests ON match_suggestions(offer_id, request_ids);
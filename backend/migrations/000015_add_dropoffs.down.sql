@@ -0,0 +1,4 @@
+ALTER TABLE requests DROP COLUMN dropped_off_at, DROP COLUMN picked_up_at;
+ALTER TABLE requests DROP CONSTRAINT requests_status_check;
+UPDATE requests SET status = 'picked_up' WHERE status = 'dropped_off';
+ALTER TABLE requests ADD CONSTRAINT requests_status_check CHECK (status IN ('open', 'matched', 'picked_up', 'cancelled', 'expired'));
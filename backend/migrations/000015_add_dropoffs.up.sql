@@ -0,0 +1,21 @@
+-- Table Definition ----------------------------------------------
+
+ALTER TABLE requests DROP CONSTRAINT requests_status_check;
+ALTER TABLE requests
+    ADD CONSTRAINT requests_status_check CHECK (status IN ('open', 'matched', 'picked_up', 'dropped_off', 'cancelled', 'expired')),
+    ADD COLUMN picked_up_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN dropped_off_at TIMESTAMP WITH TIME ZONE;
+
+-- Passengers on rides that already finished were dropped off when the ride completed
+UPDATE requests req
+SET status = 'dropped_off', dropped_off_at = r.updated_at
+FROM proposals p
+JOIN rides r ON r.id = p.ride_id
+WHERE p.request_id = req.id
+    AND p.status = 'accepted'
+    AND r.status = 'completed'
+    AND req.status = 'picked_up';
+
+-- Indices -------------------------------------------------------
+
+-- Triggers ------------------------------------------------------
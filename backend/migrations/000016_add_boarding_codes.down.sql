@@ -0,0 +1,4 @@
+ALTER TABLE proposals
+    DROP COLUMN boarded_at,
+    DROP COLUMN boarding_attempts,
+    DROP COLUMN boarding_code;
@@ -0,0 +1,18 @@
+-- Table Definition ----------------------------------------------
+
+ALTER TABLE proposals
+    ADD COLUMN boarding_code VARCHAR(6),
+    ADD COLUMN boarding_attempts SMALLINT NOT NULL DEFAULT 0,
+    ADD COLUMN boarded_at TIMESTAMP WITH TIME ZONE;
+
+-- Passengers already waiting for pickup need a code to board
+UPDATE proposals p
+SET boarding_code = lpad(floor(random() * 10000)::int::text, 4, '0')
+FROM requests req
+WHERE req.id = p.request_id
+    AND p.status = 'accepted'
+    AND req.status = 'matched';
+
+-- Indices -------------------------------------------------------
+
+-- Triggers ------------------------------------------------------
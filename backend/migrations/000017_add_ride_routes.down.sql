@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_requests_passenger_id;
+DROP INDEX IF EXISTS idx_proposals_driver_id_ride_id;
+ALTER TABLE rides DROP COLUMN route_duration, DROP COLUMN route_distance;
@@ -0,0 +1,14 @@
+-- Table Definition ----------------------------------------------
+
+-- The route the driver was given when the ride was confirmed. Rides confirmed before this
+-- was recorded have neither.
+ALTER TABLE rides
+    ADD COLUMN route_distance DOUBLE PRECISION,
+    ADD COLUMN route_duration BIGINT;
+
+-- Indices -------------------------------------------------------
+
+CREATE INDEX idx_proposals_driver_id_ride_id ON proposals(driver_id, ride_id);
+CREATE INDEX idx_requests_passenger_id ON requests(passenger_id);
+
+-- Triggers ------------------------------------------------------
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS counter_offers;
+ALTER TABLE proposals DROP COLUMN compensation;
@@ -0,0 +1,24 @@
+-- Table Definition ----------------------------------------------
+
+-- The price the driver and passenger agreed on, if they negotiated. Without one the proposal
+-- settles on the request's compensation.
+ALTER TABLE proposals ADD COLUMN compensation DECIMAL(10, 2);
+
+CREATE TABLE counter_offers (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    proposal_id BIGINT NOT NULL REFERENCES proposals(id) ON DELETE CASCADE,
+    offered_by VARCHAR(20) NOT NULL CHECK (offered_by IN ('driver', 'passenger')),
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'rejected', 'countered')),
+    answered_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Indices -------------------------------------------------------
+
+CREATE INDEX idx_counter_offers_proposal_id ON counter_offers(proposal_id);
+
+-- At most one counter-offer per proposal is waiting for an answer
+CREATE UNIQUE INDEX idx_counter_offers_one_pending ON counter_offers(proposal_id) WHERE status = 'pending';
+
+-- Triggers ------------------------------------------------------
@@ -0,0 +1,4 @@
+ALTER TABLE proposals
+    DROP COLUMN cost_share_shared_distance,
+    DROP COLUMN cost_share_distance,
+    DROP COLUMN cost_share;
@@ -0,0 +1,12 @@
+-- Table Definition ----------------------------------------------
+
+-- Each passenger's fair part of what the driver was paid, worked out when the ride completes
+-- from how far they rode and who with
+ALTER TABLE proposals
+    ADD COLUMN cost_share DECIMAL(10, 2),
+    ADD COLUMN cost_share_distance DOUBLE PRECISION,
+    ADD COLUMN cost_share_shared_distance DOUBLE PRECISION;
+
+-- Indices -------------------------------------------------------
+
+-- Triggers ------------------------------------------------------
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS ledger_postings;
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS settlements;
+DROP TABLE IF EXISTS ledger_accounts;
+DROP FUNCTION IF EXISTS check_ledger_entry_balanced();
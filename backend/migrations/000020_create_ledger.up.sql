@@ -0,0 +1,87 @@
+-- Table Definition ----------------------------------------------
+
+-- One per user, created with their first posting. Amounts are in cents.
+CREATE TABLE ledger_accounts (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    account_id BIGINT NOT NULL UNIQUE REFERENCES accounts(id) ON DELETE RESTRICT,
+    balance_cents BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE TABLE settlements (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    payer_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE RESTRICT,
+    payee_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE RESTRICT,
+    ride_id BIGINT REFERENCES rides(id) ON DELETE SET NULL,
+    request_id BIGINT REFERENCES requests(id) ON DELETE SET NULL,
+    amount_cents BIGINT NOT NULL CHECK (amount_cents >= 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'paid', 'cancelled')),
+    paid_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CHECK (payer_id != payee_id)
+);
+
+CREATE TABLE ledger_entries (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    kind VARCHAR(30) NOT NULL CHECK (kind IN ('ride_compensation', 'settlement', 'refund', 'adjustment')),
+    ride_id BIGINT REFERENCES rides(id) ON DELETE SET NULL,
+    request_id BIGINT REFERENCES requests(id) ON DELETE SET NULL,
+    settlement_id BIGINT REFERENCES settlements(id) ON DELETE SET NULL,
+    refund_of BIGINT REFERENCES ledger_entries(id) ON DELETE RESTRICT,
+    description VARCHAR(200) NOT NULL,
+    created_by BIGINT REFERENCES accounts(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Postings are never updated or deleted; corrections are new entries
+CREATE TABLE ledger_postings (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    entry_id BIGINT NOT NULL REFERENCES ledger_entries(id) ON DELETE RESTRICT,
+    ledger_account_id BIGINT NOT NULL REFERENCES ledger_accounts(id) ON DELETE RESTRICT,
+    amount_cents BIGINT NOT NULL CHECK (amount_cents != 0),
+    balance_after_cents BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Indices -------------------------------------------------------
+
+CREATE INDEX idx_ledger_postings_ledger_account_id ON ledger_postings(ledger_account_id, id);
+CREATE INDEX idx_ledger_postings_entry_id ON ledger_postings(entry_id);
+CREATE INDEX idx_settlements_payer_id ON settlements(payer_id);
+CREATE INDEX idx_settlements_payee_id ON settlements(payee_id);
+
+-- A passenger is only charged once for a ride
+CREATE UNIQUE INDEX idx_ledger_entries_one_charge ON ledger_entries(request_id) WHERE kind = 'ride_compensation';
+
+-- Functions -----------------------------------------------------
+
+CREATE OR REPLACE FUNCTION check_ledger_entry_balanced()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF (SELECT SUM(amount_cents) FROM ledger_postings WHERE entry_id = NEW.entry_id) != 0 THEN
+        RAISE EXCEPTION 'ledger entry % does not balance', NEW.entry_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Triggers ------------------------------------------------------
+
+-- Checked at commit, once every posting of the entry is in
+CREATE CONSTRAINT TRIGGER on_ledger_postings_insert_check_balanced
+AFTER INSERT ON ledger_postings
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW
+EXECUTE PROCEDURE check_ledger_entry_balanced();
+
+CREATE TRIGGER on_ledger_accounts_update_set_updated_columns
+BEFORE UPDATE ON ledger_accounts
+FOR EACH ROW
+EXECUTE PROCEDURE set_updated_columns();
+
+CREATE TRIGGER on_settlements_update_set_updated_columns
+BEFORE UPDATE ON settlements
+FOR EACH ROW
+EXECUTE PROCEDURE set_updated_columns();
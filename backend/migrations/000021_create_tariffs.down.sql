@@ -0,0 +1,3 @@
+ALTER TABLE rides DROP COLUMN tariff_id;
+DROP TABLE IF EXISTS fare_estimates;
+DROP TABLE IF EXISTS tariffs;
@@ -0,0 +1,44 @@
+-- Table Definition ----------------------------------------------
+
+-- Tariffs are never changed once created; a new version takes over from its effective_from.
+-- Amounts are in cents. Multipliers are a JSON array of {start_time, end_time, multiplier, label}
+-- with local "15:04" times in timezone.
+CREATE TABLE tariffs (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    version INT NOT NULL UNIQUE,
+    base_fare_cents BIGINT NOT NULL CHECK (base_fare_cents >= 0),
+    per_km_cents BIGINT NOT NULL CHECK (per_km_cents >= 0),
+    per_minute_cents BIGINT NOT NULL CHECK (per_minute_cents >= 0),
+    minimum_fare_cents BIGINT NOT NULL CHECK (minimum_fare_cents >= 0),
+    multipliers JSONB NOT NULL DEFAULT '[]',
+    timezone VARCHAR(50) NOT NULL,
+    effective_from TIMESTAMP WITH TIME ZONE NOT NULL,
+    notes VARCHAR(200),
+    created_by BIGINT REFERENCES accounts(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Every compensation estimate handed out, with the tariff that priced it
+CREATE TABLE fare_estimates (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    tariff_id BIGINT NOT NULL REFERENCES tariffs(id) ON DELETE RESTRICT,
+    account_id BIGINT REFERENCES accounts(id) ON DELETE SET NULL,
+    distance DOUBLE PRECISION NOT NULL,
+    duration BIGINT NOT NULL,
+    total_cents BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- The tariff in force when the ride was created, recorded once it completes
+ALTER TABLE rides ADD COLUMN tariff_id BIGINT REFERENCES tariffs(id) ON DELETE RESTRICT;
+
+-- What the constants this replaces charged, in force from the start
+INSERT INTO tariffs (version, base_fare_cents, per_km_cents, per_minute_cents, minimum_fare_cents, timezone, effective_from, notes)
+VALUES (1, 200, 25, 0, 0, 'Australia/Melbourne', '2000-01-01 00:00:00+00', 'Initial tariff');
+
+-- Indices -------------------------------------------------------
+
+CREATE INDEX idx_tariffs_effective_from ON tariffs(effective_from);
+CREATE INDEX idx_fare_estimates_tariff_id ON fare_estimates(tariff_id);
+
+-- Triggers ------------------------------------------------------
@@ -0,0 +1,2 @@
+ALTER TABLE fare_estimates DROP COLUMN toll_cents;
+DROP TABLE IF EXISTS toll_rates;
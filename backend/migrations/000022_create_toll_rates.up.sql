@@ -0,0 +1,32 @@
+-- Table Definition ----------------------------------------------
+
+-- What a car pays to use each toll road, in cents. toll_road matches ways.toll_road, set by
+-- osm/postprocess_ways.sql; 'Other' prices tolled ways that are not on a named road.
+-- A trip is charged per_km_cents for each km on the road, within minimum_cents and maximum_cents.
+CREATE TABLE toll_rates (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    toll_road VARCHAR(50) NOT NULL UNIQUE,
+    per_km_cents BIGINT NOT NULL CHECK (per_km_cents >= 0),
+    minimum_cents BIGINT NOT NULL DEFAULT 0 CHECK (minimum_cents >= 0),
+    maximum_cents BIGINT CHECK (maximum_cents >= minimum_cents),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Tolls included in an estimate's total
+ALTER TABLE fare_estimates ADD COLUMN toll_cents BIGINT NOT NULL DEFAULT 0;
+
+-- Approximate car rates
+INSERT INTO toll_rates (toll_road, per_km_cents, minimum_cents, maximum_cents)
+VALUES
+    ('CityLink', 50, 200, 1100),
+    ('EastLink', 35, 150, 900),
+    ('Other', 40, 150, NULL);
+
+-- Indices -------------------------------------------------------
+
+-- Triggers ------------------------------------------------------
+
+CREATE TRIGGER on_toll_rates_update_set_updated_columns
+BEFORE UPDATE ON toll_rates
+FOR EACH ROW
+EXECUTE PROCEDURE set_updated_columns();
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS fuel_prices;
+
+ALTER TABLE vehicles DROP COLUMN fuel_consumption, DROP COLUMN fuel_type;
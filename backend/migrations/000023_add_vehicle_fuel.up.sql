@@ -0,0 +1,32 @@
+-- Table Definition ----------------------------------------------
+
+-- Consumption is litres per 100 km, or kWh per 100 km for electric vehicles. Vehicles added
+-- before this have neither and are costed as an average petrol car.
+ALTER TABLE vehicles
+    ADD COLUMN fuel_type VARCHAR(20) CHECK (fuel_type IN ('petrol', 'premium_petrol', 'diesel', 'lpg', 'electric')),
+    ADD COLUMN fuel_consumption DOUBLE PRECISION CHECK (fuel_consumption > 0);
+
+-- What a litre, or a kWh for electric, costs. Admins keep these current.
+CREATE TABLE fuel_prices (
+    fuel_type VARCHAR(20) PRIMARY KEY,
+    price_cents BIGINT NOT NULL CHECK (price_cents >= 0),
+    updated_by BIGINT REFERENCES accounts(id) ON DELETE SET NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+INSERT INTO fuel_prices (fuel_type, price_cents)
+VALUES
+    ('petrol', 195),
+    ('premium_petrol', 215),
+    ('diesel', 200),
+    ('lpg', 110),
+    ('electric', 35);
+
+-- Indices -------------------------------------------------------
+
+-- Triggers ------------------------------------------------------
+
+CREATE TRIGGER on_fuel_prices_update_set_updated_columns
+BEFORE UPDATE ON fuel_prices
+FOR EACH ROW
+EXECUTE PROCEDURE set_updated_columns();
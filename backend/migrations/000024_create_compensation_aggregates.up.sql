@@ -0,0 +1,29 @@
+-- Table Definition ----------------------------------------------
+
+-- Rebuilt periodically by the worker from recent requests, so compensation suggestions are a
+-- lookup. Pickups and dropoffs are grouped into square cells, floor(degrees / cell size), with
+-- the cell size set by domain.CompensationCellDegrees.
+CREATE TABLE compensation_aggregates (
+    origin_cell_lat INT NOT NULL,
+    origin_cell_lon INT NOT NULL,
+    destination_cell_lat INT NOT NULL,
+    destination_cell_lon INT NOT NULL,
+    -- Completed rides and the compensation agreed for them
+    rides INT NOT NULL,
+    compensation_p25 DOUBLE PRECISION,
+    compensation_p50 DOUBLE PRECISION,
+    compensation_p75 DOUBLE PRECISION,
+    -- Median compensation of completed rides a driver proposed on quickly
+    quick_compensation_p50 DOUBLE PRECISION,
+    -- Requests that were settled, by being accepted or expiring, and how many were accepted
+    requests INT NOT NULL,
+    accepted INT NOT NULL,
+    -- Median time from an unscheduled request to the proposal that was accepted
+    accept_seconds_p50 DOUBLE PRECISION,
+    refreshed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (origin_cell_lat, origin_cell_lon, destination_cell_lat, destination_cell_lon)
+);
+
+-- Indices -------------------------------------------------------
+
+-- Triggers ------------------------------------------------------
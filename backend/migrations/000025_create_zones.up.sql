@@ -0,0 +1,39 @@
+-- Table Definition ----------------------------------------------
+
+-- Campuses, and the service area requests and offers must fall inside. polygon is a JSON ring
+-- of [longitude, latitude] points, as in GeoJSON; it need not repeat the first point.
+CREATE TABLE zones (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    code VARCHAR(10) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('campus', 'service_area')),
+    polygon JSONB NOT NULL CHECK (jsonb_array_length(polygon) >= 3),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Campus grounds, drawn generously so car parks and drop-off points on the edges count
+INSERT INTO zones (code, name, kind, polygon)
+VALUES
+    ('cl', 'Clayton', 'campus',
+        '[[145.1255, -37.9030], [145.1440, -37.9030], [145.1440, -37.9200], [145.1255, -37.9200]]'),
+    ('ca', 'Caulfield', 'campus',
+        '[[145.0410, -37.8740], [145.0490, -37.8740], [145.0490, -37.8800], [145.0410, -37.8800]]'),
+    ('pe', 'Peninsula', 'campus',
+        '[[145.1290, -38.1480], [145.1410, -38.1480], [145.1410, -38.1590], [145.1290, -38.1590]]'),
+    ('pa', 'Parkville', 'campus',
+        '[[144.9560, -37.7815], [144.9625, -37.7815], [144.9625, -37.7865], [144.9560, -37.7865]]'),
+    -- Greater Melbourne, out to the Mornington Peninsula, Geelong's edge and the Dandenongs
+    ('melb', 'Greater Melbourne', 'service_area',
+        '[[144.40, -37.45], [145.00, -37.35], [145.60, -37.55], [145.70, -38.05], [145.35, -38.55], [144.75, -38.50], [144.40, -38.10]]');
+
+-- Indices -------------------------------------------------------
+
+CREATE INDEX idx_zones_kind ON zones(kind);
+
+-- Triggers ------------------------------------------------------
+
+CREATE TRIGGER on_zones_update_set_updated_columns
+BEFORE UPDATE ON zones
+FOR EACH ROW
+EXECUTE PROCEDURE set_updated_columns();
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS driver_preferences;
+
+ALTER TABLE vehicles DROP COLUMN features;
+
+ALTER TABLE requests
+    DROP COLUMN preferences,
+    DROP COLUMN requirements;
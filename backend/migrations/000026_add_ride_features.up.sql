@@ -0,0 +1,27 @@
+-- Table Definition ----------------------------------------------
+
+-- Ride features are things like luggage space or a quiet ride. Passengers list the ones they
+-- need (requirements) and the ones they would like (preferences) on each request; vehicles and
+-- drivers list the ones they offer.
+ALTER TABLE requests
+    ADD COLUMN requirements TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN preferences TEXT[] NOT NULL DEFAULT '{}';
+
+ALTER TABLE vehicles
+    ADD COLUMN features TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE TABLE driver_preferences (
+    account_id BIGINT PRIMARY KEY REFERENCES accounts(id) ON DELETE CASCADE,
+    features TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Indices -------------------------------------------------------
+
+-- Triggers ------------------------------------------------------
+
+CREATE TRIGGER on_driver_preferences_update_set_updated_columns
+BEFORE UPDATE ON driver_preferences
+FOR EACH ROW
+EXECUTE PROCEDURE set_updated_columns();
@@ -0,0 +1,5 @@
+ALTER TABLE rides DROP COLUMN seats;
+
+ALTER TABLE vehicles DROP COLUMN seats;
+
+ALTER TABLE requests DROP COLUMN party_size;
@@ -0,0 +1,17 @@
+-- Table Definition ----------------------------------------------
+
+-- A request can be for several people travelling together
+ALTER TABLE requests
+    ADD COLUMN party_size INT NOT NULL DEFAULT 1 CHECK (party_size BETWEEN 1 AND 8);
+
+-- Passenger seats, not counting the driver's
+ALTER TABLE vehicles
+    ADD COLUMN seats INT NOT NULL DEFAULT 4 CHECK (seats BETWEEN 1 AND 8);
+
+-- The seats the ride was drafted with. NULL for rides from before seats were tracked.
+ALTER TABLE rides
+    ADD COLUMN seats INT CHECK (seats BETWEEN 1 AND 8);
+
+-- Indices -------------------------------------------------------
+
+-- Triggers ------------------------------------------------------
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_licenses_account_id;
+
+ALTER TABLE accounts DROP COLUMN date_of_birth;
+
+ALTER TABLE licenses
+    DROP CONSTRAINT licenses_expiry_check,
+    DROP COLUMN conditions,
+    DROP COLUMN license_type;
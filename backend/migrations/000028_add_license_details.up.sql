@@ -0,0 +1,20 @@
+-- Table Definition ----------------------------------------------
+
+-- Licences entered before the type was recorded are taken to be full licences
+ALTER TABLE licenses
+    ADD COLUMN license_type VARCHAR(10) NOT NULL DEFAULT 'full' CHECK (license_type IN ('l', 'p1', 'p2', 'full')),
+    ADD COLUMN conditions TEXT[] NOT NULL DEFAULT '{}',
+    ADD CONSTRAINT licenses_expiry_check CHECK (expiry > date_of_issue);
+
+ALTER TABLE licenses ALTER COLUMN license_type DROP DEFAULT;
+
+-- P1 drivers may only carry one passenger of peer age, so passengers' ages matter
+ALTER TABLE accounts
+    ADD COLUMN date_of_birth DATE;
+
+-- Indices -------------------------------------------------------
+
+-- A driver holds one licence
+CREATE UNIQUE INDEX idx_licenses_account_id ON licenses(account_id);
+
+-- Triggers ------------------------------------------------------
@@ -0,0 +1,28 @@
+-- Table Definition ----------------------------------------------
+
+-- A driver applies once they have added a licence and a vehicle. Only approved drivers can take
+-- passengers; an admin approves or suspends them, and can reinstate a suspended driver.
+CREATE TABLE driver_applications (
+    account_id BIGINT PRIMARY KEY REFERENCES accounts(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'suspended')),
+    reason TEXT,
+    reviewed_by BIGINT REFERENCES accounts(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Drivers who were already driving keep doing so
+INSERT INTO driver_applications (account_id, status)
+SELECT id, 'approved' FROM accounts WHERE type = 'driver';
+
+-- Indices -------------------------------------------------------
+
+CREATE INDEX idx_driver_applications_status ON driver_applications(status);
+
+-- Triggers ------------------------------------------------------
+
+CREATE TRIGGER on_driver_applications_update_set_updated_columns
+BEFORE UPDATE ON driver_applications
+FOR EACH ROW
+EXECUTE PROCEDURE set_updated_columns();
@@ -0,0 +1 @@
+ALTER TABLE driver_preferences DROP COLUMN avoid_tolls;
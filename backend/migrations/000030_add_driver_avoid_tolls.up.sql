@@ -0,0 +1,6 @@
+-- Table Definition ----------------------------------------------
+
+-- Drivers who avoid tolls have every route worked out for them, from the feed and matching to
+-- proposals and cost shares, kept off toll roads where there is a sensible way around
+ALTER TABLE driver_preferences
+    ADD COLUMN avoid_tolls BOOLEAN NOT NULL DEFAULT FALSE;
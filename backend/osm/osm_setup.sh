@@ -44,6 +44,7 @@ if [ "$IMPORT_MODE" = "raw" ]; then
               --port 5432 \
               --password postgres \
               --addnodes \
+              --tags \
               --clean
 
   echo "pgRouting topology creation complete."
@@ -34,4 +34,29 @@ WHERE cost IS NULL;
 
 UPDATE ways
 SET reverse_cost = 1000000000
-WHERE reverse_cost IS NULL;
\ No newline at end of file
+WHERE reverse_cost IS NULL;
+
+-- Tolls: name the toll road each tolled way belongs to, matching toll_rates.toll_road.
+-- osm_ways (and its tags) only exists when osm2pgrouting was run with --tags.
+ALTER TABLE ways ADD COLUMN IF NOT EXISTS toll_road VARCHAR(50);
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'osm_ways') THEN
+        UPDATE ways w
+        SET toll_road = CASE
+                WHEN t.names ILIKE '%citylink%' THEN 'CityLink'
+                WHEN t.names ILIKE '%eastlink%' OR t.names ILIKE '%connecteast%' THEN 'EastLink'
+                ELSE 'Other'
+            END
+        FROM (
+            SELECT osm_id, concat_ws(' ', tags -> 'name', tags -> 'operator', tags -> 'toll:name') AS names
+            FROM osm_ways
+            WHERE tags -> 'toll' = 'yes'
+        ) t
+        WHERE t.osm_id = w.osm_id;
+    END IF;
+END
+$$;
+
+CREATE INDEX IF NOT EXISTS idx_ways_toll_road ON ways(toll_road) WHERE toll_road IS NOT NULL;
\ No newline at end of file
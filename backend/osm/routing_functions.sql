@@ -1,8 +1,12 @@
+-- The signature changed when avoid_tolls was added, so drop the old one rather than overload it
+DROP FUNCTION IF EXISTS get_route_between(double precision, double precision, double precision, double precision);
+
 CREATE OR REPLACE FUNCTION get_route_between(
     start_lon double precision,
     start_lat double precision,
     end_lon double precision,
-    end_lat double precision
+    end_lat double precision,
+    avoid_tolls boolean DEFAULT false
 )
 RETURNS TABLE(
     seq bigint,        
@@ -12,7 +16,8 @@ RETURNS TABLE(
     cost_s double precision,
     agg_cost double precision,
     agg_cost_s double precision,
-    geom text
+    geom text,
+    toll_road text
 ) AS $$
 DECLARE
     start_edge record;
@@ -26,9 +31,12 @@ DECLARE
     bbox_geom geometry;
     buffer_m double precision;
     pgr_sql text;
+    -- Tolled ways cost this many times their length when avoiding tolls, so they are only
+    -- taken when there is no sensible way around
+    toll_penalty double precision := CASE WHEN avoid_tolls THEN 20.0 ELSE 1.0 END;
 BEGIN
     -- find nearest edge to start
-    SELECT w.gid, w.source, w.target, w.the_geom, w.cost AS w_cost, w.cost_s AS w_cost_s
+    SELECT w.gid, w.source, w.target, w.the_geom, w.cost AS w_cost, w.cost_s AS w_cost_s, w.toll_road AS w_toll_road
     INTO start_edge
     FROM ways w
     ORDER BY w.the_geom <-> start_point
@@ -48,7 +56,7 @@ BEGIN
     END INTO start_node;
 
     -- find nearest edge to end
-    SELECT w.gid, w.source, w.target, w.the_geom, w.cost AS w_cost, w.cost_s AS w_cost_s
+    SELECT w.gid, w.source, w.target, w.the_geom, w.cost AS w_cost, w.cost_s AS w_cost_s, w.toll_road AS w_toll_road
     INTO end_edge
     FROM ways w
     ORDER BY w.the_geom <-> end_point
@@ -80,9 +88,13 @@ BEGIN
 
     -- Build the SQL string for pgr_astar that filters ways by this bbox (inject bbox EWKT safely)
     pgr_sql := format(
-        'SELECT gid AS id, source, target, cost, reverse_cost, x1, y1, x2, y2
+        'SELECT gid AS id, source, target,
+                CASE WHEN toll_road IS NULL THEN cost ELSE cost * %s END AS cost,
+                CASE WHEN toll_road IS NULL THEN reverse_cost ELSE reverse_cost * %s END AS reverse_cost,
+                x1, y1, x2, y2
          FROM ways
          WHERE ST_Intersects(the_geom, ST_GeomFromEWKT(%L))',
+        toll_penalty, toll_penalty,
         ST_AsEWKT(bbox_geom)
     );
 
@@ -103,7 +115,8 @@ BEGIN
             start_edge.gid AS sh_edge,
             ST_Distance(start_point, start_proj) AS sh_cost,
             (ST_Distance(start_point, start_proj) / NULLIF(start_edge.w_cost, 0)) * start_edge.w_cost_s AS sh_cost_s,
-            ST_MakeLine(start_point, start_proj) AS sh_geom
+            ST_MakeLine(start_point, start_proj) AS sh_geom,
+            NULL::text AS sh_toll_road
         UNION ALL
         SELECT
             2 AS sh_seq,
@@ -135,7 +148,8 @@ BEGIN
                     ST_LineLocatePoint(start_edge.the_geom, v.the_geom)),
                 GREATEST(ST_LineLocatePoint(start_edge.the_geom, start_proj),
                         ST_LineLocatePoint(start_edge.the_geom, v.the_geom))
-            ) AS sh_geom
+            ) AS sh_geom,
+            start_edge.w_toll_road::text AS sh_toll_road
         FROM ways_vertices_pgr v
         WHERE v.id = start_node
     ),
@@ -148,7 +162,8 @@ BEGIN
             r.edge AS mr_edge,
             w.cost AS mr_cost,
             w.cost_s AS mr_cost_s,
-            w.the_geom AS mr_geom
+            w.the_geom AS mr_geom,
+            w.toll_road::text AS mr_toll_road
         FROM route r
         JOIN ways w ON r.edge = w.gid
     ),
@@ -185,7 +200,8 @@ BEGIN
                     ST_LineLocatePoint(end_edge.the_geom, end_proj)),
                 GREATEST(ST_LineLocatePoint(end_edge.the_geom, v.the_geom),
                         ST_LineLocatePoint(end_edge.the_geom, end_proj))
-            ) AS eh_geom
+            ) AS eh_geom,
+            end_edge.w_toll_road::text AS eh_toll_road
         FROM ways_vertices_pgr v, route_count rc
         WHERE v.id = end_node
 
@@ -197,17 +213,18 @@ BEGIN
             end_edge.gid AS eh_edge,
             ST_Distance(end_proj, end_point) AS eh_cost,
             (ST_Distance(end_proj, end_point) / NULLIF(end_edge.w_cost, 0)) * end_edge.w_cost_s AS eh_cost_s,
-            ST_MakeLine(end_proj, end_point) AS eh_geom
+            ST_MakeLine(end_proj, end_point) AS eh_geom,
+            NULL::text AS eh_toll_road
         FROM route_count rc
     ),
 
     -- Output assembly
     all_segments AS (
-        SELECT sh_seq AS seq, sh_node AS node, sh_edge AS edge, sh_cost AS cost, sh_cost_s AS cost_s, sh_geom AS geom FROM start_hops
+        SELECT sh_seq AS seq, sh_node AS node, sh_edge AS edge, sh_cost AS cost, sh_cost_s AS cost_s, sh_geom AS geom, sh_toll_road AS toll_road FROM start_hops
         UNION ALL
-        SELECT mr_seq AS seq, mr_node AS node, mr_edge AS edge, mr_cost AS cost, mr_cost_s AS cost_s, mr_geom AS geom FROM main_route
+        SELECT mr_seq AS seq, mr_node AS node, mr_edge AS edge, mr_cost AS cost, mr_cost_s AS cost_s, mr_geom AS geom, mr_toll_road AS toll_road FROM main_route
         UNION ALL
-        SELECT eh_seq AS seq, eh_node AS node, eh_edge AS edge, eh_cost AS cost, eh_cost_s AS cost_s, eh_geom AS geom FROM end_hops
+        SELECT eh_seq AS seq, eh_node AS node, eh_edge AS edge, eh_cost AS cost, eh_cost_s AS cost_s, eh_geom AS geom, eh_toll_road AS toll_road FROM end_hops
     )
 
     SELECT
@@ -218,7 +235,8 @@ BEGIN
         all_segments.cost_s,
         SUM(all_segments.cost) OVER (ORDER BY all_segments.seq) AS agg_cost,
         SUM(all_segments.cost_s) OVER (ORDER BY all_segments.seq) AS agg_cost_s,
-        ST_AsGeoJSON(all_segments.geom)::text AS geom
+        ST_AsGeoJSON(all_segments.geom)::text AS geom,
+        all_segments.toll_road
     FROM all_segments
     ORDER BY all_segments.seq;
 END;